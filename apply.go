@@ -0,0 +1,52 @@
+// Copyright 2022 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package kes
+
+import "fmt"
+
+// AdminOpType is the type of an AdminOp.
+type AdminOpType string
+
+// All valid AdminOp types.
+const (
+	// OpSetPolicy creates or overwrites the policy
+	// AdminOp.Name with AdminOp.Policy.
+	OpSetPolicy AdminOpType = "policy/write"
+
+	// OpDeletePolicy deletes the policy AdminOp.Name.
+	OpDeletePolicy AdminOpType = "policy/delete"
+
+	// OpAssignPolicy assigns the policy AdminOp.Name
+	// to the identity AdminOp.Identity.
+	OpAssignPolicy AdminOpType = "policy/assign"
+
+	// OpDeleteIdentity deletes the identity
+	// AdminOp.Identity.
+	OpDeleteIdentity AdminOpType = "identity/delete"
+)
+
+// AdminOp is a policy or identity operation. Multiple
+// AdminOps can be applied all-or-nothing via Apply.
+type AdminOp struct {
+	Type     AdminOpType `json:"op"`                 // The operation type
+	Name     string      `json:"name,omitempty"`     // The policy name
+	Policy   *Policy     `json:"policy,omitempty"`   // The policy. Only used by OpSetPolicy
+	Identity Identity    `json:"identity,omitempty"` // The identity. Only used by OpAssignPolicy and OpDeleteIdentity
+}
+
+// ApplyError is returned by Apply when an AdminOp
+// fails. None of the AdminOps has been applied.
+type ApplyError struct {
+	Index int     // The index of the AdminOp that failed
+	Op    AdminOp // The AdminOp that failed
+	Err   error   // The error of the AdminOp
+}
+
+func (e *ApplyError) Error() string {
+	return fmt.Sprintf("kes: failed to apply %s operation %d: %v", e.Op.Type, e.Index, e.Err)
+}
+
+// Unwrap returns the error of the AdminOp that failed.
+func (e *ApplyError) Unwrap() error { return e.Err }
@@ -0,0 +1,80 @@
+// Copyright 2022 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package kes
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"time"
+)
+
+// ErrInvalidAttestation is returned by VerifyAttestation when
+// an attestation has not been signed by the given public key.
+var ErrInvalidAttestation = errors.New("kes: invalid attestation")
+
+// Attestation is a KES server signature proving that
+// a data encryption key (DEK) has been generated by a
+// particular KES server at a particular point in time.
+//
+// An Attestation can be verified with the server's
+// public key via VerifyAttestation.
+type Attestation struct {
+	Time      time.Time // Point in time when the DEK has been generated
+	Signature []byte    // Server signature over the attestation digest
+}
+
+// Digest returns the SHA-256 digest that the KES server
+// signs to attest that it has generated the ciphertext
+// with the named key at the attestation time.
+func (a *Attestation) Digest(name string, ciphertext []byte) []byte {
+	const Domain = "kes:attestation:v1"
+
+	var timestamp [8]byte
+	binary.BigEndian.PutUint64(timestamp[:], uint64(a.Time.UnixNano()))
+
+	h := sha256.New()
+	h.Write([]byte(Domain))
+	h.Write([]byte{0})
+	h.Write([]byte(name))
+	h.Write([]byte{0})
+	h.Write(timestamp[:])
+	h.Write(ciphertext)
+	return h.Sum(nil)
+}
+
+// VerifyAttestation verifies that the attestation has been
+// signed with the private key of the given public key for the
+// ciphertext generated with the named key.
+//
+// The public key is usually the public key of the KES server
+// TLS certificate. It must be an ECDSA, Ed25519 or RSA public
+// key.
+//
+// It returns ErrInvalidAttestation if the signature is not
+// valid.
+func VerifyAttestation(publicKey crypto.PublicKey, name string, ciphertext []byte, attestation Attestation) error {
+	digest := attestation.Digest(name, ciphertext)
+
+	var valid bool
+	switch key := publicKey.(type) {
+	case *ecdsa.PublicKey:
+		valid = ecdsa.VerifyASN1(key, digest, attestation.Signature)
+	case ed25519.PublicKey:
+		valid = ed25519.Verify(key, digest, attestation.Signature)
+	case *rsa.PublicKey:
+		valid = rsa.VerifyPKCS1v15(key, crypto.SHA256, digest, attestation.Signature) == nil
+	default:
+		return errors.New("kes: unsupported attestation public key")
+	}
+	if !valid {
+		return ErrInvalidAttestation
+	}
+	return nil
+}
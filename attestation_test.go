@@ -0,0 +1,64 @@
+// Copyright 2022 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package kes
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"testing"
+	"time"
+)
+
+func TestVerifyAttestation(t *testing.T) {
+	_, ed25519Key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate Ed25519 key: %v", err)
+	}
+	ecdsaKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate ECDSA key: %v", err)
+	}
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("Failed to generate RSA key: %v", err)
+	}
+
+	for i, test := range verifyAttestationTests {
+		for _, signer := range []crypto.Signer{ed25519Key, ecdsaKey, rsaKey} {
+			var opts crypto.SignerOpts = crypto.SHA256
+			if _, ok := signer.(ed25519.PrivateKey); ok {
+				opts = crypto.Hash(0)
+			}
+
+			attestation := Attestation{Time: test.Time}
+			attestation.Signature, err = signer.Sign(rand.Reader, attestation.Digest(test.Name, test.Ciphertext), opts)
+			if err != nil {
+				t.Fatalf("Test %d: failed to sign attestation: %v", i, err)
+			}
+			if err = VerifyAttestation(signer.Public(), test.Name, test.Ciphertext, attestation); err != nil {
+				t.Fatalf("Test %d: failed to verify attestation: %v", i, err)
+			}
+
+			attestation.Time = attestation.Time.Add(time.Nanosecond)
+			if err = VerifyAttestation(signer.Public(), test.Name, test.Ciphertext, attestation); err != ErrInvalidAttestation {
+				t.Fatalf("Test %d: verified modified attestation: got '%v' - want '%v'", i, err, ErrInvalidAttestation)
+			}
+		}
+	}
+}
+
+var verifyAttestationTests = []struct {
+	Name       string
+	Ciphertext []byte
+	Time       time.Time
+}{
+	{Name: "my-key", Ciphertext: nil, Time: time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)},                       // 0
+	{Name: "my-key", Ciphertext: []byte("ciphertext"), Time: time.Date(2022, 1, 1, 0, 0, 0, 1, time.UTC)},      // 1
+	{Name: "my-key-2", Ciphertext: make([]byte, 1024), Time: time.Date(2022, 6, 15, 12, 30, 0, 500, time.UTC)}, // 2
+}
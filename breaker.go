@@ -0,0 +1,129 @@
+// Copyright 2022 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package kes
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// ErrCircuitOpen is returned by a Client when it does not
+// send a request to a KES server endpoint since too many
+// requests to this endpoint failed recently.
+//
+// The client only uses a circuit breaker if it has been
+// enabled - see TransportConfig.CircuitBreakerThreshold.
+var ErrCircuitOpen = errors.New("kes: circuit open: server endpoint is unavailable")
+
+// circuitBreaker tracks consecutive failures per server
+// endpoint. Once an endpoint has failed threshold times
+// in a row, the circuit opens and requests fail fast with
+// ErrCircuitOpen until the cooldown has passed. Then, a
+// single probe request is sent. The circuit closes again
+// if the probe succeeds and opens for another cooldown
+// period otherwise.
+type circuitBreaker struct {
+	threshold int
+	cooldown  time.Duration
+
+	lock      sync.Mutex
+	endpoints map[string]*circuitState
+}
+
+type circuitState struct {
+	failures  int       // Number of consecutive failures
+	openUntil time.Time // Point in time when the circuit allows a probe request
+	probing   bool      // Indicates whether a probe request is in progress
+}
+
+// Allow returns ErrCircuitOpen if no request should be
+// sent to the given endpoint. Otherwise, it returns nil
+// and the caller must report the request outcome via
+// Done.
+func (b *circuitBreaker) Allow(endpoint string) error {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	state, ok := b.endpoints[endpoint]
+	if !ok || state.failures < b.threshold {
+		return nil
+	}
+	if state.probing || time.Now().Before(state.openUntil) {
+		return ErrCircuitOpen
+	}
+	state.probing = true
+	return nil
+}
+
+// Done reports whether a request to the given endpoint
+// has failed.
+func (b *circuitBreaker) Done(endpoint string, failed bool) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	if !failed {
+		delete(b.endpoints, endpoint)
+		return
+	}
+	if b.endpoints == nil {
+		b.endpoints = map[string]*circuitState{}
+	}
+	state, ok := b.endpoints[endpoint]
+	if !ok {
+		state = &circuitState{}
+		b.endpoints[endpoint] = state
+	}
+	state.failures++
+	state.probing = false
+	if state.failures >= b.threshold {
+		state.openUntil = time.Now().Add(b.cooldown)
+	}
+}
+
+// Release reports that a request to the given endpoint
+// has been canceled. It does not change the number of
+// consecutive failures but allows another probe request.
+func (b *circuitBreaker) Release(endpoint string) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	if state, ok := b.endpoints[endpoint]; ok {
+		state.probing = false
+	}
+}
+
+// breakerTransport is an http.RoundTripper that
+// fails fast with ErrCircuitOpen when the circuit
+// breaker for the request endpoint is open.
+type breakerTransport struct {
+	transport http.RoundTripper
+	breaker   *circuitBreaker
+}
+
+func (t breakerTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	endpoint := req.URL.Host
+	if err := t.breaker.Allow(endpoint); err != nil {
+		return nil, err
+	}
+
+	resp, err := t.transport.RoundTrip(req)
+	switch {
+	case errors.Is(err, context.Canceled):
+		// The request has been canceled by the client.
+		// We don't know whether the endpoint is available.
+		// However, we have to release a probe, if any.
+		t.breaker.Release(endpoint)
+	case err != nil:
+		t.breaker.Done(endpoint, true)
+	case resp.StatusCode == http.StatusBadGateway || resp.StatusCode == http.StatusServiceUnavailable || resp.StatusCode == http.StatusGatewayTimeout:
+		t.breaker.Done(endpoint, true)
+	default:
+		t.breaker.Done(endpoint, false)
+	}
+	return resp, err
+}
@@ -0,0 +1,114 @@
+// Copyright 2022 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package kes
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestCircuitBreaker(t *testing.T) {
+	const Endpoint = "127.0.0.1:7373"
+	breaker := &circuitBreaker{
+		threshold: 2,
+		cooldown:  50 * time.Millisecond,
+	}
+
+	var status = http.StatusServiceUnavailable
+	transport := breakerTransport{
+		breaker: breaker,
+		transport: roundTripperFunc(func(*http.Request) (*http.Response, error) {
+			return &http.Response{StatusCode: status, Body: http.NoBody}, nil
+		}),
+	}
+	req, err := http.NewRequest(http.MethodGet, "https://"+Endpoint+"/version", nil)
+	if err != nil {
+		t.Fatalf("Failed to create request: %v", err)
+	}
+
+	for i := 0; i < breaker.threshold; i++ {
+		if _, err = transport.RoundTrip(req); err != nil {
+			t.Fatalf("Request %d: circuit should be closed: %v", i, err)
+		}
+	}
+	if _, err = transport.RoundTrip(req); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("Circuit should be open: got '%v' - want '%v'", err, ErrCircuitOpen)
+	}
+
+	// Once the cooldown has passed, a single probe
+	// is allowed. Since it fails, the circuit opens
+	// again.
+	time.Sleep(breaker.cooldown)
+	if _, err = transport.RoundTrip(req); err != nil {
+		t.Fatalf("Circuit should allow a probe: %v", err)
+	}
+	if _, err = transport.RoundTrip(req); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("Circuit should be open after failed probe: got '%v' - want '%v'", err, ErrCircuitOpen)
+	}
+
+	// A successful probe closes the circuit again.
+	status = http.StatusOK
+	time.Sleep(breaker.cooldown)
+	for i := 0; i < 2*breaker.threshold; i++ {
+		if _, err = transport.RoundTrip(req); err != nil {
+			t.Fatalf("Request %d: circuit should be closed: %v", i, err)
+		}
+	}
+}
+
+func TestCircuitBreakerProbe(t *testing.T) {
+	const Endpoint = "127.0.0.1:7373"
+	breaker := &circuitBreaker{
+		threshold: 1,
+		cooldown:  0,
+	}
+	breaker.Done(Endpoint, true)
+
+	if err := breaker.Allow(Endpoint); err != nil {
+		t.Fatalf("Circuit should allow a probe: %v", err)
+	}
+	if err := breaker.Allow(Endpoint); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("Circuit should only allow a single probe: got '%v' - want '%v'", err, ErrCircuitOpen)
+	}
+
+	breaker.Release(Endpoint) // The probe has been canceled
+	if err := breaker.Allow(Endpoint); err != nil {
+		t.Fatalf("Circuit should allow another probe: %v", err)
+	}
+	breaker.Done(Endpoint, false)
+	if len(breaker.endpoints) != 0 {
+		t.Fatal("Circuit should be closed after a successful probe")
+	}
+}
+
+func TestCircuitBreakerCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	breaker := &circuitBreaker{threshold: 1, cooldown: time.Minute}
+	transport := breakerTransport{
+		breaker: breaker,
+		transport: roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			return nil, req.Context().Err()
+		}),
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, "https://127.0.0.1:7373/version", nil)
+	if err != nil {
+		t.Fatalf("Failed to create request: %v", err)
+	}
+	if _, err = transport.RoundTrip(req); !errors.Is(err, context.Canceled) {
+		t.Fatalf("Invalid error: got '%v' - want '%v'", err, context.Canceled)
+	}
+	if err = breaker.Allow(req.URL.Host); err != nil {
+		t.Fatalf("Canceled requests should not open the circuit: %v", err)
+	}
+}
+
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) { return f(req) }
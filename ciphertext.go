@@ -0,0 +1,185 @@
+// Copyright 2022 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package kes
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strconv"
+
+	"github.com/tinylib/msgp/msgp"
+)
+
+// ErrInvalidCiphertext is returned by ParseCiphertext when
+// the given bytes are not a ciphertext produced by a KES
+// server.
+var ErrInvalidCiphertext = errors.New("kes: invalid ciphertext")
+
+// Ciphertext describes a ciphertext produced by a KES server,
+// e.g. by Encrypt or GenerateKey. It exposes the metadata a
+// KES server uses to decrypt the ciphertext.
+//
+// A Ciphertext can be used to diagnose decryption failures
+// without a server round-trip - e.g. to check whether a
+// ciphertext has been produced with a particular key.
+type Ciphertext struct {
+	algorithm string
+	keyID     string
+	iv        []byte
+	nonce     []byte
+	bytes     []byte
+}
+
+// ParseCiphertext parses b as ciphertext produced by a KES
+// server. It supports the current binary format as well as
+// the legacy JSON format.
+//
+// ParseCiphertext does not decrypt or authenticate the
+// ciphertext. It returns ErrInvalidCiphertext if b is not
+// well-formed.
+func ParseCiphertext(b []byte) (Ciphertext, error) {
+	if len(b) == 0 {
+		return Ciphertext{}, ErrInvalidCiphertext
+	}
+
+	var (
+		c   Ciphertext
+		err error
+	)
+	switch b[0] {
+	case 0x95: // msgp first byte
+		c, err = parseBinaryCiphertext(b)
+	case 0x7b: // JSON first byte
+		c, err = parseJSONCiphertext(b)
+	default:
+		if c, err = parseBinaryCiphertext(b); err != nil {
+			c, err = parseJSONCiphertext(b)
+		}
+	}
+	if err != nil {
+		return Ciphertext{}, ErrInvalidCiphertext
+	}
+	return c, nil
+}
+
+// Algorithm returns the AEAD algorithm the ciphertext has
+// been encrypted with - e.g. "AES256-GCM_SHA256".
+func (c Ciphertext) Algorithm() string { return c.algorithm }
+
+// KeyID returns the ID of the key the ciphertext has been
+// encrypted with. Two ciphertexts with different key IDs
+// have been produced by different keys.
+//
+// Ciphertexts produced by older KES servers may not contain
+// a key ID. Then, KeyID returns an empty string.
+func (c Ciphertext) KeyID() string { return c.keyID }
+
+// IV returns a copy of the ciphertext's initialization vector.
+// A KES server derives the encryption key from the IV.
+func (c Ciphertext) IV() []byte { return append([]byte(nil), c.iv...) }
+
+// Nonce returns a copy of the ciphertext's AEAD nonce.
+func (c Ciphertext) Nonce() []byte { return append([]byte(nil), c.nonce...) }
+
+// Bytes returns a copy of the encrypted bytes including
+// the AEAD authentication tag.
+func (c Ciphertext) Bytes() []byte { return append([]byte(nil), c.bytes...) }
+
+// String returns a string representation of the ciphertext
+// metadata. It contains the size but not the value of the
+// encrypted bytes.
+func (c Ciphertext) String() string {
+	return "Ciphertext{Algorithm: " + c.algorithm +
+		", KeyID: " + c.keyID +
+		", IV: " + base64.StdEncoding.EncodeToString(c.iv) +
+		", Nonce: " + base64.StdEncoding.EncodeToString(c.nonce) +
+		", Size: " + strconv.Itoa(len(c.bytes)) + "}"
+}
+
+// parseBinaryCiphertext parses b as msgp-encoded
+// ciphertext.
+func parseBinaryCiphertext(b []byte) (Ciphertext, error) {
+	const (
+		Items     = 5
+		IVSize    = 16
+		NonceSize = 12
+	)
+
+	items, b, err := msgp.ReadArrayHeaderBytes(b)
+	if err != nil {
+		return Ciphertext{}, err
+	}
+	if items != Items {
+		return Ciphertext{}, ErrInvalidCiphertext
+	}
+	algorithm, b, err := msgp.ReadStringBytes(b)
+	if err != nil {
+		return Ciphertext{}, err
+	}
+	id, b, err := msgp.ReadStringBytes(b)
+	if err != nil {
+		return Ciphertext{}, err
+	}
+	iv := make([]byte, IVSize)
+	if b, err = msgp.ReadExactBytes(b, iv); err != nil {
+		return Ciphertext{}, err
+	}
+	nonce := make([]byte, NonceSize)
+	if b, err = msgp.ReadExactBytes(b, nonce); err != nil {
+		return Ciphertext{}, err
+	}
+	bytes, b, err := msgp.ReadBytesZC(b)
+	if err != nil {
+		return Ciphertext{}, err
+	}
+	if len(b) != 0 {
+		return Ciphertext{}, ErrInvalidCiphertext
+	}
+	return Ciphertext{
+		algorithm: algorithm,
+		keyID:     id,
+		iv:        iv,
+		nonce:     nonce,
+		bytes:     append([]byte(nil), bytes...),
+	}, nil
+}
+
+// parseJSONCiphertext parses b as JSON-encoded
+// ciphertext. In the past, KES servers produced
+// JSON-encoded ciphertexts.
+func parseJSONCiphertext(b []byte) (Ciphertext, error) {
+	const (
+		IVSize    = 16
+		NonceSize = 12
+
+		AES256GCM        = "AES-256-GCM-HMAC-SHA-256"
+		CHACHA20POLY1305 = "ChaCha20Poly1305"
+	)
+	type JSON struct {
+		Algorithm string `json:"aead"`
+		ID        string `json:"id,omitempty"`
+		IV        []byte `json:"iv"`
+		Nonce     []byte `json:"nonce"`
+		Bytes     []byte `json:"bytes"`
+	}
+	var value JSON
+	if err := json.Unmarshal(b, &value); err != nil {
+		return Ciphertext{}, err
+	}
+	if value.Algorithm != AES256GCM && value.Algorithm != CHACHA20POLY1305 {
+		return Ciphertext{}, ErrInvalidCiphertext
+	}
+	if len(value.IV) != IVSize || len(value.Nonce) != NonceSize {
+		return Ciphertext{}, ErrInvalidCiphertext
+	}
+	return Ciphertext{
+		algorithm: value.Algorithm,
+		keyID:     value.ID,
+		iv:        value.IV,
+		nonce:     value.Nonce,
+		bytes:     value.Bytes,
+	}, nil
+}
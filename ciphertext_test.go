@@ -0,0 +1,109 @@
+// Copyright 2022 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package kes
+
+import (
+	"bytes"
+	"encoding/base64"
+	"strings"
+	"testing"
+
+	"github.com/tinylib/msgp/msgp"
+)
+
+var parseCiphertextTests = []struct {
+	Ciphertext []byte
+	Algorithm  string
+	KeyID      string
+	ShouldFail bool
+}{
+	{ // 0
+		Ciphertext: []byte(`{"aead":"AES-256-GCM-HMAC-SHA-256","iv":"xLxIN3tSCkg2xMafuvwUwg==","nonce":"gu0mGwUkwcvMEoi5","bytes":"WVgRjeIJm3w50C/l+y7y2i6mbNg5NCAqN1zvOYWZKmc="}`),
+		Algorithm:  "AES-256-GCM-HMAC-SHA-256",
+	},
+	{ // 1
+		Ciphertext: []byte(`{"aead":"ChaCha20Poly1305","id":"66687aadf862bd776c8fc18b8e9f8e20","iv":"EC0eZp7Pqt+LnkOae5xaAg==","nonce":"X1ejXKmH/ugFZPkk","bytes":"wIGBTDs6aOvsqJfekZ0PYRT/OHyFX2TXqeNwl1SLXOI="}`),
+		Algorithm:  "ChaCha20Poly1305",
+		KeyID:      "66687aadf862bd776c8fc18b8e9f8e20",
+	},
+	{ // 2
+		Ciphertext: binaryCiphertext("AES256-GCM_SHA256", "66687aadf862bd776c8fc18b8e9f8e20", make([]byte, 16), make([]byte, 12), make([]byte, 32)),
+		Algorithm:  "AES256-GCM_SHA256",
+		KeyID:      "66687aadf862bd776c8fc18b8e9f8e20",
+	},
+
+	{Ciphertext: nil, ShouldFail: true},                          // 3
+	{Ciphertext: []byte(`{"aead":"AES-256"}`), ShouldFail: true}, // 4
+	{ // 5
+		Ciphertext: []byte(`{"aead":"ChaCha20Poly1305","iv":"EC0eZp7Pqt+LnkOae5xaAg==","nonce":"X1ejXKmH","bytes":"wIGBTDs6aOvsqJfekZ0PYRT/OHyFX2TXqeNwl1SLXOI="}`),
+		ShouldFail: true,
+	},
+	{ // 6
+		Ciphertext: binaryCiphertext("AES256-GCM_SHA256", "", make([]byte, 12), make([]byte, 12), make([]byte, 32)),
+		ShouldFail: true,
+	},
+	{ // 7
+		Ciphertext: append(binaryCiphertext("AES256-GCM_SHA256", "", make([]byte, 16), make([]byte, 12), make([]byte, 32)), 0),
+		ShouldFail: true,
+	},
+}
+
+func TestParseCiphertext(t *testing.T) {
+	for i, test := range parseCiphertextTests {
+		c, err := ParseCiphertext(test.Ciphertext)
+		if err == nil && test.ShouldFail {
+			t.Fatalf("Test %d: should have failed", i)
+		}
+		if err != nil && !test.ShouldFail {
+			t.Fatalf("Test %d: failed to parse ciphertext: %v", i, err)
+		}
+		if err != nil {
+			if err != ErrInvalidCiphertext {
+				t.Fatalf("Test %d: invalid error: got '%v' - want '%v'", i, err, ErrInvalidCiphertext)
+			}
+			continue
+		}
+
+		if c.Algorithm() != test.Algorithm {
+			t.Fatalf("Test %d: algorithm mismatch: got '%s' - want '%s'", i, c.Algorithm(), test.Algorithm)
+		}
+		if c.KeyID() != test.KeyID {
+			t.Fatalf("Test %d: key ID mismatch: got '%s' - want '%s'", i, c.KeyID(), test.KeyID)
+		}
+		if len(c.IV()) != 16 {
+			t.Fatalf("Test %d: invalid IV size: got '%d' - want '%d'", i, len(c.IV()), 16)
+		}
+		if len(c.Nonce()) != 12 {
+			t.Fatalf("Test %d: invalid nonce size: got '%d' - want '%d'", i, len(c.Nonce()), 12)
+		}
+		if s := c.String(); strings.Contains(s, base64.StdEncoding.EncodeToString(c.Bytes())) {
+			t.Fatalf("Test %d: string representation contains the encrypted bytes: %s", i, s)
+		}
+	}
+}
+
+func TestCiphertextReadOnly(t *testing.T) {
+	c, err := ParseCiphertext(parseCiphertextTests[2].Ciphertext)
+	if err != nil {
+		t.Fatalf("Failed to parse ciphertext: %v", err)
+	}
+	c.IV()[0] = 1
+	c.Nonce()[0] = 1
+	c.Bytes()[0] = 1
+	if !bytes.Equal(c.IV(), make([]byte, 16)) || !bytes.Equal(c.Nonce(), make([]byte, 12)) || !bytes.Equal(c.Bytes(), make([]byte, 32)) {
+		t.Fatal("Ciphertext has been modified through its accessors")
+	}
+}
+
+func binaryCiphertext(algorithm, id string, iv, nonce, bytes []byte) []byte {
+	var b []byte
+	b = msgp.AppendArrayHeader(b, 5)
+	b = msgp.AppendString(b, algorithm)
+	b = msgp.AppendString(b, id)
+	b = msgp.AppendBytes(b, iv)
+	b = msgp.AppendBytes(b, nonce)
+	b = msgp.AppendBytes(b, bytes)
+	return b
+}
@@ -5,16 +5,26 @@
 package kes
 
 import (
+	"compress/gzip"
 	"context"
+	"crypto/sha256"
 	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
 	"encoding/json"
+	"encoding/pem"
 	"errors"
+	"fmt"
 	"io"
 	"math"
 	"net"
 	"net/http"
+	"net/url"
+	"os"
 	"path"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	dto "github.com/prometheus/client_model/go"
@@ -69,8 +79,96 @@ type Client struct {
 	//
 	// It must not be modified concurrently.
 	HTTPClient http.Client
+
+	// Timeouts contains optional per-API request timeouts.
+	// It maps an API path, e.g. "/v1/key/decrypt", to the
+	// max. amount of time a request to this API may take.
+	// A request to an API that is not present in Timeouts
+	// is only limited by its context.
+	//
+	// The timeout applies to the entire call, including
+	// all retries and reading the response body. A request
+	// that times out is not retried. A context deadline
+	// that expires earlier still takes precedence.
+	//
+	// It must not be modified concurrently.
+	Timeouts map[string]time.Duration
+
+	// MaxRequestSize is the max. size of a GenerateKey, Encrypt
+	// or Decrypt request body. The client rejects larger requests
+	// with a RequestTooLargeError without sending any data to the
+	// KES server. If zero, it defaults to MaxContextSize, the
+	// limit enforced by the KES server.
+	//
+	// It must not be modified concurrently.
+	MaxRequestSize int64
+
+	closed uint32       // Set to 1 by Close
+	conns  int32        // Number of open connections. Only used by NewClientWithTransportConfig
+	cert   atomic.Value // *tls.Certificate set by SetCertificate
+
+	// dial dials new connections without counting them.
+	// Only used by NewClientWithTransportConfig
+	dial func(ctx context.Context, network, addr string) (net.Conn, error)
+
+	// SHA-256 fingerprint (hex string) of the server certificate
+	// of the most recent connection. Only used by NewClientWithTransportConfig
+	fingerprint *atomic.Value
+
+	// verify is the VerifyConnection callback of the client's
+	// TLS config without recording the server certificate
+	// fingerprint. Only used by NewClientWithTransportConfig
+	verify func(tls.ConnectionState) error
+
+	breaker  *circuitBreaker // Set if TransportConfig.CircuitBreakerThreshold > 0
+	readOnly bool            // Set by ReadOnly
+
+	hookLock   sync.RWMutex
+	onRequest  []func(*http.Request)  // Hooks registered via OnRequest
+	onResponse []func(*http.Response) // Hooks registered via OnResponse
+}
+
+// ErrClientClosed is returned by any Client method
+// that gets called after the Client has been closed.
+var ErrClientClosed = errors.New("kes: client closed")
+
+// MaxContextSize is the max. size of a GenerateKey, Encrypt
+// or Decrypt request accepted by a KES server. It limits the
+// combined size of the JSON-encoded context and plaintext
+// resp. ciphertext.
+const MaxContextSize = 1 << 20 // 1 MiB
+
+// ErrContextTooLarge is returned by GenerateKey, Encrypt and
+// Decrypt, without sending a request, when the request would
+// exceed the client's MaxRequestSize, which defaults to
+// MaxContextSize. The returned error is a RequestTooLargeError
+// that matches ErrContextTooLarge. The KES server may still
+// reject requests that are smaller than MaxContextSize.
+var ErrContextTooLarge = errors.New("kes: context too large")
+
+// RequestTooLargeError is returned by GenerateKey, Encrypt
+// and Decrypt, without sending a request, when the request
+// body would exceed the client's MaxRequestSize.
+//
+// A RequestTooLargeError matches ErrContextTooLarge such
+// that errors.Is(err, ErrContextTooLarge) reports true.
+type RequestTooLargeError struct {
+	Size  int64 // Size of the request body in bytes
+	Limit int64 // Max. size of a request body in bytes
 }
 
+func (e *RequestTooLargeError) Error() string {
+	return fmt.Sprintf("kes: request too large: %d bytes exceed the limit of %d bytes", e.Size, e.Limit)
+}
+
+// Is reports whether target is ErrContextTooLarge.
+func (e *RequestTooLargeError) Is(target error) bool { return target == ErrContextTooLarge }
+
+// errInvalidDEKLength is returned by GenerateKeyN, without
+// sending a request, when the DEK length is neither 128
+// nor 256 bits.
+var errInvalidDEKLength = errors.New("kes: invalid DEK length: must be 128 or 256 bits")
+
 // NewClient returns a new KES client with the given
 // KES server endpoint that uses the given TLS certificate
 // mTLS authentication.
@@ -93,26 +191,480 @@ func NewClient(endpoint string, cert tls.Certificate) *Client {
 // certificate that is valid for client authentication.
 //
 // NewClientWithConfig uses an http.Transport with reasonable
-// defaults.
+// defaults. The endpoint may be a unix domain socket. See
+// NewClientWithTransportConfig for more details.
 func NewClientWithConfig(endpoint string, config *tls.Config) *Client {
+	return NewClientWithTransportConfig(endpoint, config, nil)
+}
+
+// TransportConfig is a structure used to configure the
+// network transport of a KES client.
+//
+// Any field that is not set, i.e. is zero, is replaced
+// by a reasonable default value.
+type TransportConfig struct {
+	// DialTimeout is the max. amount of time a dial
+	// waits for a connect to complete.
+	// If zero, it defaults to 30 seconds.
+	DialTimeout time.Duration
+
+	// KeepAlive specifies the interval between keep-alive
+	// probes for an active network connection.
+	// If zero, it defaults to 30 seconds.
+	KeepAlive time.Duration
+
+	// TLSHandshakeTimeout is the max. amount of time
+	// waiting for a TLS handshake to complete.
+	// If zero, it defaults to 10 seconds.
+	TLSHandshakeTimeout time.Duration
+
+	// IdleConnTimeout is the max. amount of time an
+	// idle connection remains in the connection pool
+	// before closing itself.
+	// If zero, it defaults to 90 seconds.
+	IdleConnTimeout time.Duration
+
+	// MaxIdleConns is the max. number of idle connections
+	// across all KES server endpoints.
+	// If zero, it defaults to 100.
+	MaxIdleConns int
+
+	// MaxIdleConnsPerHost is the max. number of idle
+	// connections per KES server endpoint.
+	// If zero, it defaults to http.DefaultMaxIdleConnsPerHost.
+	MaxIdleConnsPerHost int
+
+	// VerifyOCSP enables verification of the OCSP response
+	// stapled by the KES server during the TLS handshake.
+	// The connection fails with a *RevocationError if the
+	// OCSP response reports the server certificate as
+	// revoked. Servers that don't staple an OCSP response
+	// are accepted.
+	// If false, it defaults to not verifying OCSP responses.
+	VerifyOCSP bool
+
+	// CircuitBreakerThreshold is the number of consecutive
+	// failed requests to a KES server endpoint after which
+	// the client stops sending requests to this endpoint for
+	// CircuitBreakerCooldown. Instead, requests fail fast with
+	// ErrCircuitOpen or are sent to another endpoint, if any.
+	//
+	// A request fails if the endpoint is not reachable or
+	// responds with 502, 503 or 504.
+	// If zero, it defaults to no circuit breaker.
+	CircuitBreakerThreshold int
+
+	// CircuitBreakerCooldown is the amount of time the client
+	// does not send requests to an endpoint once the circuit
+	// breaker has opened. Then, the client sends a single probe
+	// request. If it succeeds, the circuit breaker closes again.
+	// If zero, it defaults to 30 seconds.
+	CircuitBreakerCooldown time.Duration
+
+	// Protocol is the HTTP protocol version the client uses
+	// to talk to the KES server. HTTP/2 multiplexes multiple
+	// requests over a single connection while HTTP/1.1 needs
+	// one connection per concurrent request.
+	//
+	// If HTTP2 is set, connections to servers that do not
+	// negotiate HTTP/2 fail. If HTTP1 is set, the client
+	// never uses HTTP/2 - e.g. for intermediaries that don't
+	// handle HTTP/2 correctly.
+	// If empty, it defaults to HTTP/2 with a fallback to
+	// HTTP/1.1.
+	Protocol Protocol
+}
+
+// Protocol is an HTTP protocol version.
+type Protocol string
+
+// Supported HTTP protocol versions.
+const (
+	HTTP1 Protocol = "HTTP/1.1"
+	HTTP2 Protocol = "HTTP/2"
+)
+
+// NewClientWithTransportConfig returns a new KES client with
+// the given KES server endpoint that uses the given TLS config
+// for mTLS authentication and an http.Transport configured
+// according to the given TransportConfig.
+//
+// Therefore, the config.Certificates must contain a TLS
+// certificate that is valid for client authentication.
+// Unless config.GetClientCertificate is set, the client
+// certificate can be replaced later via SetCertificate.
+//
+// The endpoint may be a unix domain socket of the form
+// unix:///path/to/kes.sock. The client then sends its
+// requests over the socket instead of TCP. The connection
+// is still secured with TLS since the KES server relies on
+// the client certificate to authenticate requests. The TLS
+// server certificate must be valid for config.ServerName or,
+// if empty, for "localhost". The client endpoint is set to
+// https://<host> such that Client.Endpoints does not contain
+// the socket path.
+//
+// If transport is nil, NewClientWithTransportConfig behaves
+// like NewClientWithConfig. In any case, request deadlines
+// and cancellations of the request context are still honored.
+func NewClientWithTransportConfig(endpoint string, config *tls.Config, transport *TransportConfig) *Client {
+	var (
+		dialTimeout         = 30 * time.Second
+		keepAlive           = 30 * time.Second
+		tlsHandshakeTimeout = 10 * time.Second
+		idleConnTimeout     = 90 * time.Second
+		maxIdleConns        = 100
+		maxIdleConnsPerHost = 0 // Zero means http.DefaultMaxIdleConnsPerHost
+	)
+	if transport != nil {
+		if transport.DialTimeout > 0 {
+			dialTimeout = transport.DialTimeout
+		}
+		if transport.KeepAlive > 0 {
+			keepAlive = transport.KeepAlive
+		}
+		if transport.TLSHandshakeTimeout > 0 {
+			tlsHandshakeTimeout = transport.TLSHandshakeTimeout
+		}
+		if transport.IdleConnTimeout > 0 {
+			idleConnTimeout = transport.IdleConnTimeout
+		}
+		if transport.MaxIdleConns > 0 {
+			maxIdleConns = transport.MaxIdleConns
+		}
+		if transport.MaxIdleConnsPerHost > 0 {
+			maxIdleConnsPerHost = transport.MaxIdleConnsPerHost
+		}
+	}
+	client := &Client{}
+	if transport != nil && transport.CircuitBreakerThreshold > 0 {
+		client.breaker = &circuitBreaker{
+			threshold: transport.CircuitBreakerThreshold,
+			cooldown:  30 * time.Second,
+		}
+		if transport.CircuitBreakerCooldown > 0 {
+			client.breaker.cooldown = transport.CircuitBreakerCooldown
+		}
+	}
+	if config != nil {
+		config = config.Clone()
+		if config.GetClientCertificate == nil {
+			config.GetClientCertificate = client.getClientCertificate(config.Certificates)
+		}
+	}
+	if transport != nil && transport.VerifyOCSP {
+		if config == nil {
+			config = &tls.Config{}
+		}
+		config.VerifyConnection = verifyOCSP(config.VerifyConnection)
+	}
+	var protocol Protocol
+	if transport != nil {
+		switch protocol = transport.Protocol; protocol {
+		case HTTP1:
+			if config == nil {
+				config = &tls.Config{}
+			}
+			config.NextProtos = []string{"http/1.1"}
+		case HTTP2:
+			if config == nil {
+				config = &tls.Config{}
+			}
+			config.NextProtos = []string{"h2"}
+			config.VerifyConnection = verifyHTTP2(config.VerifyConnection)
+		}
+	}
+	// Record the server certificate fingerprint once the
+	// connection has been verified. Hence, the fingerprint
+	// of a rejected server certificate is never recorded.
+	if config == nil {
+		config = &tls.Config{}
+	}
+	client.fingerprint = new(atomic.Value)
+	client.verify = config.VerifyConnection
+	config.VerifyConnection = recordFingerprint(client.fingerprint, config.VerifyConnection)
+
+	dialer := &net.Dialer{
+		Timeout:   dialTimeout,
+		KeepAlive: keepAlive,
+		DualStack: true,
+	}
+	httpTransport := &http.Transport{
+		Proxy:                 http.ProxyFromEnvironment,
+		DialContext:           dialer.DialContext,
+		ForceAttemptHTTP2:     true,
+		MaxIdleConns:          maxIdleConns,
+		MaxIdleConnsPerHost:   maxIdleConnsPerHost,
+		IdleConnTimeout:       idleConnTimeout,
+		TLSHandshakeTimeout:   tlsHandshakeTimeout,
+		ExpectContinueTimeout: 1 * time.Second,
+		TLSClientConfig:       config,
+	}
+	if protocol == HTTP1 {
+		// A non-nil, empty TLSNextProto map disables HTTP/2.
+		httpTransport.ForceAttemptHTTP2 = false
+		httpTransport.TLSNextProto = map[string]func(string, *tls.Conn) http.RoundTripper{}
+	}
+	if socket := strings.TrimSpace(endpoint); strings.HasPrefix(socket, "unix://") {
+		// For unix domain sockets, we always dial the socket
+		// and use a HTTPS endpoint with the TLS server name
+		// as host since the TLS server certificate is verified
+		// against the endpoint host.
+		socket = strings.TrimPrefix(socket, "unix://")
+		httpTransport.Proxy = nil
+		httpTransport.DialContext = func(ctx context.Context, _, _ string) (net.Conn, error) {
+			return dialer.DialContext(ctx, "unix", socket)
+		}
+
+		host := "localhost"
+		if config != nil && config.ServerName != "" {
+			host = config.ServerName
+		}
+		endpoint = "https://" + host
+	}
+
+	client.dial = httpTransport.DialContext
+	httpTransport.DialContext = client.dialContext
+
+	client.Endpoints = []string{endpoint}
+	client.HTTPClient = http.Client{
+		Transport: httpTransport,
+	}
+	return client
+}
+
+// LoadClientCertificate reads and parses a public/private key pair
+// from a pair of PEM-encoded files. The certificate file may contain
+// intermediate certificates following the leaf certificate.
+//
+// If the private key is an encrypted PEM block, as produced by
+// 'kes identity new --encrypt', LoadClientCertificate decrypts it
+// using the given password. Otherwise, the password is ignored.
+func LoadClientCertificate(certFile, keyFile, password string) (tls.Certificate, error) {
+	certPEM, err := os.ReadFile(certFile)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	keyPEM, err := os.ReadFile(keyFile)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+
+	var block *pem.Block
+	for rest := keyPEM; len(rest) > 0; {
+		var next *pem.Block
+		if next, rest = pem.Decode(rest); next == nil {
+			break
+		}
+		if next.Type == "PRIVATE KEY" || strings.HasSuffix(next.Type, " PRIVATE KEY") {
+			block = next
+			break
+		}
+	}
+	if block == nil {
+		return tls.Certificate{}, fmt.Errorf("kes: no PEM-encoded private key found in %q", keyFile)
+	}
+	if len(block.Headers) > 0 && x509.IsEncryptedPEMBlock(block) {
+		if password == "" {
+			return tls.Certificate{}, fmt.Errorf("kes: private key %q is encrypted but no password has been provided", keyFile)
+		}
+		der, err := x509.DecryptPEMBlock(block, []byte(password))
+		if err != nil {
+			return tls.Certificate{}, fmt.Errorf("kes: failed to decrypt private key %q: %w", keyFile, err)
+		}
+		keyPEM = pem.EncodeToMemory(&pem.Block{Type: block.Type, Bytes: der})
+	}
+	return tls.X509KeyPair(certPEM, keyPEM)
+}
+
+// Close closes all idle connections of the underlying
+// HTTP transport. Any subsequent request fails with
+// ErrClientClosed. Requests that are in progress when
+// Close is called are not canceled.
+//
+// Close is safe to call concurrently and more than once.
+func (c *Client) Close() error {
+	if atomic.CompareAndSwapUint32(&c.closed, 0, 1) {
+		c.HTTPClient.CloseIdleConnections()
+	}
+	return nil
+}
+
+// ReadOnly returns a new Client that only sends requests
+// that do not modify the state of the KES server. Any API
+// call that would modify the server state - e.g. CreateKey,
+// DeleteKey or ImportKey - fails with ErrReadOnly without
+// sending a request.
+//
+// The returned Client shares the underlying HTTP client,
+// including its connections, with c. It uses the hooks
+// registered on c at the time ReadOnly is called.
+//
+// ReadOnly complements but does not replace server-side
+// policies.
+func (c *Client) ReadOnly() *Client {
+	c.hookLock.RLock()
+	defer c.hookLock.RUnlock()
+
 	return &Client{
-		Endpoints: []string{endpoint},
-		HTTPClient: http.Client{
-			Transport: &http.Transport{
-				Proxy: http.ProxyFromEnvironment,
-				DialContext: (&net.Dialer{
-					Timeout:   30 * time.Second,
-					KeepAlive: 30 * time.Second,
-					DualStack: true,
-				}).DialContext,
-				ForceAttemptHTTP2:     true,
-				MaxIdleConns:          100,
-				IdleConnTimeout:       90 * time.Second,
-				TLSHandshakeTimeout:   10 * time.Second,
-				ExpectContinueTimeout: 1 * time.Second,
-				TLSClientConfig:       config,
-			},
-		},
+		Endpoints:      c.Endpoints,
+		HTTPClient:     c.HTTPClient,
+		Timeouts:       c.Timeouts,
+		MaxRequestSize: c.MaxRequestSize,
+		closed:         atomic.LoadUint32(&c.closed),
+		dial:           c.dial,
+		fingerprint:    c.fingerprint,
+		verify:         c.verify,
+		breaker:        c.breaker,
+		readOnly:       true,
+		onRequest:      c.onRequest[:len(c.onRequest):len(c.onRequest)],
+		onResponse:     c.onResponse[:len(c.onResponse):len(c.onResponse)],
+	}
+}
+
+// WithIdentity returns a new Client that authenticates itself
+// with the given TLS client certificate. Otherwise, it behaves
+// like c. It uses the same transport configuration, e.g. root
+// CAs and timeouts, and the hooks registered on c at the time
+// WithIdentity is called. Changes to the returned Client do
+// not affect c.
+//
+// A client certificate is bound to the TLS connection it has
+// been presented on. Hence, the returned Client does not share
+// connections with c but maintains its own connection pool.
+//
+// The returned Client records the fingerprint of the server
+// certificate, see ServerCertificateFingerprint, independently
+// of c.
+//
+// WithIdentity only works for clients created via NewClient,
+// NewClientWithConfig or NewClientWithTransportConfig. For any
+// other Client, it returns an error since the returned Client
+// would still present the identity of c.
+func (c *Client) WithIdentity(cert tls.Certificate) (*Client, error) {
+	transport, ok := c.HTTPClient.Transport.(*http.Transport)
+	if !ok || c.dial == nil {
+		return nil, errors.New("kes: client does not support changing its identity")
+	}
+
+	c.hookLock.RLock()
+	defer c.hookLock.RUnlock()
+
+	client := &Client{
+		Endpoints:      c.Endpoints,
+		HTTPClient:     c.HTTPClient,
+		Timeouts:       c.Timeouts,
+		MaxRequestSize: c.MaxRequestSize,
+		closed:         atomic.LoadUint32(&c.closed),
+		dial:           c.dial,
+		fingerprint:    new(atomic.Value),
+		verify:         c.verify,
+		breaker:        c.breaker,
+		readOnly:       c.readOnly,
+		onRequest:      c.onRequest[:len(c.onRequest):len(c.onRequest)],
+		onResponse:     c.onResponse[:len(c.onResponse):len(c.onResponse)],
+	}
+	transport = transport.Clone()
+	transport.DialContext = client.dialContext
+	if transport.TLSClientConfig != nil {
+		transport.TLSClientConfig = transport.TLSClientConfig.Clone()
+	} else {
+		transport.TLSClientConfig = &tls.Config{}
+	}
+	transport.TLSClientConfig.Certificates = []tls.Certificate{cert}
+	transport.TLSClientConfig.GetClientCertificate = client.getClientCertificate(transport.TLSClientConfig.Certificates)
+	transport.TLSClientConfig.VerifyConnection = recordFingerprint(client.fingerprint, client.verify)
+	client.HTTPClient.Transport = transport
+	return client, nil
+}
+
+// SetCertificate replaces the TLS client certificate used
+// to authenticate new connections to the KES server. Requests
+// that are in progress and pooled connections keep using the
+// certificate they have been established with.
+//
+// SetCertificate only takes effect for clients created via
+// NewClient, NewClientWithConfig or NewClientWithTransportConfig
+// whose TLS config does not specify a GetClientCertificate
+// callback.
+//
+// SetCertificate is safe to call concurrently.
+func (c *Client) SetCertificate(cert tls.Certificate) {
+	c.cert.Store(&cert)
+}
+
+// ServerCertificateFingerprint returns the hex-encoded SHA-256
+// fingerprint of the server's leaf certificate presented on the
+// most recent connection the client has established. It can be
+// used to pin the server certificate or to detect unexpected
+// server certificate changes.
+//
+// ServerCertificateFingerprint returns an error if the client has
+// not established any connection yet or if the client has not
+// been created via NewClient, NewClientWithConfig or
+// NewClientWithTransportConfig.
+//
+// ServerCertificateFingerprint is safe to call concurrently.
+func (c *Client) ServerCertificateFingerprint() (string, error) {
+	if c.fingerprint != nil {
+		if fingerprint, ok := c.fingerprint.Load().(string); ok {
+			return fingerprint, nil
+		}
+	}
+	return "", errors.New("kes: no connection to server established")
+}
+
+// OnRequest registers a hook that gets called with each
+// request before the client sends it to the KES server.
+// A hook may e.g. add request headers or record timing
+// information. If a request is retried, the hook gets
+// called for each attempt.
+//
+// A hook receives a copy of the request. It cannot change
+// how the client authenticates itself since the client
+// certificate is part of the TLS connection.
+//
+// OnRequest is safe to call concurrently.
+func (c *Client) OnRequest(hook func(*http.Request)) {
+	c.hookLock.Lock()
+	defer c.hookLock.Unlock()
+
+	c.onRequest = append(c.onRequest, hook)
+}
+
+// OnResponse registers a hook that gets called with each
+// response the client receives from the KES server. If a
+// request is retried, the hook gets called for the response
+// of each attempt.
+//
+// A hook must not read or close the response body.
+//
+// OnResponse is safe to call concurrently.
+func (c *Client) OnResponse(hook func(*http.Response)) {
+	c.hookLock.Lock()
+	defer c.hookLock.Unlock()
+
+	c.onResponse = append(c.onResponse, hook)
+}
+
+// getClientCertificate returns a tls.Config.GetClientCertificate
+// callback that returns the certificate set by SetCertificate,
+// if any. Otherwise, it selects one of the given certificates,
+// like the TLS stack does when no callback is set.
+func (c *Client) getClientCertificate(certs []tls.Certificate) func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+	return func(info *tls.CertificateRequestInfo) (*tls.Certificate, error) {
+		if cert, ok := c.cert.Load().(*tls.Certificate); ok {
+			return cert, nil
+		}
+		for i := range certs {
+			if err := info.SupportsCertificate(&certs[i]); err == nil {
+				return &certs[i], nil
+			}
+		}
+		if len(certs) > 0 {
+			return &certs[0], nil
+		}
+		return new(tls.Certificate), nil // No certificate - the server decides whether that's acceptable
 	}
 }
 
@@ -125,7 +677,7 @@ func (c *Client) Version(ctx context.Context) (string, error) {
 		StatusOK       = http.StatusOK
 		MaxResponeSize = 1024 // 1 KB
 	)
-	client := retry(c.HTTPClient)
+	client := c.retry()
 	resp, err := client.Send(ctx, Method, c.Endpoints, APIPath, nil)
 	if err != nil {
 		return "", err
@@ -138,38 +690,436 @@ func (c *Client) Version(ctx context.Context) (string, error) {
 		Version string `json:"version"`
 	}
 	var response Response
-	if err = json.NewDecoder(limitBody(resp, MaxResponeSize)).Decode(&response); err != nil {
-		return "", err
+	if err = json.NewDecoder(limitBody(resp, MaxResponeSize)).Decode(&response); err != nil {
+		return "", err
+	}
+	return response.Version, nil
+}
+
+// Ping sends a lightweight request to the KES server and
+// returns the measured round-trip time. It does not report
+// the server state. Instead, it can be used to measure the
+// latency or to establish connections before sending a
+// burst of requests.
+//
+// If the client has multiple endpoints, Ping measures the
+// round-trip time to the endpoint that replied.
+func (c *Client) Ping(ctx context.Context) (time.Duration, error) {
+	const (
+		APIPath  = "/version"
+		Method   = http.MethodGet
+		StatusOK = http.StatusOK
+	)
+	client := c.retry()
+
+	start := time.Now()
+	resp, err := client.Send(ctx, Method, c.Endpoints, APIPath, nil)
+	if err != nil {
+		return 0, err
+	}
+	rtt := time.Since(start)
+
+	if resp.StatusCode != StatusOK {
+		return 0, parseErrorResponse(resp)
+	}
+	// Drain the response body such that the connection
+	// can be reused by subsequent requests.
+	io.Copy(io.Discard, limitBody(resp, 1024))
+	resp.Body.Close()
+	return rtt, nil
+}
+
+// WaitReady blocks until the KES server is ready to serve
+// requests or the ctx is done. It polls the server every
+// interval and returns nil as soon as the server replies.
+// Errors, like refused connections while the server is
+// starting, are ignored until the ctx is done. Then, it
+// returns the ctx error.
+//
+// If interval is <= 0, WaitReady polls the server once
+// per second.
+func (c *Client) WaitReady(ctx context.Context, interval time.Duration) error {
+	if interval <= 0 {
+		interval = 1 * time.Second
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		_, err := c.Ping(ctx)
+		if err == nil {
+			return nil
+		}
+		if errors.Is(err, ErrClientClosed) {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// Status returns the current state of the KES server.
+func (c *Client) Status(ctx context.Context) (State, error) {
+	const (
+		APIPath         = "/v1/status"
+		Method          = http.MethodGet
+		StatusOK        = http.StatusOK
+		MaxResponseSize = 1 << 20 // 1 MB
+	)
+	client := c.retry()
+	resp, err := client.Send(ctx, Method, c.Endpoints, APIPath, nil)
+	if err != nil {
+		return State{}, err
+	}
+	if resp.StatusCode != StatusOK {
+		return State{}, parseErrorResponse(resp)
+	}
+
+	type Response struct {
+		Version   string        `json:"version"`
+		Commit    string        `json:"commit"`
+		BuildTime time.Time     `json:"build_time"`
+		GoVersion string        `json:"go_version"`
+		UpTime    time.Duration `json:"uptime"`
+	}
+	var response Response
+	if err = json.NewDecoder(limitBody(resp, MaxResponseSize)).Decode(&response); err != nil {
+		return State{}, err
+	}
+	return State(response), nil
+}
+
+// HealthStream returns a channel of HealthEvents sent by the
+// KES server. The first event describes the current state
+// of the KES server. Subsequent events are only sent when
+// the state changes - e.g. when the server gets sealed or
+// its key store becomes unreachable.
+//
+// When the connection to the KES server is lost, HealthStream
+// sends a HealthEvent with a non-nil Err and closes the
+// channel. Then the client may fall back to polling the
+// server. HealthStream does not reconnect automatically.
+//
+// The channel is closed without sending such an event once
+// the ctx is canceled. Callers should keep receiving from
+// the channel until it is closed.
+//
+// HealthStream fails with ErrSealed if the KES server is
+// sealed at the time of subscribing.
+func (c *Client) HealthStream(ctx context.Context) (<-chan HealthEvent, error) {
+	const (
+		APIPath  = "/v1/status/stream"
+		Method   = http.MethodGet
+		StatusOK = http.StatusOK
+	)
+	type Response struct {
+		Time   time.Time `json:"time"`
+		Sealed bool      `json:"sealed"`
+		Store  string    `json:"store"`
+	}
+	client := c.retry()
+	resp, err := client.Send(ctx, Method, c.Endpoints, APIPath, nil)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != StatusOK {
+		return nil, parseErrorResponse(resp)
+	}
+
+	events := make(chan HealthEvent)
+	go func() {
+		defer close(events)
+		defer resp.Body.Close()
+
+		decoder := json.NewDecoder(resp.Body)
+		for {
+			var (
+				response Response
+				event    HealthEvent
+			)
+			if err := decoder.Decode(&response); err != nil {
+				if ctx.Err() != nil {
+					return
+				}
+				if errors.Is(err, io.EOF) {
+					err = errors.New("kes: health stream closed by server")
+				}
+				event = HealthEvent{Time: time.Now(), Err: err}
+			} else {
+				event = HealthEvent{
+					Time:       response.Time,
+					Sealed:     response.Sealed,
+					StoreState: response.Store,
+				}
+			}
+
+			select {
+			case events <- event:
+			case <-ctx.Done():
+				return
+			}
+			if event.Err != nil {
+				return
+			}
+		}
+	}()
+	return events, nil
+}
+
+// VaultStatus returns the status of all enclaves within
+// the KES server vault keyed by the enclave name. The
+// default enclave has an empty name.
+//
+// If the server fails to fetch the status of an enclave,
+// the enclave's EnclaveStatus contains the error. The
+// status of the other enclaves is not affected.
+//
+// Only the vault operator can fetch the vault status.
+// VaultStatus returns ErrNotAllowed if the client is
+// not the vault operator.
+func (c *Client) VaultStatus(ctx context.Context) (map[string]EnclaveStatus, error) {
+	const (
+		APIPath         = "/v1/vault/status"
+		Method          = http.MethodGet
+		StatusOK        = http.StatusOK
+		MaxResponseSize = 1 << 20 // 1 MB
+	)
+	client := c.retry()
+	resp, err := client.Send(ctx, Method, c.Endpoints, APIPath, nil)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != StatusOK {
+		return nil, parseErrorResponse(resp)
+	}
+
+	type Response struct {
+		Sealed   bool          `json:"sealed"`
+		KeyCount uint64        `json:"key_count"`
+		State    string        `json:"state"`
+		Latency  time.Duration `json:"latency"`
+		Err      string        `json:"error"`
+	}
+	var response map[string]Response
+	if err = json.NewDecoder(limitBody(resp, MaxResponseSize)).Decode(&response); err != nil {
+		return nil, err
+	}
+	status := make(map[string]EnclaveStatus, len(response))
+	for name, enclave := range response {
+		s := EnclaveStatus{
+			Sealed:       enclave.Sealed,
+			KeyCount:     enclave.KeyCount,
+			StoreState:   enclave.State,
+			StoreLatency: enclave.Latency,
+		}
+		if enclave.Err != "" {
+			s.Err = errors.New(enclave.Err)
+		}
+		status[name] = s
+	}
+	return status, nil
+}
+
+// Enclave returns a new Enclave with the given name. All
+// operations of the returned Enclave - e.g. creating keys
+// or policies - are performed within the named enclave
+// instead of the default enclave.
+//
+// If name is empty, the returned Enclave refers to the
+// default enclave.
+func (c *Client) Enclave(name string) *Enclave {
+	return &Enclave{
+		name:           name,
+		endpoints:      c.Endpoints,
+		client:         c.retry(),
+		maxRequestSize: c.MaxRequestSize,
+	}
+}
+
+// CreateEnclave creates a new enclave with the given
+// name. It returns ErrEnclaveExists if an enclave with
+// the same name already exists.
+//
+// Only the vault operator can create enclaves. A KES
+// server that does not support multiple enclaves returns
+// an Error with the HTTP status code 501 (Not Implemented).
+func (c *Client) CreateEnclave(ctx context.Context, name string) error {
+	const (
+		APIPath  = "/v1/enclave/create"
+		Method   = http.MethodPost
+		StatusOK = http.StatusOK
+	)
+	client := c.retry()
+	resp, err := client.Send(ctx, Method, c.Endpoints, path.Join(APIPath, url.PathEscape(name)), nil)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != StatusOK {
+		return parseErrorResponse(resp)
+	}
+	return nil
+}
+
+// DeleteEnclave deletes the enclave with the given name.
+// It returns ErrEnclaveNotFound if no such enclave exists.
+//
+// Only the vault operator can delete enclaves. A KES
+// server that does not support multiple enclaves returns
+// an Error with the HTTP status code 501 (Not Implemented).
+func (c *Client) DeleteEnclave(ctx context.Context, name string) error {
+	const (
+		APIPath  = "/v1/enclave/delete"
+		Method   = http.MethodDelete
+		StatusOK = http.StatusOK
+	)
+	client := c.retry()
+	resp, err := client.Send(ctx, Method, c.Endpoints, path.Join(APIPath, url.PathEscape(name)), nil)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != StatusOK {
+		return parseErrorResponse(resp)
+	}
+	return nil
+}
+
+// ListEnclaves lists all enclaves that match the given
+// pattern. The pattern matching happens on the server
+// side. If pattern is empty ListEnclaves returns all
+// enclaves.
+//
+// Only the vault operator can list enclaves. The default
+// enclave is never listed. Hence, a KES server that does
+// not support multiple enclaves returns no enclaves.
+func (c *Client) ListEnclaves(ctx context.Context, pattern string) (*EnclaveIterator, error) {
+	const (
+		APIPath  = "/v1/enclave/list"
+		Method   = http.MethodGet
+		StatusOK = http.StatusOK
+	)
+	if pattern == "" { // The empty pattern never matches anything
+		const MatchAll = "*"
+		pattern = MatchAll
+	}
+
+	client := c.retry()
+	resp, err := client.Send(ctx, Method, c.Endpoints, path.Join(APIPath, url.PathEscape(pattern)), nil, withHeader("Accept-Encoding", acceptEncoding))
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != StatusOK {
+		return nil, parseErrorResponse(resp)
+	}
+	body, err := responseBody(resp)
+	if err != nil {
+		resp.Body.Close()
+		return nil, err
+	}
+	return &EnclaveIterator{
+		decoder: json.NewDecoder(body),
+		closer:  body,
+	}, nil
+}
+
+// DescribeEnclave returns the EnclaveInfo for the enclave
+// with the given name. The empty name refers to the default
+// enclave - i.e. the single, unnamed enclave of a KES server
+// that does not support multiple enclaves. It returns
+// ErrEnclaveNotFound if no such enclave exists.
+//
+// Only the vault operator can describe enclaves.
+func (c *Client) DescribeEnclave(ctx context.Context, name string) (*EnclaveInfo, error) {
+	const (
+		APIPath         = "/v1/enclave/describe"
+		Method          = http.MethodGet
+		StatusOK        = http.StatusOK
+		MaxResponseSize = 1 << 20 // 1 MiB
+	)
+	type Response struct {
+		Name       string `json:"name"`
+		Sealed     bool   `json:"sealed"`
+		KeyCount   uint64 `json:"key_count"`
+		StoreState string `json:"store_state"`
+		Err        string `json:"error"`
+	}
+
+	// In contrast to path.Join, we keep the trailing
+	// slash such that the empty name refers to the
+	// default enclave.
+	client := c.retry()
+	resp, err := client.Send(ctx, Method, c.Endpoints, APIPath+"/"+url.PathEscape(name), nil)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != StatusOK {
+		return nil, parseErrorResponse(resp)
+	}
+	var response Response
+	if err = json.NewDecoder(io.LimitReader(resp.Body, MaxResponseSize)).Decode(&response); err != nil {
+		return nil, err
 	}
-	return response.Version, nil
+	info := &EnclaveInfo{
+		Name:       response.Name,
+		Sealed:     response.Sealed,
+		KeyCount:   response.KeyCount,
+		StoreState: response.StoreState,
+	}
+	if response.Err != "" {
+		info.Err = errors.New(response.Err)
+	}
+	return info, nil
 }
 
-// Status returns the current state of the KES server.
-func (c *Client) Status(ctx context.Context) (State, error) {
+// Seal seals the KES server vault. Once sealed, the KES
+// server rejects any key, policy or identity operation
+// with ErrSealed until the vault gets unsealed again.
+//
+// It returns ErrSealed if the vault is already sealed.
+// Only the vault operator can seal the vault. Seal
+// returns ErrNotAllowed if the client is not the vault
+// operator.
+func (c *Client) Seal(ctx context.Context) error {
 	const (
-		APIPath         = "/v1/status"
-		Method          = http.MethodGet
-		StatusOK        = http.StatusOK
-		MaxResponseSize = 1 << 20 // 1 MB
+		APIPath  = "/v1/vault/seal"
+		Method   = http.MethodPost
+		StatusOK = http.StatusOK
 	)
-	client := retry(c.HTTPClient)
+	client := c.retry()
 	resp, err := client.Send(ctx, Method, c.Endpoints, APIPath, nil)
 	if err != nil {
-		return State{}, err
+		return err
 	}
 	if resp.StatusCode != StatusOK {
-		return State{}, parseErrorResponse(resp)
+		return parseErrorResponse(resp)
 	}
+	return nil
+}
 
-	type Response struct {
-		Version string        `json:"version"`
-		UpTime  time.Duration `json:"uptime"`
+// Unseal unseals the KES server vault. It returns no
+// error if the vault is not sealed.
+//
+// Only the vault operator can unseal the vault. Unseal
+// returns ErrNotAllowed if the client is not the vault
+// operator.
+func (c *Client) Unseal(ctx context.Context) error {
+	const (
+		APIPath  = "/v1/vault/unseal"
+		Method   = http.MethodPost
+		StatusOK = http.StatusOK
+	)
+	client := c.retry()
+	resp, err := client.Send(ctx, Method, c.Endpoints, APIPath, nil)
+	if err != nil {
+		return err
 	}
-	var response Response
-	if err = json.NewDecoder(limitBody(resp, MaxResponseSize)).Decode(&response); err != nil {
-		return State{}, err
+	if resp.StatusCode != StatusOK {
+		return parseErrorResponse(resp)
 	}
-	return State(response), nil
+	return nil
 }
 
 // APIs returns a list of all API endpoints supported
@@ -185,7 +1135,7 @@ func (c *Client) APIs(ctx context.Context) ([]API, error) {
 		StatusOK        = http.StatusOK
 		MaxResponseSize = 1 << 20 // 1 MB
 	)
-	client := retry(c.HTTPClient)
+	client := c.retry()
 	resp, err := client.Send(ctx, Method, c.Endpoints, APIPath, nil)
 	if err != nil {
 		return nil, err
@@ -225,28 +1175,144 @@ func (c *Client) APIs(ctx context.Context) ([]API, error) {
 func (c *Client) CreateKey(ctx context.Context, name string) error {
 	enclave := Enclave{
 		endpoints: c.Endpoints,
-		client:    retry(c.HTTPClient),
+		client:    c.retry(),
 	}
 	return enclave.CreateKey(ctx, name)
 }
 
+// CreateKeyWithAlgorithm creates a new cryptographic key that
+// is used with the given algorithm - e.g. ChaCha20Poly1305. The
+// KES server encrypts and decrypts data with the key using this
+// algorithm. Keys created via CreateKey use AES256 unless the
+// KES server lacks AES hardware support.
+//
+// It returns ErrKeyExists if a key with the same name already
+// exists.
+func (c *Client) CreateKeyWithAlgorithm(ctx context.Context, name string, algorithm KeyAlgorithm) error {
+	enclave := Enclave{
+		endpoints: c.Endpoints,
+		client:    c.retry(),
+	}
+	return enclave.CreateKeyWithAlgorithm(ctx, name, algorithm)
+}
+
+// CreateKeyIdempotent creates a new cryptographic key - like
+// CreateKey. However, repeating the request with the same name
+// and idempotency token does not fail with ErrKeyExists as long
+// as the server still remembers the token - i.e. for a few
+// minutes. Hence, CreateKeyIdempotent can be retried safely,
+// e.g. after a timeout. The server remembers tokens per client
+// identity, so a token used by another identity has no effect.
+//
+// If token is empty, CreateKeyIdempotent behaves like CreateKey.
+func (c *Client) CreateKeyIdempotent(ctx context.Context, name, token string) error {
+	enclave := Enclave{
+		endpoints: c.Endpoints,
+		client:    c.retry(),
+	}
+	return enclave.CreateKeyIdempotent(ctx, name, token)
+}
+
 // ImportKey imports the given key into a KES server. It
 // returns ErrKeyExists if a key with the same key already
 // exists.
 func (c *Client) ImportKey(ctx context.Context, name string, key []byte) error {
 	enclave := Enclave{
 		endpoints: c.Endpoints,
-		client:    retry(c.HTTPClient),
+		client:    c.retry(),
 	}
 	return enclave.ImportKey(ctx, name, key)
 }
 
+// ExportKey returns the raw key material of the given key.
+// The exported key can be imported again via ImportKey, e.g.
+// to restore a backup.
+//
+// Exporting a key requires a policy that allows the export
+// API explicitly - e.g. "/v1/key/export/<name>". Wildcard
+// rules, like "/v1/key/*/*", do not grant access to it.
+// It returns ErrNotAllowed if the client does not have
+// sufficient permissions and ErrKeyNotFound if no such
+// key exists.
+func (c *Client) ExportKey(ctx context.Context, name string) ([]byte, error) {
+	enclave := Enclave{
+		endpoints: c.Endpoints,
+		client:    c.retry(),
+	}
+	return enclave.ExportKey(ctx, name)
+}
+
+// CopyKey creates a new key dst with the same key material
+// as the key src. The key material never leaves the server.
+// Ciphertexts produced by src can be decrypted with dst, and
+// vice versa. The key dst starts with the usage counters of
+// src, as reported by DescribeKey.
+//
+// Only the admin identity can copy keys. CopyKey returns
+// ErrKeyNotFound if src does not exist and ErrKeyExists
+// if dst already exists.
+func (c *Client) CopyKey(ctx context.Context, src, dst string) error {
+	enclave := Enclave{
+		endpoints: c.Endpoints,
+		client:    c.retry(),
+	}
+	return enclave.CopyKey(ctx, src, dst)
+}
+
+// RenameKey renames the key oldName to newName. The key keeps
+// its key material, creation time and usage counters. Hence,
+// ciphertexts produced by oldName can be decrypted with newName.
+//
+// Only the admin identity can rename keys. RenameKey returns
+// ErrKeyNotFound if oldName does not exist and ErrKeyExists
+// if newName already exists.
+//
+// The key is renamed atomically. Servers whose key store
+// cannot rename keys atomically reject the request with
+// http.StatusNotImplemented.
+func (c *Client) RenameKey(ctx context.Context, oldName, newName string) error {
+	enclave := Enclave{
+		endpoints: c.Endpoints,
+		client:    c.retry(),
+	}
+	return enclave.RenameKey(ctx, oldName, newName)
+}
+
+// DescribeKey returns the KeyInfo for the given key.
+// It returns ErrKeyNotFound if no such key exists.
+//
+// The returned KeyInfo contains the number of encrypt,
+// decrypt and generate operations the server has performed
+// with the key. These usage counters are not persisted and
+// get reset when the server restarts. Hence, they are not
+// suitable as the only source for compliance reports.
+func (c *Client) DescribeKey(ctx context.Context, name string) (*KeyInfo, error) {
+	enclave := Enclave{
+		endpoints: c.Endpoints,
+		client:    c.retry(),
+	}
+	return enclave.DescribeKey(ctx, name)
+}
+
+// KeyExists reports whether the named key exists at the
+// KES server. It returns false and no error if the key
+// does not exist.
+//
+// KeyExists requires permission to describe the key.
+func (c *Client) KeyExists(ctx context.Context, name string) (bool, error) {
+	enclave := Enclave{
+		endpoints: c.Endpoints,
+		client:    c.retry(),
+	}
+	return enclave.KeyExists(ctx, name)
+}
+
 // DeleteKey deletes the key from a KES server. It returns
 // ErrKeyNotFound if no such key exists.
 func (c *Client) DeleteKey(ctx context.Context, name string) error {
 	enclave := Enclave{
 		endpoints: c.Endpoints,
-		client:    retry(c.HTTPClient),
+		client:    c.retry(),
 	}
 	return enclave.DeleteKey(ctx, name)
 }
@@ -268,26 +1334,125 @@ func (c *Client) DeleteKey(ctx context.Context, name string) error {
 // context or must be able to re-generate it.
 //
 // GenerateKey returns ErrKeyNotFound if no key with the given name
-// exists.
+// exists. It returns ErrContextTooLarge if the request exceeds
+// the client's MaxRequestSize.
 func (c *Client) GenerateKey(ctx context.Context, name string, context []byte) (DEK, error) {
 	enclave := Enclave{
-		endpoints: c.Endpoints,
-		client:    retry(c.HTTPClient),
+		endpoints:      c.Endpoints,
+		client:         c.retry(),
+		maxRequestSize: c.MaxRequestSize,
 	}
 	return enclave.GenerateKey(ctx, name, context)
 }
 
+// GenerateKeyWithNonce returns a new generated data encryption
+// key (DEK), like GenerateKey. However, the KES server returns
+// the same DEK when it receives a request from the same client
+// identity with the same key name, context and nonce again within
+// a short time window. Hence, a client can retry
+// GenerateKeyWithNonce safely without generating additional DEKs.
+// If the server remembers too many DEKs already, a retried
+// request may return a new DEK.
+//
+// The nonce should be a unique value, e.g. a random 16 byte
+// value, per DEK and must not be larger than 256 bytes.
+func (c *Client) GenerateKeyWithNonce(ctx context.Context, name string, context, nonce []byte) (DEK, error) {
+	enclave := Enclave{
+		endpoints:      c.Endpoints,
+		client:         c.retry(),
+		maxRequestSize: c.MaxRequestSize,
+	}
+	return enclave.GenerateKeyWithNonce(ctx, name, context, nonce)
+}
+
+// GenerateKeyN returns a new generated data encryption key (DEK),
+// like GenerateKey. However, the plaintext DEK is bits long. The
+// bits must be either 128 or 256.
+//
+// The DEK is still encrypted with the named key at the KES server.
+// Only the length of the generated DEK changes. A 128 bit DEK may
+// be used for AES-128 encryption, for example.
+func (c *Client) GenerateKeyN(ctx context.Context, name string, bits int, context []byte) (DEK, error) {
+	enclave := Enclave{
+		endpoints:      c.Endpoints,
+		client:         c.retry(),
+		maxRequestSize: c.MaxRequestSize,
+	}
+	return enclave.GenerateKeyN(ctx, name, bits, context)
+}
+
+// GenerateAttestedKey returns a new generated data encryption
+// key (DEK), like GenerateKey, and an Attestation signed by the
+// KES server. The Attestation proves that the DEK ciphertext
+// has been generated by the KES server at the attestation time
+// and can be verified via VerifyAttestation.
+//
+// The KES server only signs attestations if it has been
+// configured with an attestation key. Otherwise, it rejects
+// the request with HTTP 501 (Not Implemented).
+func (c *Client) GenerateAttestedKey(ctx context.Context, name string, context []byte) (DEK, Attestation, error) {
+	enclave := Enclave{
+		endpoints:      c.Endpoints,
+		client:         c.retry(),
+		maxRequestSize: c.MaxRequestSize,
+	}
+	return enclave.GenerateAttestedKey(ctx, name, context)
+}
+
+// GenerateKeys returns n new data encryption keys (DEKs) generated
+// with a single request to the KES server. Each DEK is generated
+// independently, as if by GenerateKey, and is bound to the same
+// context. The DEKs are returned in the order the KES server
+// generated them.
+//
+// The KES server limits how many DEKs can be generated by a single
+// request. Currently, n must not be greater than 1000.
+//
+// GenerateKeys returns ErrKeyNotFound if no key with the given name
+// exists.
+func (c *Client) GenerateKeys(ctx context.Context, name string, n int, context []byte) ([]DEK, error) {
+	enclave := Enclave{
+		endpoints: c.Endpoints,
+		client:    c.retry(),
+	}
+	return enclave.GenerateKeys(ctx, name, n, context)
+}
+
+// GenerateKeyMulti returns a new plaintext data encryption key
+// and one ciphertext of it per named key. Each ciphertext is
+// generated as if by GenerateKey, is bound to the same context
+// and decrypts to the same plaintext independently. For example,
+// a DEK can be wrapped under a primary and a secondary key such
+// that either key can decrypt it.
+//
+// The KES server only generates the DEK if the client is allowed
+// to generate DEKs with each of the keys. It limits how many keys
+// can be used by a single request. Currently, at most 100 keys
+// may be specified.
+//
+// GenerateKeyMulti returns ErrKeyNotFound if any of the keys does
+// not exist.
+func (c *Client) GenerateKeyMulti(ctx context.Context, keys []string, context []byte) (plaintext []byte, ciphertexts map[string][]byte, err error) {
+	enclave := Enclave{
+		endpoints: c.Endpoints,
+		client:    c.retry(),
+	}
+	return enclave.GenerateKeyMulti(ctx, keys, context)
+}
+
 // Encrypt encrypts the given plaintext with the named key at the
 // KES server. The optional context is cryptographically bound to
 // the returned ciphertext. The exact same context must be provided
 // when decrypting the ciphertext again.
 //
 // Encrypt returns ErrKeyNotFound if no such key exists at the KES
-// server.
+// server. It returns ErrContextTooLarge if the plaintext and context
+// exceed the client's MaxRequestSize.
 func (c *Client) Encrypt(ctx context.Context, name string, plaintext, context []byte) ([]byte, error) {
 	enclave := Enclave{
-		endpoints: c.Endpoints,
-		client:    retry(c.HTTPClient),
+		endpoints:      c.Endpoints,
+		client:         c.retry(),
+		maxRequestSize: c.MaxRequestSize,
 	}
 	return enclave.Encrypt(ctx, name, plaintext, context)
 }
@@ -298,15 +1463,50 @@ func (c *Client) Encrypt(ctx context.Context, name string, plaintext, context []
 //
 // Decrypt returns ErrKeyNotFound if no such key exists. It returns
 // ErrDecrypt when the ciphertext has been modified or a different
-// context value is provided.
+// context value is provided. It returns ErrContextTooLarge if the
+// ciphertext and context exceed the client's MaxRequestSize.
 func (c *Client) Decrypt(ctx context.Context, name string, ciphertext, context []byte) ([]byte, error) {
 	enclave := Enclave{
-		endpoints: c.Endpoints,
-		client:    retry(c.HTTPClient),
+		endpoints:      c.Endpoints,
+		client:         c.retry(),
+		maxRequestSize: c.MaxRequestSize,
 	}
 	return enclave.Decrypt(ctx, name, ciphertext, context)
 }
 
+// ReWrap decrypts the ciphertext with the named key at the KES
+// server and encrypts the resulting plaintext again with the same
+// key. The plaintext never leaves the KES server. The exact same
+// context, used during Encrypt, must be provided. It is also used
+// as context of the returned ciphertext.
+//
+// ReWrap returns ErrKeyNotFound if no such key exists. It returns
+// ErrDecrypt when the ciphertext has been modified or a different
+// context value is provided.
+func (c *Client) ReWrap(ctx context.Context, name string, ciphertext, context []byte) ([]byte, error) {
+	enclave := Enclave{
+		endpoints: c.Endpoints,
+		client:    c.retry(),
+	}
+	return enclave.ReWrap(ctx, name, ciphertext, context)
+}
+
+// DeriveKey derives a new key of the given length from the
+// named key at the KES server using HKDF-SHA256 with the given
+// info and the fixed salt "kes-key-derive". The same info always
+// yields the same derived key.
+// Hence, an application can derive distinct keys from one key
+// without storing each derived key.
+//
+// DeriveKey returns ErrKeyNotFound if no such key exists.
+func (c *Client) DeriveKey(ctx context.Context, name string, info []byte, length int) ([]byte, error) {
+	enclave := Enclave{
+		endpoints: c.Endpoints,
+		client:    c.retry(),
+	}
+	return enclave.DeriveKey(ctx, name, info, length)
+}
+
 // DecryptAll decrypts all ciphertexts with the named key at the
 // KES server. It either returns all decrypted plaintexts or the
 // first decryption error.
@@ -317,7 +1517,7 @@ func (c *Client) Decrypt(ctx context.Context, name string, ciphertext, context [
 func (c *Client) DecryptAll(ctx context.Context, name string, ciphertexts ...CCP) ([]PCP, error) {
 	enclave := Enclave{
 		endpoints: c.Endpoints,
-		client:    retry(c.HTTPClient),
+		client:    c.retry(),
 	}
 	return enclave.DecryptAll(ctx, name, ciphertexts...)
 }
@@ -331,11 +1531,43 @@ func (c *Client) DecryptAll(ctx context.Context, name string, ciphertexts ...CCP
 func (c *Client) ListKeys(ctx context.Context, pattern string) (*KeyIterator, error) {
 	enclave := Enclave{
 		endpoints: c.Endpoints,
-		client:    retry(c.HTTPClient),
+		client:    c.retry(),
 	}
 	return enclave.ListKeys(ctx, pattern)
 }
 
+// ListKeysSorted lists all names of cryptographic keys that match
+// the given pattern, like ListKeys. However, the KES server returns
+// the keys in the given order.
+//
+// The KES server has to fetch all matching keys before it can sort
+// them. Hence, the first key may be returned later than by ListKeys.
+// To limit its memory usage, the server only sorts up to 10000 keys.
+// If more keys match the pattern, it returns an error.
+func (c *Client) ListKeysSorted(ctx context.Context, pattern string, order KeyOrder) (*KeyIterator, error) {
+	enclave := Enclave{
+		endpoints: c.Endpoints,
+		client:    c.retry(),
+	}
+	return enclave.ListKeysSorted(ctx, pattern, order)
+}
+
+// ListKeysWithOptions lists all names of cryptographic keys that
+// match the given pattern, like ListKeys. However, the KES server
+// only returns keys created within the time window specified by
+// the options, if any, and in the specified order. If requested,
+// it also reports when and by whom each key has been created.
+//
+// Keys without a creation timestamp are not listed if the options
+// specify a time window.
+func (c *Client) ListKeysWithOptions(ctx context.Context, pattern string, opts ListKeysOptions) (*KeyIterator, error) {
+	enclave := Enclave{
+		endpoints: c.Endpoints,
+		client:    c.retry(),
+	}
+	return enclave.ListKeysWithOptions(ctx, pattern, opts)
+}
+
 // SetPolicy creates the given policy. If a policy with the same
 // name already exists, SetPolicy overwrites the existing policy
 // with the given one. Any existing identites will be assigned to
@@ -343,34 +1575,64 @@ func (c *Client) ListKeys(ctx context.Context, pattern string) (*KeyIterator, er
 func (c *Client) SetPolicy(ctx context.Context, name string, policy *Policy) error {
 	enclave := Enclave{
 		endpoints: c.Endpoints,
-		client:    retry(c.HTTPClient),
+		client:    c.retry(),
 	}
 	return enclave.SetPolicy(ctx, name, policy)
 }
 
+// DescribePolicy returns the PolicyInfo for the given
+// policy. It contains the rules of the policy, its parent
+// policies and the effective rules that apply to requests.
+// It returns ErrPolicyNotFound if no such policy exists.
+func (c *Client) DescribePolicy(ctx context.Context, name string) (*PolicyInfo, error) {
+	enclave := Enclave{
+		endpoints: c.Endpoints,
+		client:    c.retry(),
+	}
+	return enclave.DescribePolicy(ctx, name)
+}
+
 // GetPolicy returns the policy with the given name.
 // It returns ErrPolicyNotFound if no such policy
 // exists.
 func (c *Client) GetPolicy(ctx context.Context, name string) (*Policy, error) {
 	enclave := Enclave{
 		endpoints: c.Endpoints,
-		client:    retry(c.HTTPClient),
+		client:    c.retry(),
 	}
 	return enclave.GetPolicy(ctx, name)
 }
 
 // DeletePolicy deletes the policy with the given name. Any
-// assigned identities will be removed as well.
+// assigned identities will be removed as well. A policy that
+// is extended by another policy cannot be deleted until the
+// other policy is deleted or no longer extends it.
 //
 // It returns ErrPolicyNotFound if no such policy exists.
 func (c *Client) DeletePolicy(ctx context.Context, name string) error {
 	enclave := Enclave{
 		endpoints: c.Endpoints,
-		client:    retry(c.HTTPClient),
+		client:    c.retry(),
 	}
 	return enclave.DeletePolicy(ctx, name)
 }
 
+// Apply applies the given AdminOps in order. Either all
+// AdminOps succeed or none does. If an AdminOp fails, Apply
+// returns an *ApplyError that contains the AdminOp and its
+// error.
+//
+// Only the admin identity can apply AdminOps. Apply may be
+// used to create a policy and assign identities to it at
+// once, without leaving a partially applied state behind.
+func (c *Client) Apply(ctx context.Context, ops []AdminOp) error {
+	enclave := Enclave{
+		endpoints: c.Endpoints,
+		client:    c.retry(),
+	}
+	return enclave.Apply(ctx, ops)
+}
+
 // ListPolicies lists all policy names that match the given pattern.
 // It returns a PolicyIterator that iterates over all matched policies.
 //
@@ -379,7 +1641,7 @@ func (c *Client) DeletePolicy(ctx context.Context, name string) error {
 func (c *Client) ListPolicies(ctx context.Context, pattern string) (*PolicyIterator, error) {
 	enclave := Enclave{
 		endpoints: c.Endpoints,
-		client:    retry(c.HTTPClient),
+		client:    c.retry(),
 	}
 	return enclave.ListPolicies(ctx, pattern)
 }
@@ -392,30 +1654,61 @@ func (c *Client) ListPolicies(ctx context.Context, pattern string) (*PolicyItera
 func (c *Client) AssignPolicy(ctx context.Context, policy string, identity Identity) error {
 	enclave := Enclave{
 		endpoints: c.Endpoints,
-		client:    retry(c.HTTPClient),
+		client:    c.retry(),
 	}
 	return enclave.AssignPolicy(ctx, policy, identity)
 }
 
+// AssignPolicyWithTTL assigns the policy to the identity
+// for the given amount of time. Once the TTL has elapsed,
+// the KES server treats the identity as unknown and rejects
+// any request it sends.
+//
+// AssignPolicyWithTTL returns PolicyNotFound if no such
+// policy exists.
+func (c *Client) AssignPolicyWithTTL(ctx context.Context, policy string, identity Identity, ttl time.Duration) error {
+	enclave := Enclave{
+		endpoints: c.Endpoints,
+		client:    c.retry(),
+	}
+	return enclave.AssignPolicyWithTTL(ctx, policy, identity, ttl)
+}
+
+// AssignPolicies assigns the policy to all given identities
+// within a single request. The KES admin identity cannot be
+// assigned to any policy.
+//
+// If some identities cannot be assigned, AssignPolicies returns
+// an *AssignPolicyError describing which identities failed. All
+// other identities are assigned to the policy.
+func (c *Client) AssignPolicies(ctx context.Context, policy string, identities []Identity) error {
+	enclave := Enclave{
+		endpoints: c.Endpoints,
+		client:    c.retry(),
+	}
+	return enclave.AssignPolicies(ctx, policy, identities)
+}
+
 // DescribeIdentity returns an IdentityInfo describing the given identity.
 func (c *Client) DescribeIdentity(ctx context.Context, identity Identity) (*IdentityInfo, error) {
 	enclave := Enclave{
 		endpoints: c.Endpoints,
-		client:    retry(c.HTTPClient),
+		client:    c.retry(),
 	}
 	return enclave.DescribeIdentity(ctx, identity)
 }
 
 // DescribeSelf returns an IdentityInfo describing the identity
 // making the API request. It also returns the assigned policy,
-// if any.
+// if any. The IdentityInfo contains the Allow and Deny rules
+// of the policy, unless the identity is the admin.
 //
 // DescribeSelf allows an application to obtain identity and
 // policy information about itself.
 func (c *Client) DescribeSelf(ctx context.Context) (*IdentityInfo, *Policy, error) {
 	enclave := Enclave{
 		endpoints: c.Endpoints,
-		client:    retry(c.HTTPClient),
+		client:    c.retry(),
 	}
 	return enclave.DescribeSelf(ctx)
 }
@@ -428,11 +1721,48 @@ func (c *Client) DescribeSelf(ctx context.Context) (*IdentityInfo, *Policy, erro
 func (c *Client) DeleteIdentity(ctx context.Context, identity Identity) error {
 	enclave := Enclave{
 		endpoints: c.Endpoints,
-		client:    retry(c.HTTPClient),
+		client:    c.retry(),
 	}
 	return enclave.DeleteIdentity(ctx, identity)
 }
 
+// SetIdentityAlias sets a human-readable alias for the given
+// identity. Aliases are unique such that no two identities
+// can have the same alias. An empty alias removes the current
+// alias of the identity.
+//
+// An alias is purely cosmetic. A KES server never uses it to
+// authorize requests.
+//
+// SetIdentityAlias returns ErrAliasExists if another identity
+// already uses the alias.
+func (c *Client) SetIdentityAlias(ctx context.Context, identity Identity, alias string) error {
+	enclave := Enclave{
+		endpoints: c.Endpoints,
+		client:    c.retry(),
+	}
+	return enclave.SetIdentityAlias(ctx, identity, alias)
+}
+
+// DeleteIdentitiesByPolicy removes all identities assigned
+// to the given policy and returns the number of removed
+// identities. Once removed, any operation issued by one of
+// these identities will fail with ErrNotAllowed.
+//
+// Only the KES admin can delete identities by policy. The
+// KES admin identity is never removed.
+//
+// If the server fails to delete one of the identities, it
+// stops. DeleteIdentitiesByPolicy then returns the number
+// of identities removed before the failure and the error.
+func (c *Client) DeleteIdentitiesByPolicy(ctx context.Context, policy string) (int, error) {
+	enclave := Enclave{
+		endpoints: c.Endpoints,
+		client:    c.retry(),
+	}
+	return enclave.DeleteIdentitiesByPolicy(ctx, policy)
+}
+
 // ListIdentities lists all identites that match the given pattern.
 //
 // The pattern matching happens on the server side. If pattern is empty
@@ -440,25 +1770,45 @@ func (c *Client) DeleteIdentity(ctx context.Context, identity Identity) error {
 func (c *Client) ListIdentities(ctx context.Context, pattern string) (*IdentityIterator, error) {
 	enclave := Enclave{
 		endpoints: c.Endpoints,
-		client:    retry(c.HTTPClient),
+		client:    c.retry(),
 	}
 	return enclave.ListIdentities(ctx, pattern)
 }
 
+// ListPolicyIdentities lists all identities that are assigned
+// to the policy with the given name.
+//
+// The policy does not have to exist. Hence, ListPolicyIdentities
+// also lists identities that are still assigned to a policy that
+// has been deleted.
+func (c *Client) ListPolicyIdentities(ctx context.Context, policy string) (*IdentityIterator, error) {
+	enclave := Enclave{
+		endpoints: c.Endpoints,
+		client:    c.retry(),
+	}
+	return enclave.ListPolicyIdentities(ctx, policy)
+}
+
 // AuditLog returns a stream of audit events produced by the
 // KES server. The stream does not contain any events that
 // happened in the past.
 //
-// It returns ErrNotAllowed if the client does not
-// have sufficient permissions to subscribe to the
-// audit log.
+// Each audit event contains the request timestamp, the
+// client identity, the API path, the response status
+// code and the response time. The stream gets closed
+// when the ctx is canceled. Reconnecting is the
+// responsibility of the caller.
+//
+// It returns ErrNotAllowed if the client is not the
+// KES server admin. Only the admin can subscribe to
+// the audit log.
 func (c *Client) AuditLog(ctx context.Context) (*AuditStream, error) {
 	const (
 		APIPath  = "/v1/log/audit"
 		Method   = http.MethodGet
 		StatusOK = http.StatusOK
 	)
-	client := retry(c.HTTPClient)
+	client := c.retry()
 	resp, err := client.Send(ctx, Method, c.Endpoints, APIPath, nil)
 	if err != nil {
 		return nil, err
@@ -471,18 +1821,22 @@ func (c *Client) AuditLog(ctx context.Context) (*AuditStream, error) {
 
 // ErrorLog returns a stream of error events produced by the
 // KES server. The stream does not contain any events that
-// happened in the past.
+// happened in the past. Each event contains the error
+// message logged by the server.
 //
-// It returns ErrNotAllowed if the client does not
-// have sufficient permissions to subscribe to the
-// error log.
+// The stream ends when ctx is canceled or the ErrorStream
+// is closed. It does not reconnect automatically when the
+// connection to the server breaks.
+//
+// Only the admin identity can subscribe to the error log.
+// It returns ErrNotAllowed if the client is not the admin.
 func (c *Client) ErrorLog(ctx context.Context) (*ErrorStream, error) {
 	const (
 		APIPath  = "/v1/log/error"
 		Method   = http.MethodGet
 		StatusOK = http.StatusOK
 	)
-	client := retry(c.HTTPClient)
+	client := c.retry()
 	resp, err := client.Send(ctx, Method, c.Endpoints, APIPath, nil)
 	if err != nil {
 		return nil, err
@@ -504,7 +1858,7 @@ func (c *Client) Metrics(ctx context.Context) (Metric, error) {
 		StatusOK       = http.StatusOK
 		MaxResponeSize = 1 << 20 // 1 MB
 	)
-	client := retry(c.HTTPClient)
+	client := c.retry()
 	resp, err := client.Send(ctx, Method, c.Endpoints, APIPath, nil)
 	if err != nil {
 		return Metric{}, err
@@ -519,7 +1873,10 @@ func (c *Client) Metrics(ctx context.Context) (Metric, error) {
 		MetricRequestErr        = "kes_http_request_error"
 		MetricRequestFail       = "kes_http_request_failure"
 		MetricRequestActive     = "kes_http_request_active"
+		MetricConnections       = "kes_http_connections"
 		MetricAuditEvents       = "kes_log_audit_events"
+		MetricAuditDelivered    = "kes_log_audit_events_delivered"
+		MetricAuditDropped      = "kes_log_audit_events_dropped"
 		MetricErrorEvents       = "kes_log_error_events"
 		MetricResponseTime      = "kes_http_response_time"
 		MetricSystemUpTme       = "kes_system_up_time"
@@ -562,8 +1919,14 @@ func (c *Client) Metrics(ctx context.Context) (Metric, error) {
 			metric.RequestFail = uint64(rawMetric.GetCounter().GetValue())
 		case kind == dto.MetricType_GAUGE && name == MetricRequestActive:
 			metric.RequestActive = uint64(rawMetric.GetGauge().GetValue())
+		case kind == dto.MetricType_GAUGE && name == MetricConnections:
+			metric.Connections = uint64(rawMetric.GetGauge().GetValue())
 		case kind == dto.MetricType_COUNTER && name == MetricAuditEvents:
 			metric.AuditEvents = uint64(rawMetric.GetCounter().GetValue())
+		case kind == dto.MetricType_COUNTER && name == MetricAuditDelivered:
+			metric.AuditEventsDelivered = uint64(rawMetric.GetCounter().GetValue())
+		case kind == dto.MetricType_COUNTER && name == MetricAuditDropped:
+			metric.AuditEventsDropped = uint64(rawMetric.GetCounter().GetValue())
 		case kind == dto.MetricType_COUNTER && name == MetricErrorEvents:
 			metric.ErrorEvents = uint64(rawMetric.GetCounter().GetValue())
 		case kind == dto.MetricType_HISTOGRAM && name == MetricResponseTime:
@@ -596,6 +1959,191 @@ func (c *Client) Metrics(ctx context.Context) (Metric, error) {
 	return metric, nil
 }
 
+// retry returns a retry client that sends requests using the
+// client's HTTP client. Once the client has been closed, any
+// request fails with ErrClientClosed.
+func (c *Client) retry() retry {
+	client := retry{Client: c.HTTPClient, timeouts: c.Timeouts}
+	if atomic.LoadUint32(&c.closed) == 1 {
+		client.Transport = closedTransport{}
+		return client
+	}
+
+	transport := client.Transport
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+	client.Transport = deadlineTransport{transport: transport}
+
+	c.hookLock.RLock()
+	defer c.hookLock.RUnlock()
+	if len(c.onRequest) > 0 || len(c.onResponse) > 0 {
+		transport := client.Transport
+		if transport == nil {
+			transport = http.DefaultTransport
+		}
+		client.Transport = hookTransport{
+			transport:  transport,
+			onRequest:  c.onRequest[:len(c.onRequest):len(c.onRequest)],
+			onResponse: c.onResponse[:len(c.onResponse):len(c.onResponse)],
+		}
+	}
+	if c.breaker != nil {
+		transport := client.Transport
+		if transport == nil {
+			transport = http.DefaultTransport
+		}
+		client.Transport = breakerTransport{
+			transport: transport,
+			breaker:   c.breaker,
+		}
+	}
+	if c.readOnly {
+		transport := client.Transport
+		if transport == nil {
+			transport = http.DefaultTransport
+		}
+		client.Transport = readOnlyTransport{transport: transport}
+	}
+	return client
+}
+
+// cancelBody is an io.ReadCloser that cancels
+// a context once it gets closed.
+type cancelBody struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (b cancelBody) Close() error {
+	defer b.cancel()
+	return b.ReadCloser.Close()
+}
+
+// deadlineTransport is an http.RoundTripper that tells
+// the server how much time is left until the request
+// context expires. The server stops processing requests
+// once the remaining time has elapsed, even if its own
+// timeout for the API has not.
+type deadlineTransport struct {
+	transport http.RoundTripper
+}
+
+func (t deadlineTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	deadline, ok := req.Context().Deadline()
+	if !ok {
+		return t.transport.RoundTrip(req)
+	}
+	if timeout := time.Until(deadline).Truncate(time.Millisecond); timeout > 0 {
+		req = req.Clone(req.Context())
+		req.Header.Set("X-Request-Timeout", timeout.String())
+	}
+	return t.transport.RoundTrip(req)
+}
+
+// hookTransport is an http.RoundTripper that calls
+// request and response hooks around each round trip.
+type hookTransport struct {
+	transport  http.RoundTripper
+	onRequest  []func(*http.Request)
+	onResponse []func(*http.Response)
+}
+
+func (t hookTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if len(t.onRequest) > 0 {
+		req = req.Clone(req.Context()) // A RoundTripper must not modify the request
+		for _, hook := range t.onRequest {
+			hook(req)
+		}
+	}
+	resp, err := t.transport.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	for _, hook := range t.onResponse {
+		hook(resp)
+	}
+	return resp, nil
+}
+
+// OpenConnections returns the number of network connections
+// to the KES server that are currently open. Connections may
+// be in use or idle.
+//
+// OpenConnections only counts connections of clients created
+// via NewClient, NewClientWithConfig or NewClientWithTransportConfig.
+// For other clients, it returns 0.
+func (c *Client) OpenConnections() int { return int(atomic.LoadInt32(&c.conns)) }
+
+// dialContext dials a new connection and adds it
+// to the client's open connections.
+func (c *Client) dialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	conn, err := c.dial(ctx, network, addr)
+	if err != nil {
+		return nil, err
+	}
+	atomic.AddInt32(&c.conns, 1)
+	return &countConn{Conn: conn, counter: &c.conns}, nil
+}
+
+// countConn is a net.Conn that decrements
+// its counter once it gets closed.
+type countConn struct {
+	net.Conn
+
+	once    sync.Once
+	counter *int32
+}
+
+func (c *countConn) Close() error {
+	c.once.Do(func() { atomic.AddInt32(c.counter, -1) })
+	return c.Conn.Close()
+}
+
+// verifyHTTP2 returns a tls.Config.VerifyConnection callback
+// that rejects connections that have not negotiated HTTP/2
+// once the given verify callback, if any, succeeds.
+func verifyHTTP2(verify func(tls.ConnectionState) error) func(tls.ConnectionState) error {
+	return func(state tls.ConnectionState) error {
+		if verify != nil {
+			if err := verify(state); err != nil {
+				return err
+			}
+		}
+		if state.NegotiatedProtocol != "h2" {
+			return errors.New("kes: server does not support HTTP/2")
+		}
+		return nil
+	}
+}
+
+// recordFingerprint returns a tls.Config.VerifyConnection
+// callback that stores the SHA-256 fingerprint of the server
+// certificate in v once the given verify callback, if any,
+// succeeds.
+func recordFingerprint(v *atomic.Value, verify func(tls.ConnectionState) error) func(tls.ConnectionState) error {
+	return func(state tls.ConnectionState) error {
+		if verify != nil {
+			if err := verify(state); err != nil {
+				return err
+			}
+		}
+		if len(state.PeerCertificates) > 0 {
+			fingerprint := sha256.Sum256(state.PeerCertificates[0].Raw)
+			v.Store(hex.EncodeToString(fingerprint[:]))
+		}
+		return nil
+	}
+}
+
+// closedTransport is an http.RoundTripper that
+// rejects all requests with ErrClientClosed.
+type closedTransport struct{}
+
+func (closedTransport) RoundTrip(*http.Request) (*http.Response, error) {
+	return nil, ErrClientClosed
+}
+
 // endpoint returns an endpoint URL starting with the
 // given endpoint followed by the path elements.
 //
@@ -629,3 +2177,32 @@ func limitBody(r *http.Response, maxLen int64) io.Reader {
 	}
 	return io.LimitReader(r.Body, size)
 }
+
+// acceptEncoding is the Accept-Encoding header value
+// sent by list APIs that return potentially large
+// response streams.
+const acceptEncoding = "gzip"
+
+// responseBody returns the body of the given response.
+// If the body has been compressed by the server, the
+// returned io.ReadCloser transparently decompresses it.
+//
+// Closing the returned io.ReadCloser closes the
+// response body.
+func responseBody(r *http.Response) (io.ReadCloser, error) {
+	if !strings.EqualFold(r.Header.Get("Content-Encoding"), "gzip") {
+		return r.Body, nil
+	}
+	gz, err := gzip.NewReader(r.Body)
+	if err != nil {
+		return nil, err
+	}
+	type ReadCloser struct {
+		io.Reader
+		io.Closer
+	}
+	return ReadCloser{
+		Reader: gz,
+		Closer: r.Body,
+	}, nil
+}
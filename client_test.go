@@ -5,7 +5,26 @@
 package kes
 
 import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"io"
+	"log"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
 	"testing"
+	"time"
 )
 
 var endpointTests = []struct {
@@ -46,3 +65,531 @@ func TestEndpoint(t *testing.T) {
 		}
 	}
 }
+
+var newClientWithTransportConfigTests = []struct {
+	Config              *TransportConfig
+	TLSHandshakeTimeout time.Duration
+	IdleConnTimeout     time.Duration
+	MaxIdleConns        int
+	MaxIdleConnsPerHost int
+}{
+	{ // 0
+		Config:              nil,
+		TLSHandshakeTimeout: 10 * time.Second,
+		IdleConnTimeout:     90 * time.Second,
+		MaxIdleConns:        100,
+		MaxIdleConnsPerHost: 0,
+	},
+	{ // 1
+		Config:              &TransportConfig{},
+		TLSHandshakeTimeout: 10 * time.Second,
+		IdleConnTimeout:     90 * time.Second,
+		MaxIdleConns:        100,
+		MaxIdleConnsPerHost: 0,
+	},
+	{ // 2
+		Config: &TransportConfig{
+			DialTimeout:         5 * time.Second,
+			TLSHandshakeTimeout: 3 * time.Second,
+			IdleConnTimeout:     time.Minute,
+			MaxIdleConns:        500,
+			MaxIdleConnsPerHost: 50,
+		},
+		TLSHandshakeTimeout: 3 * time.Second,
+		IdleConnTimeout:     time.Minute,
+		MaxIdleConns:        500,
+		MaxIdleConnsPerHost: 50,
+	},
+}
+
+func TestNewClientWithTransportConfig(t *testing.T) {
+	for i, test := range newClientWithTransportConfigTests {
+		client := NewClientWithTransportConfig("https://127.0.0.1:7373", nil, test.Config)
+		transport, ok := client.HTTPClient.Transport.(*http.Transport)
+		if !ok {
+			t.Fatalf("Test %d: invalid transport type: got '%T' - want '%T'", i, client.HTTPClient.Transport, transport)
+		}
+		if transport.TLSHandshakeTimeout != test.TLSHandshakeTimeout {
+			t.Fatalf("Test %d: TLS handshake timeout mismatch: got '%v' - want '%v'", i, transport.TLSHandshakeTimeout, test.TLSHandshakeTimeout)
+		}
+		if transport.IdleConnTimeout != test.IdleConnTimeout {
+			t.Fatalf("Test %d: idle connection timeout mismatch: got '%v' - want '%v'", i, transport.IdleConnTimeout, test.IdleConnTimeout)
+		}
+		if transport.MaxIdleConns != test.MaxIdleConns {
+			t.Fatalf("Test %d: max. idle connections mismatch: got '%d' - want '%d'", i, transport.MaxIdleConns, test.MaxIdleConns)
+		}
+		if transport.MaxIdleConnsPerHost != test.MaxIdleConnsPerHost {
+			t.Fatalf("Test %d: max. idle connections per host mismatch: got '%d' - want '%d'", i, transport.MaxIdleConnsPerHost, test.MaxIdleConnsPerHost)
+		}
+	}
+}
+
+func TestLoadClientCertificate(t *testing.T) {
+	const Password = "my-password"
+
+	priv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate private key: %v", err)
+	}
+	template := x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "kes-client"},
+		NotBefore:    time.Now(),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	certDER, err := x509.CreateCertificate(rand.Reader, &template, &template, &priv.PublicKey, priv)
+	if err != nil {
+		t.Fatalf("Failed to create certificate: %v", err)
+	}
+	privDER, err := x509.MarshalPKCS8PrivateKey(priv)
+	if err != nil {
+		t.Fatalf("Failed to encode private key: %v", err)
+	}
+	encBlock, err := x509.EncryptPEMBlock(rand.Reader, "PRIVATE KEY", privDER, []byte(Password), x509.PEMCipherAES256)
+	if err != nil {
+		t.Fatalf("Failed to encrypt private key: %v", err)
+	}
+
+	var (
+		dir         = t.TempDir()
+		certFile    = filepath.Join(dir, "client.crt")
+		keyFile     = filepath.Join(dir, "client.key")
+		encKeyFile  = filepath.Join(dir, "client-enc.key")
+		writeToFile = func(filename string, block *pem.Block) {
+			if err := os.WriteFile(filename, pem.EncodeToMemory(block), 0o600); err != nil {
+				t.Fatalf("Failed to write %q: %v", filename, err)
+			}
+		}
+	)
+	writeToFile(certFile, &pem.Block{Type: "CERTIFICATE", Bytes: certDER})
+	writeToFile(keyFile, &pem.Block{Type: "PRIVATE KEY", Bytes: privDER})
+	writeToFile(encKeyFile, encBlock)
+
+	if _, err = LoadClientCertificate(certFile, keyFile, ""); err != nil {
+		t.Fatalf("Failed to load plaintext private key: %v", err)
+	}
+	if _, err = LoadClientCertificate(certFile, keyFile, Password); err != nil {
+		t.Fatalf("Failed to load plaintext private key with password: %v", err)
+	}
+	if _, err = LoadClientCertificate(certFile, encKeyFile, Password); err != nil {
+		t.Fatalf("Failed to load encrypted private key: %v", err)
+	}
+	if _, err = LoadClientCertificate(certFile, encKeyFile, ""); err == nil {
+		t.Fatal("Loading an encrypted private key without a password should fail")
+	}
+	if _, err = LoadClientCertificate(certFile, encKeyFile, "wrong-password"); err == nil {
+		t.Fatal("Loading an encrypted private key with a wrong password should fail")
+	}
+}
+
+func TestClientClose(t *testing.T) {
+	client := NewClient("https://127.0.0.1:7373", tls.Certificate{})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := client.Close(); err != nil {
+				t.Errorf("Failed to close client: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if err := client.Close(); err != nil {
+		t.Fatalf("Failed to close client again: %v", err)
+	}
+	if _, err := client.Version(context.Background()); !errors.Is(err, ErrClientClosed) {
+		t.Fatalf("Request on closed client should fail with '%v' - got '%v'", ErrClientClosed, err)
+	}
+	if err := client.CreateKey(context.Background(), "my-key"); !errors.Is(err, ErrClientClosed) {
+		t.Fatalf("Request on closed client should fail with '%v' - got '%v'", ErrClientClosed, err)
+	}
+}
+
+var contextTooLargeTests = []struct {
+	Plaintext []byte
+	Context   []byte
+	Err       error
+}{
+	{Plaintext: nil, Context: nil, Err: ErrClientClosed},                                          // 0
+	{Plaintext: make([]byte, 512*1024), Context: nil, Err: ErrClientClosed},                       // 1
+	{Plaintext: nil, Context: make([]byte, MaxContextSize), Err: ErrContextTooLarge},              // 2
+	{Plaintext: make([]byte, 512*1024), Context: make([]byte, 512*1024), Err: ErrContextTooLarge}, // 3
+}
+
+func TestContextTooLarge(t *testing.T) {
+	client := NewClient("https://127.0.0.1:7373", tls.Certificate{})
+	client.Close() // Any request that gets sent fails with ErrClientClosed
+
+	for i, test := range contextTooLargeTests {
+		if _, err := client.Encrypt(context.Background(), "my-key", test.Plaintext, test.Context); !errors.Is(err, test.Err) {
+			t.Fatalf("Test %d: encrypt: got '%v' - want '%v'", i, err, test.Err)
+		}
+		if _, err := client.Decrypt(context.Background(), "my-key", test.Plaintext, test.Context); !errors.Is(err, test.Err) {
+			t.Fatalf("Test %d: decrypt: got '%v' - want '%v'", i, err, test.Err)
+		}
+		if test.Plaintext == nil {
+			if _, err := client.GenerateKey(context.Background(), "my-key", test.Context); !errors.Is(err, test.Err) {
+				t.Fatalf("Test %d: generate: got '%v' - want '%v'", i, err, test.Err)
+			}
+		}
+	}
+}
+
+var maxRequestSizeTests = []struct {
+	MaxRequestSize int64
+	Plaintext      []byte
+	Err            error
+}{
+	{MaxRequestSize: 0, Plaintext: make([]byte, 512), Err: ErrClientClosed},                             // 0
+	{MaxRequestSize: 1024, Plaintext: make([]byte, 512), Err: ErrClientClosed},                          // 1
+	{MaxRequestSize: 1024, Plaintext: make([]byte, 1024), Err: ErrContextTooLarge},                      // 2
+	{MaxRequestSize: 2 * MaxContextSize, Plaintext: make([]byte, MaxContextSize), Err: ErrClientClosed}, // 3
+}
+
+func TestMaxRequestSize(t *testing.T) {
+	client := NewClient("https://127.0.0.1:7373", tls.Certificate{})
+	client.Close() // Any request that gets sent fails with ErrClientClosed
+
+	for i, test := range maxRequestSizeTests {
+		client.MaxRequestSize = test.MaxRequestSize
+
+		_, err := client.Encrypt(context.Background(), "my-key", test.Plaintext, nil)
+		if !errors.Is(err, test.Err) {
+			t.Fatalf("Test %d: encrypt: got '%v' - want '%v'", i, err, test.Err)
+		}
+		if test.Err == ErrContextTooLarge {
+			var sizeErr *RequestTooLargeError
+			if !errors.As(err, &sizeErr) {
+				t.Fatalf("Test %d: encrypt: got '%v' - want a RequestTooLargeError", i, err)
+			}
+			if sizeErr.Limit != test.MaxRequestSize || sizeErr.Size <= test.MaxRequestSize {
+				t.Fatalf("Test %d: invalid RequestTooLargeError: got %d bytes with limit %d", i, sizeErr.Size, sizeErr.Limit)
+			}
+		}
+		if _, err = client.Enclave("my-enclave").Encrypt(context.Background(), "my-key", test.Plaintext, nil); !errors.Is(err, test.Err) {
+			t.Fatalf("Test %d: enclave encrypt: got '%v' - want '%v'", i, err, test.Err)
+		}
+	}
+}
+
+func TestUnixSocketEndpoint(t *testing.T) {
+	socket := filepath.Join(t.TempDir(), "kes.sock")
+	listener, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Skipf("Unix domain sockets are not supported: %v", err)
+	}
+
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"version":"v0.0.0-dev"}`))
+	}))
+	server.Listener = listener
+	server.StartTLS()
+	defer server.Close()
+
+	rootCAs := x509.NewCertPool()
+	rootCAs.AddCert(server.Certificate())
+	client := NewClientWithConfig("unix://"+socket, &tls.Config{
+		RootCAs:    rootCAs,
+		ServerName: "example.com", // The httptest server certificate is valid for example.com
+	})
+	if len(client.Endpoints) != 1 || client.Endpoints[0] != "https://example.com" {
+		t.Fatalf("Invalid client endpoints: got %v - want '[https://example.com]'", client.Endpoints)
+	}
+
+	version, err := client.Version(context.Background())
+	if err != nil {
+		t.Fatalf("Failed to fetch server version over unix socket: %v", err)
+	}
+	if version != "v0.0.0-dev" {
+		t.Fatalf("Invalid server version: got '%s' - want '%s'", version, "v0.0.0-dev")
+	}
+}
+
+var protocolTests = []struct {
+	Protocol    Protocol
+	ServerHTTP2 bool
+	ProtoMajor  int
+	ShouldFail  bool
+}{
+	{Protocol: "", ServerHTTP2: true, ProtoMajor: 2},        // 0
+	{Protocol: "", ServerHTTP2: false, ProtoMajor: 1},       // 1
+	{Protocol: HTTP2, ServerHTTP2: true, ProtoMajor: 2},     // 2
+	{Protocol: HTTP2, ServerHTTP2: false, ShouldFail: true}, // 3
+	{Protocol: HTTP1, ServerHTTP2: true, ProtoMajor: 1},     // 4
+	{Protocol: HTTP1, ServerHTTP2: false, ProtoMajor: 1},    // 5
+}
+
+func TestProtocol(t *testing.T) {
+	for i, test := range protocolTests {
+		server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(`{"version":"v0.0.0-dev"}`))
+		}))
+		server.EnableHTTP2 = test.ServerHTTP2
+		server.Config.ErrorLog = log.New(io.Discard, "", 0) // Ignore the handshake errors of test 3
+		server.StartTLS()
+
+		rootCAs := x509.NewCertPool()
+		rootCAs.AddCert(server.Certificate())
+		client := NewClientWithTransportConfig(server.URL, &tls.Config{RootCAs: rootCAs}, &TransportConfig{
+			Protocol: test.Protocol,
+		})
+		var protoMajor int
+		client.OnResponse(func(resp *http.Response) { protoMajor = resp.ProtoMajor })
+
+		_, err := client.Version(context.Background())
+		if err == nil && test.ShouldFail {
+			t.Fatalf("Test %d: should fail but succeeded", i)
+		}
+		if err != nil && !test.ShouldFail {
+			t.Fatalf("Test %d: failed to fetch server version: %v", i, err)
+		}
+		if !test.ShouldFail {
+			if protoMajor != test.ProtoMajor {
+				t.Fatalf("Test %d: invalid protocol: got 'HTTP/%d' - want 'HTTP/%d'", i, protoMajor, test.ProtoMajor)
+			}
+			if n := client.OpenConnections(); n != 1 {
+				t.Fatalf("Test %d: invalid number of open connections: got '%d' - want '1'", i, n)
+			}
+		}
+		client.Close()
+		server.Close()
+	}
+}
+
+func TestHealthStreamDisconnect(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		w.Write([]byte(`{"time":"2022-06-03T10:00:00Z","sealed":false,"store":"available"}` + "\n"))
+	}))
+	defer server.Close()
+
+	rootCAs := x509.NewCertPool()
+	rootCAs.AddCert(server.Certificate())
+	client := NewClientWithConfig(server.URL, &tls.Config{RootCAs: rootCAs})
+	defer client.Close()
+
+	events, err := client.HealthStream(context.Background())
+	if err != nil {
+		t.Fatalf("Failed to subscribe to health stream: %v", err)
+	}
+	if event := <-events; event.Err != nil || event.StoreState != "available" {
+		t.Fatalf("Invalid health event: %+v", event)
+	}
+	if event := <-events; event.Err == nil {
+		t.Fatalf("Losing the connection should send an error event: got %+v", event)
+	}
+	if _, ok := <-events; ok {
+		t.Fatal("Health stream should be closed after the connection has been lost")
+	}
+}
+
+func TestVaultStatusEnclaveError(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"":{"sealed":false,"key_count":2,"state":"available"},"tenant-1":{"sealed":false,"state":"unreachable","error":"connection refused"}}`))
+	}))
+	defer server.Close()
+
+	rootCAs := x509.NewCertPool()
+	rootCAs.AddCert(server.Certificate())
+	client := NewClientWithConfig(server.URL, &tls.Config{RootCAs: rootCAs})
+	defer client.Close()
+
+	status, err := client.VaultStatus(context.Background())
+	if err != nil {
+		t.Fatalf("Failed to fetch vault status: %v", err)
+	}
+	if enclave := status[""]; enclave.Err != nil || enclave.KeyCount != 2 {
+		t.Fatalf("Invalid status of default enclave: %+v", enclave)
+	}
+	if enclave := status["tenant-1"]; enclave.Err == nil || enclave.StoreState != "unreachable" {
+		t.Fatalf("Invalid status of unreachable enclave: %+v", enclave)
+	}
+}
+
+func TestDescribeEnclaveError(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"name":"tenant-1","sealed":false,"key_count":0,"store_state":"unreachable","error":"connection refused"}`))
+	}))
+	defer server.Close()
+
+	rootCAs := x509.NewCertPool()
+	rootCAs.AddCert(server.Certificate())
+	client := NewClientWithConfig(server.URL, &tls.Config{RootCAs: rootCAs})
+	defer client.Close()
+
+	info, err := client.DescribeEnclave(context.Background(), "tenant-1")
+	if err != nil {
+		t.Fatalf("Failed to describe enclave: %v", err)
+	}
+	if info.Err == nil || info.Err.Error() != "connection refused" || info.StoreState != "unreachable" {
+		t.Fatalf("Invalid info of unreachable enclave: %+v", info)
+	}
+}
+
+func TestDescribeSelfPolicy(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"identity":"a4d8fbf7f4e84f6f1bfd2a6a7c2c4f6e3e0a7d4e7f4e4a0b8b4a3c2d1e0f9a8b","policy_name":"my-policy","policy":{"Allow":["/v1/key/create/*"],"Deny":["/v1/key/delete/*"]}}`))
+	}))
+	defer server.Close()
+
+	rootCAs := x509.NewCertPool()
+	rootCAs.AddCert(server.Certificate())
+	client := NewClientWithConfig(server.URL, &tls.Config{RootCAs: rootCAs})
+	defer client.Close()
+
+	info, policy, err := client.DescribeSelf(context.Background())
+	if err != nil {
+		t.Fatalf("Failed to describe identity: %v", err)
+	}
+	if len(policy.Allow) != 1 || policy.Allow[0] != "/v1/key/create/*" || len(policy.Deny) != 1 || policy.Deny[0] != "/v1/key/delete/*" {
+		t.Fatalf("Invalid policy: got allow '%v' and deny '%v'", policy.Allow, policy.Deny)
+	}
+	if len(info.Allow) != 1 || len(info.Deny) != 1 {
+		t.Fatalf("Invalid identity info: got allow '%v' and deny '%v'", info.Allow, info.Deny)
+	}
+}
+
+func TestDeleteIdentitiesByPolicyPartialFailure(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"deleted":2,"error":"key store is not reachable"}`))
+	}))
+	defer server.Close()
+
+	rootCAs := x509.NewCertPool()
+	rootCAs.AddCert(server.Certificate())
+	client := NewClientWithConfig(server.URL, &tls.Config{RootCAs: rootCAs})
+	defer client.Close()
+
+	n, err := client.DeleteIdentitiesByPolicy(context.Background(), "my-policy")
+	if err == nil {
+		t.Fatal("Deleting identities should have failed")
+	}
+	if n != 2 {
+		t.Fatalf("Invalid number of deleted identities: got '%d' - want '%d'", n, 2)
+	}
+}
+
+var retryTimeoutTests = []struct {
+	Path    string
+	Timeout time.Duration
+}{
+	{Path: "/version", Timeout: 0},                                          // 0
+	{Path: "/v1/key/decrypt/my-key", Timeout: time.Second},                  // 1
+	{Path: "/v1/key/bulk/decrypt/my-key", Timeout: 0},                       // 2
+	{Path: "/v1/key/list/*", Timeout: time.Minute},                          // 3
+	{Path: "/v1/key/create/my-key", Timeout: time.Minute},                   // 4
+	{Path: "/v1/key/decrypt/my-key?enclave=tenant-1", Timeout: time.Second}, // 5
+}
+
+func TestRetryTimeout(t *testing.T) {
+	timeouts := map[string]time.Duration{
+		"/v1/key/decrypt": time.Second,
+		"/v1/key":         time.Minute,
+		"/v1/key/bulk":    0,
+	}
+	for i, test := range retryTimeoutTests {
+		var deadline time.Time
+		var hasDeadline bool
+		client := retry{
+			Client: http.Client{
+				Transport: roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+					deadline, hasDeadline = req.Context().Deadline()
+					return &http.Response{StatusCode: http.StatusOK, Body: http.NoBody}, nil
+				}),
+			},
+			timeouts: timeouts,
+		}
+		resp, err := client.Send(context.Background(), http.MethodGet, []string{"https://127.0.0.1:7373"}, test.Path, nil)
+		if err != nil {
+			t.Fatalf("Test %d: request failed: %v", i, err)
+		}
+		resp.Body.Close()
+
+		if test.Timeout == 0 && hasDeadline {
+			t.Fatalf("Test %d: request has a deadline but no timeout is set", i)
+		}
+		if test.Timeout > 0 {
+			if !hasDeadline {
+				t.Fatalf("Test %d: request has no deadline", i)
+			}
+			if d := time.Until(deadline); d > test.Timeout || d < test.Timeout-10*time.Second {
+				t.Fatalf("Test %d: invalid deadline: got %v - want %v", i, d, test.Timeout)
+			}
+		}
+	}
+}
+
+func TestRetryTimeoutNotPerAttempt(t *testing.T) {
+	const Timeout = 200 * time.Millisecond
+
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select { // Never respond in time
+		case <-r.Context().Done():
+		case <-time.After(5 * time.Second):
+		}
+	}))
+	defer server.Close()
+
+	rootCAs := x509.NewCertPool()
+	rootCAs.AddCert(server.Certificate())
+	client := NewClientWithConfig("", &tls.Config{RootCAs: rootCAs})
+	client.Endpoints = []string{server.URL, server.URL}
+	client.Timeouts = map[string]time.Duration{"/v1/key/create": Timeout}
+	defer client.Close()
+
+	start := time.Now()
+	if err := client.CreateKey(context.Background(), "my-key"); err == nil {
+		t.Fatal("Request should have timed out")
+	}
+	if elapsed := time.Since(start); elapsed > Timeout+500*time.Millisecond {
+		t.Fatalf("Request took too long: got %v - want at most %v", elapsed, Timeout+500*time.Millisecond)
+	}
+}
+
+func TestDeadlineTransport(t *testing.T) {
+	var header string
+	transport := deadlineTransport{
+		transport: roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			header = req.Header.Get("X-Request-Timeout")
+			return &http.Response{StatusCode: http.StatusOK, Body: http.NoBody}, nil
+		}),
+	}
+
+	req, err := http.NewRequest(http.MethodGet, "https://127.0.0.1:7373/v1/key/decrypt/my-key", nil)
+	if err != nil {
+		t.Fatalf("Failed to create request: %v", err)
+	}
+	if _, err = transport.RoundTrip(req); err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	if header != "" {
+		t.Fatalf("Request without deadline has a request timeout: %s", header)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if _, err = transport.RoundTrip(req.WithContext(ctx)); err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	timeout, err := time.ParseDuration(header)
+	if err != nil {
+		t.Fatalf("Invalid request timeout %q: %v", header, err)
+	}
+	if timeout <= 0 || timeout > 5*time.Second {
+		t.Fatalf("Invalid request timeout: got %v - want at most %v", timeout, 5*time.Second)
+	}
+	if req.Header.Get("X-Request-Timeout") != "" {
+		t.Fatal("Original request has been modified")
+	}
+}
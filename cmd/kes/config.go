@@ -378,7 +378,7 @@ var _ auth.IdentitySet = (*identitySet)(nil) // compiler check
 
 func (i *identitySet) Admin(ctx context.Context) (kes.Identity, error) { return i.admin, nil }
 
-func (i *identitySet) Assign(_ context.Context, policy string, identity kes.Identity) error {
+func (i *identitySet) Assign(_ context.Context, policy string, identity kes.Identity, expiresAt time.Time) error {
 	if i.admin == identity {
 		return kes.NewError(http.StatusBadRequest, "identity is root")
 	}
@@ -389,6 +389,8 @@ func (i *identitySet) Assign(_ context.Context, policy string, identity kes.Iden
 		Policy:    policy,
 		CreatedAt: time.Now().UTC(),
 		CreatedBy: i.admin,
+		ExpiresAt: expiresAt,
+		Alias:     i.roles[identity].Alias,
 	}
 	return nil
 }
@@ -410,6 +412,29 @@ func (i *identitySet) Get(_ context.Context, identity kes.Identity) (auth.Identi
 	return policy, nil
 }
 
+func (i *identitySet) SetAlias(_ context.Context, identity kes.Identity, alias string) error {
+	if i.admin == identity {
+		return kes.NewError(http.StatusBadRequest, "identity is root")
+	}
+	i.lock.Lock()
+	defer i.lock.Unlock()
+
+	info, ok := i.roles[identity]
+	if !ok {
+		return auth.ErrIdentityNotFound
+	}
+	if alias != "" {
+		for id, role := range i.roles {
+			if id != identity && role.Alias == alias {
+				return auth.ErrAliasExists
+			}
+		}
+	}
+	info.Alias = alias
+	i.roles[identity] = info
+	return nil
+}
+
 func (i *identitySet) Delete(_ context.Context, identity kes.Identity) error {
 	i.lock.Lock()
 	defer i.lock.Unlock()
@@ -0,0 +1,197 @@
+// Copyright 2022 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/fatih/color"
+	"github.com/minio/kes/internal/cli"
+	"github.com/minio/kes/internal/fips"
+	flag "github.com/spf13/pflag"
+)
+
+const fipsCmdUsage = `Usage:
+    kes fips <command>
+
+Commands:
+    check                    Run FIPS self-tests.
+
+Options:
+    -h, --help               Print command line options.
+`
+
+func fipsCmd(args []string) {
+	cmd := flag.NewFlagSet(args[0], flag.ContinueOnError)
+	cmd.Usage = func() { fmt.Fprint(os.Stderr, fipsCmdUsage) }
+
+	subCmds := commands{
+		"check": checkFIPSCmd,
+	}
+	if len(args) < 2 {
+		cmd.Usage()
+		os.Exit(2)
+	}
+	if cmd, ok := subCmds[args[1]]; ok {
+		cmd(args[1:])
+		return
+	}
+
+	if err := cmd.Parse(args[1:]); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			os.Exit(2)
+		}
+		cli.Fatalf("%v. See 'kes fips --help'", err)
+	}
+	if cmd.NArg() > 0 {
+		cli.Fatalf("%q is not a fips command. See 'kes fips --help'", cmd.Arg(0))
+	}
+	cmd.Usage()
+	os.Exit(2)
+}
+
+const checkFIPSCmdUsage = `Usage:
+    kes fips check
+
+Runs self-tests of the cryptographic primitives a KES binary
+uses in FIPS mode:
+  - FIPS mode:    the binary has been built in FIPS mode.
+  - AES-256-GCM:  known-answer test for en/decryption.
+  - P-256:        pairwise consistency test of the key
+                  generation used by 'kes identity new'.
+
+It exits with a non-zero status code if any test fails.
+
+Options:
+    -h, --help               Print command line options.
+
+Examples:
+    $ kes fips check
+`
+
+func checkFIPSCmd(args []string) {
+	cmd := flag.NewFlagSet(args[0], flag.ContinueOnError)
+	cmd.Usage = func() { fmt.Fprint(os.Stderr, checkFIPSCmdUsage) }
+	if err := cmd.Parse(args[1:]); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			os.Exit(2)
+		}
+		cli.Fatalf("%v. See 'kes fips check --help'", err)
+	}
+	if cmd.NArg() > 0 {
+		cli.Fatal("too many arguments. See 'kes fips check --help'")
+	}
+
+	tests := []struct {
+		Name string
+		Run  func() error
+	}{
+		{Name: "FIPS mode", Run: checkFIPSMode},
+		{Name: "AES-256-GCM", Run: checkAESGCM},
+		{Name: "P-256", Run: checkP256},
+	}
+	var failed int
+	for _, test := range tests {
+		if err := test.Run(); err != nil {
+			failed++
+			fmt.Printf("%-14s %s: %v\n", test.Name, color.RedString("FAIL"), err)
+		} else {
+			fmt.Printf("%-14s %s\n", test.Name, color.GreenString("PASS"))
+		}
+	}
+	if failed > 0 {
+		cli.Fatalf("%d of %d FIPS self-tests failed", failed, len(tests))
+	}
+}
+
+// checkFIPSMode returns an error if the binary
+// has not been built in FIPS mode.
+func checkFIPSMode() error {
+	if !fips.Enabled {
+		return errors.New("binary has not been built in FIPS mode")
+	}
+	return nil
+}
+
+// checkAESGCM runs a known-answer test for AES-256-GCM
+// using test case 14 of the GCM specification.
+func checkAESGCM() error {
+	var (
+		key        = make([]byte, 32)
+		nonce      = make([]byte, 12)
+		plaintext  = make([]byte, 16)
+		ciphertext = mustDecodeHex("cea7403d4d606b6e074ec5d3baf39d18" + "d0d1c8a799996bf0265b98b5d48ab919")
+	)
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return err
+	}
+	if sealed := aead.Seal(nil, nonce, plaintext, nil); !bytes.Equal(sealed, ciphertext) {
+		return errors.New("encryption: ciphertext does not match the expected value")
+	}
+	opened, err := aead.Open(nil, nonce, ciphertext, nil)
+	if err != nil {
+		return fmt.Errorf("decryption: %v", err)
+	}
+	if !bytes.Equal(opened, plaintext) {
+		return errors.New("decryption: plaintext does not match the expected value")
+	}
+
+	tampered := append([]byte(nil), ciphertext...)
+	tampered[0] ^= 1
+	if _, err = aead.Open(nil, nonce, tampered, nil); err == nil {
+		return errors.New("decryption: modified ciphertext has not been rejected")
+	}
+	return nil
+}
+
+// checkP256 generates a P-256 key pair, like 'kes identity new',
+// and verifies that a signature created with the private key can
+// be verified with the public key.
+func checkP256() error {
+	privateKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return fmt.Errorf("key generation: %v", err)
+	}
+	if !privateKey.Curve.IsOnCurve(privateKey.X, privateKey.Y) {
+		return errors.New("key generation: public key is not on the curve")
+	}
+
+	digest := sha256.Sum256([]byte("kes fips check"))
+	signature, err := ecdsa.SignASN1(rand.Reader, privateKey, digest[:])
+	if err != nil {
+		return fmt.Errorf("signing: %v", err)
+	}
+	if !ecdsa.VerifyASN1(&privateKey.PublicKey, digest[:], signature) {
+		return errors.New("verification: valid signature has been rejected")
+	}
+	digest[0] ^= 1
+	if ecdsa.VerifyASN1(&privateKey.PublicKey, digest[:], signature) {
+		return errors.New("verification: invalid signature has been accepted")
+	}
+	return nil
+}
+
+func mustDecodeHex(s string) []byte {
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		panic(err)
+	}
+	return b
+}
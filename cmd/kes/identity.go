@@ -12,6 +12,7 @@ import (
 	"crypto/ed25519"
 	"crypto/elliptic"
 	"crypto/rand"
+	"crypto/rsa"
 	"crypto/sha256"
 	"crypto/x509"
 	"crypto/x509/pkix"
@@ -26,6 +27,7 @@ import (
 	"os/signal"
 	"sort"
 	"strings"
+	"text/tabwriter"
 	"time"
 
 	"github.com/minio/kes"
@@ -44,6 +46,7 @@ Commands:
     of                       Compute a KES identity
     ls                       List KES identities
     rm                       Remove a KES identity
+    whoami                   Describe the current KES identity
 
 Options:
     -h, --help               Print command line options
@@ -54,10 +57,11 @@ func identityCmd(args []string) {
 	cmd.Usage = func() { fmt.Fprint(os.Stderr, identityCmdUsage) }
 
 	subCmds := commands{
-		"new": newIdentityCmd,
-		"of":  ofIdentityCmd,
-		"ls":  lsIdentityCmd,
-		"rm":  rmIdentityCmd,
+		"new":    newIdentityCmd,
+		"of":     ofIdentityCmd,
+		"ls":     lsIdentityCmd,
+		"rm":     rmIdentityCmd,
+		"whoami": whoamiIdentityCmd,
 	}
 
 	if len(args) < 2 {
@@ -93,6 +97,8 @@ Options:
     --ip <IP>                Add <IP> as subject alternative name. (SAN)
     --dns <DOMAIN>           Add <DOMAIN> as subject alternative name. (SAN)
     --expiry <DURATION>      Duration until the certificate expires. (default: 720h)
+    --key-type <TYPE>        Private key type: ed25519, p256, p384 or rsa2048.
+                             (default: ed25519 or p256 in FIPS mode)
     --encrypt                Encrypt the private key with a password.
 
     -h, --help               Print command line options.
@@ -101,6 +107,7 @@ Examples:
     $ kes identity new Client-1
     $ kes identity new --ip "192.168.0.182" --ip "10.0.0.92" Client-1
     $ kes identity new --key client1.key --cert client1.key --encrypt Client-1
+    $ kes identity new --key-type p384 Client-1
 `
 
 func newIdentityCmd(args []string) {
@@ -114,6 +121,7 @@ func newIdentityCmd(args []string) {
 		IPs       []net.IP
 		domains   []string
 		expiry    time.Duration
+		keyType   string
 		encrypt   bool
 	)
 	cmd.StringVar(&keyPath, "key", "private.key", "Path to private key")
@@ -122,6 +130,7 @@ func newIdentityCmd(args []string) {
 	cmd.IPSliceVar(&IPs, "ip", []net.IP{}, "Add <IP> as subject alternative name")
 	cmd.StringSliceVar(&domains, "dns", []string{}, "Add <DOMAIN> as subject alternative name")
 	cmd.DurationVar(&expiry, "expiry", 720*time.Hour, "Duration until the certificate expires")
+	cmd.StringVar(&keyType, "key-type", "", "Private key type: ed25519, p256, p384 or rsa2048")
 	cmd.BoolVar(&encrypt, "encrypt", false, "Encrypt the private key with a password")
 	if err := cmd.Parse(args[1:]); err != nil {
 		if errors.Is(err, flag.ErrHelp) {
@@ -136,23 +145,49 @@ func newIdentityCmd(args []string) {
 		cli.Fatal("too many arguments. See 'kes identity new --help'")
 	}
 
+	if keyType == "" {
+		keyType = "ed25519"
+		if fips.Enabled {
+			keyType = "p256"
+		}
+	}
+	keyType = strings.ToLower(keyType)
+	if keyType == "ed25519" && fips.Enabled {
+		cli.Fatal("invalid key type: ed25519 is not supported in FIPS mode. See 'kes identity new --help'")
+	}
+
 	var (
 		subject    = cmd.Arg(0)
 		publicKey  crypto.PublicKey
 		privateKey crypto.PrivateKey
+		keyUsage   = x509.KeyUsageDigitalSignature
 	)
-	if fips.Enabled {
-		private, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	switch keyType {
+	case "ed25519":
+		public, private, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			cli.Fatalf("failed to generate private key: %v", err)
+		}
+		publicKey, privateKey = public, private
+	case "p256", "p384":
+		curve := elliptic.P256()
+		if keyType == "p384" {
+			curve = elliptic.P384()
+		}
+		private, err := ecdsa.GenerateKey(curve, rand.Reader)
 		if err != nil {
 			cli.Fatalf("failed to generate private key: %v", err)
 		}
 		publicKey, privateKey = private.Public(), private
-	} else {
-		public, private, err := ed25519.GenerateKey(rand.Reader)
+	case "rsa2048":
+		private, err := rsa.GenerateKey(rand.Reader, 2048)
 		if err != nil {
 			cli.Fatalf("failed to generate private key: %v", err)
 		}
-		publicKey, privateKey = public, private
+		publicKey, privateKey = private.Public(), private
+		keyUsage |= x509.KeyUsageKeyEncipherment
+	default:
+		cli.Fatalf("invalid key type %q. See 'kes identity new --help'", keyType)
 	}
 
 	serialNumberLimit := new(big.Int).Lsh(big.NewInt(1), 128)
@@ -167,7 +202,7 @@ func newIdentityCmd(args []string) {
 		},
 		NotBefore: time.Now().UTC(),
 		NotAfter:  time.Now().UTC().Add(expiry),
-		KeyUsage:  x509.KeyUsageDigitalSignature,
+		KeyUsage:  keyUsage,
 		ExtKeyUsage: []x509.ExtKeyUsage{
 			x509.ExtKeyUsageServerAuth,
 			x509.ExtKeyUsageClientAuth,
@@ -244,20 +279,24 @@ func newIdentityCmd(args []string) {
 }
 
 const ofIdentityCmdUsage = `Usage:
-    kes identity of <certificate>...
+    kes identity of [options] <certificate>...
 
 Options:
+    --csr                    Compute the identity of certificate requests.
     -h, --help               Print command line options.
 
 Examples:
     $ kes identity of client.crt
     $ kes identity of client1.crt client2.crt
+    $ kes identity of --csr client.csr
 `
 
 func ofIdentityCmd(args []string) {
 	cmd := flag.NewFlagSet(args[0], flag.ContinueOnError)
 	cmd.Usage = func() { fmt.Fprint(os.Stderr, ofIdentityCmdUsage) }
 
+	var csrFlag bool
+	cmd.BoolVar(&csrFlag, "csr", false, "Compute the identity of certificate requests")
 	if err := cmd.Parse(args[1:]); err != nil {
 		if errors.Is(err, flag.ErrHelp) {
 			os.Exit(2)
@@ -273,6 +312,23 @@ func ofIdentityCmd(args []string) {
 		if err != nil {
 			return "", err
 		}
+		if csrFlag {
+			pemBlock, err = xhttp.FilterPEM(pemBlock, func(b *pem.Block) bool { return b.Type == "CERTIFICATE REQUEST" })
+			if err != nil {
+				return "", fmt.Errorf("failed to parse certificate request in %q: %v", filename, err)
+			}
+			next, _ := pem.Decode(pemBlock)
+			if next == nil {
+				return "", fmt.Errorf("failed to parse certificate request in %q: no PEM-encoded certificate request found", filename)
+			}
+			csr, err := x509.ParseCertificateRequest(next.Bytes)
+			if err != nil {
+				return "", fmt.Errorf("failed to parse certificate request in %q: %v", filename, err)
+			}
+			identity := sha256.Sum256(csr.RawSubjectPublicKeyInfo)
+			return kes.Identity(hex.EncodeToString(identity[:])), nil
+		}
+
 		pemBlock, err = xhttp.FilterPEM(pemBlock, func(b *pem.Block) bool { return b.Type == "CERTIFICATE" })
 		if err != nil {
 			return "", fmt.Errorf("failed to parse certificate in %q: %v", filename, err)
@@ -329,19 +385,27 @@ const lsIdentityCmdUsage = `Usage:
     kes identity ls [options] [<pattern>]
 
 Options:
+    -o, --output <format>    Print output in the given format: json, table
+                             or text. By default, text when attached to a
+                             terminal and json otherwise.
     -k, --insecure           Skip TLS certificate validation.
     -h, --help               Print command line options.
 
 Examples:
     $ kes identity ls
     $ kes identity ls 'b804befd*'
+    $ kes identity ls --output table
 `
 
 func lsIdentityCmd(args []string) {
 	cmd := flag.NewFlagSet(args[0], flag.ContinueOnError)
 	cmd.Usage = func() { fmt.Fprint(os.Stderr, lsIdentityCmdUsage) }
 
-	var insecureSkipVerify bool
+	var (
+		outputFlag         string
+		insecureSkipVerify bool
+	)
+	cmd.StringVarP(&outputFlag, "output", "o", "", "Print output in the given format")
 	cmd.BoolVarP(&insecureSkipVerify, "insecure", "k", false, "Skip TLS certificate validation")
 	if err := cmd.Parse(args[1:]); err != nil {
 		if errors.Is(err, flag.ErrHelp) {
@@ -353,6 +417,10 @@ func lsIdentityCmd(args []string) {
 	if cmd.NArg() > 1 {
 		cli.Fatal("too many arguments. See 'kes identity ls --help'")
 	}
+	output, err := parseOutput(outputFlag, outputJSON, outputTable, outputText)
+	if err != nil {
+		cli.Fatalf("%v. See 'kes identity ls --help'", err)
+	}
 
 	pattern := "*"
 	if cmd.NArg() == 1 {
@@ -373,28 +441,48 @@ func lsIdentityCmd(args []string) {
 	}
 	defer identities.Close()
 
-	if isTerm(os.Stdout) {
-		sorted := make([]kes.IdentityInfo, 0, 100)
-		for identities.Next() {
-			sorted = append(sorted, identities.Value())
+	if output == outputJSON {
+		if _, err = identities.WriteTo(os.Stdout); err != nil {
+			cli.Fatal(err)
 		}
 		if err = identities.Close(); err != nil {
 			cli.Fatalf("failed to list identities: %v", err)
 		}
-		sort.Slice(sorted, func(i, j int) bool {
-			return strings.Compare(sorted[i].Identity.String(), sorted[j].Identity.String()) < 0
-		})
+		return
+	}
 
+	sorted := make([]kes.IdentityInfo, 0, 100)
+	for identities.Next() {
+		sorted = append(sorted, identities.Value())
+	}
+	if err = identities.Close(); err != nil {
+		cli.Fatalf("failed to list identities: %v", err)
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		return strings.Compare(sorted[i].Identity.String(), sorted[j].Identity.String()) < 0
+	})
+
+	if output == outputTable {
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "IDENTITY\tALIAS\tPOLICY\tADMIN\tCREATED AT")
 		for _, id := range sorted {
-			fmt.Printf("%s => %s\n", id.Identity, id.Policy)
+			var createdAt string
+			if !id.CreatedAt.IsZero() {
+				createdAt = id.CreatedAt.Local().Format(time.RFC3339)
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%t\t%s\n", id.Identity, id.Alias, id.Policy, id.IsAdmin, createdAt)
 		}
-	} else {
-		if _, err = identities.WriteTo(os.Stdout); err != nil {
+		if err = w.Flush(); err != nil {
 			cli.Fatal(err)
 		}
+		return
 	}
-	if err = identities.Close(); err != nil {
-		cli.Fatalf("failed to list identities: %v", err)
+	for _, id := range sorted {
+		if id.Alias != "" {
+			fmt.Printf("%s (%s) => %s\n", id.Identity, id.Alias, id.Policy)
+		} else {
+			fmt.Printf("%s => %s\n", id.Identity, id.Policy)
+		}
 	}
 }
 
@@ -425,12 +513,21 @@ func rmIdentityCmd(args []string) {
 		cli.Fatal("no identity specified. See 'kes identity rm --help'")
 	}
 
+	identities := make([]kes.Identity, 0, cmd.NArg())
+	for _, arg := range cmd.Args() {
+		identity, err := kes.ParseIdentity(arg)
+		if err != nil {
+			cli.Fatal(err)
+		}
+		identities = append(identities, identity)
+	}
+
 	client := newClient(insecureSkipVerify)
 	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, os.Kill)
 	defer cancel()
 
-	for _, identity := range cmd.Args() {
-		if err := client.DeleteIdentity(ctx, kes.Identity(identity)); err != nil {
+	for _, identity := range identities {
+		if err := client.DeleteIdentity(ctx, identity); err != nil {
 			if errors.Is(err, context.Canceled) {
 				os.Exit(1)
 			}
@@ -438,3 +535,107 @@ func rmIdentityCmd(args []string) {
 		}
 	}
 }
+
+const whoamiIdentityCmdUsage = `Usage:
+    kes identity whoami [options]
+
+Options:
+    -o, --output <format>    Print output in the given format: json or text.
+                             By default, text when attached to a terminal
+                             and json otherwise.
+    -k, --insecure           Skip TLS certificate validation.
+    -h, --help               Print command line options.
+
+Examples:
+    $ kes identity whoami
+    $ kes identity whoami --output json
+`
+
+func whoamiIdentityCmd(args []string) {
+	cmd := flag.NewFlagSet(args[0], flag.ContinueOnError)
+	cmd.Usage = func() { fmt.Fprint(os.Stderr, whoamiIdentityCmdUsage) }
+
+	var (
+		outputFlag         string
+		insecureSkipVerify bool
+	)
+	cmd.StringVarP(&outputFlag, "output", "o", "", "Print output in the given format")
+	cmd.BoolVarP(&insecureSkipVerify, "insecure", "k", false, "Skip TLS certificate validation")
+	if err := cmd.Parse(args[1:]); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			os.Exit(2)
+		}
+		cli.Fatalf("%v. See 'kes identity whoami --help'", err)
+	}
+
+	if cmd.NArg() > 0 {
+		cli.Fatal("too many arguments. See 'kes identity whoami --help'")
+	}
+	output, err := parseOutput(outputFlag, outputJSON, outputText)
+	if err != nil {
+		cli.Fatalf("%v. See 'kes identity whoami --help'", err)
+	}
+
+	client := newClient(insecureSkipVerify)
+
+	ctx, cancelCtx := signal.NotifyContext(context.Background(), os.Interrupt, os.Kill)
+	defer cancelCtx()
+
+	info, _, err := client.DescribeSelf(ctx)
+	if err != nil {
+		if errors.Is(err, context.Canceled) {
+			os.Exit(1)
+		}
+		cli.Fatalf("failed to describe identity: %v", err)
+	}
+
+	if output == outputJSON {
+		type Response struct {
+			Identity   kes.Identity `json:"identity"`
+			IsAdmin    bool         `json:"admin"`
+			PolicyName string       `json:"policy_name,omitempty"`
+			CreatedAt  time.Time    `json:"created_at,omitempty"`
+			CreatedBy  kes.Identity `json:"created_by,omitempty"`
+			Allow      []string     `json:"allow,omitempty"`
+			Deny       []string     `json:"deny,omitempty"`
+		}
+		response := Response{
+			Identity:   info.Identity,
+			IsAdmin:    info.IsAdmin,
+			PolicyName: info.Policy,
+			CreatedAt:  info.CreatedAt,
+			CreatedBy:  info.CreatedBy,
+			Allow:      info.Allow,
+			Deny:       info.Deny,
+		}
+		if err = json.NewEncoder(os.Stdout).Encode(response); err != nil {
+			cli.Fatal(err)
+		}
+		return
+	}
+
+	fmt.Println("Identity:", info.Identity)
+	if info.IsAdmin {
+		fmt.Println("Admin:   ", "yes")
+		return
+	}
+	fmt.Println("Policy:  ", info.Policy)
+	if !info.CreatedAt.IsZero() {
+		fmt.Println("Created: ", info.CreatedAt.Local().Format(time.RFC3339))
+	}
+	if !info.CreatedBy.IsUnknown() {
+		fmt.Println("By:      ", info.CreatedBy)
+	}
+	if len(info.Allow) > 0 {
+		fmt.Println("Allow:")
+		for _, pattern := range info.Allow {
+			fmt.Println("  -", pattern)
+		}
+	}
+	if len(info.Deny) > 0 {
+		fmt.Println("Deny:")
+		for _, pattern := range info.Deny {
+			fmt.Println("  -", pattern)
+		}
+	}
+}
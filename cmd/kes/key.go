@@ -7,11 +7,17 @@ package main
 import (
 	"context"
 	"encoding/base64"
+	"encoding/hex"
 	"errors"
 	"fmt"
 	"os"
 	"os/signal"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
 
+	"github.com/minio/kes"
 	"github.com/minio/kes/internal/cli"
 	flag "github.com/spf13/pflag"
 )
@@ -114,7 +120,7 @@ func createKeyCmd(args []string) {
 }
 
 const importKeyCmdUsage = `Usage:
-    kes key import [options] <name> [<key>]
+    kes key import [options] <name> <key | key-file>
 
 Options:
     -k, --insecure           Skip TLS certificate validation.
@@ -122,6 +128,7 @@ Options:
 
 Examples:
     $ kes key import my-key-2 Xlnr/nOgAWE5cA7GAsl3L2goCvmfs6KE0gNgB1T93wE=
+    $ kes key import my-key-2 ./my-key-2.key
 `
 
 func importKeyCmd(args []string) {
@@ -146,9 +153,26 @@ func importKeyCmd(args []string) {
 		cli.Fatal("too many arguments. See 'kes key import --help'")
 	}
 	name := cmd.Arg(0)
-	key, err := base64.StdEncoding.DecodeString(cmd.Arg(1))
-	if err != nil {
-		cli.Fatalf("invalid key: %v. See 'kes key import --help'", err)
+
+	var (
+		key []byte
+		err error
+	)
+	b, rErr := os.ReadFile(cmd.Arg(1))
+	switch {
+	case rErr == nil:
+		if key, err = decodeKey(b); err != nil {
+			cli.Fatalf("invalid key file %q: %v", cmd.Arg(1), err)
+		}
+	case os.IsNotExist(rErr): // The argument is not a file but a base64-encoded key
+		if key, err = base64.StdEncoding.DecodeString(cmd.Arg(1)); err != nil {
+			cli.Fatalf("invalid key: %v. See 'kes key import --help'", err)
+		}
+	default:
+		cli.Fatalf("failed to read key file %q: %v", cmd.Arg(1), rErr)
+	}
+	if len(key) != 32 {
+		cli.Fatalf("invalid key: key must be 32 bytes long but is %d bytes long", len(key))
 	}
 
 	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, os.Kill)
@@ -163,23 +187,51 @@ func importKeyCmd(args []string) {
 	}
 }
 
+// decodeKey decodes a 256 bit key from b. The key
+// is either raw, hex-encoded or base64-encoded.
+func decodeKey(b []byte) ([]byte, error) {
+	if len(b) == 32 {
+		return b, nil
+	}
+
+	s := strings.TrimSpace(string(b))
+	if len(s) == hex.EncodedLen(32) {
+		if key, err := hex.DecodeString(s); err == nil {
+			return key, nil
+		}
+	}
+	key, err := base64.StdEncoding.DecodeString(s)
+	if err != nil {
+		return nil, errors.New("key is neither 32 raw bytes nor hex or base64 encoded")
+	}
+	return key, nil
+}
+
 const lsKeyCmdUsage = `Usage:
     kes key ls [options] [<pattern>]
 
 Options:
+    -o, --output <format>    Print output in the given format: json, table
+                             or text. By default, table when attached to a
+                             terminal and json otherwise.
     -k, --insecure           Skip TLS certificate validation.
     -h, --help               Print command line options.
 
 Examples:
     $ kes key ls
     $ kes key ls 'my-key*'
+    $ kes key ls --output json
 `
 
 func lsKeyCmd(args []string) {
 	cmd := flag.NewFlagSet(args[0], flag.ContinueOnError)
 	cmd.Usage = func() { fmt.Fprint(os.Stderr, lsKeyCmdUsage) }
 
-	var insecureSkipVerify bool
+	var (
+		outputFlag         string
+		insecureSkipVerify bool
+	)
+	cmd.StringVarP(&outputFlag, "output", "o", "", "Print output in the given format")
 	cmd.BoolVarP(&insecureSkipVerify, "insecure", "k", false, "Skip TLS certificate validation")
 	if err := cmd.Parse(args[1:]); err != nil {
 		if errors.Is(err, flag.ErrHelp) {
@@ -191,6 +243,13 @@ func lsKeyCmd(args []string) {
 	if cmd.NArg() > 1 {
 		cli.Fatal("too many arguments. See 'kes key ls --help'")
 	}
+	if outputFlag == "" && isTerm(os.Stdout) {
+		outputFlag = outputTable
+	}
+	output, err := parseOutput(outputFlag, outputJSON, outputTable, outputText)
+	if err != nil {
+		cli.Fatalf("%v. See 'kes key ls --help'", err)
+	}
 
 	pattern := "*"
 	if cmd.NArg() == 1 {
@@ -201,7 +260,9 @@ func lsKeyCmd(args []string) {
 	defer cancelCtx()
 
 	client := newClient(insecureSkipVerify)
-	iterator, err := client.ListKeys(ctx, pattern)
+	iterator, err := client.ListKeysWithOptions(ctx, pattern, kes.ListKeysOptions{
+		Metadata: output != outputText, // Plain text output only shows key names
+	})
 	if err != nil {
 		if errors.Is(err, context.Canceled) {
 			os.Exit(1)
@@ -210,18 +271,45 @@ func lsKeyCmd(args []string) {
 	}
 	defer iterator.Close()
 
-	if isTerm(os.Stdout) {
-		for iterator.Next() {
-			fmt.Println(iterator.Value().Name)
-		}
-	} else {
+	if output == outputJSON {
 		if _, err = iterator.WriteTo(os.Stdout); err != nil {
 			cli.Fatal(err)
 		}
+		if err = iterator.Close(); err != nil {
+			cli.Fatal(err)
+		}
+		return
+	}
+
+	keys := make([]kes.KeyInfo, 0, 100)
+	for iterator.Next() {
+		keys = append(keys, iterator.Value())
 	}
 	if err = iterator.Close(); err != nil {
 		cli.Fatal(err)
 	}
+	sort.Slice(keys, func(i, j int) bool {
+		return strings.Compare(keys[i].Name, keys[j].Name) < 0
+	})
+
+	if output == outputTable {
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "NAME\tCREATED AT\tCREATED BY")
+		for _, key := range keys {
+			var createdAt string
+			if !key.CreatedAt.IsZero() {
+				createdAt = key.CreatedAt.Local().Format(time.RFC3339)
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\n", key.Name, createdAt, key.CreatedBy)
+		}
+		if err = w.Flush(); err != nil {
+			cli.Fatal(err)
+		}
+		return
+	}
+	for _, key := range keys {
+		fmt.Println(key.Name)
+	}
 }
 
 const rmKeyCmdUsage = `Usage:
@@ -12,6 +12,7 @@ import (
 	"fmt"
 	"os"
 	"strings"
+	"time"
 
 	"github.com/minio/kes"
 	"github.com/minio/kes/internal/cli"
@@ -24,6 +25,14 @@ import (
 // to set the binary version.
 var version = "0.0.0-dev"
 
+// Use e.g.: go build -ldflags "-X main.commit=$(git rev-parse HEAD)"
+// to set the git commit the binary is built from.
+var commit = ""
+
+// Use e.g.: go build -ldflags "-X main.buildTime=$(date -u +%Y-%m-%dT%H:%M:%SZ)"
+// to set the RFC 3339 build time of the binary.
+var buildTime = ""
+
 type commands = map[string]func([]string)
 
 const usage = `Usage:
@@ -42,6 +51,7 @@ Commands:
 
     migrate                  Migrate KMS data.
     update                   Update KES binary.
+    fips                     Run FIPS self-tests.
 
 Options:
     -v, --version            Print version information.
@@ -65,6 +75,7 @@ func main() {
 
 		"migrate": migrateCmd,
 		"update":  updateCmd,
+		"fips":    fipsCmd,
 	}
 
 	if len(os.Args) < 2 {
@@ -169,6 +180,42 @@ func newClient(insecureSkipVerify bool) *kes.Client {
 
 func isTerm(f *os.File) bool { return term.IsTerminal(int(f.Fd())) }
 
+// parseBuildTime parses the RFC 3339 build time. It
+// returns the zero time if buildTime is empty or
+// malformed.
+func parseBuildTime(buildTime string) time.Time {
+	t, err := time.Parse(time.RFC3339, buildTime)
+	if err != nil {
+		return time.Time{}
+	}
+	return t.UTC()
+}
+
+// Output formats that can be selected via the '--output' flag.
+const (
+	outputJSON  = "json"
+	outputTable = "table"
+	outputText  = "text"
+)
+
+// parseOutput returns the output format selected via the
+// '--output' flag. If no format has been selected, it returns
+// outputText when STDOUT is a terminal and outputJSON otherwise.
+func parseOutput(format string, formats ...string) (string, error) {
+	if format == "" {
+		if isTerm(os.Stdout) {
+			return outputText, nil
+		}
+		return outputJSON, nil
+	}
+	for _, f := range formats {
+		if format == f {
+			return format, nil
+		}
+	}
+	return "", fmt.Errorf("invalid output format %q: must be one of %s", format, strings.Join(formats, ", "))
+}
+
 func decodePrivateKey(pemBlock []byte) (*pem.Block, error) {
 	ErrNoPrivateKey := errors.New("no PEM-encoded private key found")
 
@@ -108,6 +108,7 @@ func traceMetricsWithUI(ctx context.Context, client *kes.Client, rate time.Durat
 		fmt.Println()
 		fmt.Println(bold.Sprint(" UpTime:       "), metric.UpTime)
 		fmt.Println(bold.Sprint(" Audit Events: "), metric.AuditEvents)
+		fmt.Println(bold.Sprint(" Audit Drops:  "), metric.AuditEventsDropped)
 		fmt.Println(bold.Sprint(" Error Events: "), metric.ErrorEvents)
 	}
 	var (
@@ -5,12 +5,19 @@
 package main
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"net/http"
 	"os"
 	"os/signal"
+	"path"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
 
 	"github.com/minio/kes"
 	"github.com/minio/kes/internal/cli"
@@ -24,8 +31,11 @@ Commands:
     create                   Create a new policy.
     assign                   Assign a policy to identities.
     ls                       List policies.
+    set                      Create or replace a policy.
     rm                       Remove a policy.
     show                     Display a policy.
+    test                     Test whether a policy allows a request.
+    validate                 Validate policy files.
 
 Options:
     -h, --help               Print command line options.
@@ -36,11 +46,14 @@ func policyCmd(args []string) {
 	cmd.Usage = func() { fmt.Fprintf(os.Stderr, policyCmdUsage) }
 
 	subCmds := commands{
-		"create": createPolicyCmd,
-		"assign": assignPolicyCmd,
-		"ls":     lsPolicyCmd,
-		"rm":     rmPolicyCmd,
-		"show":   showPolicyCmd,
+		"create":   createPolicyCmd,
+		"assign":   assignPolicyCmd,
+		"ls":       lsPolicyCmd,
+		"set":      setPolicyCmd,
+		"rm":       rmPolicyCmd,
+		"show":     showPolicyCmd,
+		"test":     testPolicyCmd,
+		"validate": validatePolicyCmd,
 	}
 	if len(args) < 2 {
 		cmd.Usage()
@@ -97,7 +110,72 @@ func createPolicyCmd(args []string) {
 	}
 
 	name := cmd.Arg(0)
-	filename := cmd.Arg(1)
+	policy := readPolicyFile(cmd.Arg(1))
+
+	ctx, cancelCtx := signal.NotifyContext(context.Background(), os.Interrupt, os.Kill)
+	defer cancelCtx()
+
+	client := newClient(insecureSkipVerify)
+	if err := client.SetPolicy(ctx, name, policy); err != nil {
+		if errors.Is(err, context.Canceled) {
+			os.Exit(1)
+		}
+		cli.Fatalf("failed to create policy %q: %v", name, err)
+	}
+}
+
+const setPolicyCmdUsage = `Usage:
+    kes policy set [options] <name> <path>
+
+Options:
+    -k, --insecure           Skip TLS certificate validation.
+    -h, --help               Print command line options.
+
+Examples:
+    $ kes policy set my-policy ./policy.json
+`
+
+func setPolicyCmd(args []string) {
+	cmd := flag.NewFlagSet(args[0], flag.ContinueOnError)
+	cmd.Usage = func() { fmt.Fprint(os.Stderr, setPolicyCmdUsage) }
+
+	var insecureSkipVerify bool
+	cmd.BoolVarP(&insecureSkipVerify, "insecure", "k", false, "Skip TLS certificate validation")
+	if err := cmd.Parse(args[1:]); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			os.Exit(2)
+		}
+		cli.Fatalf("%v. See 'kes policy set --help'", err)
+	}
+
+	switch {
+	case cmd.NArg() == 0:
+		cli.Fatal("no policy name specified. See 'kes policy set --help'")
+	case cmd.NArg() == 1:
+		cli.Fatal("no policy file specified. See 'kes policy set --help'")
+	case cmd.NArg() > 2:
+		cli.Fatal("too many arguments. See 'kes policy set --help'")
+	}
+
+	name := cmd.Arg(0)
+	policy := readPolicyFile(cmd.Arg(1))
+
+	ctx, cancelCtx := signal.NotifyContext(context.Background(), os.Interrupt, os.Kill)
+	defer cancelCtx()
+
+	client := newClient(insecureSkipVerify)
+	if err := client.SetPolicy(ctx, name, policy); err != nil {
+		if errors.Is(err, context.Canceled) {
+			os.Exit(1)
+		}
+		cli.Fatalf("failed to set policy %q: %v", name, err)
+	}
+}
+
+// readPolicyFile reads and validates the JSON policy
+// stored in the given file. It terminates the program
+// if the file does not contain a valid policy.
+func readPolicyFile(filename string) *kes.Policy {
 	b, err := os.ReadFile(filename)
 	if err != nil {
 		cli.Fatalf("failed to read %q: %v", filename, err)
@@ -107,17 +185,156 @@ func createPolicyCmd(args []string) {
 	if err = json.Unmarshal(b, &policy); err != nil {
 		cli.Fatalf("failed to read %q: %v", filename, err)
 	}
+	if err = kes.ValidatePolicy(&policy); err != nil {
+		cli.Fatalf("invalid policy %q: %v", filename, err)
+	}
+	return &policy
+}
 
-	ctx, cancelCtx := signal.NotifyContext(context.Background(), os.Interrupt, os.Kill)
-	defer cancelCtx()
+const validatePolicyCmdUsage = `Usage:
+    kes policy validate <path>...
 
-	client := newClient(insecureSkipVerify)
-	if err := client.SetPolicy(ctx, name, &policy); err != nil {
-		if errors.Is(err, context.Canceled) {
-			os.Exit(1)
+Validates that the given files contain well-formed JSON policies.
+It reports unknown fields, malformed glob patterns and patterns
+that don't match any KES server API. It exits with a non-zero
+status code if any policy file is invalid.
+
+Options:
+    -h, --help               Print command line options.
+
+Examples:
+    $ kes policy validate ./policy.json
+    $ kes policy validate ./policies/*.json
+`
+
+func validatePolicyCmd(args []string) {
+	cmd := flag.NewFlagSet(args[0], flag.ContinueOnError)
+	cmd.Usage = func() { fmt.Fprint(os.Stderr, validatePolicyCmdUsage) }
+	if err := cmd.Parse(args[1:]); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			os.Exit(2)
+		}
+		cli.Fatalf("%v. See 'kes policy validate --help'", err)
+	}
+	if cmd.NArg() == 0 {
+		cli.Fatal("no policy file specified. See 'kes policy validate --help'")
+	}
+
+	var invalid int
+	for _, filename := range cmd.Args() {
+		b, err := os.ReadFile(filename)
+		if err != nil {
+			cli.Fatalf("failed to read %q: %v", filename, err)
+		}
+		if issues := validatePolicyFile(b); len(issues) > 0 {
+			invalid++
+			for _, issue := range issues {
+				line, text := lineAt(b, issue.Offset)
+				fmt.Fprintf(os.Stderr, "%s:%d: %s\n", filename, line, issue.Message)
+				if text != "" {
+					fmt.Fprintf(os.Stderr, "    %s\n", text)
+				}
+			}
+		}
+	}
+	if invalid > 0 {
+		cli.Fatalf("%d of %d policy files are invalid", invalid, cmd.NArg())
+	}
+}
+
+// policyIssue describes a problem of a JSON policy
+// at a particular byte offset.
+type policyIssue struct {
+	Offset  int64
+	Message string
+}
+
+// validatePolicyFile parses the JSON policy b and returns
+// all issues sorted by their position within b.
+func validatePolicyFile(b []byte) []policyIssue {
+	var policy kes.Policy
+	decoder := json.NewDecoder(bytes.NewReader(b))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&policy); err != nil {
+		var (
+			syntaxErr *json.SyntaxError
+			typeErr   *json.UnmarshalTypeError
+			offset    int64
+		)
+		switch {
+		case errors.As(err, &syntaxErr):
+			offset = syntaxErr.Offset
+		case errors.As(err, &typeErr):
+			offset = typeErr.Offset
+		case strings.HasPrefix(err.Error(), "json: unknown field "):
+			// The decoder does not report the offset of unknown
+			// fields. Hence, we look for the first occurrence.
+			field := strings.TrimPrefix(err.Error(), "json: unknown field ")
+			offset = int64(bytes.Index(b, []byte(field)))
+		}
+		return []policyIssue{{Offset: offset, Message: strings.TrimPrefix(err.Error(), "json: ")}}
+	}
+
+	// We validate each rule on its own such that all invalid
+	// rules are reported - not just the first one. A rule is
+	// located by searching for its next JSON string occurrence.
+	var (
+		issues  []policyIssue
+		offsets = map[string]int64{}
+	)
+	locate := func(pattern string) int64 {
+		quoted, _ := json.Marshal(pattern)
+		start := offsets[pattern]
+		if i := bytes.Index(b[start:], quoted); i >= 0 {
+			offsets[pattern] = start + int64(i+len(quoted))
+			return start + int64(i)
+		}
+		return -1
+	}
+	for _, pattern := range policy.Allow {
+		offset := locate(pattern)
+		if err := kes.ValidatePolicy(&kes.Policy{Allow: []string{pattern}}); err != nil {
+			issues = append(issues, policyIssue{Offset: offset, Message: strings.TrimPrefix(err.Error(), "kes: ")})
+		}
+	}
+	for _, pattern := range policy.Deny {
+		offset := locate(pattern)
+		if err := kes.ValidatePolicy(&kes.Policy{Deny: []string{pattern}}); err != nil {
+			issues = append(issues, policyIssue{Offset: offset, Message: strings.TrimPrefix(err.Error(), "kes: ")})
 		}
-		cli.Fatalf("failed to create policy %q: %v", name, err)
 	}
+	for pattern, rate := range policy.RateLimit {
+		offset := locate(pattern)
+		if err := kes.ValidatePolicy(&kes.Policy{RateLimit: map[string]float64{pattern: rate}}); err != nil {
+			issues = append(issues, policyIssue{Offset: offset, Message: strings.TrimPrefix(err.Error(), "kes: ")})
+		}
+	}
+	if policy.AllowWindow != nil {
+		offset := locate("allow_window")
+		if err := kes.ValidatePolicy(&kes.Policy{AllowWindow: policy.AllowWindow}); err != nil {
+			issues = append(issues, policyIssue{Offset: offset, Message: strings.TrimPrefix(err.Error(), "kes: ")})
+		}
+	}
+	sort.SliceStable(issues, func(i, j int) bool { return issues[i].Offset < issues[j].Offset })
+	return issues
+}
+
+// lineAt returns the 1-based line number and the trimmed
+// text of the line containing the given byte offset. It
+// returns 0 and an empty line if the offset is out of range.
+func lineAt(b []byte, offset int64) (int, string) {
+	if offset < 0 || offset > int64(len(b)) {
+		return 0, ""
+	}
+	line := 1 + bytes.Count(b[:offset], []byte{'\n'})
+	start := bytes.LastIndexByte(b[:offset], '\n') + 1
+	end := bytes.IndexByte(b[offset:], '\n')
+	if end < 0 {
+		end = len(b)
+	} else {
+		end += int(offset)
+	}
+	return line, strings.TrimSpace(string(b[start:end]))
 }
 
 const assignPolicyCmdUsage = `Usage:
@@ -171,19 +388,27 @@ const lsPolicyCmdUsage = `Usage:
     kes policy ls [options] [<pattern>]
 
 Options:
+    -o, --output <format>    Print output in the given format: json, table
+                             or text. By default, text when attached to a
+                             terminal and json otherwise.
     -k, --insecure           Skip TLS certificate validation.
     -h, --help               Print command line options.
 
 Examples:
     $ kes policy ls
     $ kes policy ls 'my-policy*'
+    $ kes policy ls --output table
 `
 
 func lsPolicyCmd(args []string) {
 	cmd := flag.NewFlagSet(args[0], flag.ContinueOnError)
 	cmd.Usage = func() { fmt.Fprintf(os.Stderr, lsPolicyCmdUsage) }
 
-	var insecureSkipVerify bool
+	var (
+		outputFlag         string
+		insecureSkipVerify bool
+	)
+	cmd.StringVarP(&outputFlag, "output", "o", "", "Print output in the given format")
 	cmd.BoolVarP(&insecureSkipVerify, "insecure", "k", false, "Skip TLS certificate validation")
 	if err := cmd.Parse(args[1:]); err != nil {
 		if errors.Is(err, flag.ErrHelp) {
@@ -195,6 +420,10 @@ func lsPolicyCmd(args []string) {
 	if cmd.NArg() > 1 {
 		cli.Fatal("too many arguments. See 'kes policy ls --help'")
 	}
+	output, err := parseOutput(outputFlag, outputJSON, outputTable, outputText)
+	if err != nil {
+		cli.Fatalf("%v. See 'kes policy ls --help'", err)
+	}
 
 	pattern := "*"
 	if cmd.NArg() == 1 {
@@ -214,18 +443,43 @@ func lsPolicyCmd(args []string) {
 	}
 	defer policies.Close()
 
-	if isTerm(os.Stdout) {
-		for policies.Next() {
-			fmt.Println(policies.Name())
-		}
-	} else {
+	if output == outputJSON {
 		if _, err = policies.WriteTo(os.Stdout); err != nil {
 			cli.Fatal(err)
 		}
+		if err = policies.Close(); err != nil {
+			cli.Fatalf("failed to list policies: %v", err)
+		}
+		return
+	}
+
+	sorted := make([]kes.PolicyInfo, 0, 100)
+	for policies.Next() {
+		sorted = append(sorted, policies.Value())
 	}
 	if err = policies.Close(); err != nil {
 		cli.Fatalf("failed to list policies: %v", err)
 	}
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Name < sorted[j].Name })
+
+	if output == outputTable {
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "POLICY\tCREATED AT\tCREATED BY")
+		for _, policy := range sorted {
+			var createdAt string
+			if !policy.CreatedAt.IsZero() {
+				createdAt = policy.CreatedAt.Local().Format(time.RFC3339)
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\n", policy.Name, createdAt, policy.CreatedBy)
+		}
+		if err = w.Flush(); err != nil {
+			cli.Fatal(err)
+		}
+		return
+	}
+	for _, policy := range sorted {
+		fmt.Println(policy.Name)
+	}
 }
 
 const rmPolicyCmdUsage = `Usage:
@@ -274,18 +528,26 @@ const showPolicyCmdUsage = `Usage:
     kes policy show [options] <name>
 
 Options:
+    -o, --output <format>    Print output in the given format: json or text.
+                             By default, text when attached to a terminal
+                             and json otherwise.
     -k, --insecure           Skip TLS certificate validation.
     -h, --help               Print command line options.
 
 Examples:
     $ kes policy show my-policy
+    $ kes policy show --output json my-policy
 `
 
 func showPolicyCmd(args []string) {
 	cmd := flag.NewFlagSet(args[0], flag.ContinueOnError)
 	cmd.Usage = func() { fmt.Fprint(os.Stderr, showPolicyCmdUsage) }
 
-	var insecureSkipVerify bool
+	var (
+		outputFlag         string
+		insecureSkipVerify bool
+	)
+	cmd.StringVarP(&outputFlag, "output", "o", "", "Print output in the given format")
 	cmd.BoolVarP(&insecureSkipVerify, "insecure", "k", false, "Skip TLS certificate validation")
 	if err := cmd.Parse(args[1:]); err != nil {
 		if errors.Is(err, flag.ErrHelp) {
@@ -296,6 +558,13 @@ func showPolicyCmd(args []string) {
 	if cmd.NArg() == 0 {
 		cli.Fatal("no policy name specified. See 'kes policy show --help'")
 	}
+	if cmd.NArg() > 1 {
+		cli.Fatal("too many arguments. See 'kes policy show --help'")
+	}
+	output, err := parseOutput(outputFlag, outputJSON, outputText)
+	if err != nil {
+		cli.Fatalf("%v. See 'kes policy show --help'", err)
+	}
 
 	name := cmd.Arg(0)
 	client := newClient(insecureSkipVerify)
@@ -308,11 +577,220 @@ func showPolicyCmd(args []string) {
 		if errors.Is(err, context.Canceled) {
 			os.Exit(1)
 		}
+		if errors.Is(err, kes.ErrPolicyNotFound) {
+			cli.Fatalf("policy %q not found", name)
+		}
 		cli.Fatalf("failed to show policy %q: %v", name, err)
 	}
-	encoder := json.NewEncoder(os.Stdout)
-	if isTerm(os.Stdout) {
-		encoder.SetIndent("", "  ")
+	sort.Strings(policy.Allow)
+	sort.Strings(policy.Deny)
+
+	if output == outputJSON {
+		if err = json.NewEncoder(os.Stdout).Encode(policy); err != nil {
+			cli.Fatal(err)
+		}
+		return
+	}
+
+	fmt.Println("Policy:", name)
+	if len(policy.Extends) > 0 {
+		fmt.Println("Extends:")
+		for _, parent := range policy.Extends {
+			fmt.Println("  -", parent)
+		}
+	}
+	if len(policy.Allow) > 0 {
+		fmt.Println("Allow:")
+		for _, pattern := range policy.Allow {
+			fmt.Println("  -", pattern)
+		}
+	}
+	if len(policy.Deny) > 0 {
+		fmt.Println("Deny:")
+		for _, pattern := range policy.Deny {
+			fmt.Println("  -", pattern)
+		}
+	}
+	if len(policy.RateLimit) > 0 {
+		patterns := make([]string, 0, len(policy.RateLimit))
+		for pattern := range policy.RateLimit {
+			patterns = append(patterns, pattern)
+		}
+		sort.Strings(patterns)
+
+		fmt.Println("Rate Limits:")
+		for _, pattern := range patterns {
+			fmt.Printf("  - %s: %g req/s\n", pattern, policy.RateLimit[pattern])
+		}
+	}
+	if window := policy.AllowWindow; window != nil {
+		days, start, end, location := "every day", window.Start, window.End, window.Location
+		if len(window.Days) > 0 {
+			days = strings.Join(window.Days, ", ")
+		}
+		if start == "" {
+			start = "00:00"
+		}
+		if end == "" {
+			end = "24:00"
+		}
+		if location == "" {
+			location = "UTC"
+		}
+		fmt.Println("Allow Window:")
+		fmt.Printf("  - %s: %s - %s %s\n", days, start, end, location)
+	}
+}
+
+const testPolicyCmdUsage = `Usage:
+    kes policy test [options] <identity> <method> <path>
+
+Reports whether the policy assigned to the identity allows
+a request with the given HTTP method and URL path, and which
+rule matched. A request is denied if any deny rule matches,
+otherwise allowed if any allow rule matches, and otherwise
+denied by default. Policy rules only match the URL path.
+
+Options:
+    -o, --output <format>    Print output in the given format: json, text.
+    -k, --insecure           Skip TLS certificate validation.
+    -h, --help               Print command line options.
+
+Examples:
+    $ kes policy test 3ecfcdf38fcbe141ae26a1030f81e96b753365a46760ae6b578698a97c59fd22 POST /v1/key/generate/my-key
+`
+
+func testPolicyCmd(args []string) {
+	cmd := flag.NewFlagSet(args[0], flag.ContinueOnError)
+	cmd.Usage = func() { fmt.Fprint(os.Stderr, testPolicyCmdUsage) }
+
+	var (
+		outputFlag         string
+		insecureSkipVerify bool
+	)
+	cmd.StringVarP(&outputFlag, "output", "o", "", "Print output in the given format")
+	cmd.BoolVarP(&insecureSkipVerify, "insecure", "k", false, "Skip TLS certificate validation")
+	if err := cmd.Parse(args[1:]); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			os.Exit(2)
+		}
+		cli.Fatalf("%v. See 'kes policy test --help'", err)
+	}
+	switch {
+	case cmd.NArg() == 0:
+		cli.Fatal("no identity specified. See 'kes policy test --help'")
+	case cmd.NArg() == 1:
+		cli.Fatal("no HTTP method specified. See 'kes policy test --help'")
+	case cmd.NArg() == 2:
+		cli.Fatal("no URL path specified. See 'kes policy test --help'")
+	case cmd.NArg() > 3:
+		cli.Fatal("too many arguments. See 'kes policy test --help'")
+	}
+	output, err := parseOutput(outputFlag, outputJSON, outputText)
+	if err != nil {
+		cli.Fatalf("%v. See 'kes policy test --help'", err)
+	}
+
+	identity := kes.Identity(cmd.Arg(0))
+	method := strings.ToUpper(cmd.Arg(1))
+	switch method {
+	case http.MethodGet, http.MethodPost, http.MethodPut, http.MethodDelete:
+	default:
+		cli.Fatalf("invalid HTTP method %q. See 'kes policy test --help'", cmd.Arg(1))
+	}
+	urlPath := cmd.Arg(2)
+	if !strings.HasPrefix(urlPath, "/") {
+		urlPath = "/" + urlPath
+	}
+
+	client := newClient(insecureSkipVerify)
+	ctx, cancelCtx := signal.NotifyContext(context.Background(), os.Interrupt, os.Kill)
+	defer cancelCtx()
+
+	type Result struct {
+		Allowed bool   `json:"allowed"`
+		Policy  string `json:"policy,omitempty"`
+		Rule    string `json:"rule,omitempty"`
+		Reason  string `json:"reason"`
+	}
+	var result Result
+
+	info, err := client.DescribeIdentity(ctx, identity)
+	var kesErr kes.Error
+	switch {
+	case errors.Is(err, context.Canceled):
+		os.Exit(1)
+	case errors.As(err, &kesErr) && kesErr.Status() == http.StatusNotFound:
+		result.Reason = "identity has no policy"
+	case err != nil:
+		cli.Fatalf("failed to describe identity %q: %v", identity, err)
+	case info.IsAdmin:
+		result.Allowed = true
+		result.Reason = "admin identity"
+	default:
+		policy, err := client.DescribePolicy(ctx, info.Policy)
+		if err != nil {
+			if errors.Is(err, context.Canceled) {
+				os.Exit(1)
+			}
+			cli.Fatalf("failed to describe policy %q: %v", info.Policy, err)
+		}
+		result.Policy = info.Policy
+		result.Allowed, result.Rule, result.Reason = matchPolicy(policy, urlPath, time.Now())
+	}
+
+	if output == outputJSON {
+		if err = json.NewEncoder(os.Stdout).Encode(result); err != nil {
+			cli.Fatal(err)
+		}
+		return
+	}
+	if result.Allowed {
+		fmt.Printf("%s %s: allowed (%s)\n", method, urlPath, result.Reason)
+	} else {
+		fmt.Printf("%s %s: denied (%s)\n", method, urlPath, result.Reason)
+	}
+	if result.Policy != "" {
+		fmt.Println("Policy:", result.Policy)
+	}
+	if result.Rule != "" {
+		fmt.Println("Rule:  ", result.Rule)
+	}
+}
+
+// matchPolicy reports whether the policy allows requests
+// to the given URL path at the given point in time, which
+// rule matched, if any, and why. It evaluates the rules
+// like a KES server: any matching deny rule takes precedence
+// over all allow rules, exporting a key requires an explicit
+// allow rule - i.e. only its last path segment may contain
+// wildcards - and requests outside the policy's allow window
+// are denied.
+func matchPolicy(policy *kes.PolicyInfo, urlPath string, now time.Time) (bool, string, string) {
+	const ExportAPI = "/v1/key/export"
+
+	sort.Strings(policy.EffectiveDeny)
+	sort.Strings(policy.EffectiveAllow)
+	for _, pattern := range policy.EffectiveDeny {
+		if ok, err := path.Match(pattern, urlPath); ok && err == nil {
+			return false, pattern, "deny rule matches"
+		}
+	}
+
+	explicit := path.Dir(urlPath) == ExportAPI
+	for _, pattern := range policy.EffectiveAllow {
+		if explicit && path.Dir(pattern) != path.Dir(urlPath) {
+			continue
+		}
+		if ok, err := path.Match(pattern, urlPath); ok && err == nil {
+			if policy.AllowWindow != nil && !policy.AllowWindow.Contains(now) {
+				return false, pattern, "outside of allow window"
+			}
+			return true, pattern, "allow rule matches"
+		}
+	}
+	if explicit {
+		return false, "", "no explicit allow rule matches"
 	}
-	encoder.Encode(policy)
+	return false, "", "no rule matches"
 }
@@ -0,0 +1,85 @@
+// Copyright 2022 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/minio/kes"
+)
+
+// matchPolicyTime is Monday, 2022-01-03 12:00 UTC.
+var matchPolicyTime = time.Date(2022, time.January, 3, 12, 0, 0, 0, time.UTC)
+
+var matchPolicyTests = []struct {
+	Policy  *kes.PolicyInfo
+	Path    string
+	Allowed bool
+	Rule    string
+}{
+	{ // 0
+		Policy:  &kes.PolicyInfo{EffectiveAllow: []string{"/v1/key/create/*"}},
+		Path:    "/v1/key/create/my-key",
+		Allowed: true,
+		Rule:    "/v1/key/create/*",
+	},
+	{ // 1
+		Policy: &kes.PolicyInfo{
+			EffectiveAllow: []string{"/v1/key/create/*"},
+			EffectiveDeny:  []string{"/v1/key/create/my-*"},
+		},
+		Path:    "/v1/key/create/my-key",
+		Allowed: false,
+		Rule:    "/v1/key/create/my-*",
+	},
+	{ // 2
+		Policy:  &kes.PolicyInfo{EffectiveAllow: []string{"/v1/key/*/*"}},
+		Path:    "/v1/key/create/my-key",
+		Allowed: true,
+		Rule:    "/v1/key/*/*",
+	},
+	{ // 3
+		Policy:  &kes.PolicyInfo{EffectiveAllow: []string{"/v1/key/*/*"}},
+		Path:    "/v1/key/export/my-key",
+		Allowed: false, // Exporting keys requires an explicit allow rule
+	},
+	{ // 4
+		Policy:  &kes.PolicyInfo{EffectiveAllow: []string{"/v1/key/*/*", "/v1/key/export/*"}},
+		Path:    "/v1/key/export/my-key",
+		Allowed: true,
+		Rule:    "/v1/key/export/*",
+	},
+	{ // 5
+		Policy: &kes.PolicyInfo{
+			EffectiveAllow: []string{"/v1/key/create/*"},
+			AllowWindow:    &kes.TimeWindow{Days: []string{"Mon"}, Start: "08:00", End: "18:00"},
+		},
+		Path:    "/v1/key/create/my-key",
+		Allowed: true,
+		Rule:    "/v1/key/create/*",
+	},
+	{ // 6
+		Policy: &kes.PolicyInfo{
+			EffectiveAllow: []string{"/v1/key/create/*"},
+			AllowWindow:    &kes.TimeWindow{Days: []string{"Tue"}, Start: "08:00", End: "18:00"},
+		},
+		Path:    "/v1/key/create/my-key",
+		Allowed: false, // Outside of the allow window
+		Rule:    "/v1/key/create/*",
+	},
+}
+
+func TestMatchPolicy(t *testing.T) {
+	for i, test := range matchPolicyTests {
+		allowed, rule, _ := matchPolicy(test.Policy, test.Path, matchPolicyTime)
+		if allowed != test.Allowed {
+			t.Fatalf("Test %d: got allowed '%v' - want '%v'", i, allowed, test.Allowed)
+		}
+		if rule != test.Rule {
+			t.Fatalf("Test %d: got rule '%s' - want '%s'", i, rule, test.Rule)
+		}
+	}
+}
@@ -17,6 +17,7 @@ import (
 	"net/http"
 	"os"
 	"os/signal"
+	"regexp"
 	"runtime"
 	"strings"
 	"syscall"
@@ -193,6 +194,20 @@ func serverCmd(args []string) {
 	if err != nil {
 		cli.Fatal(err)
 	}
+	var validateKeyName func(string) error
+	if pattern := config.KeyName.Pattern.Value(); pattern != "" {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			cli.Fatalf("invalid key name pattern %q: %v", pattern, err)
+		}
+		validateKeyName = func(name string) error {
+			if !re.MatchString(name) {
+				return fmt.Errorf("name must match %q", pattern)
+			}
+			return nil
+		}
+	}
+
 	cache := key.NewCache(store, &key.CacheConfig{
 		Expiry:        config.Cache.Expiry.Any.Value(),
 		ExpiryUnused:  config.Cache.Expiry.Unused.Value(),
@@ -230,18 +245,25 @@ func serverCmd(args []string) {
 	server := http.Server{
 		Addr: config.Address.Value(),
 		Handler: xhttp.NewServerMux(&xhttp.ServerConfig{
-			Version:  version,
-			Vault:    sys.NewStatelessVault(config.Admin.Identity.Value(), cache, policySet, identitySet),
-			Proxy:    proxy,
-			AuditLog: auditLog,
-			ErrorLog: errorLog,
-			Metrics:  metrics,
+			Version:   version,
+			Commit:    commit,
+			BuildTime: parseBuildTime(buildTime),
+			Vault:     sys.NewStatelessVault(config.Admin.Identity.Value(), cache, policySet, identitySet),
+			Proxy:     proxy,
+			AuditLog:  auditLog,
+			ErrorLog:  errorLog,
+			Metrics:   metrics,
+			KeepAlive: config.Log.KeepAlive.Value(),
+
+			AttestationKey:  certificate,
+			ValidateKeyName: validateKeyName,
 		}),
 		TLSConfig: &tls.Config{
 			MinVersion:     tls.VersionTLS12,
 			GetCertificate: certificate.GetCertificate,
 		},
-		ErrorLog: errorLog.Log(),
+		ErrorLog:  errorLog.Log(),
+		ConnState: metrics.ConnState,
 
 		ReadHeaderTimeout: 5 * time.Second,
 		WriteTimeout:      0 * time.Second, // explicitly set no write timeout - see timeout handler.
@@ -73,6 +73,15 @@ func statusCmd(args []string) {
 		}
 		fmt.Println("   Latency:", latency.Round(time.Millisecond))
 		fmt.Println("   Version:", status.Version)
+		if status.Commit != "" {
+			fmt.Println("   Commit: ", status.Commit)
+		}
+		if !status.BuildTime.IsZero() {
+			fmt.Println("   Built:  ", status.BuildTime.Local().Format(time.RFC3339))
+		}
+		if status.GoVersion != "" {
+			fmt.Println("   Go:     ", status.GoVersion)
+		}
 	} else {
 		json.NewEncoder(os.Stdout).Encode(status)
 	}
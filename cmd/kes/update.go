@@ -5,10 +5,15 @@
 package main
 
 import (
+	"bytes"
+	"crypto"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
+	"math/rand"
 	"net"
 	"net/http"
 	"os"
@@ -27,18 +32,42 @@ const updateCmdUsage = `Usage:
     kes update [options]
 
 Options:
+    --version <version>      Update to the given release instead of the
+                             latest one.
+    -f, --force              Allow updating to an older release.
+    --file <path>            Update from a local binary instead of
+                             downloading a release. Requires --sig.
+    --sig <path>             Path to the minisign signature of the
+                             local binary.
+    --retries <n>            Retry failed downloads up to n times with an
+                             exponential backoff. (default: 3)
     -k, --insecure           Skip TLS certificate validation.
     -h, --help               Print command line options.
 
 Examples:
     $ kes update
+    $ kes update --version v0.19.0
+    $ kes update --file ./kes-linux-amd64 --sig ./kes-linux-amd64.minisig
+    $ kes update --retries 5
 `
 
 func updateCmd(args []string) {
 	cmd := flag.NewFlagSet(args[0], flag.ContinueOnError)
 	cmd.Usage = func() { fmt.Fprint(os.Stderr, updateCmdUsage) }
 
-	var insecureSkipVerify bool
+	var (
+		release            string
+		force              bool
+		binFile            string
+		sigFile            string
+		retries            int
+		insecureSkipVerify bool
+	)
+	cmd.StringVar(&release, "version", "", "Update to the given release")
+	cmd.BoolVarP(&force, "force", "f", false, "Allow updating to an older release")
+	cmd.StringVar(&binFile, "file", "", "Update from a local binary")
+	cmd.StringVar(&sigFile, "sig", "", "Path to the minisign signature of the local binary")
+	cmd.IntVar(&retries, "retries", 3, "Retry failed downloads up to n times")
 	cmd.BoolVarP(&insecureSkipVerify, "insecure", "k", false, "Skip TLS certificate validation")
 	if err := cmd.Parse(args[1:]); err != nil {
 		if errors.Is(err, flag.ErrHelp) {
@@ -50,7 +79,25 @@ func updateCmd(args []string) {
 	if cmd.NArg() != 0 {
 		cli.Fatal("too many arguments. See 'kes update --help'")
 	}
-	if err := updateInplace(); err != nil {
+	if retries < 0 {
+		cli.Fatal("invalid number of retries: must not be negative. See 'kes update --help'")
+	}
+
+	if binFile != "" || sigFile != "" {
+		switch {
+		case binFile == "":
+			cli.Fatal("no binary specified. See 'kes update --help'")
+		case sigFile == "":
+			cli.Fatal("no signature specified. See 'kes update --help'")
+		case release != "":
+			cli.Fatal("'--file' and '--version' cannot be used together. See 'kes update --help'")
+		}
+		if err := updateFromFile(binFile, sigFile); err != nil {
+			cli.Fatal(err)
+		}
+		return
+	}
+	if err := updateInplace(release, force, retries); err != nil {
 		cli.Fatal(err)
 	}
 }
@@ -71,35 +118,142 @@ func getUpdateTransport(timeout time.Duration) http.RoundTripper {
 	return updateTransport
 }
 
-func getUpdateReaderFromURL(u string, transport http.RoundTripper) (io.ReadCloser, int64, error) {
+func getUpdateReaderFromURL(u string, transport http.RoundTripper, retries int) (io.ReadCloser, int64, error) {
 	clnt := &http.Client{
 		Transport: transport,
 	}
-	req, err := http.NewRequest(http.MethodGet, u, nil)
+
+	var resp *http.Response
+	err := retryWithBackoff(retries, func() error {
+		req, err := http.NewRequest(http.MethodGet, u, nil)
+		if err != nil {
+			return err
+		}
+		resp, err = clnt.Do(req)
+		if err != nil {
+			return err
+		}
+		if resp.StatusCode != http.StatusOK {
+			resp.Body.Close()
+			return statusError{URL: u, StatusCode: resp.StatusCode}
+		}
+		return nil
+	})
 	if err != nil {
 		return nil, -1, err
 	}
+	return resp.Body, resp.ContentLength, nil
+}
+
+// downloadFile fetches the file at the given URL and passes
+// its content to read. It retries both, sending the request
+// and reading the response body. Hence, a connection that
+// breaks while reading the body does not fail the download.
+//
+// The read function must not return permanent errors, e.g.
+// parsing errors, since all its errors get retried.
+func downloadFile(u string, transport http.RoundTripper, retries int, read func(body io.Reader, length int64) error) error {
+	return retryWithBackoff(retries, func() error {
+		body, length, err := getUpdateReaderFromURL(u, transport, 0)
+		if err != nil {
+			return err
+		}
+		defer body.Close()
 
-	resp, err := clnt.Do(req)
+		return read(body, length)
+	})
+}
+
+// getUpdateFile fetches the file at the given URL and
+// returns its content. It reads at most maxSize bytes.
+func getUpdateFile(u string, transport http.RoundTripper, retries int, maxSize int64) ([]byte, error) {
+	var content []byte
+	err := downloadFile(u, transport, retries, func(body io.Reader, _ int64) (err error) {
+		content, err = io.ReadAll(io.LimitReader(body, maxSize))
+		return err
+	})
 	if err != nil {
-		return nil, -1, err
+		return nil, err
 	}
-	return resp.Body, resp.ContentLength, nil
+	return content, nil
+}
+
+// statusError is returned when a download fails
+// with an unexpected HTTP response status code.
+type statusError struct {
+	URL        string
+	StatusCode int
+}
+
+func (e statusError) Error() string {
+	return fmt.Sprintf("%s: %s", e.URL, http.StatusText(e.StatusCode))
+}
+
+// retryWithBackoff calls fn until it succeeds, fails with
+// a permanent error or fn has been retried the given number
+// of times. It waits an exponentially growing, randomized
+// delay between two calls.
+//
+// A statusError is permanent unless the server responded
+// with 429 (Too Many Requests) or a 5xx status code. Any
+// other error, e.g. a network error, is considered
+// temporary.
+func retryWithBackoff(retries int, fn func() error) error {
+	const (
+		BaseDelay = 500 * time.Millisecond
+		MaxDelay  = 10 * time.Second
+	)
+	for attempt := 0; ; attempt++ {
+		err := fn()
+		if err == nil || attempt >= retries {
+			return err
+		}
+		var sErr statusError
+		if errors.As(err, &sErr) && sErr.StatusCode != http.StatusTooManyRequests && sErr.StatusCode < 500 {
+			return err
+		}
+
+		delay := BaseDelay << attempt
+		if delay > MaxDelay {
+			delay = MaxDelay
+		}
+		time.Sleep(delay/2 + time.Duration(rand.Int63n(int64(delay/2)))) // Add jitter to avoid synchronized retries
+	}
+}
+
+// getChecksum fetches and parses the SHA-256 checksum
+// file at the given URL. The checksum file contains the
+// hex-encoded checksum followed by the file name.
+func getChecksum(u string, transport http.RoundTripper, retries int) ([]byte, error) {
+	const MaxSize = 1 << 10 // 1 KiB - a checksum file is just a single line
+	content, err := getUpdateFile(u, transport, retries, MaxSize)
+	if err != nil {
+		return nil, err
+	}
+	fields := strings.Fields(string(content))
+	if len(fields) == 0 {
+		return nil, errors.New("checksum file is empty")
+	}
+	checksum, err := hex.DecodeString(fields[0])
+	if err != nil || len(checksum) != sha256.Size {
+		return nil, errors.New("checksum file does not contain a SHA-256 checksum")
+	}
+	return checksum, nil
 }
 
 const defaultPubKey = "RWTx5Zr1tiHQLwG9keckT0c45M3AGeHD6IvimQHpyRywVWGbP1aVSGav"
 
-func getLatestRelease(tr http.RoundTripper) (string, error) {
+func getLatestRelease(tr http.RoundTripper, retries int) (string, error) {
+	const MaxSize = 1 << 20 // 1 MiB
 	releaseURL := "https://api.github.com/repos/minio/kes/releases/latest"
 
-	body, _, err := getUpdateReaderFromURL(releaseURL, tr)
+	content, err := getUpdateFile(releaseURL, tr, retries, MaxSize)
 	if err != nil {
 		return "", fmt.Errorf("unable to access github release URL %w", err)
 	}
-	defer body.Close()
 
 	lm := make(map[string]interface{})
-	if err = json.NewDecoder(body).Decode(&lm); err != nil {
+	if err = json.Unmarshal(content, &lm); err != nil {
 		return "", err
 	}
 	rel, ok := lm["tag_name"].(string)
@@ -109,59 +263,119 @@ func getLatestRelease(tr http.RoundTripper) (string, error) {
 	return rel, nil
 }
 
-func updateInplace() error {
+// updateInplace replaces the running binary with the
+// given release. If release is empty, it updates to the
+// latest release. It refuses to update to an older
+// release unless force is true.
+func updateInplace(release string, force bool, retries int) error {
 	transport := getUpdateTransport(30 * time.Second)
-	rel, err := getLatestRelease(transport)
+
+	current, err := semver.Make(version)
 	if err != nil {
 		return err
 	}
 
-	latest, err := semver.Make(strings.TrimPrefix(rel, "v"))
-	if err != nil {
-		return err
+	rel := release
+	if rel == "" {
+		if rel, err = getLatestRelease(transport, retries); err != nil {
+			return err
+		}
+	} else if !strings.HasPrefix(rel, "v") {
+		rel = "v" + rel
 	}
 
-	current, err := semver.Make(version)
+	target, err := semver.Make(strings.TrimPrefix(rel, "v"))
 	if err != nil {
-		return err
+		return fmt.Errorf("invalid release %q: %w", rel, err)
 	}
 
-	if current.GTE(latest) {
+	switch {
+	case release == "" && current.GTE(target):
 		fmt.Printf("You are already running the latest version v%q.\n", version)
 		return nil
+	case current.EQ(target):
+		fmt.Printf("You are already running version %s.\n", rel)
+		return nil
+	case current.GT(target) && !force:
+		return fmt.Errorf("refusing to downgrade from v%s to %s. Use --force to downgrade", version, rel)
 	}
 
+	// We fetch the signature and checksum before downloading
+	// the binary such that we don't download the binary if
+	// it cannot be verified anyway.
 	kesBin := fmt.Sprintf("https://github.com/minio/kes/releases/download/%s/kes-%s-%s", rel, runtime.GOOS, runtime.GOARCH)
-	reader, length, err := getUpdateReaderFromURL(kesBin, transport)
+	v := selfupdate.NewVerifier()
+	err = retryWithBackoff(retries, func() error {
+		return v.LoadFromURL(kesBin+".minisig", minisignPublicKey(), transport)
+	})
+	if err != nil {
+		return fmt.Errorf("unable to fetch binary signature for %s: %w", kesBin, err)
+	}
+	checksum, err := getChecksum(kesBin+".sha256sum", transport, retries)
+	if err != nil {
+		return fmt.Errorf("unable to fetch binary checksum for %s: %w", kesBin, err)
+	}
+	opts := selfupdate.Options{
+		Verifier: v,
+		Hash:     crypto.SHA256,
+		Checksum: checksum, // Verified before the binary gets replaced
+	}
+
+	var binary []byte
+	err = downloadFile(kesBin, transport, retries, func(body io.Reader, length int64) (err error) {
+		tmpl := `{{ red "Downloading:" }} {{bar . (red "[") (green "=") (red "]")}} {{speed . | rndcolor }}`
+		bar := pb.ProgressBarTemplate(tmpl).Start64(length)
+		defer bar.Finish()
+
+		binary, err = io.ReadAll(bar.NewProxyReader(body))
+		return err
+	})
 	if err != nil {
 		return fmt.Errorf("unable to fetch binary from %s: %w", kesBin, err)
 	}
+	if err = selfupdate.Apply(bytes.NewReader(binary), opts); err != nil {
+		if rerr := selfupdate.RollbackError(err); rerr != nil {
+			return rerr
+		}
+		return err
+	}
+	fmt.Printf("Updated 'kes' to release %s\n", rel)
+	return nil
+}
 
-	minisignPubkey := os.Getenv("KES_MINISIGN_PUBKEY")
-	if minisignPubkey == "" {
-		minisignPubkey = defaultPubKey
+// updateFromFile replaces the running binary with the
+// binary at binFile once its minisign signature, stored
+// at sigFile, has been verified.
+func updateFromFile(binFile, sigFile string) error {
+	file, err := os.Open(binFile)
+	if err != nil {
+		return err
 	}
+	defer file.Close()
 
 	v := selfupdate.NewVerifier()
-	if err = v.LoadFromURL(kesBin+".minisig", minisignPubkey, transport); err != nil {
-		return fmt.Errorf("unable to fetch binary signature for %s: %w", kesBin, err)
+	if err = v.LoadFromFile(sigFile, minisignPublicKey()); err != nil {
+		return fmt.Errorf("unable to read binary signature %s: %w", sigFile, err)
 	}
 	opts := selfupdate.Options{
 		Verifier: v,
 	}
-
-	tmpl := `{{ red "Downloading:" }} {{bar . (red "[") (green "=") (red "]")}} {{speed . | rndcolor }}`
-	bar := pb.ProgressBarTemplate(tmpl).Start64(length)
-	barReader := bar.NewProxyReader(reader)
-	if err = selfupdate.Apply(barReader, opts); err != nil {
-		bar.Finish()
+	if err = selfupdate.Apply(file, opts); err != nil {
 		if rerr := selfupdate.RollbackError(err); rerr != nil {
 			return rerr
 		}
 		return err
 	}
-
-	bar.Finish()
-	fmt.Printf("Updated 'kes' to latest release %s\n", rel)
+	fmt.Printf("Updated 'kes' from %s\n", binFile)
 	return nil
 }
+
+// minisignPublicKey returns the minisign public key used
+// to verify release binaries. It can be overwritten via
+// the KES_MINISIGN_PUBKEY environment variable.
+func minisignPublicKey() string {
+	if key := os.Getenv("KES_MINISIGN_PUBKEY"); key != "" {
+		return key
+	}
+	return defaultPubKey
+}
@@ -8,6 +8,7 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"io"
 	"net/http"
 	"net/url"
@@ -28,6 +29,163 @@ type Enclave struct {
 	name      string
 	endpoints []string
 	client    retry
+
+	maxRequestSize int64 // See Client.MaxRequestSize
+}
+
+// EnclaveInfo describes a KES enclave.
+type EnclaveInfo struct {
+	Name string `json:"name"` // Name of the enclave
+
+	// The following fields are only populated by
+	// Client.DescribeEnclave.
+
+	Sealed   bool   `json:"sealed,omitempty"`    // Indicates whether the enclave is sealed
+	KeyCount uint64 `json:"key_count,omitempty"` // Number of keys within the enclave
+
+	// State of the enclave's key store backend - either
+	// "available", "reachable" or "unreachable". Empty if
+	// the enclave is sealed.
+	StoreState string `json:"store_state,omitempty"`
+
+	// Err is not nil if the KES server failed to fetch the
+	// status of the enclave - e.g. because its key store
+	// is not reachable. Then KeyCount is not valid.
+	Err error `json:"-"`
+}
+
+// EnclaveIterator iterates over a stream of EnclaveInfo objects.
+// Close the EnclaveIterator to release associated resources.
+//
+// An EnclaveIterator is not safe for concurrent use. Calling
+// Next while another call of Next is in progress returns
+// false. However, Close may be called concurrently to
+// cancel an in-progress call of Next.
+type EnclaveIterator struct {
+	decoder *json.Decoder
+	closer  io.Closer
+
+	current EnclaveInfo
+	err     error
+	closed  bool // Close has been called
+	done    bool // The stream has been read completely
+	guard   iteratorGuard
+}
+
+// Value returns the current EnclaveInfo. It remains valid
+// until Next is called again.
+func (i *EnclaveIterator) Value() EnclaveInfo { return i.current }
+
+// Name returns the name of the current enclave.
+// It is a short-hand for Value().Name.
+func (i *EnclaveIterator) Name() string { return i.current.Name }
+
+// Next returns true if there is another EnclaveInfo.
+// It returns false if there are no more EnclaveInfo
+// objects or when the EnclaveIterator encounters an
+// error.
+func (i *EnclaveIterator) Next() bool {
+	type Response struct {
+		Name string `json:"name"`
+
+		Err string `json:"error"`
+	}
+	if !i.enter() {
+		return false
+	}
+	defer i.guard.Leave()
+
+	if i.closed {
+		if i.err == nil {
+			i.err = ErrIteratorClosed
+		}
+		return false
+	}
+	if i.done || i.err != nil {
+		return false
+	}
+
+	var resp Response
+	if err := i.decoder.Decode(&resp); err != nil {
+		if i.guard.Canceled() { // Close has been called concurrently
+			i.current = EnclaveInfo{}
+			i.closed = true
+			return false
+		}
+		if errors.Is(err, io.EOF) {
+			i.err = i.finish()
+		} else {
+			i.err = err
+		}
+		return false
+	}
+	if resp.Err != "" {
+		i.err = errors.New(resp.Err)
+		return false
+	}
+
+	i.current = EnclaveInfo{
+		Name: resp.Name,
+	}
+	return true
+}
+
+// Close closes the EnclaveIterator and releases any
+// associated resources. It returns the first error
+// encountered while iterating, if any.
+//
+// Close is idempotent. Calling Close more than once
+// returns the same error. Once closed, Next returns
+// false and Value returns the zero EnclaveInfo. Calling Next
+// after Close records ErrIteratorClosed, which any
+// subsequent Close call returns.
+//
+// Calling Close while Next is in progress closes the
+// underlying stream such that Next returns false. Then
+// Close returns nil and the EnclaveIterator is closed once
+// Next returns.
+func (i *EnclaveIterator) Close() error {
+	if !i.enter() {
+		if i.guard.Cancel() {
+			i.closer.Close()
+		}
+		return nil
+	}
+	defer i.guard.Leave()
+
+	if !i.closed {
+		if !i.done {
+			if err := i.closer.Close(); i.err == nil {
+				i.err = err
+			}
+		}
+		i.current = EnclaveInfo{}
+		i.closed = true
+	}
+	return i.err
+}
+
+// finish releases any associated resources once the
+// entire stream has been read. The caller must hold
+// the iterator guard.
+func (i *EnclaveIterator) finish() error {
+	i.current = EnclaveInfo{}
+	i.done = true
+	return i.closer.Close()
+}
+
+// enter acquires the iterator guard. It returns false
+// if the EnclaveIterator is in use. Once Close has canceled
+// the EnclaveIterator, enter marks it as closed.
+func (i *EnclaveIterator) enter() bool {
+	if !i.guard.Enter() {
+		return false
+	}
+	if i.guard.Canceled() && !i.closed {
+		i.current = EnclaveInfo{}
+		i.closed = true
+	}
+	return true
 }
 
 // CreateKey creates a new cryptographic key. The key will
@@ -51,6 +209,68 @@ func (e *Enclave) CreateKey(ctx context.Context, name string) error {
 	return nil
 }
 
+// CreateKeyWithAlgorithm creates a new cryptographic key that
+// is used with the given algorithm. The KES server encrypts
+// and decrypts data with the key using this algorithm.
+//
+// It returns ErrKeyExists if a key with the same name already
+// exists.
+func (e *Enclave) CreateKeyWithAlgorithm(ctx context.Context, name string, algorithm KeyAlgorithm) error {
+	const (
+		APIPath  = "/v1/key/create"
+		Method   = http.MethodPost
+		StatusOK = http.StatusOK
+	)
+	type Request struct {
+		Algorithm KeyAlgorithm `json:"algorithm"`
+	}
+	body, err := json.Marshal(Request{
+		Algorithm: algorithm,
+	})
+	if err != nil {
+		return err
+	}
+
+	resp, err := e.client.Send(ctx, Method, e.endpoints, e.path(APIPath, name), bytes.NewReader(body), withHeader("Content-Type", "application/json"))
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != StatusOK {
+		return parseErrorResponse(resp)
+	}
+	return nil
+}
+
+// CreateKeyIdempotent creates a new cryptographic key - like
+// CreateKey. However, repeating the request with the same name
+// and idempotency token does not fail with ErrKeyExists as long
+// as the server still remembers the token - i.e. for a few
+// minutes. Hence, CreateKeyIdempotent can be retried safely,
+// e.g. after a timeout. The server remembers tokens per client
+// identity, so a token used by another identity has no effect.
+//
+// If token is empty, CreateKeyIdempotent behaves like CreateKey.
+func (e *Enclave) CreateKeyIdempotent(ctx context.Context, name, token string) error {
+	const (
+		APIPath  = "/v1/key/create"
+		Method   = http.MethodPost
+		StatusOK = http.StatusOK
+	)
+
+	var options []requestOption
+	if token != "" {
+		options = append(options, withHeader("Idempotency-Key", token))
+	}
+	resp, err := e.client.Send(ctx, Method, e.endpoints, e.path(APIPath, name), nil, options...)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != StatusOK {
+		return parseErrorResponse(resp)
+	}
+	return nil
+}
+
 // ImportKey imports the given key into a KES server. It
 // returns ErrKeyExists if a key with the same key already
 // exists.
@@ -80,6 +300,178 @@ func (e *Enclave) ImportKey(ctx context.Context, name string, key []byte) error
 	return nil
 }
 
+// ExportKey returns the raw key material of the given key.
+// The exported key can be imported again via ImportKey, e.g.
+// to restore a backup.
+//
+// Exporting a key requires a policy that allows the export
+// API explicitly - e.g. "/v1/key/export/<name>". Wildcard
+// rules, like "/v1/key/*/*", do not grant access to it.
+// It returns ErrNotAllowed if the client does not have
+// sufficient permissions and ErrKeyNotFound if no such
+// key exists.
+func (e *Enclave) ExportKey(ctx context.Context, name string) ([]byte, error) {
+	const (
+		APIPath         = "/v1/key/export"
+		Method          = http.MethodGet
+		StatusOK        = http.StatusOK
+		MaxResponseSize = 1 << 20 // 1 MiB
+	)
+	type Response struct {
+		Bytes []byte `json:"bytes"`
+	}
+
+	resp, err := e.client.Send(ctx, Method, e.endpoints, e.path(APIPath, name), nil)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != StatusOK {
+		return nil, parseErrorResponse(resp)
+	}
+	var response Response
+	if err = decodeSecret(resp, MaxResponseSize, &response); err != nil {
+		return nil, err
+	}
+	return response.Bytes, nil
+}
+
+// CopyKey creates a new key dst with the same key material
+// as the key src. The key material never leaves the server.
+// Ciphertexts produced by src can be decrypted with dst, and
+// vice versa. The key dst starts with the usage counters of
+// src, as reported by DescribeKey.
+//
+// Only the admin identity can copy keys. CopyKey returns
+// ErrKeyNotFound if src does not exist and ErrKeyExists
+// if dst already exists.
+func (e *Enclave) CopyKey(ctx context.Context, src, dst string) error {
+	const (
+		APIPath  = "/v1/key/copy"
+		Method   = http.MethodPost
+		StatusOK = http.StatusOK
+	)
+	type Request struct {
+		Name string `json:"name"`
+	}
+	body, err := json.Marshal(Request{
+		Name: dst,
+	})
+	if err != nil {
+		return err
+	}
+
+	resp, err := e.client.Send(ctx, Method, e.endpoints, e.path(APIPath, src), bytes.NewReader(body), withHeader("Content-Type", "application/json"))
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != StatusOK {
+		return parseErrorResponse(resp)
+	}
+	return nil
+}
+
+// RenameKey renames the key oldName to newName. The key keeps
+// its key material, creation time and usage counters. Hence,
+// ciphertexts produced by oldName can be decrypted with newName.
+//
+// Only the admin identity can rename keys. RenameKey returns
+// ErrKeyNotFound if oldName does not exist and ErrKeyExists
+// if newName already exists.
+//
+// The key is renamed atomically. Servers whose key store
+// cannot rename keys atomically reject the request with
+// http.StatusNotImplemented.
+func (e *Enclave) RenameKey(ctx context.Context, oldName, newName string) error {
+	const (
+		APIPath  = "/v1/key/rename"
+		Method   = http.MethodPost
+		StatusOK = http.StatusOK
+	)
+	type Request struct {
+		Name string `json:"name"`
+	}
+	body, err := json.Marshal(Request{
+		Name: newName,
+	})
+	if err != nil {
+		return err
+	}
+
+	resp, err := e.client.Send(ctx, Method, e.endpoints, e.path(APIPath, oldName), bytes.NewReader(body), withHeader("Content-Type", "application/json"))
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != StatusOK {
+		return parseErrorResponse(resp)
+	}
+	return nil
+}
+
+// DescribeKey returns the KeyInfo for the given key.
+// It returns ErrKeyNotFound if no such key exists.
+//
+// The returned KeyInfo contains the number of encrypt,
+// decrypt and generate operations the server has performed
+// with the key. These usage counters are not persisted and
+// get reset when the server restarts. Hence, they are not
+// suitable as the only source for compliance reports.
+func (e *Enclave) DescribeKey(ctx context.Context, name string) (*KeyInfo, error) {
+	const (
+		APIPath         = "/v1/key/describe"
+		Method          = http.MethodGet
+		StatusOK        = http.StatusOK
+		MaxResponseSize = 1 << 20 // 1 MiB
+	)
+	type Response struct {
+		Name          string       `json:"name"`
+		CreatedAt     time.Time    `json:"created_at"`
+		CreatedBy     Identity     `json:"created_by"`
+		Algorithm     KeyAlgorithm `json:"algorithm"`
+		EncryptCount  uint64       `json:"encrypt_count"`
+		DecryptCount  uint64       `json:"decrypt_count"`
+		GenerateCount uint64       `json:"generate_count"`
+		LastUsedAt    time.Time    `json:"last_used_at"`
+	}
+
+	resp, err := e.client.Send(ctx, Method, e.endpoints, e.path(APIPath, name), nil)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != StatusOK {
+		return nil, parseErrorResponse(resp)
+	}
+	var response Response
+	if err = json.NewDecoder(io.LimitReader(resp.Body, MaxResponseSize)).Decode(&response); err != nil {
+		return nil, err
+	}
+	return &KeyInfo{
+		Name:          response.Name,
+		CreatedAt:     response.CreatedAt,
+		CreatedBy:     response.CreatedBy,
+		Algorithm:     response.Algorithm,
+		EncryptCount:  response.EncryptCount,
+		DecryptCount:  response.DecryptCount,
+		GenerateCount: response.GenerateCount,
+		LastUsedAt:    response.LastUsedAt,
+	}, nil
+}
+
+// KeyExists reports whether the named key exists at the
+// KES server. It returns false and no error if the key
+// does not exist.
+//
+// KeyExists requires permission to describe the key.
+func (e *Enclave) KeyExists(ctx context.Context, name string) (bool, error) {
+	_, err := e.DescribeKey(ctx, name)
+	if errors.Is(err, ErrKeyNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
 // DeleteKey deletes the key from a KES server. It returns
 // ErrKeyNotFound if no such key exists.
 func (e *Enclave) DeleteKey(ctx context.Context, name string) error {
@@ -118,6 +510,55 @@ func (e *Enclave) DeleteKey(ctx context.Context, name string) error {
 // GenerateKey returns ErrKeyNotFound if no key with the given name
 // exists.
 func (e *Enclave) GenerateKey(ctx context.Context, name string, context []byte) (DEK, error) {
+	dek, _, err := e.generateKey(ctx, name, context, nil, 0, false)
+	return dek, err
+}
+
+// GenerateKeyWithNonce returns a new generated data encryption
+// key (DEK), like GenerateKey. However, the KES server returns
+// the same DEK when it receives a request from the same client
+// identity with the same key name, context and nonce again within
+// a short time window. Hence, a client can retry
+// GenerateKeyWithNonce safely without generating additional DEKs.
+// If the server remembers too many DEKs already, a retried
+// request may return a new DEK.
+//
+// The nonce should be a unique value, e.g. a random 16 byte
+// value, per DEK and must not be larger than 256 bytes.
+func (e *Enclave) GenerateKeyWithNonce(ctx context.Context, name string, context, nonce []byte) (DEK, error) {
+	dek, _, err := e.generateKey(ctx, name, context, nonce, 0, false)
+	return dek, err
+}
+
+// GenerateKeyN returns a new generated data encryption key (DEK),
+// like GenerateKey. However, the plaintext DEK is bits long. The
+// bits must be either 128 or 256.
+//
+// The DEK is still encrypted with the named key at the KES server.
+// Only the length of the generated DEK changes. A 128 bit DEK may
+// be used for AES-128 encryption, for example.
+func (e *Enclave) GenerateKeyN(ctx context.Context, name string, bits int, context []byte) (DEK, error) {
+	if bits != 128 && bits != 256 {
+		return DEK{}, errInvalidDEKLength
+	}
+	dek, _, err := e.generateKey(ctx, name, context, nil, bits, false)
+	return dek, err
+}
+
+// GenerateAttestedKey returns a new generated data encryption
+// key (DEK), like GenerateKey, and an Attestation signed by the
+// KES server. The Attestation proves that the DEK ciphertext
+// has been generated by the KES server at the attestation time
+// and can be verified via VerifyAttestation.
+//
+// The KES server only signs attestations if it has been
+// configured with an attestation key. Otherwise, it rejects
+// the request with HTTP 501 (Not Implemented).
+func (e *Enclave) GenerateAttestedKey(ctx context.Context, name string, context []byte) (DEK, Attestation, error) {
+	return e.generateKey(ctx, name, context, nil, 0, true)
+}
+
+func (e *Enclave) generateKey(ctx context.Context, name string, context, nonce []byte, bits int, attest bool) (DEK, Attestation, error) {
 	const (
 		APIPath         = "/v1/key/generate"
 		Method          = http.MethodPost
@@ -126,6 +567,83 @@ func (e *Enclave) GenerateKey(ctx context.Context, name string, context []byte)
 	)
 	type Request struct {
 		Context []byte `json:"context,omitempty"` // A context is optional
+		Nonce   []byte `json:"nonce,omitempty"`
+		Bits    int    `json:"bits,omitempty"`
+		Attest  bool   `json:"attest,omitempty"`
+	}
+	type AttestationResponse struct {
+		Time      time.Time `json:"time"`
+		Signature []byte    `json:"signature"`
+	}
+	type Response struct {
+		Plaintext   []byte               `json:"plaintext"`
+		Ciphertext  []byte               `json:"ciphertext"`
+		Attestation *AttestationResponse `json:"attestation"`
+	}
+
+	body, err := json.Marshal(Request{
+		Context: context,
+		Nonce:   nonce,
+		Bits:    bits,
+		Attest:  attest,
+	})
+	if err != nil {
+		return DEK{}, Attestation{}, err
+	}
+	if err = e.checkRequestSize(len(body)); err != nil {
+		return DEK{}, Attestation{}, err
+	}
+
+	resp, err := e.client.Send(ctx, Method, e.endpoints, e.path(APIPath, name), bytes.NewReader(body), withHeader("Content-Type", "application/json"))
+	if err != nil {
+		return DEK{}, Attestation{}, err
+	}
+	if resp.StatusCode != StatusOK {
+		return DEK{}, Attestation{}, parseErrorResponse(resp)
+	}
+	defer resp.Body.Close()
+
+	var response Response
+	if err = decodeSecret(resp, MaxResponseSize, &response); err != nil {
+		return DEK{}, Attestation{}, err
+	}
+	dek := DEK{
+		Plaintext:  response.Plaintext,
+		Ciphertext: response.Ciphertext,
+	}
+	if !attest {
+		return dek, Attestation{}, nil
+	}
+	if response.Attestation == nil {
+		return DEK{}, Attestation{}, errors.New("kes: server response does not contain an attestation")
+	}
+	return dek, Attestation{
+		Time:      response.Attestation.Time,
+		Signature: response.Attestation.Signature,
+	}, nil
+}
+
+// GenerateKeys returns n new data encryption keys (DEKs) generated
+// with a single request to the KES server. Each DEK is generated
+// independently, as if by GenerateKey, and is bound to the same
+// context. The DEKs are returned in the order the KES server
+// generated them.
+//
+// The KES server limits how many DEKs can be generated by a single
+// request. Currently, n must not be greater than 1000.
+//
+// GenerateKeys returns ErrKeyNotFound if no key with the given name
+// exists.
+func (e *Enclave) GenerateKeys(ctx context.Context, name string, n int, context []byte) ([]DEK, error) {
+	const (
+		APIPath         = "/v1/key/bulk/generate"
+		Method          = http.MethodPost
+		StatusOK        = http.StatusOK
+		MaxResponseSize = 1 << 20 // 1 MiB
+	)
+	type Request struct {
+		Context []byte `json:"context,omitempty"` // A context is optional
+		Count   int    `json:"count"`
 	}
 	type Response struct {
 		Plaintext  []byte `json:"plaintext"`
@@ -134,25 +652,92 @@ func (e *Enclave) GenerateKey(ctx context.Context, name string, context []byte)
 
 	body, err := json.Marshal(Request{
 		Context: context,
+		Count:   n,
 	})
 	if err != nil {
-		return DEK{}, err
+		return nil, err
 	}
 
 	resp, err := e.client.Send(ctx, Method, e.endpoints, e.path(APIPath, name), bytes.NewReader(body), withHeader("Content-Type", "application/json"))
 	if err != nil {
-		return DEK{}, err
+		return nil, err
+	}
+	if resp.StatusCode != StatusOK {
+		return nil, parseErrorResponse(resp)
+	}
+	defer resp.Body.Close()
+
+	var responses []Response
+	if err = decodeSecret(resp, MaxResponseSize, &responses); err != nil {
+		return nil, err
+	}
+	deks := make([]DEK, 0, len(responses))
+	for _, response := range responses {
+		deks = append(deks, DEK(response))
+	}
+	return deks, nil
+}
+
+// GenerateKeyMulti returns a new plaintext data encryption key
+// and one ciphertext of it per named key. Each ciphertext is
+// generated as if by GenerateKey, is bound to the same context
+// and decrypts to the same plaintext independently. For example,
+// a DEK can be wrapped under a primary and a secondary key such
+// that either key can decrypt it.
+//
+// The KES server only generates the DEK if the client is allowed
+// to generate DEKs with each of the keys. It limits how many keys
+// can be used by a single request. Currently, at most 100 keys
+// may be specified.
+//
+// GenerateKeyMulti returns ErrKeyNotFound if any of the keys does
+// not exist.
+func (e *Enclave) GenerateKeyMulti(ctx context.Context, keys []string, context []byte) ([]byte, map[string][]byte, error) {
+	const (
+		APIPath         = "/v1/key/multi/generate"
+		Method          = http.MethodPost
+		StatusOK        = http.StatusOK
+		MaxResponseSize = 1 << 20 // 1 MiB
+	)
+	type Request struct {
+		Keys    []string `json:"keys,omitempty"`
+		Context []byte   `json:"context,omitempty"` // A context is optional
+	}
+	type Response struct {
+		Plaintext   []byte            `json:"plaintext"`
+		Ciphertexts map[string][]byte `json:"ciphertexts"`
+	}
+	if len(keys) == 0 {
+		return nil, nil, errors.New("kes: no keys specified")
+	}
+
+	body, err := json.Marshal(Request{
+		Keys:    keys[1:],
+		Context: context,
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	resp, err := e.client.Send(ctx, Method, e.endpoints, e.path(APIPath, keys[0]), bytes.NewReader(body), withHeader("Content-Type", "application/json"))
+	if err != nil {
+		return nil, nil, err
 	}
 	if resp.StatusCode != StatusOK {
-		return DEK{}, parseErrorResponse(resp)
+		return nil, nil, parseErrorResponse(resp)
 	}
 	defer resp.Body.Close()
 
 	var response Response
-	if err = json.NewDecoder(io.LimitReader(resp.Body, MaxResponseSize)).Decode(&response); err != nil {
-		return DEK{}, err
+	if err = decodeSecret(resp, MaxResponseSize, &response); err != nil {
+		return nil, nil, err
+	}
+	for _, name := range keys {
+		if _, ok := response.Ciphertexts[name]; !ok {
+			return nil, nil, errors.New("kes: server response does not contain a ciphertext for each key")
+		}
 	}
-	return DEK(response), nil
+	return response.Plaintext, response.Ciphertexts, nil
 }
 
 // Encrypt encrypts the given plaintext with the named key at the
@@ -184,6 +769,9 @@ func (e *Enclave) Encrypt(ctx context.Context, name string, plaintext, context [
 	if err != nil {
 		return nil, err
 	}
+	if err = e.checkRequestSize(len(body)); err != nil {
+		return nil, err
+	}
 
 	resp, err := e.client.Send(ctx, Method, e.endpoints, e.path(APIPath, name), bytes.NewReader(body), withHeader("Content-Type", "application/json"))
 	if err != nil {
@@ -229,6 +817,9 @@ func (e *Enclave) Decrypt(ctx context.Context, name string, ciphertext, context
 	if err != nil {
 		return nil, err
 	}
+	if err = e.checkRequestSize(len(body)); err != nil {
+		return nil, err
+	}
 
 	resp, err := e.client.Send(ctx, Method, e.endpoints, e.path(APIPath, name), bytes.NewReader(body), withHeader("Content-Type", "application/json"))
 	if err != nil {
@@ -240,12 +831,105 @@ func (e *Enclave) Decrypt(ctx context.Context, name string, ciphertext, context
 	defer resp.Body.Close()
 
 	var response Response
-	if err = json.NewDecoder(io.LimitReader(resp.Body, MaxResponseSize)).Decode(&response); err != nil {
+	if err = decodeSecret(resp, MaxResponseSize, &response); err != nil {
 		return nil, err
 	}
 	return response.Plaintext, nil
 }
 
+// ReWrap decrypts the ciphertext with the named key at the KES
+// server and encrypts the resulting plaintext again with the same
+// key. The plaintext never leaves the KES server. The exact same
+// context, used during Encrypt, must be provided. It is also used
+// as context of the returned ciphertext.
+//
+// ReWrap returns ErrKeyNotFound if no such key exists. It returns
+// ErrDecrypt when the ciphertext has been modified or a different
+// context value is provided.
+func (e *Enclave) ReWrap(ctx context.Context, name string, ciphertext, context []byte) ([]byte, error) {
+	const (
+		APIPath         = "/v1/key/rewrap"
+		Method          = http.MethodPost
+		StatusOK        = http.StatusOK
+		MaxResponseSize = 1 << 20 // 1 MiB
+	)
+	type Request struct {
+		Ciphertext []byte `json:"ciphertext"`
+		Context    []byte `json:"context,omitempty"` // A context is optional
+	}
+	type Response struct {
+		Ciphertext []byte `json:"ciphertext"`
+	}
+	body, err := json.Marshal(Request{
+		Ciphertext: ciphertext,
+		Context:    context,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := e.client.Send(ctx, Method, e.endpoints, e.path(APIPath, name), bytes.NewReader(body), withHeader("Content-Type", "application/json"))
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != StatusOK {
+		return nil, parseErrorResponse(resp)
+	}
+	defer resp.Body.Close()
+
+	var response Response
+	if err = json.NewDecoder(io.LimitReader(resp.Body, MaxResponseSize)).Decode(&response); err != nil {
+		return nil, err
+	}
+	return response.Ciphertext, nil
+}
+
+// DeriveKey derives a new key of the given length from the
+// named key at the KES server using HKDF-SHA256 with the given
+// info and the fixed salt "kes-key-derive". The same info always
+// yields the same derived key.
+// Hence, an application can derive distinct keys from one key
+// without storing each derived key.
+//
+// DeriveKey returns ErrKeyNotFound if no such key exists.
+func (e *Enclave) DeriveKey(ctx context.Context, name string, info []byte, length int) ([]byte, error) {
+	const (
+		APIPath         = "/v1/key/derive"
+		Method          = http.MethodPost
+		StatusOK        = http.StatusOK
+		MaxResponseSize = 1 << 20 // 1 MiB
+	)
+	type Request struct {
+		Info   []byte `json:"info,omitempty"`
+		Length int    `json:"length"`
+	}
+	type Response struct {
+		Key []byte `json:"key"`
+	}
+	body, err := json.Marshal(Request{
+		Info:   info,
+		Length: length,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := e.client.Send(ctx, Method, e.endpoints, e.path(APIPath, name), bytes.NewReader(body), withHeader("Content-Type", "application/json"))
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != StatusOK {
+		return nil, parseErrorResponse(resp)
+	}
+	defer resp.Body.Close()
+
+	var response Response
+	if err = decodeSecret(resp, MaxResponseSize, &response); err != nil {
+		return nil, err
+	}
+	return response.Key, nil
+}
+
 // DecryptAll decrypts all ciphertexts with the named key at the
 // KES server. It either returns all decrypted plaintexts or the
 // first decryption error.
@@ -292,7 +976,7 @@ func (e *Enclave) DecryptAll(ctx context.Context, name string, ciphertexts ...CC
 	defer resp.Body.Close()
 
 	var responses []Response
-	if err = json.NewDecoder(io.LimitReader(resp.Body, MaxResponseSize)).Decode(&responses); err != nil {
+	if err = decodeSecret(resp, MaxResponseSize, &responses); err != nil {
 		return nil, err
 	}
 
@@ -313,6 +997,30 @@ func (e *Enclave) DecryptAll(ctx context.Context, name string, ciphertexts ...CC
 // The pattern matching happens on the server side. If pattern is empty
 // the KeyIterator iterates over all key names.
 func (e *Enclave) ListKeys(ctx context.Context, pattern string) (*KeyIterator, error) {
+	return e.ListKeysWithOptions(ctx, pattern, ListKeysOptions{})
+}
+
+// ListKeysSorted lists all names of cryptographic keys that match
+// the given pattern, like ListKeys. However, the KES server returns
+// the keys in the given order.
+//
+// The KES server has to fetch all matching keys before it can sort
+// them. Hence, the first key may be returned later than by ListKeys.
+// To limit its memory usage, the server only sorts up to 10000 keys.
+// If more keys match the pattern, it returns an error.
+func (e *Enclave) ListKeysSorted(ctx context.Context, pattern string, order KeyOrder) (*KeyIterator, error) {
+	return e.ListKeysWithOptions(ctx, pattern, ListKeysOptions{Order: order})
+}
+
+// ListKeysWithOptions lists all names of cryptographic keys that
+// match the given pattern, like ListKeys. However, the KES server
+// only returns keys created within the time window specified by
+// the options, if any, and in the specified order. If requested,
+// it also reports when and by whom each key has been created.
+//
+// Keys without a creation timestamp are not listed if the options
+// specify a time window.
+func (e *Enclave) ListKeysWithOptions(ctx context.Context, pattern string, opts ListKeysOptions) (*KeyIterator, error) {
 	const (
 		APIPath  = "/v1/key/list"
 		Method   = http.MethodGet
@@ -324,16 +1032,42 @@ func (e *Enclave) ListKeys(ctx context.Context, pattern string) (*KeyIterator, e
 		pattern = MatchAll
 	}
 
-	resp, err := e.client.Send(ctx, Method, e.endpoints, e.path(APIPath, pattern), nil)
+	query := url.Values{}
+	if opts.Order != "" {
+		query.Set("sort", string(opts.Order))
+	}
+	if opts.Metadata {
+		query.Set("metadata", "true")
+	}
+	if !opts.CreatedAfter.IsZero() {
+		query.Set("created_after", opts.CreatedAfter.UTC().Format(time.RFC3339Nano))
+	}
+	if !opts.CreatedBefore.IsZero() {
+		query.Set("created_before", opts.CreatedBefore.UTC().Format(time.RFC3339Nano))
+	}
+	api := e.path(APIPath, pattern)
+	if len(query) > 0 {
+		if e.name != "" {
+			api += "&" + query.Encode()
+		} else {
+			api += "?" + query.Encode()
+		}
+	}
+	resp, err := e.client.Send(ctx, Method, e.endpoints, api, nil, withHeader("Accept-Encoding", acceptEncoding))
 	if err != nil {
 		return nil, err
 	}
 	if resp.StatusCode != StatusOK {
 		return nil, parseErrorResponse(resp)
 	}
+	body, err := responseBody(resp)
+	if err != nil {
+		resp.Body.Close()
+		return nil, err
+	}
 	return &KeyIterator{
-		decoder: json.NewDecoder(resp.Body),
-		closer:  resp.Body,
+		decoder: json.NewDecoder(body),
+		closer:  body,
 	}, nil
 }
 
@@ -343,16 +1077,37 @@ func (e *Enclave) ListKeys(ctx context.Context, pattern string) (*KeyIterator, e
 //
 // AssignPolicy returns PolicyNotFound if no such policy exists.
 func (e *Enclave) AssignPolicy(ctx context.Context, policy string, identity Identity) error {
+	return e.assignPolicy(ctx, policy, identity, 0)
+}
+
+// AssignPolicyWithTTL assigns the policy to the identity
+// for the given amount of time. Once the TTL has elapsed,
+// the KES server treats the identity as unknown and rejects
+// any request it sends.
+//
+// AssignPolicyWithTTL returns PolicyNotFound if no such
+// policy exists.
+func (e *Enclave) AssignPolicyWithTTL(ctx context.Context, policy string, identity Identity, ttl time.Duration) error {
+	if ttl <= 0 {
+		return errors.New("kes: invalid policy assignment: TTL must be positive")
+	}
+	return e.assignPolicy(ctx, policy, identity, ttl)
+}
+
+// assignPolicy assigns the policy to the identity. If ttl
+// is not zero, the assignment expires after ttl.
+func (e *Enclave) assignPolicy(ctx context.Context, policy string, identity Identity, ttl time.Duration) error {
 	const (
 		APIPath  = "/v1/policy/assign"
 		Method   = http.MethodPost
 		StatusOK = http.StatusOK
 	)
 	type Request struct {
-		Identity Identity `json:"identity"`
+		Identity Identity      `json:"identity"`
+		TTL      time.Duration `json:"ttl,omitempty"`
 	}
 
-	body, err := json.Marshal(Request{Identity: identity})
+	body, err := json.Marshal(Request{Identity: identity, TTL: ttl})
 	if err != nil {
 		return err
 	}
@@ -366,6 +1121,57 @@ func (e *Enclave) AssignPolicy(ctx context.Context, policy string, identity Iden
 	return nil
 }
 
+// AssignPolicies assigns the policy to all given identities
+// within a single request. The KES admin identity cannot be
+// assigned to any policy.
+//
+// If some identities cannot be assigned, AssignPolicies returns
+// an *AssignPolicyError describing which identities failed. All
+// other identities are assigned to the policy.
+func (e *Enclave) AssignPolicies(ctx context.Context, policy string, identities []Identity) error {
+	const (
+		APIPath         = "/v1/policy/bulk/assign"
+		Method          = http.MethodPost
+		StatusOK        = http.StatusOK
+		MaxResponseSize = 1 << 20 // 1 MiB
+	)
+	type Request struct {
+		Identities []Identity `json:"identities"`
+	}
+	type Response struct {
+		Identity Identity `json:"identity"`
+		Err      string   `json:"error"`
+	}
+
+	body, err := json.Marshal(Request{Identities: identities})
+	if err != nil {
+		return err
+	}
+	resp, err := e.client.Send(ctx, Method, e.endpoints, e.path(APIPath, policy), bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != StatusOK {
+		return parseErrorResponse(resp)
+	}
+	defer resp.Body.Close()
+
+	var responses []Response
+	if err = json.NewDecoder(io.LimitReader(resp.Body, MaxResponseSize)).Decode(&responses); err != nil {
+		return err
+	}
+	if len(responses) == 0 {
+		return nil
+	}
+	assignErr := &AssignPolicyError{
+		Errors: make(map[Identity]error, len(responses)),
+	}
+	for _, response := range responses {
+		assignErr.Errors[response.Identity] = errors.New(response.Err)
+	}
+	return assignErr
+}
+
 // SetPolicy creates the given policy. If a policy with the same
 // name already exists, SetPolicy overwrites the existing policy
 // with the given one. Any existing identites will be assigned to
@@ -392,6 +1198,53 @@ func (e *Enclave) SetPolicy(ctx context.Context, name string, policy *Policy) er
 	return nil
 }
 
+// DescribePolicy returns the PolicyInfo for the given
+// policy. It contains the rules of the policy, its parent
+// policies and the effective rules that apply to requests.
+// It returns ErrPolicyNotFound if no such policy exists.
+func (e *Enclave) DescribePolicy(ctx context.Context, name string) (*PolicyInfo, error) {
+	const (
+		APIPath         = "/v1/policy/describe"
+		Method          = http.MethodGet
+		StatusOK        = http.StatusOK
+		MaxResponseSize = 1 << 20 // 1 MiB
+	)
+	type Response struct {
+		Allow          []string    `json:"allow"`
+		Deny           []string    `json:"deny"`
+		Extends        []string    `json:"extends"`
+		EffectiveAllow []string    `json:"effective_allow"`
+		EffectiveDeny  []string    `json:"effective_deny"`
+		AllowWindow    *TimeWindow `json:"effective_allow_window"`
+		CreatedAt      time.Time   `json:"created_at"`
+		CreatedBy      Identity    `json:"created_by"`
+	}
+
+	resp, err := e.client.Send(ctx, Method, e.endpoints, e.path(APIPath, name), nil)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != StatusOK {
+		return nil, parseErrorResponse(resp)
+	}
+
+	var response Response
+	if err = json.NewDecoder(io.LimitReader(resp.Body, MaxResponseSize)).Decode(&response); err != nil {
+		return nil, err
+	}
+	return &PolicyInfo{
+		Name:           name,
+		CreatedAt:      response.CreatedAt,
+		CreatedBy:      response.CreatedBy,
+		Allow:          response.Allow,
+		Deny:           response.Deny,
+		Extends:        response.Extends,
+		EffectiveAllow: response.EffectiveAllow,
+		EffectiveDeny:  response.EffectiveDeny,
+		AllowWindow:    response.AllowWindow,
+	}, nil
+}
+
 // GetPolicy returns the policy with the given name.
 // It returns ErrPolicyNotFound if no such policy
 // exists.
@@ -419,7 +1272,9 @@ func (e *Enclave) GetPolicy(ctx context.Context, name string) (*Policy, error) {
 }
 
 // DeletePolicy deletes the policy with the given name. Any
-// assigned identities will be removed as well.
+// assigned identities will be removed as well. A policy that
+// is extended by another policy cannot be deleted until the
+// other policy is deleted or no longer extends it.
 //
 // It returns ErrPolicyNotFound if no such policy exists.
 func (e *Enclave) DeletePolicy(ctx context.Context, name string) error {
@@ -439,6 +1294,64 @@ func (e *Enclave) DeletePolicy(ctx context.Context, name string) error {
 	return nil
 }
 
+// Apply applies the given AdminOps in order. Either all
+// AdminOps succeed or none does. If an AdminOp fails, Apply
+// returns an *ApplyError that contains the AdminOp and its
+// error.
+//
+// Only the admin identity can apply AdminOps. Apply may be
+// used to create a policy and assign identities to it at
+// once, without leaving a partially applied state behind.
+func (e *Enclave) Apply(ctx context.Context, ops []AdminOp) error {
+	const (
+		APIPath     = "/v1/apply"
+		Method      = http.MethodPost
+		StatusOK    = http.StatusOK
+		MaxBodySize = 1 << 20 // 1 MiB
+	)
+	type Request struct {
+		Ops []AdminOp `json:"ops"`
+	}
+	type Response struct {
+		Index *int `json:"index"`
+	}
+	body, err := json.Marshal(Request{
+		Ops: ops,
+	})
+	if err != nil {
+		return err
+	}
+
+	resp, err := e.client.Send(ctx, Method, e.endpoints, e.path(APIPath), bytes.NewReader(body), withHeader("Content-Type", "application/json"))
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode == StatusOK {
+		return nil
+	}
+
+	// The server reports which operation has failed. Hence,
+	// we have to read the response body before parsing the
+	// error.
+	data, err := io.ReadAll(io.LimitReader(resp.Body, MaxBodySize))
+	resp.Body.Close()
+	if err != nil {
+		return err
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(data))
+	resp.ContentLength = int64(len(data))
+	err = parseErrorResponse(resp)
+
+	var response Response
+	if json.Unmarshal(data, &response) != nil || response.Index == nil {
+		return err
+	}
+	if i := *response.Index; i >= 0 && i < len(ops) {
+		return &ApplyError{Index: i, Op: ops[i], Err: err}
+	}
+	return err
+}
+
 // ListPolicies lists all policy names that match the given pattern.
 //
 // The pattern matching happens on the server side. If pattern is empty
@@ -455,16 +1368,21 @@ func (e *Enclave) ListPolicies(ctx context.Context, pattern string) (*PolicyIter
 		pattern = MatchAll
 	}
 
-	resp, err := e.client.Send(ctx, Method, e.endpoints, e.path(APIPath, pattern), nil)
+	resp, err := e.client.Send(ctx, Method, e.endpoints, e.path(APIPath, pattern), nil, withHeader("Accept-Encoding", acceptEncoding))
 	if err != nil {
 		return nil, err
 	}
 	if resp.StatusCode != StatusOK {
 		return nil, parseErrorResponse(resp)
 	}
+	body, err := responseBody(resp)
+	if err != nil {
+		resp.Body.Close()
+		return nil, err
+	}
 	return &PolicyIterator{
-		decoder: json.NewDecoder(resp.Body),
-		closer:  resp.Body,
+		decoder: json.NewDecoder(body),
+		closer:  body,
 	}, nil
 }
 
@@ -481,6 +1399,8 @@ func (e *Enclave) DescribeIdentity(ctx context.Context, identity Identity) (*Ide
 		Policy    string    `json:"policy"`
 		CreatedAt time.Time `json:"created_at"`
 		CreatedBy Identity  `json:"created_by"`
+		ExpiresAt time.Time `json:"expires_at"`
+		Alias     string    `json:"alias"`
 	}
 
 	resp, err := e.client.Send(ctx, Method, e.endpoints, e.path(APIPath, identity.String()), nil)
@@ -500,12 +1420,15 @@ func (e *Enclave) DescribeIdentity(ctx context.Context, identity Identity) (*Ide
 		IsAdmin:   response.IsAdmin,
 		CreatedAt: response.CreatedAt,
 		CreatedBy: response.CreatedBy,
+		ExpiresAt: response.ExpiresAt,
+		Alias:     response.Alias,
 	}, nil
 }
 
 // DescribeSelf returns an IdentityInfo describing the identity
 // making the API request. It also returns the assigned policy,
-// if any.
+// if any. The IdentityInfo contains the Allow and Deny rules
+// of the policy, unless the identity is the admin.
 //
 // DescribeSelf allows an application to obtain identity and
 // policy information about itself.
@@ -517,13 +1440,14 @@ func (e *Enclave) DescribeSelf(ctx context.Context) (*IdentityInfo, *Policy, err
 		MaxResponseSize = 1 << 20 // 1 MiB
 	)
 	type InlinePolicy struct {
-		Allow []string `json:"allow"`
-		Deny  []string `json:"deny"`
+		Allow []string `json:"Allow"`
+		Deny  []string `json:"Deny"`
 	}
 	type Response struct {
 		Identity   Identity     `json:"identity"`
 		IsAdmin    bool         `json:"admin"`
 		PolicyName string       `json:"policy_name"`
+		Alias      string       `json:"alias"`
 		CreatedAt  time.Time    `json:"created_at"`
 		CreatedBy  Identity     `json:"created_by"`
 		Policy     InlinePolicy `json:"policy"`
@@ -546,6 +1470,10 @@ func (e *Enclave) DescribeSelf(ctx context.Context) (*IdentityInfo, *Policy, err
 		CreatedAt: response.CreatedAt,
 		CreatedBy: response.CreatedBy,
 		IsAdmin:   response.IsAdmin,
+		Alias:     response.Alias,
+	}
+	if !info.IsAdmin {
+		info.Allow, info.Deny = response.Policy.Allow, response.Policy.Deny
 	}
 	policy := &Policy{
 		Allow: response.Policy.Allow,
@@ -576,6 +1504,82 @@ func (e *Enclave) DeleteIdentity(ctx context.Context, identity Identity) error {
 	return nil
 }
 
+// SetIdentityAlias sets a human-readable alias for the given
+// identity. Aliases are unique such that no two identities
+// can have the same alias. An empty alias removes the current
+// alias of the identity.
+//
+// An alias is purely cosmetic. A KES server never uses it to
+// authorize requests.
+//
+// SetIdentityAlias returns ErrAliasExists if another identity
+// already uses the alias.
+func (e *Enclave) SetIdentityAlias(ctx context.Context, identity Identity, alias string) error {
+	const (
+		APIPath  = "/v1/identity/alias"
+		Method   = http.MethodPost
+		StatusOK = http.StatusOK
+	)
+	type Request struct {
+		Alias string `json:"alias"`
+	}
+
+	body, err := json.Marshal(Request{Alias: alias})
+	if err != nil {
+		return err
+	}
+	resp, err := e.client.Send(ctx, Method, e.endpoints, e.path(APIPath, identity.String()), bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != StatusOK {
+		return parseErrorResponse(resp)
+	}
+	return nil
+}
+
+// DeleteIdentitiesByPolicy removes all identities assigned
+// to the given policy and returns the number of removed
+// identities. Once removed, any operation issued by one of
+// these identities will fail with ErrNotAllowed.
+//
+// Only the KES admin can delete identities by policy. The
+// KES admin identity is never removed.
+//
+// If the server fails to delete one of the identities, it
+// stops. DeleteIdentitiesByPolicy then returns the number
+// of identities removed before the failure and the error.
+func (e *Enclave) DeleteIdentitiesByPolicy(ctx context.Context, policy string) (int, error) {
+	const (
+		APIPath         = "/v1/identity/delete-by-policy"
+		Method          = http.MethodDelete
+		StatusOK        = http.StatusOK
+		MaxResponseSize = 1 << 20 // 1 MiB
+	)
+	type Response struct {
+		Deleted int    `json:"deleted"`
+		Err     string `json:"error"`
+	}
+
+	resp, err := e.client.Send(ctx, Method, e.endpoints, e.path(APIPath, policy), nil)
+	if err != nil {
+		return 0, err
+	}
+	if resp.StatusCode != StatusOK {
+		return 0, parseErrorResponse(resp)
+	}
+	defer resp.Body.Close()
+
+	var response Response
+	if err = json.NewDecoder(io.LimitReader(resp.Body, MaxResponseSize)).Decode(&response); err != nil {
+		return 0, err
+	}
+	if response.Err != "" {
+		return response.Deleted, errors.New(response.Err)
+	}
+	return response.Deleted, nil
+}
+
 // ListIdentities lists all identites that match the given pattern.
 //
 // The pattern matching happens on the server side. If pattern is empty
@@ -587,16 +1591,52 @@ func (e *Enclave) ListIdentities(ctx context.Context, pattern string) (*Identity
 		StatusOK = http.StatusOK
 	)
 
-	resp, err := e.client.Send(ctx, Method, e.endpoints, e.path(APIPath, pattern), nil)
+	resp, err := e.client.Send(ctx, Method, e.endpoints, e.path(APIPath, pattern), nil, withHeader("Accept-Encoding", acceptEncoding))
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != StatusOK {
+		return nil, parseErrorResponse(resp)
+	}
+	body, err := responseBody(resp)
+	if err != nil {
+		resp.Body.Close()
+		return nil, err
+	}
+	return &IdentityIterator{
+		decoder: json.NewDecoder(body),
+		closer:  body,
+	}, nil
+}
+
+// ListPolicyIdentities lists all identities that are assigned
+// to the policy with the given name.
+//
+// The policy does not have to exist. Hence, ListPolicyIdentities
+// also lists identities that are still assigned to a policy that
+// has been deleted.
+func (e *Enclave) ListPolicyIdentities(ctx context.Context, policy string) (*IdentityIterator, error) {
+	const (
+		APIPath  = "/v1/policy/identities"
+		Method   = http.MethodGet
+		StatusOK = http.StatusOK
+	)
+
+	resp, err := e.client.Send(ctx, Method, e.endpoints, e.path(APIPath, policy), nil, withHeader("Accept-Encoding", acceptEncoding))
 	if err != nil {
 		return nil, err
 	}
 	if resp.StatusCode != StatusOK {
 		return nil, parseErrorResponse(resp)
 	}
+	body, err := responseBody(resp)
+	if err != nil {
+		resp.Body.Close()
+		return nil, err
+	}
 	return &IdentityIterator{
-		decoder: json.NewDecoder(resp.Body),
-		closer:  resp.Body,
+		decoder: json.NewDecoder(body),
+		closer:  body,
 	}, nil
 }
 
@@ -605,7 +1645,21 @@ func (e *Enclave) path(api string, args ...string) string {
 		api = path.Join(api, url.PathEscape(arg))
 	}
 	if e.name != "" {
-		api = "?enclave=" + url.QueryEscape(e.name)
+		api += "?enclave=" + url.QueryEscape(e.name)
 	}
 	return api
 }
+
+// checkRequestSize returns a *RequestTooLargeError if a
+// request body of the given size exceeds the max. request
+// size of the enclave.
+func (e *Enclave) checkRequestSize(size int) error {
+	limit := e.maxRequestSize
+	if limit <= 0 {
+		limit = MaxContextSize
+	}
+	if int64(size) > limit {
+		return &RequestTooLargeError{Size: int64(size), Limit: limit}
+	}
+	return nil
+}
@@ -0,0 +1,28 @@
+// Copyright 2022 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package kes
+
+import "testing"
+
+var enclavePathTests = []struct {
+	Enclave string
+	API     string
+	Args    []string
+	Path    string
+}{
+	{Enclave: "", API: "/v1/key/create", Args: []string{"my-key"}, Path: "/v1/key/create/my-key"},                          // 0
+	{Enclave: "tenant-1", API: "/v1/key/create", Args: []string{"my-key"}, Path: "/v1/key/create/my-key?enclave=tenant-1"}, // 1
+	{Enclave: "tenant-1", API: "/v1/identity/self/describe", Path: "/v1/identity/self/describe?enclave=tenant-1"},          // 2
+	{Enclave: "tenant 1", API: "/v1/key/list", Args: []string{"*"}, Path: "/v1/key/list/%2A?enclave=tenant+1"},             // 3
+}
+
+func TestEnclavePath(t *testing.T) {
+	for i, test := range enclavePathTests {
+		enclave := Enclave{name: test.Enclave}
+		if path := enclave.path(test.API, test.Args...); path != test.Path {
+			t.Fatalf("Test %d: path mismatch: got '%s' - want '%s'", i, path, test.Path)
+		}
+	}
+}
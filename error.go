@@ -6,9 +6,12 @@ package kes
 
 import (
 	"encoding/json"
+	"fmt"
 	"io"
 	"net/http"
+	"strconv"
 	"strings"
+	"time"
 )
 
 // KES server API errors
@@ -34,6 +37,10 @@ var (
 	// the ciphertext has been (maliciously) modified.
 	ErrDecrypt = NewError(http.StatusBadRequest, "decryption failed: ciphertext is not authentic")
 
+	// ErrAliasExists is returned by a KES server when a client tries
+	// to set an identity alias that is already used by another identity.
+	ErrAliasExists = NewError(http.StatusBadRequest, "identity alias already exists")
+
 	// ErrEnclaveExists is returned by a KES server when a client tries
 	// to create an enclave that already exists.
 	ErrEnclaveExists = NewError(http.StatusBadRequest, "enclave already exists")
@@ -41,12 +48,24 @@ var (
 	// ErrEnclaveNotFound is returned by a KES server when a client tries
 	// to access an enclave which does not exist.
 	ErrEnclaveNotFound = NewError(http.StatusNotFound, "enclave does not exist")
+
+	// ErrSealed is returned by a KES server when a client tries to
+	// access a sealed vault. A sealed vault rejects any key, policy
+	// or identity operation until it gets unsealed again.
+	ErrSealed = NewError(http.StatusForbidden, "vault sealed")
+
+	// ErrRateLimited is returned by a KES server when a client
+	// exceeds a rate limit. The error returned by a Client is a
+	// *RateLimitError that matches ErrRateLimited via errors.Is
+	// and tells the client how long to wait before retrying.
+	ErrRateLimited = NewError(http.StatusTooManyRequests, "too many requests: rate limit exceeded")
 )
 
 // Error is a KES server API error.
 type Error struct {
-	code    int
-	message string
+	code      int
+	message   string
+	requestID string
 }
 
 // NewError returns a new Error with the given
@@ -61,6 +80,17 @@ func NewError(code int, msg string) Error {
 // Status returns the HTTP status code of the error.
 func (e Error) Status() int { return e.code }
 
+// RequestID returns the ID of the request that failed,
+// as reported by the KES server. It can be used to find
+// the corresponding audit event in the server logs.
+//
+// The request ID is only set for errors returned by a
+// KES server due to an internal failure, i.e. with a
+// 5xx status code. Otherwise, it is empty such that
+// errors can be compared to well-known errors, like
+// ErrKeyNotFound.
+func (e Error) RequestID() string { return e.requestID }
+
 func (e Error) Error() string { return e.message }
 
 // parseErrorResponse returns an error containing
@@ -68,16 +98,38 @@ func (e Error) Error() string { return e.message }
 // as error message if the response is an error
 // response - i.e. status code >= 400.
 //
+// If the response status code is 429 (Too Many Requests),
+// parseErrorResponse returns a *RateLimitError.
+//
 // If the response status code is < 400, e.g. 200 OK,
 // parseErrorResponse returns nil and does not attempt
 // to read or close the response body.
 //
 // If resp is an error response, parseErrorResponse reads
-// and closes the response body.
+// and closes the response body. If the response status
+// code is >= 500, the returned Error contains the request
+// ID sent by the server.
 func parseErrorResponse(resp *http.Response) error {
 	if resp == nil || resp.StatusCode < 400 {
 		return nil
 	}
+	err := parseError(resp)
+	if kesErr, ok := err.(Error); ok && resp.StatusCode == http.StatusTooManyRequests {
+		return &RateLimitError{
+			Err:        kesErr,
+			retryAfter: parseRetryAfter(resp.Header.Get("Retry-After"), time.Now()),
+		}
+	}
+	if kesErr, ok := err.(Error); ok && resp.StatusCode >= 500 {
+		kesErr.requestID = resp.Header.Get("X-Request-Id")
+		return kesErr
+	}
+	return err
+}
+
+// parseError returns an error containing the response
+// status code and response body as error message.
+func parseError(resp *http.Response) error {
 	if resp.Body == nil {
 		return NewError(resp.StatusCode, "")
 	}
@@ -116,3 +168,64 @@ func parseErrorResponse(resp *http.Response) error {
 	}
 	return NewError(resp.StatusCode, sb.String())
 }
+
+// RateLimitError is returned by a Client when the KES
+// server rejects a request with 429 (Too Many Requests),
+// e.g. because the client exceeds a policy rate limit.
+//
+// A RateLimitError matches ErrRateLimited via errors.Is.
+type RateLimitError struct {
+	Err Error // The error returned by the KES server
+
+	retryAfter time.Duration
+}
+
+// Status returns the HTTP status code 429 (Too Many Requests).
+func (e *RateLimitError) Status() int { return e.Err.Status() }
+
+// RetryAfter returns the duration a client should wait
+// before sending the request again, as specified by the
+// KES server. It returns 0 if the server has not sent a
+// valid Retry-After header.
+func (e *RateLimitError) RetryAfter() time.Duration { return e.retryAfter }
+
+func (e *RateLimitError) Error() string { return e.Err.Error() }
+
+// Unwrap returns the error returned by the KES server.
+func (e *RateLimitError) Unwrap() error { return e.Err }
+
+// Is reports whether target is ErrRateLimited.
+func (e *RateLimitError) Is(target error) bool { return target == ErrRateLimited }
+
+// parseRetryAfter parses the value of a Retry-After header,
+// which is either a number of seconds or an HTTP date. It
+// returns 0 if the value is invalid or lies in the past.
+func parseRetryAfter(value string, now time.Time) time.Duration {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.ParseUint(value, 10, 32); err == nil {
+		return time.Duration(seconds) * time.Second
+	}
+	if date, err := http.ParseTime(value); err == nil && date.After(now) {
+		return date.Sub(now)
+	}
+	return 0
+}
+
+// AssignPolicyError is returned by AssignPolicies when
+// one or more identities could not be assigned to the
+// policy.
+type AssignPolicyError struct {
+	Errors map[Identity]error // The error for each identity that could not be assigned
+}
+
+func (e *AssignPolicyError) Error() string {
+	if len(e.Errors) == 1 {
+		for identity, err := range e.Errors {
+			return fmt.Sprintf("kes: failed to assign identity %q: %v", identity, err)
+		}
+	}
+	return fmt.Sprintf("kes: failed to assign %d identities", len(e.Errors))
+}
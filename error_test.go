@@ -5,8 +5,12 @@
 package kes
 
 import (
+	"errors"
+	"io"
 	"net/http"
+	"strings"
 	"testing"
+	"time"
 )
 
 var newErrorTests = []struct {
@@ -18,6 +22,7 @@ var newErrorTests = []struct {
 	{Code: http.StatusNotFound, Message: "key does not exist", Err: ErrKeyNotFound},
 	{Code: http.StatusBadRequest, Message: "key already exists", Err: ErrKeyExists},
 	{Code: http.StatusForbidden, Message: "not authorized: insufficient permissions", Err: ErrNotAllowed},
+	{Code: http.StatusForbidden, Message: "vault sealed", Err: ErrSealed},
 }
 
 func TestNewError(t *testing.T) {
@@ -28,3 +33,88 @@ func TestNewError(t *testing.T) {
 		}
 	}
 }
+
+var parseErrorResponseTests = []struct {
+	Status    int
+	Body      string
+	RequestID string
+	Err       Error
+}{
+	{Status: http.StatusNotFound, Body: `{"message":"key does not exist"}`, RequestID: "abc", Err: ErrKeyNotFound},                                                                                  // 0
+	{Status: http.StatusInternalServerError, Body: `{"message":"internal error"}`, RequestID: "abc", Err: Error{code: http.StatusInternalServerError, message: "internal error", requestID: "abc"}}, // 1
+	{Status: http.StatusServiceUnavailable, Body: `{"message":"unavailable"}`, Err: NewError(http.StatusServiceUnavailable, "unavailable")},                                                         // 2
+}
+
+func TestParseErrorResponse(t *testing.T) {
+	for i, test := range parseErrorResponseTests {
+		resp := &http.Response{
+			StatusCode:    test.Status,
+			Header:        http.Header{},
+			Body:          io.NopCloser(strings.NewReader(test.Body)),
+			ContentLength: int64(len(test.Body)),
+		}
+		resp.Header.Set("Content-Type", "application/json")
+		if test.RequestID != "" {
+			resp.Header.Set("X-Request-Id", test.RequestID)
+		}
+
+		err := parseErrorResponse(resp)
+		if err != test.Err {
+			t.Fatalf("Test %d: got '%v' - want '%v'", i, err, test.Err)
+		}
+		if id := err.(Error).RequestID(); id != test.Err.RequestID() {
+			t.Fatalf("Test %d: request ID mismatch: got '%s' - want '%s'", i, id, test.Err.RequestID())
+		}
+	}
+}
+
+func TestParseRateLimitResponse(t *testing.T) {
+	const Body = `{"message":"too many requests: rate limit exceeded"}`
+	resp := &http.Response{
+		StatusCode:    http.StatusTooManyRequests,
+		Header:        http.Header{},
+		Body:          io.NopCloser(strings.NewReader(Body)),
+		ContentLength: int64(len(Body)),
+	}
+	resp.Header.Set("Content-Type", "application/json")
+	resp.Header.Set("Retry-After", "3")
+
+	err := parseErrorResponse(resp)
+	if !errors.Is(err, ErrRateLimited) {
+		t.Fatalf("Error should match '%v': got '%v'", ErrRateLimited, err)
+	}
+	var rateLimitErr *RateLimitError
+	if !errors.As(err, &rateLimitErr) {
+		t.Fatalf("Error should be a RateLimitError: got '%T'", err)
+	}
+	if retryAfter := rateLimitErr.RetryAfter(); retryAfter != 3*time.Second {
+		t.Fatalf("Retry-After mismatch: got '%v' - want '%v'", retryAfter, 3*time.Second)
+	}
+	var kesErr Error
+	if !errors.As(err, &kesErr) || kesErr.Status() != http.StatusTooManyRequests {
+		t.Fatalf("Error should unwrap to an Error with status '%d': got '%v'", http.StatusTooManyRequests, err)
+	}
+}
+
+var parseRetryAfterTests = []struct {
+	Value      string
+	RetryAfter time.Duration
+}{
+	{Value: "", RetryAfter: 0},                                             // 0
+	{Value: "0", RetryAfter: 0},                                            // 1
+	{Value: "5", RetryAfter: 5 * time.Second},                              // 2
+	{Value: " 120 ", RetryAfter: 2 * time.Minute},                          // 3
+	{Value: "-1", RetryAfter: 0},                                           // 4
+	{Value: "Sun, 06 Nov 1994 08:49:47 GMT", RetryAfter: 10 * time.Second}, // 5
+	{Value: "Sun, 06 Nov 1994 08:49:27 GMT", RetryAfter: 0},                // 6
+	{Value: "soon", RetryAfter: 0},                                         // 7
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(1994, time.November, 6, 8, 49, 37, 0, time.UTC)
+	for i, test := range parseRetryAfterTests {
+		if retryAfter := parseRetryAfter(test.Value, now); retryAfter != test.RetryAfter {
+			t.Fatalf("Test %d: got '%v' - want '%v'", i, retryAfter, test.RetryAfter)
+		}
+	}
+}
@@ -5,9 +5,15 @@
 package kes
 
 import (
+	"crypto"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"io"
+	"strings"
 	"time"
 )
 
@@ -31,6 +37,50 @@ func (id Identity) IsUnknown() bool { return id == IdentityUnknown }
 // the identity.
 func (id Identity) String() string { return string(id) }
 
+// Validate returns an error if the identity is not a
+// well-formed identity - i.e. a hex-encoded SHA-256 hash
+// consisting of 64 lower-case hex characters.
+func (id Identity) Validate() error {
+	if len(id) != 2*sha256.Size {
+		return fmt.Errorf("kes: invalid identity %q: must be %d hex characters long", string(id), 2*sha256.Size)
+	}
+	for _, c := range id {
+		if (c < '0' || c > '9') && (c < 'a' || c > 'f') {
+			return fmt.Errorf("kes: invalid identity %q: must only contain lower-case hex characters", string(id))
+		}
+	}
+	return nil
+}
+
+// ParseIdentity parses s as an identity and returns an
+// error if s is not a well-formed identity. Leading and
+// trailing white space is ignored.
+func ParseIdentity(s string) (Identity, error) {
+	id := Identity(strings.TrimSpace(s))
+	if err := id.Validate(); err != nil {
+		return IdentityUnknown, err
+	}
+	return id, nil
+}
+
+// IdentityFromPublicKey returns the identity of a client
+// certificate that contains the given public key. It is
+// the hex-encoded SHA-256 hash of the public key's ASN.1
+// DER-encoded X.509 SubjectPublicKeyInfo - i.e. the same
+// identity a KES server computes during the TLS handshake.
+//
+// Hence, IdentityFromPublicKey can be used to compute the
+// identity of a client before its certificate has been
+// issued.
+func IdentityFromPublicKey(pub crypto.PublicKey) (Identity, error) {
+	spki, err := x509.MarshalPKIXPublicKey(pub)
+	if err != nil {
+		return IdentityUnknown, err
+	}
+	identity := sha256.Sum256(spki)
+	return Identity(hex.EncodeToString(identity[:])), nil
+}
+
 // IdentityInfo describes a KES identity.
 type IdentityInfo struct {
 	Identity  Identity
@@ -38,17 +88,33 @@ type IdentityInfo struct {
 	Policy    string    // Name of the associated policy
 	CreatedAt time.Time // Point in time when the identity was created
 	CreatedBy Identity  // Identity that created the identity
+	ExpiresAt time.Time // Point in time when the policy assignment expires, if any
+	Alias     string    // Human-readable name of the identity, if any
+
+	// Allow and Deny are the rules of the associated policy.
+	// They are only populated by DescribeSelf and are nil
+	// for an admin identity since the admin is not subject
+	// to any policy.
+	Allow []string
+	Deny  []string
 }
 
 // IdentityIterator iterates over a stream of IdentityInfo objects.
 // Close the IdentityIterator to release associated resources.
+//
+// An IdentityIterator is not safe for concurrent use. Calling
+// Next while another call of Next is in progress returns
+// false. However, Close may be called concurrently to
+// cancel an in-progress call of Next.
 type IdentityIterator struct {
 	decoder *json.Decoder
 	closer  io.Closer
 
 	current IdentityInfo
 	err     error
-	closed  bool
+	closed  bool // Close has been called
+	done    bool // The stream has been read completely
+	guard   iteratorGuard
 }
 
 // Value returns the current IdentityInfo. It remains valid
@@ -67,6 +133,10 @@ func (i *IdentityIterator) Policy() string { return i.current.Policy }
 // identity. It is a short-hand for Value().CreatedAt.
 func (i *IdentityIterator) CreatedAt() time.Time { return i.current.CreatedAt }
 
+// Alias returns the alias of the current identity, if any.
+// It is a short-hand for Value().Alias.
+func (i *IdentityIterator) Alias() string { return i.current.Alias }
+
 // CreatedBy returns the identiy that created the current identity.
 // It is a short-hand for Value().CreatedBy.
 func (i *IdentityIterator) CreatedBy() Identity { return i.current.CreatedBy }
@@ -82,17 +152,34 @@ func (i *IdentityIterator) Next() bool {
 		Policy    string    `json:"policy"`
 		CreatedAt time.Time `json:"created_at"`
 		CreatedBy Identity  `json:"created_by"`
+		Alias     string    `json:"alias"`
 
 		Err string `json:"error"`
 	}
 
-	if i.closed || i.err != nil {
+	if !i.enter() {
+		return false
+	}
+	defer i.guard.Leave()
+
+	if i.closed {
+		if i.err == nil {
+			i.err = ErrIteratorClosed
+		}
+		return false
+	}
+	if i.done || i.err != nil {
 		return false
 	}
 	var resp Response
 	if err := i.decoder.Decode(&resp); err != nil {
+		if i.guard.Canceled() { // Close has been called concurrently
+			i.current = IdentityInfo{}
+			i.closed = true
+			return false
+		}
 		if errors.Is(err, io.EOF) {
-			i.err = i.Close()
+			i.err = i.finish()
 		} else {
 			i.err = err
 		}
@@ -108,6 +195,7 @@ func (i *IdentityIterator) Next() bool {
 		Policy:    resp.Policy,
 		CreatedAt: resp.CreatedAt,
 		CreatedBy: resp.CreatedBy,
+		Alias:     resp.Alias,
 	}
 	return true
 }
@@ -123,14 +211,20 @@ func (i *IdentityIterator) WriteTo(w io.Writer) (int64, error) {
 		Policy    string    `json:"policy,omitempty"`
 		CreatedAt time.Time `json:"created_at,omitempty"`
 		CreatedBy Identity  `json:"created_by,omitempty"`
+		Alias     string    `json:"alias,omitempty"`
 
 		Err string `json:"error,omitempty"`
 	}
-	if i.err != nil {
-		return 0, i.err
+	if !i.enter() {
+		return 0, ErrIteratorInUse
 	}
-	if i.closed {
-		return 0, errors.New("kes: WriteTo called after Close")
+	defer i.guard.Leave()
+
+	if i.closed && i.err == nil {
+		i.err = ErrIteratorClosed
+	}
+	if i.done || i.err != nil {
+		return 0, i.err
 	}
 
 	cw := countWriter{W: w}
@@ -138,8 +232,13 @@ func (i *IdentityIterator) WriteTo(w io.Writer) (int64, error) {
 	for {
 		var resp Response
 		if err := i.decoder.Decode(&resp); err != nil {
+			if i.guard.Canceled() { // Close has been called concurrently
+				i.current = IdentityInfo{}
+				i.closed = true
+				return cw.N, ErrIteratorClosed
+			}
 			if errors.Is(err, io.EOF) {
-				i.err = i.Close()
+				i.err = i.finish()
 			} else {
 				i.err = err
 			}
@@ -156,16 +255,60 @@ func (i *IdentityIterator) WriteTo(w io.Writer) (int64, error) {
 	}
 }
 
-// Close closes the IdentityIterator and releases
-// any associated resources
+// Close closes the IdentityIterator and releases any
+// associated resources. It returns the first error
+// encountered while iterating, if any.
+//
+// Close is idempotent. Calling Close more than once
+// returns the same error. Once closed, Next returns
+// false and Value returns the zero IdentityInfo. Calling Next
+// after Close records ErrIteratorClosed, which any
+// subsequent Close call returns.
+//
+// Calling Close while Next is in progress closes the
+// underlying stream such that Next returns false. Then
+// Close returns nil and the IdentityIterator is closed once
+// Next returns.
 func (i *IdentityIterator) Close() error {
+	if !i.enter() {
+		if i.guard.Cancel() {
+			i.closer.Close()
+		}
+		return nil
+	}
+	defer i.guard.Leave()
+
 	if !i.closed {
-		err := i.closer.Close()
-		if i.err == nil {
-			i.err = err
+		if !i.done {
+			if err := i.closer.Close(); i.err == nil {
+				i.err = err
+			}
 		}
+		i.current = IdentityInfo{}
 		i.closed = true
-		return err
 	}
 	return i.err
 }
+
+// finish releases any associated resources once the
+// entire stream has been read. The caller must hold
+// the iterator guard.
+func (i *IdentityIterator) finish() error {
+	i.current = IdentityInfo{}
+	i.done = true
+	return i.closer.Close()
+}
+
+// enter acquires the iterator guard. It returns false
+// if the IdentityIterator is in use. Once Close has canceled
+// the IdentityIterator, enter marks it as closed.
+func (i *IdentityIterator) enter() bool {
+	if !i.guard.Enter() {
+		return false
+	}
+	if i.guard.Canceled() && !i.closed {
+		i.current = IdentityInfo{}
+		i.closed = true
+	}
+	return true
+}
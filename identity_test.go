@@ -0,0 +1,108 @@
+// Copyright 2022 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package kes
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/hex"
+	"math/big"
+	"testing"
+	"time"
+)
+
+func TestIdentityFromPublicKey(t *testing.T) {
+	ed25519Pub, ed25519Priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate Ed25519 key: %v", err)
+	}
+	ecdsaPriv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate ECDSA key: %v", err)
+	}
+	rsaPriv, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("Failed to generate RSA key: %v", err)
+	}
+
+	tests := []struct {
+		PublicKey  crypto.PublicKey
+		PrivateKey crypto.Signer
+	}{
+		{PublicKey: ed25519Pub, PrivateKey: ed25519Priv},         // 0
+		{PublicKey: &ecdsaPriv.PublicKey, PrivateKey: ecdsaPriv}, // 1
+		{PublicKey: &rsaPriv.PublicKey, PrivateKey: rsaPriv},     // 2
+	}
+	for i, test := range tests {
+		template := x509.Certificate{
+			SerialNumber: big.NewInt(1),
+			Subject:      pkix.Name{CommonName: "test"},
+			NotBefore:    time.Now(),
+			NotAfter:     time.Now().Add(time.Hour),
+		}
+		der, err := x509.CreateCertificate(rand.Reader, &template, &template, test.PublicKey, test.PrivateKey)
+		if err != nil {
+			t.Fatalf("Test %d: failed to create certificate: %v", i, err)
+		}
+		cert, err := x509.ParseCertificate(der)
+		if err != nil {
+			t.Fatalf("Test %d: failed to parse certificate: %v", i, err)
+		}
+		sum := sha256.Sum256(cert.RawSubjectPublicKeyInfo)
+		want := Identity(hex.EncodeToString(sum[:]))
+
+		identity, err := IdentityFromPublicKey(test.PublicKey)
+		if err != nil {
+			t.Fatalf("Test %d: failed to compute identity: %v", i, err)
+		}
+		if identity != want {
+			t.Fatalf("Test %d: identity mismatch: got '%s' - want '%s'", i, identity, want)
+		}
+	}
+
+	if _, err = IdentityFromPublicKey(nil); err == nil {
+		t.Fatal("Computing the identity of a nil public key succeeded")
+	}
+}
+
+var parseIdentityTests = []struct {
+	Identity   string
+	ShouldFail bool
+}{
+	{Identity: "3ecfcdf38fcbe141ae26a1030f81e96b753365a46760ae6b578698a97c59fd22"},    // 0
+	{Identity: " 3ecfcdf38fcbe141ae26a1030f81e96b753365a46760ae6b578698a97c59fd22\n"}, // 1
+
+	{Identity: "", ShouldFail: true}, // 2
+	{Identity: "3ecfcdf38fcbe141ae26a1030f81e96b753365a46760ae6b578698a97c59fd2", ShouldFail: true},   // 3
+	{Identity: "3ecfcdf38fcbe141ae26a1030f81e96b753365a46760ae6b578698a97c59fd223", ShouldFail: true}, // 4
+	{Identity: "3ECFCDF38FCBE141AE26A1030F81E96B753365A46760AE6B578698A97C59FD22", ShouldFail: true},  // 5
+	{Identity: "3ecfcdf38fcbe141ae26a1030f81e96b753365a46760ae6b578698a97c59fdzz", ShouldFail: true},  // 6
+	{Identity: "3ecfcdf38fcbe141ae26a1030f81e96b 753365a46760ae6b578698a97c59fd2", ShouldFail: true},  // 7
+}
+
+func TestParseIdentity(t *testing.T) {
+	for i, test := range parseIdentityTests {
+		identity, err := ParseIdentity(test.Identity)
+		if test.ShouldFail {
+			if err == nil {
+				t.Fatalf("Test %d: should fail but succeeded", i)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("Test %d: should pass but failed: %v", i, err)
+		}
+		if err = identity.Validate(); err != nil {
+			t.Fatalf("Test %d: parsed identity is invalid: %v", i, err)
+		}
+	}
+}
@@ -19,6 +19,10 @@ import (
 // identity does not exist.
 var ErrIdentityNotFound = kes.NewError(http.StatusNotFound, "identity does not exist")
 
+// ErrAliasExists is returned by an IdentitySet if an alias
+// is already used by another identity.
+var ErrAliasExists = kes.ErrAliasExists
+
 // Identify computes the identity of the given HTTP request.
 //
 // If the request was not sent over TLS or no client
@@ -61,10 +65,12 @@ type IdentitySet interface {
 	Admin(ctx context.Context) (kes.Identity, error)
 
 	// Assign assigns the policy to the given identity.
+	// If expiresAt is not zero, the assignment expires
+	// at the given point in time.
 	//
 	// It returns an error when the identity is equal
 	// to the admin identity.
-	Assign(ctx context.Context, policy string, identity kes.Identity) error
+	Assign(ctx context.Context, policy string, identity kes.Identity, expiresAt time.Time) error
 
 	// Get returns the IdentityInfo of an assigned identity.
 	//
@@ -72,6 +78,14 @@ type IdentitySet interface {
 	// associated to the given identity.
 	Get(ctx context.Context, identity kes.Identity) (IdentityInfo, error)
 
+	// SetAlias sets the alias of an assigned identity. An
+	// empty alias removes the identity's current alias.
+	//
+	// It returns ErrIdentityNotFound when the identity is
+	// not assigned and ErrAliasExists when the alias is
+	// already used by another identity.
+	SetAlias(ctx context.Context, identity kes.Identity, alias string) error
+
 	// Delete deletes the given identity from the list of
 	// assigned identites.
 	//
@@ -132,6 +146,22 @@ type IdentityInfo struct {
 	// CreatedBy is the identity that assigned this
 	// identity to its policy.
 	CreatedBy kes.Identity
+
+	// ExpiresAt is the point in time when the policy
+	// assignment expires. If zero, the assignment
+	// never expires.
+	ExpiresAt time.Time
+
+	// Alias is an optional human-readable name of the
+	// identity. It is purely cosmetic and must not be
+	// used for authorization decisions.
+	Alias string
+}
+
+// IsExpired reports whether the policy assignment
+// has expired.
+func (i *IdentityInfo) IsExpired() bool {
+	return !i.ExpiresAt.IsZero() && time.Now().After(i.ExpiresAt)
 }
 
 // ROIdentitySet wraps i and returns a readonly IdentitySet.
@@ -145,7 +175,7 @@ func (r roIdentitySet) Admin(ctx context.Context) (kes.Identity, error) {
 	return r.set.Admin(ctx)
 }
 
-func (r roIdentitySet) Assign(context.Context, string, kes.Identity) error {
+func (r roIdentitySet) Assign(context.Context, string, kes.Identity, time.Time) error {
 	return kes.NewError(http.StatusNotImplemented, "readonly identity: assigning an identity is not supported")
 }
 
@@ -153,6 +183,10 @@ func (r roIdentitySet) Get(ctx context.Context, identity kes.Identity) (Identity
 	return r.set.Get(ctx, identity)
 }
 
+func (r roIdentitySet) SetAlias(context.Context, kes.Identity, string) error {
+	return kes.NewError(http.StatusNotImplemented, "readonly identity: setting an identity alias is not supported")
+}
+
 func (r roIdentitySet) Delete(context.Context, kes.Identity) error {
 	return kes.NewError(http.StatusNotImplemented, "readonly identity: deleting an identity is not supported")
 }
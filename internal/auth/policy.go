@@ -81,6 +81,23 @@ type Policy struct {
 	// against the URL path of incoming requests.
 	Deny []string
 
+	// RateLimit maps glob patterns to the max. number
+	// of requests per second an identity may send to
+	// API paths matching the pattern. Requests that
+	// exceed a rate limit are rejected.
+	RateLimit map[string]float64
+
+	// Extends is a list of parent policies. The policy
+	// inherits all allow, deny and rate limit rules of
+	// its parents. See ResolvePolicy.
+	Extends []string
+
+	// AllowWindow is an optional time window. If set, only
+	// requests within the window are allowed. A policy
+	// without a time window inherits the one of its first
+	// parent that has a time window. See ResolvePolicy.
+	AllowWindow *kes.TimeWindow
+
 	// CreatedAt is the point in time when the policy
 	// has been created.
 	CreatedAt time.Time
@@ -109,6 +126,124 @@ func (p *Policy) Verify(r *http.Request) error {
 	return kes.ErrNotAllowed
 }
 
+// VerifyExplicit reports whether the given HTTP request is
+// explicitly allowed. In contrast to Verify, an allow pattern
+// only matches if it names the API of the request literally.
+// Only the last path segment, e.g. the key name, may contain
+// wildcards. For example, "/v1/key/export/my-key" and
+// "/v1/key/export/*" match the API "/v1/key/export/my-key",
+// but "/v1/key/*/*" does not.
+//
+// Otherwise, VerifyExplicit returns ErrNotAllowed.
+func (p *Policy) VerifyExplicit(r *http.Request) error {
+	for _, pattern := range p.Deny {
+		if ok, err := path.Match(pattern, r.URL.Path); ok && err == nil {
+			return kes.ErrNotAllowed
+		}
+	}
+	api := path.Dir(r.URL.Path)
+	for _, pattern := range p.Allow {
+		if path.Dir(pattern) != api {
+			continue
+		}
+		if ok, err := path.Match(pattern, r.URL.Path); ok && err == nil {
+			return nil
+		}
+	}
+	return kes.ErrNotAllowed
+}
+
+// MaxPolicyDepth is the max. length of a policy inheritance
+// chain. A policy may extend a parent policy that extends
+// another parent policy, and so on, but not more than
+// MaxPolicyDepth times.
+const MaxPolicyDepth = 8
+
+// ErrPolicyCycle is returned by ResolvePolicy when a policy
+// extends itself - either directly or via its parents.
+var ErrPolicyCycle = kes.NewError(http.StatusBadRequest, "invalid policy: policy inheritance contains a cycle")
+
+// ErrPolicyTooDeep is returned by ResolvePolicy when a policy
+// inheritance chain is longer than MaxPolicyDepth.
+var ErrPolicyTooDeep = kes.NewError(http.StatusBadRequest, "invalid policy: policy inheritance exceeds max. depth")
+
+// ResolvePolicy returns the effective policy of the given
+// policy with the given name. The effective policy contains
+// all allow, deny and rate limit rules of the policy and of
+// all policies it extends, directly or indirectly. Since a
+// deny rule takes precedence over any allow rule, a parent's
+// deny rule also applies to requests allowed by the policy.
+// If the policy and one of its parents specify a rate limit
+// for the same pattern, the rate limit of the policy applies.
+// The same applies to the time window.
+//
+// It returns ErrPolicyCycle if the policy extends itself and
+// ErrPolicyTooDeep if the inheritance chain is too long. It
+// returns kes.ErrPolicyNotFound if a parent does not exist.
+func ResolvePolicy(ctx context.Context, policies PolicySet, name string, policy *Policy) (*Policy, error) {
+	if len(policy.Extends) == 0 {
+		return policy, nil
+	}
+
+	effective := &Policy{
+		Allow:       append([]string(nil), policy.Allow...),
+		Deny:        append([]string(nil), policy.Deny...),
+		RateLimit:   make(map[string]float64, len(policy.RateLimit)),
+		AllowWindow: policy.AllowWindow,
+		CreatedAt:   policy.CreatedAt,
+		CreatedBy:   policy.CreatedBy,
+	}
+	for pattern, rate := range policy.RateLimit {
+		effective.RateLimit[pattern] = rate
+	}
+
+	var (
+		chain    = map[string]bool{name: true} // Policies on the current inheritance chain
+		resolved = map[string]bool{}           // Policies whose rules have been added already
+	)
+	var extend func(parents []string, depth int) error
+	extend = func(parents []string, depth int) error {
+		for _, parent := range parents {
+			if chain[parent] {
+				return ErrPolicyCycle
+			}
+			if resolved[parent] {
+				continue
+			}
+			if depth > MaxPolicyDepth {
+				return ErrPolicyTooDeep
+			}
+
+			p, err := policies.Get(ctx, parent)
+			if err != nil {
+				return err
+			}
+			effective.Allow = append(effective.Allow, p.Allow...)
+			effective.Deny = append(effective.Deny, p.Deny...)
+			for pattern, rate := range p.RateLimit {
+				if _, ok := effective.RateLimit[pattern]; !ok {
+					effective.RateLimit[pattern] = rate
+				}
+			}
+			if effective.AllowWindow == nil {
+				effective.AllowWindow = p.AllowWindow
+			}
+
+			chain[parent] = true
+			if err = extend(p.Extends, depth+1); err != nil {
+				return err
+			}
+			delete(chain, parent)
+			resolved[parent] = true
+		}
+		return nil
+	}
+	if err := extend(policy.Extends, 1); err != nil {
+		return nil, err
+	}
+	return effective, nil
+}
+
 // ROPolicySet wraps p and returns a readonly PolicySet.
 func ROPolicySet(p PolicySet) PolicySet { return roPolicySet{set: p} }
 
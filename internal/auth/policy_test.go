@@ -0,0 +1,147 @@
+// Copyright 2022 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package auth
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strconv"
+	"testing"
+
+	"github.com/minio/kes"
+)
+
+// policyMap is a minimal PolicySet used for testing.
+type policyMap map[string]*Policy
+
+func (m policyMap) Set(_ context.Context, name string, policy *Policy) error {
+	m[name] = policy
+	return nil
+}
+
+func (m policyMap) Get(_ context.Context, name string) (*Policy, error) {
+	policy, ok := m[name]
+	if !ok {
+		return nil, kes.ErrPolicyNotFound
+	}
+	return policy, nil
+}
+
+func (m policyMap) Delete(_ context.Context, name string) error {
+	delete(m, name)
+	return nil
+}
+
+func (m policyMap) List(context.Context) (PolicyIterator, error) {
+	return nil, kes.NewError(http.StatusNotImplemented, "not implemented")
+}
+
+var resolvePolicyTests = []struct {
+	Policies policyMap
+	Name     string
+	Path     string
+	Allowed  bool
+	Err      error
+}{
+	{ // 0
+		Policies: policyMap{"child": {Allow: []string{"/v1/key/create/*"}}},
+		Name:     "child",
+		Path:     "/v1/key/create/my-key",
+		Allowed:  true,
+	},
+	{ // 1
+		Policies: policyMap{
+			"child":  {Allow: []string{"/v1/key/create/*"}, Extends: []string{"parent"}},
+			"parent": {Allow: []string{"/v1/key/generate/*"}},
+		},
+		Name:    "child",
+		Path:    "/v1/key/generate/my-key",
+		Allowed: true,
+	},
+	{ // 2
+		Policies: policyMap{
+			"child":  {Allow: []string{"/v1/key/generate/*"}, Extends: []string{"parent"}},
+			"parent": {Deny: []string{"/v1/key/generate/internal-*"}},
+		},
+		Name:    "child",
+		Path:    "/v1/key/generate/internal-key",
+		Allowed: false,
+	},
+	{ // 3
+		Policies: policyMap{
+			"child": {Extends: []string{"a", "b"}},
+			"a":     {Extends: []string{"base"}},
+			"b":     {Extends: []string{"base"}},
+			"base":  {Allow: []string{"/v1/key/decrypt/*"}},
+		},
+		Name:    "child",
+		Path:    "/v1/key/decrypt/my-key",
+		Allowed: true,
+	},
+	{ // 4
+		Policies: policyMap{
+			"child": {Extends: []string{"child"}},
+		},
+		Name: "child",
+		Err:  ErrPolicyCycle,
+	},
+	{ // 5
+		Policies: policyMap{
+			"child": {Extends: []string{"a"}},
+			"a":     {Extends: []string{"b"}},
+			"b":     {Extends: []string{"a"}},
+		},
+		Name: "child",
+		Err:  ErrPolicyCycle,
+	},
+	{ // 6
+		Policies: policyMap{
+			"child": {Extends: []string{"parent"}},
+		},
+		Name: "child",
+		Err:  kes.ErrPolicyNotFound,
+	},
+}
+
+func TestResolvePolicy(t *testing.T) {
+	ctx := context.Background()
+	for i, test := range resolvePolicyTests {
+		policy, err := ResolvePolicy(ctx, test.Policies, test.Name, test.Policies[test.Name])
+		if err != test.Err {
+			t.Fatalf("Test %d: got error '%v' - want '%v'", i, err, test.Err)
+		}
+		if err != nil {
+			continue
+		}
+
+		req := &http.Request{URL: &url.URL{Path: test.Path}}
+		if err = policy.Verify(req); test.Allowed && err != nil {
+			t.Fatalf("Test %d: request should be allowed: %v", i, err)
+		}
+		if !test.Allowed && err == nil {
+			t.Fatalf("Test %d: request should be denied", i)
+		}
+	}
+}
+
+func TestResolvePolicyDepth(t *testing.T) {
+	policies := policyMap{}
+	for i := 0; i < MaxPolicyDepth; i++ {
+		policies["policy-"+strconv.Itoa(i)] = &Policy{Extends: []string{"policy-" + strconv.Itoa(i+1)}}
+	}
+	policies["policy-"+strconv.Itoa(MaxPolicyDepth)] = &Policy{}
+
+	ctx := context.Background()
+	if _, err := ResolvePolicy(ctx, policies, "policy-0", policies["policy-0"]); err != nil {
+		t.Fatalf("Failed to resolve policy with max. depth: %v", err)
+	}
+
+	policies["policy-"+strconv.Itoa(MaxPolicyDepth)].Extends = []string{"policy-last"}
+	policies["policy-last"] = &Policy{}
+	if _, err := ResolvePolicy(ctx, policies, "policy-0", policies["policy-0"]); err != ErrPolicyTooDeep {
+		t.Fatalf("Resolving policy exceeding max. depth: got '%v' - want '%v'", err, ErrPolicyTooDeep)
+	}
+}
@@ -0,0 +1,168 @@
+// Copyright 2022 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package auth
+
+import (
+	"math"
+	"net/http"
+	"path"
+	"sync"
+	"time"
+
+	"github.com/minio/kes"
+)
+
+// RateLimitError is returned by a RateLimiter when
+// a request exceeds a rate limit.
+type RateLimitError struct {
+	retryAfter time.Duration
+}
+
+var _ error = (*RateLimitError)(nil) // compiler check
+
+// Status returns the HTTP status code 429 (Too Many Requests).
+func (e *RateLimitError) Status() int { return http.StatusTooManyRequests }
+
+// RetryAfter returns the duration a client should wait
+// before sending the request again.
+func (e *RateLimitError) RetryAfter() time.Duration { return e.retryAfter }
+
+func (e *RateLimitError) Error() string { return "too many requests: rate limit exceeded" }
+
+// A RateLimiter enforces the rate limits of policies.
+//
+// It keeps track of the request rate per identity and
+// rate limit pattern. The zero value is ready to use.
+type RateLimiter struct {
+	lock      sync.Mutex
+	buckets   map[rateLimitKey]*tokenBucket
+	lastSweep time.Time
+}
+
+// sweepInterval is the interval in which a RateLimiter
+// removes buckets that are no longer in use.
+const sweepInterval = 1 * time.Minute
+
+// Verify reports whether the identity may send the given
+// request according to the rate limits of the policy.
+//
+// It returns no error if no rate limit pattern matches the
+// URL path or no matching rate limit has been exceeded.
+// Otherwise, it returns a *RateLimitError.
+func (l *RateLimiter) Verify(identity kes.Identity, policy *Policy, r *http.Request) error {
+	if len(policy.RateLimit) == 0 {
+		return nil
+	}
+	return l.verify(identity, policy, r.URL.Path, time.Now())
+}
+
+func (l *RateLimiter) verify(identity kes.Identity, policy *Policy, urlPath string, now time.Time) error {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	if l.buckets == nil {
+		l.buckets = map[rateLimitKey]*tokenBucket{}
+	}
+	if now.Sub(l.lastSweep) >= sweepInterval {
+		l.sweep(now)
+	}
+
+	// A request consumes a token from every matching bucket.
+	// Hence, we first check that each matching bucket holds
+	// a token. Otherwise, a request that gets rejected by
+	// one rate limit would still count towards all others.
+	var (
+		buckets    = make([]*tokenBucket, 0, len(policy.RateLimit))
+		retryAfter time.Duration
+	)
+	for pattern, rate := range policy.RateLimit {
+		if ok, err := path.Match(pattern, urlPath); !ok || err != nil {
+			continue
+		}
+		if rate <= 0 {
+			return &RateLimitError{retryAfter: time.Second}
+		}
+
+		key := rateLimitKey{identity: identity, pattern: pattern}
+		bucket, ok := l.buckets[key]
+		if !ok || bucket.rate != rate {
+			bucket = newTokenBucket(rate, now)
+			l.buckets[key] = bucket
+		}
+		if d := bucket.wait(now); d > retryAfter {
+			retryAfter = d
+		}
+		buckets = append(buckets, bucket)
+	}
+	if retryAfter > 0 {
+		return &RateLimitError{retryAfter: retryAfter}
+	}
+	for _, bucket := range buckets {
+		bucket.take()
+	}
+	return nil
+}
+
+// sweep removes all buckets that are full again. Such a
+// bucket is equivalent to a new one. Hence, removing it
+// does not change which requests get rejected.
+func (l *RateLimiter) sweep(now time.Time) {
+	for key, bucket := range l.buckets {
+		if bucket.isFull(now) {
+			delete(l.buckets, key)
+		}
+	}
+	l.lastSweep = now
+}
+
+type rateLimitKey struct {
+	identity kes.Identity
+	pattern  string
+}
+
+// tokenBucket is a token bucket that gets refilled
+// with rate tokens per second. It can hold up to
+// max(1, rate) tokens such that short bursts are
+// possible.
+type tokenBucket struct {
+	rate   float64
+	size   float64
+	tokens float64
+	last   time.Time
+}
+
+func newTokenBucket(rate float64, now time.Time) *tokenBucket {
+	size := math.Max(1, rate)
+	return &tokenBucket{
+		rate:   rate,
+		size:   size,
+		tokens: size,
+		last:   now,
+	}
+}
+
+// wait refills the bucket and returns the duration
+// until it holds a token. It returns 0 if the bucket
+// holds at least one token.
+func (b *tokenBucket) wait(now time.Time) time.Duration {
+	if elapsed := now.Sub(b.last); elapsed > 0 {
+		b.tokens = math.Min(b.size, b.tokens+elapsed.Seconds()*b.rate)
+		b.last = now
+	}
+	if b.tokens < 1 {
+		return time.Duration((1 - b.tokens) / b.rate * float64(time.Second))
+	}
+	return 0
+}
+
+// take removes one token from the bucket. The bucket
+// must hold a token - i.e. wait must have returned 0.
+func (b *tokenBucket) take() { b.tokens-- }
+
+// isFull reports whether the bucket will have been
+// refilled completely at the given point in time.
+func (b *tokenBucket) isFull(now time.Time) bool {
+	return b.tokens+now.Sub(b.last).Seconds()*b.rate >= b.size
+}
@@ -0,0 +1,125 @@
+// Copyright 2022 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package auth
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/minio/kes"
+)
+
+var rateLimiterVerifyTests = []struct {
+	Policy   *Policy
+	Path     string
+	Requests int // Number of requests that must pass
+}{
+	{Policy: &Policy{}, Path: "/v1/key/generate/my-key", Requests: 100},                                                                        // 0
+	{Policy: &Policy{RateLimit: map[string]float64{"/v1/key/generate/*": 1}}, Path: "/v1/key/generate/my-key", Requests: 1},                    // 1
+	{Policy: &Policy{RateLimit: map[string]float64{"/v1/key/generate/*": 5}}, Path: "/v1/key/generate/my-key", Requests: 5},                    // 2
+	{Policy: &Policy{RateLimit: map[string]float64{"/v1/key/generate/*": 0.5}}, Path: "/v1/key/generate/my-key", Requests: 1},                  // 3
+	{Policy: &Policy{RateLimit: map[string]float64{"/v1/key/generate/*": 1}}, Path: "/v1/key/decrypt/my-key", Requests: 100},                   // 4
+	{Policy: &Policy{RateLimit: map[string]float64{"/v1/key/*/*": 2, "/v1/key/generate/*": 10}}, Path: "/v1/key/generate/my-key", Requests: 2}, // 5
+}
+
+func TestRateLimiterVerify(t *testing.T) {
+	const Identity = kes.Identity("57eb2da320a48ebe2750e95c50b3d64240aef4cd5d54c28a4f25155e88c98580")
+
+	for i, test := range rateLimiterVerifyTests {
+		var limiter RateLimiter
+		req := &http.Request{URL: &url.URL{Path: test.Path}}
+		for j := 0; j < test.Requests; j++ {
+			if err := limiter.Verify(Identity, test.Policy, req); err != nil {
+				t.Fatalf("Test %d: request %d got rejected: %v", i, j, err)
+			}
+		}
+		if len(test.Policy.RateLimit) == 0 {
+			continue
+		}
+
+		var rateLimitErr *RateLimitError
+		err := limiter.Verify(Identity, test.Policy, req)
+		if test.Path == "/v1/key/decrypt/my-key" {
+			if err != nil {
+				t.Fatalf("Test %d: unlimited request got rejected: %v", i, err)
+			}
+			continue
+		}
+		if !errors.As(err, &rateLimitErr) {
+			t.Fatalf("Test %d: request should have been rejected: got '%v'", i, err)
+		}
+		if rateLimitErr.Status() != http.StatusTooManyRequests {
+			t.Fatalf("Test %d: status code mismatch: got '%d' - want '%d'", i, rateLimitErr.Status(), http.StatusTooManyRequests)
+		}
+		if rateLimitErr.RetryAfter() <= 0 {
+			t.Fatalf("Test %d: invalid retry-after: got '%v'", i, rateLimitErr.RetryAfter())
+		}
+
+		// Another identity must not be affected by the rate limit.
+		if err = limiter.Verify("163d766f3e88f2a02b15a46bc541cc679c4cbb0a060405f298d5fc0d9d876bb3", test.Policy, req); err != nil {
+			t.Fatalf("Test %d: request of another identity got rejected: %v", i, err)
+		}
+	}
+}
+
+func TestRateLimiterRejectConsumesNoTokens(t *testing.T) {
+	const Identity = kes.Identity("57eb2da320a48ebe2750e95c50b3d64240aef4cd5d54c28a4f25155e88c98580")
+	var (
+		limiter RateLimiter
+		now     = time.Now()
+		policy  = &Policy{RateLimit: map[string]float64{"/v1/key/*/*": 2, "/v1/key/generate/*": 1}}
+	)
+
+	if err := limiter.verify(Identity, policy, "/v1/key/generate/my-key", now); err != nil {
+		t.Fatalf("Request got rejected: %v", err)
+	}
+	for i := 0; i < 5; i++ {
+		if err := limiter.verify(Identity, policy, "/v1/key/generate/my-key", now); err == nil {
+			t.Fatalf("Request %d should have been rejected", i)
+		}
+	}
+
+	// The rejected requests must not have consumed tokens
+	// of the "/v1/key/*/*" rate limit. Hence, one token is
+	// left.
+	if err := limiter.verify(Identity, policy, "/v1/key/decrypt/my-key", now); err != nil {
+		t.Fatalf("Request got rejected: %v", err)
+	}
+	if err := limiter.verify(Identity, policy, "/v1/key/decrypt/my-key", now); err == nil {
+		t.Fatal("Request should have been rejected")
+	}
+}
+
+func TestRateLimiterSweep(t *testing.T) {
+	const Identity = kes.Identity("57eb2da320a48ebe2750e95c50b3d64240aef4cd5d54c28a4f25155e88c98580")
+	var (
+		limiter RateLimiter
+		now     = time.Now()
+		policy  = &Policy{RateLimit: map[string]float64{"/v1/key/generate/*": 0.01}}
+	)
+
+	if err := limiter.verify(Identity, policy, "/v1/key/generate/my-key", now); err != nil {
+		t.Fatalf("Request got rejected: %v", err)
+	}
+	if err := limiter.verify("163d766f3e88f2a02b15a46bc541cc679c4cbb0a060405f298d5fc0d9d876bb3", policy, "/v1/key/generate/my-key", now); err != nil {
+		t.Fatalf("Request got rejected: %v", err)
+	}
+
+	// The buckets take 100 seconds to be refilled. Hence,
+	// a sweep after one minute must keep them.
+	limiter.lock.Lock()
+	limiter.sweep(now.Add(sweepInterval))
+	if n := len(limiter.buckets); n != 2 {
+		t.Fatalf("Bucket count mismatch: got '%d' - want '%d'", n, 2)
+	}
+	limiter.sweep(now.Add(100 * time.Second))
+	if n := len(limiter.buckets); n != 0 {
+		t.Fatalf("Bucket count mismatch: got '%d' - want '%d'", n, 0)
+	}
+	limiter.lock.Unlock()
+}
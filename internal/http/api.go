@@ -5,8 +5,10 @@
 package http
 
 import (
+	"crypto"
+	"crypto/rand"
 	"errors"
-	"log"
+	"fmt"
 	"net"
 	"net/http"
 	"net/url"
@@ -35,6 +37,14 @@ type ServerConfig struct {
 	// If empty, it defaults to v0.0.0-dev.
 	Version string
 
+	// Commit is the git commit the KES server
+	// has been built from. It may be empty.
+	Commit string
+
+	// BuildTime is the point in time when the
+	// KES server has been built. It may be zero.
+	BuildTime time.Time
+
 	// Certificate is TLS server certificate.
 	Certificate *Certificate
 
@@ -62,6 +72,32 @@ type ServerConfig struct {
 	// the server.
 	Metrics *metric.Metrics
 
+	// KeepAlive is the interval after which the server
+	// sends a heartbeat to clients subscribed to a log
+	// stream if no event has been sent in the meantime.
+	// Heartbeats keep idle streams alive when clients
+	// are connected via proxies that close idle
+	// connections.
+	//
+	// If KeepAlive is not positive, no heartbeats
+	// are sent.
+	KeepAlive time.Duration
+
+	// AttestationKey is an optional private key used to
+	// sign attestations of generated data keys. If nil,
+	// the server rejects requests for attestations.
+	AttestationKey crypto.Signer
+
+	// ValidateKeyName is an optional function that checks
+	// the name of a key before it gets created or imported.
+	// It can be used to enforce additional naming rules,
+	// e.g. a tenant prefix. The server always checks that
+	// the name is a valid API argument first.
+	//
+	// If ValidateKeyName returns an error, the server
+	// rejects the request with 400 Bad Request.
+	ValidateKeyName func(name string) error
+
 	APIs []API
 }
 
@@ -72,35 +108,54 @@ func NewServerMux(config *ServerConfig) *http.ServeMux {
 	mux := http.NewServeMux()
 	config.APIs = append(config.APIs, version(mux, config))
 	config.APIs = append(config.APIs, status(mux, config))
+	config.APIs = append(config.APIs, statusStream(mux, config))
 	config.APIs = append(config.APIs, metrics(mux, config))
 	config.APIs = append(config.APIs, listAPIs(mux, config))
 
 	config.APIs = append(config.APIs, createKey(mux, config))
 	config.APIs = append(config.APIs, importKey(mux, config))
+	config.APIs = append(config.APIs, describeKey(mux, config))
 	config.APIs = append(config.APIs, deleteKey(mux, config))
 	config.APIs = append(config.APIs, generateKey(mux, config))
 	config.APIs = append(config.APIs, encryptKey(mux, config))
 	config.APIs = append(config.APIs, decryptKey(mux, config))
 	config.APIs = append(config.APIs, bulkDecryptKey(mux, config))
+	config.APIs = append(config.APIs, bulkGenerateKey(mux, config))
+	config.APIs = append(config.APIs, multiGenerateKey(mux, config))
+	config.APIs = append(config.APIs, rewrapKey(mux, config))
+	config.APIs = append(config.APIs, deriveKey(mux, config))
+	config.APIs = append(config.APIs, exportKey(mux, config))
+	config.APIs = append(config.APIs, copyKey(mux, config))
+	config.APIs = append(config.APIs, renameKey(mux, config))
 	config.APIs = append(config.APIs, listKey(mux, config))
 
 	config.APIs = append(config.APIs, describePolicy(mux, config))
 	config.APIs = append(config.APIs, assignPolicy(mux, config))
+	config.APIs = append(config.APIs, bulkAssignPolicy(mux, config))
 	config.APIs = append(config.APIs, readPolicy(mux, config))
 	config.APIs = append(config.APIs, writePolicy(mux, config))
 	config.APIs = append(config.APIs, listPolicy(mux, config))
+	config.APIs = append(config.APIs, listPolicyIdentities(mux, config))
 	config.APIs = append(config.APIs, deletePolicy(mux, config))
+	config.APIs = append(config.APIs, applyOps(mux, config))
 
 	config.APIs = append(config.APIs, describeIdentity(mux, config))
 	config.APIs = append(config.APIs, selfDescribeIdentity(mux, config))
 	config.APIs = append(config.APIs, listIdentity(mux, config))
+	config.APIs = append(config.APIs, setIdentityAlias(mux, config))
 	config.APIs = append(config.APIs, deleteIdentity(mux, config))
+	config.APIs = append(config.APIs, deleteIdentitiesByPolicy(mux, config))
 
 	config.APIs = append(config.APIs, logErrorEvents(mux, config))
 	config.APIs = append(config.APIs, logAuditEvents(mux, config))
 
 	config.APIs = append(config.APIs, createEnclave(mux, config))
 	config.APIs = append(config.APIs, deleteEnclave(mux, config))
+	config.APIs = append(config.APIs, listEnclaves(mux, config))
+	config.APIs = append(config.APIs, describeEnclave(mux, config))
+	config.APIs = append(config.APIs, vaultStatus(mux, config))
+	config.APIs = append(config.APIs, sealVault(mux, config))
+	config.APIs = append(config.APIs, unsealVault(mux, config))
 
 	mux.HandleFunc("/", timeout(10*time.Second, func(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusNotImplemented)
@@ -113,12 +168,17 @@ var errMethodNotAllowed = kes.NewError(http.StatusMethodNotAllowed, http.StatusT
 // audit returns an http.ResponseWriter that wraps w
 // and logs an audit event containing some request
 // details right before w sends a response to the client.
-func audit(w http.ResponseWriter, r *http.Request, logger *log.Logger) http.ResponseWriter {
+func audit(w http.ResponseWriter, r *http.Request, config *ServerConfig) http.ResponseWriter {
+	id := requestID(r)
+	w.Header().Set(requestIDHeader, id)
+
 	aw := &AuditResponseWriter{
 		ResponseWriter: w,
-		Logger:         logger,
+		Logger:         config.AuditLog.Log(),
+		Metrics:        config.Metrics,
 
 		URL:       *r.URL,
+		RequestID: id,
 		Identity:  auth.Identify(r),
 		CreatedAt: time.Now(),
 	}
@@ -130,6 +190,39 @@ func audit(w http.ResponseWriter, r *http.Request, logger *log.Logger) http.Resp
 	return aw
 }
 
+// requestIDHeader is the HTTP header containing the
+// request ID. The server echos the ID of each request
+// in its response.
+const requestIDHeader = "X-Request-Id"
+
+// requestID returns the ID of the request. If the client
+// has sent a valid request ID, requestID returns it.
+// Otherwise, it generates a new random (version 4) UUID.
+func requestID(r *http.Request) string {
+	const MaxLength = 128 // Some arbitrary but reasonable limit
+
+	if id := r.Header.Get(requestIDHeader); id != "" && len(id) <= MaxLength {
+		valid := true
+		for _, c := range id {
+			if c <= ' ' || c > '~' { // Only printable ASCII characters except space
+				valid = false
+				break
+			}
+		}
+		if valid {
+			return id
+		}
+	}
+
+	var id [16]byte
+	if _, err := rand.Read(id[:]); err != nil {
+		return ""
+	}
+	id[6] = (id[6] & 0x0f) | 0x40 // Version 4
+	id[8] = (id[8] & 0x3f) | 0x80 // Variant RFC 4122
+	return fmt.Sprintf("%x-%x-%x-%x-%x", id[0:4], id[4:6], id[6:8], id[8:10], id[10:])
+}
+
 func proxy(proxy *auth.TLSProxy, f http.HandlerFunc) http.HandlerFunc {
 	if proxy == nil {
 		return f
@@ -147,6 +240,22 @@ func lookupEnclave(vault sys.Vault, req *http.Request) (*sys.Enclave, error) {
 	return vault.GetEnclave(req.Context(), req.URL.Query().Get("enclave"))
 }
 
+// verifyOperator verifies that the given request has been
+// sent by the operator of the vault. Otherwise, it returns
+// kes.ErrNotAllowed. Like the admin of an enclave, an unknown
+// identity is never the operator - even if the operator is
+// not set.
+func verifyOperator(vault sys.Vault, req *http.Request) error {
+	operator, err := vault.Operator(req.Context())
+	if err != nil {
+		return err
+	}
+	if identity := auth.Identify(req); identity.IsUnknown() || identity != operator {
+		return kes.ErrNotAllowed
+	}
+	return nil
+}
+
 // validateName checks whether name is a valid
 // KES HTTP API argument. For example a valid
 // key or policy name.
@@ -184,6 +293,25 @@ func validateName(name string) error {
 	return nil
 }
 
+// validateKeyName checks whether name is a valid key
+// name. In addition to validateName, it applies the
+// ServerConfig.ValidateKeyName rules, if any.
+func validateKeyName(config *ServerConfig, name string) error {
+	if err := validateName(name); err != nil {
+		return err
+	}
+	if config.ValidateKeyName == nil {
+		return nil
+	}
+	if err := config.ValidateKeyName(name); err != nil {
+		if _, ok := err.(kes.Error); ok {
+			return err
+		}
+		return kes.NewError(http.StatusBadRequest, "invalid argument: key name: "+err.Error())
+	}
+	return nil
+}
+
 // validatePattern checks whether pattern is a valid
 // KES HTTP API argument pattern. For example a valid
 // key or policy pattern for listing.
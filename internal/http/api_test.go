@@ -5,8 +5,17 @@
 package http
 
 import (
+	"bytes"
+	"errors"
+	"net/http"
+	"net/http/httptest"
 	"strings"
 	"testing"
+
+	"github.com/minio/kes"
+	xlog "github.com/minio/kes/internal/log"
+	"github.com/minio/kes/internal/metric"
+	"github.com/minio/kes/internal/sys"
 )
 
 var validateNameTests = []struct {
@@ -52,6 +61,42 @@ func TestValidateName(t *testing.T) {
 	}
 }
 
+func tenantKeyName(name string) error {
+	if !strings.HasPrefix(name, "tenant-") {
+		return errors.New("name must start with 'tenant-'")
+	}
+	return nil
+}
+
+var validateKeyNameTests = []struct {
+	Name       string
+	Validate   func(string) error
+	ShouldFail bool
+}{
+	{Name: "my-key"}, // 0
+	{Name: "tenant-my-key", Validate: tenantKeyName},                 // 1
+	{Name: "", ShouldFail: true},                                     // 2
+	{Name: "my-key", Validate: tenantKeyName, ShouldFail: true},      // 3
+	{Name: "tenant-../x", Validate: tenantKeyName, ShouldFail: true}, // 4
+}
+
+func TestValidateKeyName(t *testing.T) {
+	for i, test := range validateKeyNameTests {
+		config := &ServerConfig{ValidateKeyName: test.Validate}
+		err := validateKeyName(config, test.Name)
+		if test.ShouldFail {
+			if err == nil {
+				t.Fatalf("Test %d: should fail but succeeded", i)
+			}
+			if kesErr, ok := err.(kes.Error); !ok || kesErr.Status() != http.StatusBadRequest {
+				t.Fatalf("Test %d: invalid error: got '%v' - want status %d", i, err, http.StatusBadRequest)
+			}
+		} else if err != nil {
+			t.Fatalf("Test %d: should pass but failed: %v", i, err)
+		}
+	}
+}
+
 var validatePatternTests = []struct {
 	Pattern    string
 	ShouldFail bool
@@ -99,3 +144,64 @@ func TestValidatePattern(t *testing.T) {
 		}
 	}
 }
+
+func TestRequestID(t *testing.T) {
+	const ID = "my-request-id"
+
+	req := httptest.NewRequest(http.MethodGet, "/v1/key/create/my-key", nil)
+	req.Header.Set(requestIDHeader, ID)
+	if id := requestID(req); id != ID {
+		t.Fatalf("Request ID mismatch: got '%s' - want '%s'", id, ID)
+	}
+
+	for i, id := range []string{"", "my request id", strings.Repeat("a", 129), "my-id\n"} {
+		req.Header.Set(requestIDHeader, id)
+		generated := requestID(req)
+		if generated == id {
+			t.Fatalf("Test %d: invalid request ID has not been replaced", i)
+		}
+		if len(generated) != 36 || generated[14] != '4' {
+			t.Fatalf("Test %d: generated request ID is not a version 4 UUID: '%s'", i, generated)
+		}
+	}
+}
+
+func TestAuditRequestID(t *testing.T) {
+	const ID = "my-request-id"
+
+	var buffer bytes.Buffer
+	config := &ServerConfig{
+		AuditLog: xlog.NewTarget(&buffer),
+		Metrics:  metric.New(),
+	}
+	config.AuditLog.Log().SetFlags(0)
+
+	req := httptest.NewRequest(http.MethodGet, "/v1/key/create/my-key", nil)
+	req.Header.Set(requestIDHeader, ID)
+	resp := httptest.NewRecorder()
+
+	w := audit(resp, req, config)
+	w.WriteHeader(http.StatusInternalServerError)
+
+	if id := resp.Header().Get(requestIDHeader); id != ID {
+		t.Fatalf("Response request ID mismatch: got '%s' - want '%s'", id, ID)
+	}
+	stream := kes.NewAuditStream(&buffer)
+	if !stream.Next() {
+		t.Fatalf("No audit event has been logged: %v", stream.Close())
+	}
+	if id := stream.Event().RequestID; id != ID {
+		t.Fatalf("Audit event request ID mismatch: got '%s' - want '%s'", id, ID)
+	}
+}
+
+func TestVerifyOperator(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/v1/enclave/list", nil)
+
+	// A request without a client certificate has the unknown identity.
+	// It must not be the operator, even if no operator is set.
+	vault := sys.NewStatelessVault(kes.IdentityUnknown, nil, nil, nil)
+	if err := verifyOperator(vault, req); !errors.Is(err, kes.ErrNotAllowed) {
+		t.Fatalf("Unknown identity is the operator: got '%v' - want '%v'", err, kes.ErrNotAllowed)
+	}
+}
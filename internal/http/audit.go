@@ -13,6 +13,7 @@ import (
 	"time"
 
 	"github.com/minio/kes"
+	"github.com/minio/kes/internal/metric"
 )
 
 // AuditResponseWriter is an http.ResponseWriter that
@@ -25,8 +26,15 @@ type AuditResponseWriter struct {
 	// on the first invocation of Write resp. WriteHeader.
 	Logger *log.Logger
 
-	URL url.URL // The request URL
-	IP  net.IP  // The client IP address
+	// Metrics, if not nil, counts the audit events that
+	// have been delivered to all log targets and the events
+	// that have been dropped since at least one log target
+	// failed to receive them.
+	Metrics *metric.Metrics
+
+	URL       url.URL // The request URL
+	IP        net.IP  // The client IP address
+	RequestID string  // The ID of the request
 
 	Identity  kes.Identity // The client's X.509 identity
 	CreatedAt time.Time    // The time when we receive the request
@@ -47,6 +55,7 @@ var (
 // invoked again.
 func (w *AuditResponseWriter) WriteHeader(statusCode int) {
 	type RequestInfo struct {
+		ID       string       `json:"id,omitempty"`
 		IP       net.IP       `json:"ip,omitempty"`
 		APIPath  string       `json:"path"`
 		Identity kes.Identity `json:"identity,omitempty"`
@@ -64,9 +73,10 @@ func (w *AuditResponseWriter) WriteHeader(statusCode int) {
 		w.sentHeader = true
 		w.ResponseWriter.WriteHeader(statusCode) // Sent the status code BEFORE logging the event
 
-		json.NewEncoder(w.Logger.Writer()).Encode(Response{
+		err := json.NewEncoder(w.Logger.Writer()).Encode(Response{
 			Timestamp: w.CreatedAt,
 			Request: RequestInfo{
+				ID:       w.RequestID,
 				IP:       w.IP,
 				APIPath:  w.URL.Path,
 				Identity: w.Identity,
@@ -76,6 +86,9 @@ func (w *AuditResponseWriter) WriteHeader(statusCode int) {
 				Time:       time.Now().UTC().Sub(w.CreatedAt.UTC()).Truncate(1 * time.Microsecond),
 			},
 		})
+		if w.Metrics != nil {
+			w.Metrics.CountAuditEvent(err)
+		}
 	}
 }
 
@@ -0,0 +1,137 @@
+// Copyright 2022 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package http
+
+import (
+	"compress/gzip"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// minCompressSize is the minimal size of a response
+// body that gets compressed. Compressing smaller
+// responses is not worth the overhead.
+const minCompressSize = 4 << 10 // 4 KiB
+
+// compressWriter is an http.ResponseWriter that
+// compresses the response body with gzip once it
+// exceeds minCompressSize bytes. Smaller responses
+// are sent as they are.
+//
+// A compressWriter has to be closed once the handler
+// has written the entire response.
+type compressWriter struct {
+	http.ResponseWriter
+
+	status      int    // The status code passed to WriteHeader
+	buffer      []byte // The response body until it exceeds minCompressSize
+	gzip        *gzip.Writer
+	passthrough bool // If true, the response is not compressed
+}
+
+var _ http.ResponseWriter = (*compressWriter)(nil)
+
+// newCompressWriter returns a new compressWriter that
+// wraps w. The compressWriter only compresses the
+// response if the client accepts a gzip encoding.
+func newCompressWriter(w http.ResponseWriter, r *http.Request) *compressWriter {
+	w.Header().Add("Vary", "Accept-Encoding")
+	return &compressWriter{
+		ResponseWriter: w,
+		passthrough:    !acceptsGzip(r),
+	}
+}
+
+// WriteHeader records the given status code. Only
+// 200 OK responses get compressed. Any other status
+// code gets sent immediately.
+func (w *compressWriter) WriteHeader(status int) {
+	if w.status != 0 {
+		return
+	}
+	w.status = status
+
+	if w.passthrough || status != http.StatusOK {
+		w.passthrough = true
+		w.ResponseWriter.WriteHeader(status)
+	}
+}
+
+func (w *compressWriter) Write(p []byte) (int, error) {
+	if w.status == 0 {
+		w.WriteHeader(http.StatusOK)
+	}
+	if w.passthrough {
+		return w.ResponseWriter.Write(p)
+	}
+	if w.gzip != nil {
+		return w.gzip.Write(p)
+	}
+
+	w.buffer = append(w.buffer, p...)
+	if len(w.buffer) < minCompressSize {
+		return len(p), nil
+	}
+	w.Header().Set("Content-Encoding", "gzip")
+	w.Header().Del("Content-Length")
+	w.ResponseWriter.WriteHeader(w.status)
+
+	w.gzip = gzip.NewWriter(w.ResponseWriter)
+	if _, err := w.gzip.Write(w.buffer); err != nil {
+		return 0, err
+	}
+	w.buffer = nil
+	return len(p), nil
+}
+
+// Close sends any buffered response data to the
+// client. If the response is compressed, Close
+// writes the remaining gzip data.
+func (w *compressWriter) Close() error {
+	if w.gzip != nil {
+		return w.gzip.Close()
+	}
+	if w.passthrough {
+		return nil
+	}
+	if w.status != 0 {
+		w.ResponseWriter.WriteHeader(w.status)
+	}
+	w.passthrough = true
+	if len(w.buffer) > 0 {
+		_, err := w.ResponseWriter.Write(w.buffer)
+		w.buffer = nil
+		return err
+	}
+	return nil
+}
+
+// acceptsGzip reports whether the client accepts
+// gzip-compressed responses according to its
+// Accept-Encoding header.
+func acceptsGzip(r *http.Request) bool {
+	for _, header := range r.Header.Values("Accept-Encoding") {
+		for _, coding := range strings.Split(header, ",") {
+			params := strings.Split(coding, ";")
+			if name := strings.TrimSpace(params[0]); !strings.EqualFold(name, "gzip") {
+				continue
+			}
+
+			accepted := true
+			for _, param := range params[1:] {
+				param = strings.TrimSpace(param)
+				if !strings.HasPrefix(param, "q=") {
+					continue
+				}
+				if q, err := strconv.ParseFloat(strings.TrimPrefix(param, "q="), 64); err != nil || q <= 0 {
+					accepted = false
+				}
+			}
+			return accepted
+		}
+	}
+	return false
+}
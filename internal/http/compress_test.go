@@ -0,0 +1,100 @@
+// Copyright 2022 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package http
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+var acceptsGzipTests = []struct {
+	Header string
+	Accept bool
+}{
+	{Header: "", Accept: false},                // 0
+	{Header: "gzip", Accept: true},             // 1
+	{Header: "gzip, zstd", Accept: true},       // 2
+	{Header: "zstd, GZIP;q=0.5", Accept: true}, // 3
+	{Header: "gzip;q=0", Accept: false},        // 4
+	{Header: "deflate, br", Accept: false},     // 5
+	{Header: "gzip;q=invalid", Accept: false},  // 6
+}
+
+func TestAcceptsGzip(t *testing.T) {
+	for i, test := range acceptsGzipTests {
+		req := httptest.NewRequest(http.MethodGet, "/v1/key/list/*", nil)
+		if test.Header != "" {
+			req.Header.Set("Accept-Encoding", test.Header)
+		}
+		if accept := acceptsGzip(req); accept != test.Accept {
+			t.Fatalf("Test %d: got '%v' - want '%v'", i, accept, test.Accept)
+		}
+	}
+}
+
+var compressWriterTests = []struct {
+	AcceptEncoding string
+	Status         int
+	Size           int
+	Compressed     bool
+}{
+	{AcceptEncoding: "gzip", Status: http.StatusOK, Size: minCompressSize, Compressed: true},                   // 0
+	{AcceptEncoding: "gzip", Status: http.StatusOK, Size: minCompressSize - 1, Compressed: false},              // 1
+	{AcceptEncoding: "", Status: http.StatusOK, Size: 2 * minCompressSize, Compressed: false},                  // 2
+	{AcceptEncoding: "gzip", Status: http.StatusInternalServerError, Size: minCompressSize, Compressed: false}, // 3
+	{AcceptEncoding: "gzip", Status: http.StatusOK, Size: 0, Compressed: false},                                // 4
+}
+
+func TestCompressWriter(t *testing.T) {
+	for i, test := range compressWriterTests {
+		req := httptest.NewRequest(http.MethodGet, "/v1/key/list/*", nil)
+		if test.AcceptEncoding != "" {
+			req.Header.Set("Accept-Encoding", test.AcceptEncoding)
+		}
+		rec := httptest.NewRecorder()
+		body := bytes.Repeat([]byte{'a'}, test.Size)
+
+		w := newCompressWriter(rec, req)
+		w.WriteHeader(test.Status)
+		for j := 0; j < len(body); j += 100 { // Write the body in small chunks
+			end := j + 100
+			if end > len(body) {
+				end = len(body)
+			}
+			if _, err := w.Write(body[j:end]); err != nil {
+				t.Fatalf("Test %d: failed to write response: %v", i, err)
+			}
+		}
+		if err := w.Close(); err != nil {
+			t.Fatalf("Test %d: failed to close writer: %v", i, err)
+		}
+
+		if rec.Code != test.Status {
+			t.Fatalf("Test %d: status code mismatch: got '%d' - want '%d'", i, rec.Code, test.Status)
+		}
+		compressed := rec.Header().Get("Content-Encoding") == "gzip"
+		if compressed != test.Compressed {
+			t.Fatalf("Test %d: got compressed '%v' - want '%v'", i, compressed, test.Compressed)
+		}
+
+		response := rec.Body.Bytes()
+		if compressed {
+			gz, err := gzip.NewReader(rec.Body)
+			if err != nil {
+				t.Fatalf("Test %d: invalid gzip response: %v", i, err)
+			}
+			if response, err = ioutil.ReadAll(gz); err != nil {
+				t.Fatalf("Test %d: invalid gzip response: %v", i, err)
+			}
+		}
+		if !bytes.Equal(response, body) {
+			t.Fatalf("Test %d: response body mismatch", i)
+		}
+	}
+}
@@ -5,12 +5,11 @@
 package http
 
 import (
+	"encoding/json"
 	"net/http"
+	"path"
 	"strings"
 	"time"
-
-	"github.com/minio/kes"
-	"github.com/minio/kes/internal/auth"
 )
 
 func createEnclave(mux *http.ServeMux, config *ServerConfig) API {
@@ -21,7 +20,7 @@ func createEnclave(mux *http.ServeMux, config *ServerConfig) API {
 		Timeout = 15 * time.Second
 	)
 	handler := func(w http.ResponseWriter, r *http.Request) {
-		w = audit(w, r, config.AuditLog.Log())
+		w = audit(w, r, config)
 
 		if r.Method != Method {
 			w.Header().Set("Accept", Method)
@@ -35,15 +34,11 @@ func createEnclave(mux *http.ServeMux, config *ServerConfig) API {
 		}
 		r.Body = http.MaxBytesReader(w, r.Body, MaxBody)
 
-		operator, err := config.Vault.Operator(r.Context())
+		err := verifyOperator(config.Vault, r)
 		if err != nil {
 			Error(w, err)
 			return
 		}
-		if identity := auth.Identify(r); identity != operator {
-			Error(w, kes.ErrNotAllowed)
-			return
-		}
 
 		name := strings.TrimSpace(strings.TrimPrefix(r.URL.Path, APIPath))
 		if err := validateName(name); err != nil {
@@ -73,7 +68,7 @@ func deleteEnclave(mux *http.ServeMux, config *ServerConfig) API {
 		Timeout = 15 * time.Second
 	)
 	handler := func(w http.ResponseWriter, r *http.Request) {
-		w = audit(w, r, config.AuditLog.Log())
+		w = audit(w, r, config)
 
 		if r.Method != Method {
 			w.Header().Set("Accept", Method)
@@ -87,15 +82,11 @@ func deleteEnclave(mux *http.ServeMux, config *ServerConfig) API {
 		}
 		r.Body = http.MaxBytesReader(w, r.Body, MaxBody)
 
-		operator, err := config.Vault.Operator(r.Context())
+		err := verifyOperator(config.Vault, r)
 		if err != nil {
 			Error(w, err)
 			return
 		}
-		if identity := auth.Identify(r); identity != operator {
-			Error(w, kes.ErrNotAllowed)
-			return
-		}
 
 		name := strings.TrimSpace(strings.TrimPrefix(r.URL.Path, APIPath))
 		if err := validateName(name); err != nil {
@@ -116,3 +107,293 @@ func deleteEnclave(mux *http.ServeMux, config *ServerConfig) API {
 		Timeout: Timeout,
 	}
 }
+
+func listEnclaves(mux *http.ServeMux, config *ServerConfig) API {
+	const (
+		Method      = http.MethodGet
+		APIPath     = "/v1/enclave/list/"
+		MaxBody     = 0
+		Timeout     = 15 * time.Second
+		ContentType = "application/x-ndjson"
+	)
+	type Response struct {
+		Name string `json:"name,omitempty"`
+		Err  string `json:"error,omitempty"`
+	}
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		w = audit(w, r, config)
+
+		if r.Method != Method {
+			w.Header().Set("Accept", Method)
+			Error(w, errMethodNotAllowed)
+			return
+		}
+		if err := normalizeURL(r.URL, APIPath); err != nil {
+			Error(w, err)
+			return
+		}
+		r.Body = http.MaxBytesReader(w, r.Body, MaxBody)
+
+		err := verifyOperator(config.Vault, r)
+		if err != nil {
+			Error(w, err)
+			return
+		}
+
+		pattern := strings.TrimSpace(strings.TrimPrefix(r.URL.Path, APIPath))
+		if err = validatePattern(pattern); err != nil {
+			Error(w, err)
+			return
+		}
+		cw := newCompressWriter(w, r)
+		defer cw.Close()
+		w = cw
+
+		names, err := config.Vault.ListEnclaves(r.Context())
+		if err != nil {
+			Error(w, err)
+			return
+		}
+
+		encoder := json.NewEncoder(w)
+		w.Header().Set("Content-Type", ContentType)
+		for _, name := range names {
+			if ok, _ := path.Match(pattern, name); !ok || name == "" {
+				continue
+			}
+			if err = encoder.Encode(Response{Name: name}); err != nil {
+				return
+			}
+		}
+		w.WriteHeader(http.StatusOK)
+	}
+	mux.HandleFunc(APIPath, timeout(Timeout, proxy(config.Proxy, config.Metrics.Count(config.Metrics.Latency(handler)))))
+	return API{
+		Method:  Method,
+		Path:    APIPath,
+		MaxBody: MaxBody,
+		Timeout: Timeout,
+	}
+}
+
+func describeEnclave(mux *http.ServeMux, config *ServerConfig) API {
+	const (
+		Method      = http.MethodGet
+		APIPath     = "/v1/enclave/describe/"
+		MaxBody     = 0
+		Timeout     = 15 * time.Second
+		ContentType = "application/json"
+	)
+	type Response struct {
+		Name       string `json:"name"`
+		Sealed     bool   `json:"sealed"`
+		KeyCount   uint64 `json:"key_count"`
+		StoreState string `json:"store_state,omitempty"`
+		Err        string `json:"error,omitempty"`
+	}
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		w = audit(w, r, config)
+
+		if r.Method != Method {
+			w.Header().Set("Accept", Method)
+			Error(w, errMethodNotAllowed)
+			return
+		}
+		if err := normalizeURL(r.URL, APIPath); err != nil {
+			Error(w, err)
+			return
+		}
+		r.Body = http.MaxBytesReader(w, r.Body, MaxBody)
+
+		err := verifyOperator(config.Vault, r)
+		if err != nil {
+			Error(w, err)
+			return
+		}
+
+		// The default enclave has an empty name. Hence,
+		// an empty name is valid and refers to it.
+		name := strings.TrimSpace(strings.TrimPrefix(r.URL.Path, APIPath))
+		if name != "" {
+			if err = validateName(name); err != nil {
+				Error(w, err)
+				return
+			}
+		}
+
+		status, err := config.Vault.EnclaveStatus(r.Context(), name)
+		if err != nil {
+			Error(w, err)
+			return
+		}
+		response := Response{
+			Name:     name,
+			Sealed:   status.Sealed,
+			KeyCount: status.KeyCount,
+		}
+		if !status.Sealed {
+			response.StoreState = status.Store.State.String()
+		}
+		if status.Err != nil {
+			response.Err = status.Err.Error()
+		}
+		w.Header().Set("Content-Type", ContentType)
+		json.NewEncoder(w).Encode(response)
+	}
+	mux.HandleFunc(APIPath, timeout(Timeout, proxy(config.Proxy, config.Metrics.Count(config.Metrics.Latency(handler)))))
+	return API{
+		Method:  Method,
+		Path:    APIPath,
+		MaxBody: MaxBody,
+		Timeout: Timeout,
+	}
+}
+
+func vaultStatus(mux *http.ServeMux, config *ServerConfig) API {
+	const (
+		Method      = http.MethodGet
+		APIPath     = "/v1/vault/status"
+		MaxBody     = 0
+		Timeout     = 15 * time.Second
+		ContentType = "application/json"
+	)
+	type Status struct {
+		Sealed   bool          `json:"sealed"`
+		KeyCount uint64        `json:"key_count"`
+		State    string        `json:"state"`
+		Latency  time.Duration `json:"latency"`
+		Err      string        `json:"error,omitempty"`
+	}
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		w = audit(w, r, config)
+
+		if r.Method != Method {
+			w.Header().Set("Accept", Method)
+			Error(w, errMethodNotAllowed)
+			return
+		}
+		if err := normalizeURL(r.URL, APIPath); err != nil {
+			Error(w, err)
+			return
+		}
+		r.Body = http.MaxBytesReader(w, r.Body, MaxBody)
+
+		err := verifyOperator(config.Vault, r)
+		if err != nil {
+			Error(w, err)
+			return
+		}
+
+		enclaves, err := config.Vault.Status(r.Context())
+		if err != nil {
+			Error(w, err)
+			return
+		}
+		response := make(map[string]Status, len(enclaves))
+		for name, status := range enclaves {
+			s := Status{
+				Sealed:   status.Sealed,
+				KeyCount: status.KeyCount,
+				State:    status.Store.State.String(),
+				Latency:  status.Store.Latency,
+			}
+			if status.Err != nil {
+				s.Err = status.Err.Error()
+			}
+			response[name] = s
+		}
+		w.Header().Set("Content-Type", ContentType)
+		json.NewEncoder(w).Encode(response)
+	}
+	mux.HandleFunc(APIPath, timeout(Timeout, proxy(config.Proxy, config.Metrics.Count(config.Metrics.Latency(handler)))))
+	return API{
+		Method:  Method,
+		Path:    APIPath,
+		MaxBody: MaxBody,
+		Timeout: Timeout,
+	}
+}
+
+func sealVault(mux *http.ServeMux, config *ServerConfig) API {
+	const (
+		Method  = http.MethodPost
+		APIPath = "/v1/vault/seal"
+		MaxBody = 0
+		Timeout = 15 * time.Second
+	)
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		w = audit(w, r, config)
+
+		if r.Method != Method {
+			w.Header().Set("Accept", Method)
+			Error(w, errMethodNotAllowed)
+			return
+		}
+		if err := normalizeURL(r.URL, APIPath); err != nil {
+			Error(w, err)
+			return
+		}
+		r.Body = http.MaxBytesReader(w, r.Body, MaxBody)
+
+		err := verifyOperator(config.Vault, r)
+		if err != nil {
+			Error(w, err)
+			return
+		}
+
+		if err = config.Vault.Seal(r.Context()); err != nil {
+			Error(w, err)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}
+	mux.HandleFunc(APIPath, timeout(Timeout, proxy(config.Proxy, config.Metrics.Count(config.Metrics.Latency(handler)))))
+	return API{
+		Method:  Method,
+		Path:    APIPath,
+		MaxBody: MaxBody,
+		Timeout: Timeout,
+	}
+}
+
+func unsealVault(mux *http.ServeMux, config *ServerConfig) API {
+	const (
+		Method  = http.MethodPost
+		APIPath = "/v1/vault/unseal"
+		MaxBody = 0
+		Timeout = 15 * time.Second
+	)
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		w = audit(w, r, config)
+
+		if r.Method != Method {
+			w.Header().Set("Accept", Method)
+			Error(w, errMethodNotAllowed)
+			return
+		}
+		if err := normalizeURL(r.URL, APIPath); err != nil {
+			Error(w, err)
+			return
+		}
+		r.Body = http.MaxBytesReader(w, r.Body, MaxBody)
+
+		err := verifyOperator(config.Vault, r)
+		if err != nil {
+			Error(w, err)
+			return
+		}
+
+		if err = config.Vault.Unseal(r.Context()); err != nil {
+			Error(w, err)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}
+	mux.HandleFunc(APIPath, timeout(Timeout, proxy(config.Proxy, config.Metrics.Count(config.Metrics.Latency(handler)))))
+	return API{
+		Method:  Method,
+		Path:    APIPath,
+		MaxBody: MaxBody,
+		Timeout: Timeout,
+	}
+}
@@ -7,8 +7,10 @@ package http
 import (
 	"fmt"
 	"io"
+	"math"
 	"net/http"
 	"strconv"
+	"time"
 )
 
 // Error sends the given err as JSON error response to w.
@@ -17,6 +19,9 @@ import (
 // response status code to err.Status(). Otherwise, it will
 // send 500 (internal server error).
 //
+// If err has a 'RetryAfter() time.Duration' method then Error
+// also sets the Retry-After header.
+//
 // If err is nil then Error will send the status code 500 and
 // an empty JSON response body - i.e. '{}'.
 func Error(w http.ResponseWriter, err error) error {
@@ -25,6 +30,10 @@ func Error(w http.ResponseWriter, err error) error {
 		status = e.Status()
 	}
 
+	if e, ok := err.(interface{ RetryAfter() time.Duration }); ok {
+		retryAfter := int(math.Ceil(e.RetryAfter().Seconds()))
+		w.Header().Set("Retry-After", strconv.Itoa(retryAfter))
+	}
 	w.Header().Set("Content-Type", "application/json; charset=utf-8")
 	w.Header().Set("X-Content-Type-Options", "nosniff")
 	w.WriteHeader(status)
@@ -1,8 +1,11 @@
 package http
 
 import (
+	"context"
 	"encoding/json"
 	"net/http"
+	"runtime"
+	"sync"
 	"time"
 
 	"github.com/prometheus/common/expfmt"
@@ -16,17 +19,23 @@ func version(mux *http.ServeMux, config *ServerConfig) API {
 		Timeout = 15 * time.Second
 	)
 	type Response struct {
-		Version string `json:"version"`
+		Version   string     `json:"version"`
+		Commit    string     `json:"commit,omitempty"`
+		BuildTime *time.Time `json:"build_time,omitempty"`
+		GoVersion string     `json:"go_version"`
 	}
 	handler := func(w http.ResponseWriter, r *http.Request) {
-		w = audit(w, r, config.AuditLog.Log())
+		w = audit(w, r, config)
 		if r.Method != Method {
 			w.Header().Set("Accept", Method)
 			Error(w, errMethodNotAllowed)
 			return
 		}
 		json.NewEncoder(w).Encode(Response{
-			Version: config.Version,
+			Version:   config.Version,
+			Commit:    config.Commit,
+			BuildTime: buildTime(config),
+			GoVersion: runtime.Version(),
 		})
 	}
 	mux.HandleFunc(APIPath, timeout(Timeout, proxy(config.Proxy, config.Metrics.Count(config.Metrics.Latency(handler)))))
@@ -38,6 +47,17 @@ func version(mux *http.ServeMux, config *ServerConfig) API {
 	}
 }
 
+// buildTime returns a pointer to the build time of the
+// server, or nil if it is not known. Unlike a zero
+// time.Time, nil gets omitted in JSON responses.
+func buildTime(config *ServerConfig) *time.Time {
+	if config.BuildTime.IsZero() {
+		return nil
+	}
+	t := config.BuildTime
+	return &t
+}
+
 func status(mux *http.ServeMux, config *ServerConfig) API {
 	const (
 		Method      = http.MethodGet
@@ -47,8 +67,11 @@ func status(mux *http.ServeMux, config *ServerConfig) API {
 		ContentType = "application/json"
 	)
 	type Response struct {
-		Version string        `json:"version"`
-		UpTime  time.Duration `json:"uptime"`
+		Version   string        `json:"version"`
+		Commit    string        `json:"commit,omitempty"`
+		BuildTime *time.Time    `json:"build_time,omitempty"`
+		GoVersion string        `json:"go_version"`
+		UpTime    time.Duration `json:"uptime"`
 	}
 	startTime := time.Now().UTC()
 	handler := func(w http.ResponseWriter, r *http.Request) {
@@ -75,8 +98,11 @@ func status(mux *http.ServeMux, config *ServerConfig) API {
 
 		w.Header().Set("Content-Type", ContentType)
 		json.NewEncoder(w).Encode(Response{
-			Version: config.Version,
-			UpTime:  time.Since(startTime).Round(time.Second),
+			Version:   config.Version,
+			Commit:    config.Commit,
+			BuildTime: buildTime(config),
+			GoVersion: runtime.Version(),
+			UpTime:    time.Since(startTime).Round(time.Second),
 		})
 	}
 	mux.HandleFunc(APIPath, timeout(Timeout, proxy(config.Proxy, config.Metrics.Count(config.Metrics.Latency(handler)))))
@@ -88,6 +114,110 @@ func status(mux *http.ServeMux, config *ServerConfig) API {
 	}
 }
 
+func statusStream(mux *http.ServeMux, config *ServerConfig) API {
+	const (
+		Method      = http.MethodGet
+		APIPath     = "/v1/status/stream"
+		MaxBody     = 0
+		Timeout     = 0 * time.Second // No timeout
+		ContentType = "application/x-ndjson"
+		Interval    = 1 * time.Second // How often the server checks for state changes
+	)
+	type Event struct {
+		Time   time.Time `json:"time"`
+		Sealed bool      `json:"sealed"`
+		Store  string    `json:"store,omitempty"`
+	}
+	// All subscribers share the same watcher such that
+	// the server polls each enclave only once per interval.
+	watcher := &statusWatcher{vault: config.Vault, interval: Interval}
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != Method {
+			w.Header().Set("Accept", Method)
+			Error(w, errMethodNotAllowed)
+			return
+		}
+		if err := normalizeURL(r.URL, APIPath); err != nil {
+			Error(w, err)
+			return
+		}
+		r.Body = http.MaxBytesReader(w, r.Body, MaxBody)
+
+		enclave, err := lookupEnclave(config.Vault, r)
+		if err != nil {
+			Error(w, err)
+			return
+		}
+		if err = enclave.VerifyRequest(r); err != nil {
+			Error(w, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", ContentType)
+		w.WriteHeader(http.StatusOK)
+		if flusher, ok := w.(http.Flusher); ok {
+			flusher.Flush() // Send the response headers before the first event
+		}
+
+		// The keep-alive goroutine must have returned before
+		// the handler returns. Otherwise, it may write to the
+		// ResponseWriter after the handler has returned.
+		ctx, cancel := context.WithCancel(r.Context())
+		var wg sync.WaitGroup
+		defer func() {
+			cancel()
+			wg.Wait()
+		}()
+
+		out := newKeepAliveWriter(NewFlushWriter(w))
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			out.KeepAlive(ctx, config.KeepAlive)
+		}()
+
+		states, unsubscribe := watcher.Subscribe(r.URL.Query().Get("enclave"))
+		defer unsubscribe()
+
+		// We only send an event when the state has changed.
+		// The first event describes the initial state.
+		var (
+			encoder = json.NewEncoder(out)
+			last    *Event
+		)
+		for {
+			var state enclaveState
+			select {
+			case <-ctx.Done():
+				return
+			case state = <-states:
+			}
+			if state.Err != nil {
+				return // The enclave has been deleted or is not reachable. The client has to subscribe again.
+			}
+
+			event := Event{
+				Time:   time.Now().UTC(),
+				Sealed: state.Sealed,
+				Store:  state.Store,
+			}
+			if last == nil || last.Sealed != event.Sealed || last.Store != event.Store {
+				if err = encoder.Encode(event); err != nil {
+					return
+				}
+				last = &event
+			}
+		}
+	}
+	mux.HandleFunc(APIPath, proxy(config.Proxy, config.Metrics.Count(config.Metrics.Latency(handler))))
+	return API{
+		Method:  Method,
+		Path:    APIPath,
+		MaxBody: MaxBody,
+		Timeout: Timeout,
+	}
+}
+
 func metrics(mux *http.ServeMux, config *ServerConfig) API {
 	const (
 		Method  = http.MethodGet
@@ -6,6 +6,7 @@ package http
 
 import (
 	"encoding/json"
+	"errors"
 	"net/http"
 	"path"
 	"strings"
@@ -28,9 +29,11 @@ func describeIdentity(mux *http.ServeMux, config *ServerConfig) API {
 		Policy    string       `json:"policy"`
 		CreatedAt time.Time    `json:"created_at,omitempty"`
 		CreatedBy kes.Identity `json:"created_by,omitempty"`
+		ExpiresAt time.Time    `json:"expires_at,omitempty"`
+		Alias     string       `json:"alias,omitempty"`
 	}
 	handler := func(w http.ResponseWriter, r *http.Request) {
-		w = audit(w, r, config.AuditLog.Log())
+		w = audit(w, r, config)
 
 		if r.Method != Method {
 			w.Header().Set("Accept", Method)
@@ -69,6 +72,8 @@ func describeIdentity(mux *http.ServeMux, config *ServerConfig) API {
 			Policy:    info.Policy,
 			CreatedAt: info.CreatedAt,
 			CreatedBy: info.CreatedBy,
+			ExpiresAt: info.ExpiresAt,
+			Alias:     info.Alias,
 		})
 	}
 	mux.HandleFunc(APIPath, timeout(Timeout, proxy(config.Proxy, config.Metrics.Count(config.Metrics.Latency(handler)))))
@@ -88,14 +93,15 @@ func selfDescribeIdentity(mux *http.ServeMux, config *ServerConfig) API {
 		Timeout = 15 * time.Second
 	)
 	type InlinePolicy struct {
-		Allow []string
-		Deny  []string
+		Allow []string `json:"Allow"` // Capitalized for compatibility with existing clients
+		Deny  []string `json:"Deny"`
 	}
 	type Response struct {
 		Identity kes.Identity `json:"identity"`
 
 		IsAdmin    bool   `json:"admin"`
 		PolicyName string `json:"policy_name,omitempty"`
+		Alias      string `json:"alias,omitempty"`
 
 		CreatedAt time.Time    `json:"created_at,omitempty"`
 		CreatedBy kes.Identity `json:"created_by,omitempty"`
@@ -103,7 +109,7 @@ func selfDescribeIdentity(mux *http.ServeMux, config *ServerConfig) API {
 		Policy InlinePolicy `json:"policy"`
 	}
 	handler := func(w http.ResponseWriter, r *http.Request) {
-		w = audit(w, r, config.AuditLog.Log())
+		w = audit(w, r, config)
 
 		if r.Method != Method {
 			w.Header().Set("Accept", Method)
@@ -141,6 +147,7 @@ func selfDescribeIdentity(mux *http.ServeMux, config *ServerConfig) API {
 			Identity:   identity,
 			PolicyName: info.Policy,
 			IsAdmin:    info.IsAdmin,
+			Alias:      info.Alias,
 			CreatedAt:  info.CreatedAt,
 			CreatedBy:  info.CreatedBy,
 			Policy: InlinePolicy{
@@ -158,6 +165,75 @@ func selfDescribeIdentity(mux *http.ServeMux, config *ServerConfig) API {
 	}
 }
 
+func setIdentityAlias(mux *http.ServeMux, config *ServerConfig) API {
+	const (
+		Method  = http.MethodPost
+		APIPath = "/v1/identity/alias/"
+		MaxBody = 1024 // 1 KB
+		Timeout = 15 * time.Second
+	)
+	type Request struct {
+		Alias string `json:"alias"`
+	}
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		w = audit(w, r, config)
+
+		if r.Method != Method {
+			w.Header().Set("Accept", Method)
+			Error(w, errMethodNotAllowed)
+			return
+		}
+		if err := normalizeURL(r.URL, APIPath); err != nil {
+			Error(w, err)
+			return
+		}
+		r.Body = http.MaxBytesReader(w, r.Body, MaxBody)
+
+		enclave, err := lookupEnclave(config.Vault, r)
+		if err != nil {
+			Error(w, err)
+			return
+		}
+		if err = enclave.VerifyRequest(r); err != nil {
+			Error(w, err)
+			return
+		}
+
+		name := strings.TrimSpace(strings.TrimPrefix(r.URL.Path, APIPath))
+		if err = validateName(name); err != nil {
+			Error(w, err)
+			return
+		}
+
+		var req Request
+		if err = json.NewDecoder(r.Body).Decode(&req); err != nil {
+			Error(w, err)
+			return
+		}
+		// An empty alias removes the current alias. Otherwise,
+		// an alias has to be a valid name such that it can be
+		// printed safely, e.g. in logs.
+		if req.Alias != "" {
+			if err = validateName(req.Alias); err != nil {
+				Error(w, kes.NewError(http.StatusBadRequest, "invalid identity alias"))
+				return
+			}
+		}
+		if err = enclave.SetIdentityAlias(r.Context(), kes.Identity(name), req.Alias); err != nil {
+			Error(w, err)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}
+	mux.HandleFunc(APIPath, timeout(Timeout, proxy(config.Proxy, config.Metrics.Count(config.Metrics.Latency(handler)))))
+	return API{
+		Method:  Method,
+		Path:    APIPath,
+		MaxBody: MaxBody,
+		Timeout: Timeout,
+	}
+}
+
 func deleteIdentity(mux *http.ServeMux, config *ServerConfig) API {
 	const (
 		Method  = http.MethodDelete
@@ -166,7 +242,7 @@ func deleteIdentity(mux *http.ServeMux, config *ServerConfig) API {
 		Timeout = 15 * time.Second
 	)
 	handler := func(w http.ResponseWriter, r *http.Request) {
-		w = audit(w, r, config.AuditLog.Log())
+		w = audit(w, r, config)
 
 		if r.Method != Method {
 			w.Header().Set("Accept", Method)
@@ -209,6 +285,109 @@ func deleteIdentity(mux *http.ServeMux, config *ServerConfig) API {
 	}
 }
 
+func deleteIdentitiesByPolicy(mux *http.ServeMux, config *ServerConfig) API {
+	const (
+		Method      = http.MethodDelete
+		APIPath     = "/v1/identity/delete-by-policy/"
+		MaxBody     = 0
+		Timeout     = 15 * time.Second
+		ContentType = "application/json"
+	)
+	type Response struct {
+		Deleted int    `json:"deleted"`
+		Err     string `json:"error,omitempty"`
+	}
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		w = audit(w, r, config)
+
+		if r.Method != Method {
+			w.Header().Set("Accept", Method)
+			Error(w, errMethodNotAllowed)
+			return
+		}
+		if err := normalizeURL(r.URL, APIPath); err != nil {
+			Error(w, err)
+			return
+		}
+		r.Body = http.MaxBytesReader(w, r.Body, MaxBody)
+
+		enclave, err := lookupEnclave(config.Vault, r)
+		if err != nil {
+			Error(w, err)
+			return
+		}
+		if err = enclave.VerifyAdmin(r); err != nil { // Only the admin can delete identities in bulk
+			Error(w, err)
+			return
+		}
+
+		// The policy does not have to exist. Identities may
+		// still be assigned to a policy that has been deleted.
+		name := strings.TrimSpace(strings.TrimPrefix(r.URL.Path, APIPath))
+		if err = validateName(name); err != nil {
+			Error(w, err)
+			return
+		}
+		iterator, err := enclave.ListIdentities(r.Context())
+		if err != nil {
+			Error(w, err)
+			return
+		}
+		var identities []kes.Identity
+		for iterator.Next() {
+			// Identities whose policy assignment has expired
+			// are still assigned to the policy and must be
+			// deleted as well.
+			info, err := enclave.GetIdentityAssignment(r.Context(), iterator.Identity())
+			if errors.Is(err, auth.ErrIdentityNotFound) {
+				continue // The identity has been deleted concurrently
+			}
+			if err != nil {
+				iterator.Close()
+				Error(w, err)
+				return
+			}
+			if info.IsAdmin || info.Policy != name {
+				continue
+			}
+			identities = append(identities, iterator.Identity())
+		}
+		if err = iterator.Close(); err != nil {
+			Error(w, err)
+			return
+		}
+
+		// We delete the identities once we are done iterating
+		// since an iterator may not support concurrent changes.
+		//
+		// If deleting an identity fails, we report how many
+		// identities have been deleted before. Otherwise, the
+		// client could not tell whether any identity has been
+		// deleted.
+		var response Response
+		for _, identity := range identities {
+			if err = enclave.DeleteIdentity(r.Context(), identity); err != nil {
+				if response.Deleted == 0 {
+					Error(w, err)
+					return
+				}
+				response.Err = err.Error()
+				break
+			}
+			response.Deleted++
+		}
+		w.Header().Set("Content-Type", ContentType)
+		json.NewEncoder(w).Encode(response)
+	}
+	mux.HandleFunc(APIPath, timeout(Timeout, proxy(config.Proxy, config.Metrics.Count(config.Metrics.Latency(handler)))))
+	return API{
+		Method:  Method,
+		Path:    APIPath,
+		MaxBody: MaxBody,
+		Timeout: Timeout,
+	}
+}
+
 func listIdentity(mux *http.ServeMux, config *ServerConfig) API {
 	const (
 		Method      = http.MethodGet
@@ -223,11 +402,12 @@ func listIdentity(mux *http.ServeMux, config *ServerConfig) API {
 		Policy    string       `json:"policy"`
 		CreatedAt time.Time    `json:"created_at,omitempty"`
 		CreatedBy kes.Identity `json:"created_by,omitempty"`
+		Alias     string       `json:"alias,omitempty"`
 
 		Err string `json:"error,omitempty"`
 	}
 	handler := func(w http.ResponseWriter, r *http.Request) {
-		w = audit(w, r, config.AuditLog.Log())
+		w = audit(w, r, config)
 
 		if r.Method != Method {
 			w.Header().Set("Accept", Method)
@@ -255,6 +435,10 @@ func listIdentity(mux *http.ServeMux, config *ServerConfig) API {
 			Error(w, err)
 			return
 		}
+		cw := newCompressWriter(w, r)
+		defer cw.Close()
+		w = cw
+
 		iterator, err := enclave.ListIdentities(r.Context())
 		if err != nil {
 			Error(w, err)
@@ -269,6 +453,9 @@ func listIdentity(mux *http.ServeMux, config *ServerConfig) API {
 				continue
 			}
 			info, err := enclave.GetIdentity(r.Context(), iterator.Identity())
+			if errors.Is(err, auth.ErrIdentityNotFound) {
+				continue // The identity has been deleted concurrently or its assignment has expired
+			}
 			if err != nil {
 				encoder.Encode(Response{Err: err.Error()})
 				return
@@ -282,6 +469,7 @@ func listIdentity(mux *http.ServeMux, config *ServerConfig) API {
 				Policy:    info.Policy,
 				CreatedAt: info.CreatedAt,
 				CreatedBy: info.CreatedBy,
+				Alias:     info.Alias,
 			})
 			if err != nil {
 				return
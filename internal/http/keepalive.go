@@ -0,0 +1,78 @@
+// Copyright 2022 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package http
+
+import (
+	"context"
+	"io"
+	"sync"
+	"time"
+)
+
+// heartbeat is sent to a client when a stream has been
+// idle for too long. Since a stream consists of JSON
+// objects separated by newlines, a single newline is
+// whitespace that any JSON decoder skips.
+var heartbeat = []byte{'\n'}
+
+// keepAliveWriter is an io.Writer that sends heartbeats
+// to a client to keep long-lived but idle response
+// streams alive. Otherwise, intermediaries, like load
+// balancers or proxies, may close connections that
+// have not transferred any data for some time.
+//
+// A keepAliveWriter is safe for concurrent use.
+type keepAliveWriter struct {
+	lock      sync.Mutex
+	w         io.Writer
+	lastWrite time.Time
+}
+
+// newKeepAliveWriter returns a new keepAliveWriter
+// that wraps w.
+func newKeepAliveWriter(w io.Writer) *keepAliveWriter {
+	return &keepAliveWriter{
+		w:         w,
+		lastWrite: time.Now(),
+	}
+}
+
+func (w *keepAliveWriter) Write(p []byte) (int, error) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	w.lastWrite = time.Now()
+	return w.w.Write(p)
+}
+
+// KeepAlive blocks until ctx is done. Meanwhile, it
+// writes a heartbeat to the underlying io.Writer
+// whenever nothing has been written for the given
+// interval.
+//
+// If interval is not positive, KeepAlive does not
+// send any heartbeats.
+func (w *keepAliveWriter) KeepAlive(ctx context.Context, interval time.Duration) {
+	if interval <= 0 {
+		<-ctx.Done()
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			w.lock.Lock()
+			if now.Sub(w.lastWrite) >= interval {
+				w.lastWrite = now
+				w.w.Write(heartbeat)
+			}
+			w.lock.Unlock()
+		}
+	}
+}
@@ -0,0 +1,61 @@
+// Copyright 2022 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package http
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/minio/kes"
+)
+
+func TestKeepAliveWriter(t *testing.T) {
+	var buffer bytes.Buffer
+	w := newKeepAliveWriter(&buffer)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	if _, err := w.Write([]byte(`{"message":"first"}` + "\n")); err != nil {
+		t.Fatalf("Failed to write event: %v", err)
+	}
+	w.KeepAlive(ctx, 10*time.Millisecond)
+	if _, err := w.Write([]byte(`{"message":"second"}` + "\n")); err != nil {
+		t.Fatalf("Failed to write event: %v", err)
+	}
+
+	output := buffer.String()
+	if !strings.Contains(output, "}\n\n") {
+		t.Fatalf("No heartbeat has been sent: %q", output)
+	}
+
+	stream := kes.NewErrorStream(strings.NewReader(output))
+	var messages []string
+	for stream.Next() {
+		messages = append(messages, stream.Message())
+	}
+	if err := stream.Close(); err != nil {
+		t.Fatalf("Failed to read stream: %v", err)
+	}
+	if len(messages) != 2 || messages[0] != "first" || messages[1] != "second" {
+		t.Fatalf("Heartbeats have not been ignored: got %q - want %q", messages, []string{"first", "second"})
+	}
+}
+
+func TestKeepAliveWriterDisabled(t *testing.T) {
+	var buffer bytes.Buffer
+	w := newKeepAliveWriter(&buffer)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	w.KeepAlive(ctx, 0)
+	if buffer.Len() != 0 {
+		t.Fatalf("Heartbeats have been sent although keep alive is disabled: %q", buffer.String())
+	}
+}
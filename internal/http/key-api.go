@@ -5,10 +5,15 @@
 package http
 
 import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
 	"encoding/json"
-	"math/rand"
+	"errors"
+	"io"
 	"net/http"
 	"path"
+	"sort"
 	"strings"
 	"time"
 
@@ -23,11 +28,14 @@ func createKey(mux *http.ServeMux, config *ServerConfig) API {
 	const (
 		Method  = http.MethodPost
 		APIPath = "/v1/key/create/"
-		MaxBody = 0
+		MaxBody = 1024 // 1 KB
 		Timeout = 15 * time.Second
 	)
+	type Request struct {
+		Algorithm string `json:"algorithm"` // optional
+	}
 	handler := func(w http.ResponseWriter, r *http.Request) {
-		w = audit(w, r, config.AuditLog.Log())
+		w = audit(w, r, config)
 
 		if r.Method != Method {
 			w.Header().Set("Accept", Method)
@@ -51,16 +59,38 @@ func createKey(mux *http.ServeMux, config *ServerConfig) API {
 		}
 
 		name := strings.TrimSpace(strings.TrimPrefix(r.URL.Path, APIPath))
-		if err = validateName(name); err != nil {
+		if err = validateKeyName(config, name); err != nil {
+			Error(w, err)
+			return
+		}
+
+		// The request body is optional. An empty body
+		// selects the default algorithm.
+		var req Request
+		if err = json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
 			Error(w, err)
 			return
 		}
 
 		var algorithm key.Algorithm
-		if fips.Enabled || cpu.HasAESGCM() {
+		switch key.Algorithm(req.Algorithm) {
+		case key.AES256_GCM_SHA256:
 			algorithm = key.AES256_GCM_SHA256
-		} else {
+		case key.XCHACHA20_POLY1305:
+			if fips.Enabled {
+				Error(w, kes.NewError(http.StatusBadRequest, "invalid algorithm: not supported in FIPS mode"))
+				return
+			}
 			algorithm = key.XCHACHA20_POLY1305
+		case key.AlgorithmGeneric:
+			if fips.Enabled || cpu.HasAESGCM() {
+				algorithm = key.AES256_GCM_SHA256
+			} else {
+				algorithm = key.XCHACHA20_POLY1305
+			}
+		default:
+			Error(w, kes.NewError(http.StatusBadRequest, "invalid algorithm"))
+			return
 		}
 
 		key, err := key.Random(algorithm, auth.Identify(r))
@@ -68,7 +98,8 @@ func createKey(mux *http.ServeMux, config *ServerConfig) API {
 			Error(w, err)
 			return
 		}
-		if err = enclave.CreateKey(r.Context(), name, key); err != nil {
+		token := strings.TrimSpace(r.Header.Get("Idempotency-Key"))
+		if err = enclave.CreateKeyIdempotent(r.Context(), name, key, auth.Identify(r), token); err != nil {
 			Error(w, err)
 			return
 		}
@@ -95,7 +126,7 @@ func importKey(mux *http.ServeMux, config *ServerConfig) API {
 		Algorithm string `json:"algorithm"`
 	}
 	handler := func(w http.ResponseWriter, r *http.Request) {
-		w = audit(w, r, config.AuditLog.Log())
+		w = audit(w, r, config)
 
 		if r.Method != Method {
 			w.Header().Set("Accept", Method)
@@ -119,7 +150,7 @@ func importKey(mux *http.ServeMux, config *ServerConfig) API {
 		}
 
 		name := strings.TrimSpace(strings.TrimPrefix(r.URL.Path, APIPath))
-		if err = validateName(name); err != nil {
+		if err = validateKeyName(config, name); err != nil {
 			Error(w, err)
 			return
 		}
@@ -167,6 +198,81 @@ func importKey(mux *http.ServeMux, config *ServerConfig) API {
 	}
 }
 
+func describeKey(mux *http.ServeMux, config *ServerConfig) API {
+	const (
+		Method      = http.MethodGet
+		APIPath     = "/v1/key/describe/"
+		MaxBody     = 0
+		Timeout     = 15 * time.Second
+		ContentType = "application/json"
+	)
+	type Response struct {
+		Name          string       `json:"name"`
+		CreatedAt     time.Time    `json:"created_at,omitempty"`
+		CreatedBy     kes.Identity `json:"created_by,omitempty"`
+		Algorithm     string       `json:"algorithm,omitempty"`
+		EncryptCount  uint64       `json:"encrypt_count"`
+		DecryptCount  uint64       `json:"decrypt_count"`
+		GenerateCount uint64       `json:"generate_count"`
+		LastUsedAt    time.Time    `json:"last_used_at,omitempty"`
+	}
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		w = audit(w, r, config)
+
+		if r.Method != Method {
+			w.Header().Set("Accept", Method)
+			Error(w, errMethodNotAllowed)
+			return
+		}
+		if err := normalizeURL(r.URL, APIPath); err != nil {
+			Error(w, err)
+			return
+		}
+		r.Body = http.MaxBytesReader(w, r.Body, MaxBody)
+
+		enclave, err := lookupEnclave(config.Vault, r)
+		if err != nil {
+			Error(w, err)
+			return
+		}
+		if err = enclave.VerifyRequest(r); err != nil {
+			Error(w, err)
+			return
+		}
+
+		name := strings.TrimSpace(strings.TrimPrefix(r.URL.Path, APIPath))
+		if err = validateName(name); err != nil {
+			Error(w, err)
+			return
+		}
+		key, err := enclave.GetKey(r.Context(), name)
+		if err != nil {
+			Error(w, err)
+			return
+		}
+		usage := enclave.KeyUsage(name)
+
+		w.Header().Set("Content-Type", ContentType)
+		json.NewEncoder(w).Encode(Response{
+			Name:          name,
+			CreatedAt:     key.CreatedAt(),
+			CreatedBy:     key.CreatedBy(),
+			Algorithm:     key.Algorithm().String(),
+			EncryptCount:  usage.EncryptCount,
+			DecryptCount:  usage.DecryptCount,
+			GenerateCount: usage.GenerateCount,
+			LastUsedAt:    usage.LastUsedAt,
+		})
+	}
+	mux.HandleFunc(APIPath, timeout(Timeout, proxy(config.Proxy, config.Metrics.Count(config.Metrics.Latency(handler)))))
+	return API{
+		Method:  Method,
+		Path:    APIPath,
+		MaxBody: MaxBody,
+		Timeout: Timeout,
+	}
+}
+
 func deleteKey(mux *http.ServeMux, config *ServerConfig) API {
 	const (
 		Method  = http.MethodDelete
@@ -175,7 +281,7 @@ func deleteKey(mux *http.ServeMux, config *ServerConfig) API {
 		Timeout = 15 * time.Second
 	)
 	handler := func(w http.ResponseWriter, r *http.Request) {
-		w = audit(w, r, config.AuditLog.Log())
+		w = audit(w, r, config)
 
 		if r.Method != Method {
 			w.Header().Set("Accept", Method)
@@ -226,16 +332,26 @@ func generateKey(mux *http.ServeMux, config *ServerConfig) API {
 		MaxBody     = 1 << 20
 		Timeout     = 15 * time.Second
 		ContentType = "application/json"
+
+		MaxNonceSize = 256
 	)
 	type Request struct {
 		Context []byte `json:"context"` // optional
+		Attest  bool   `json:"attest"`  // optional
+		Nonce   []byte `json:"nonce"`   // optional
+		Bits    int    `json:"bits"`    // optional
+	}
+	type Attestation struct {
+		Time      time.Time `json:"time"`
+		Signature []byte    `json:"signature"`
 	}
 	type Response struct {
-		Plaintext  []byte `json:"plaintext"`
-		Ciphertext []byte `json:"ciphertext"`
+		Plaintext   []byte       `json:"plaintext"`
+		Ciphertext  []byte       `json:"ciphertext"`
+		Attestation *Attestation `json:"attestation,omitempty"`
 	}
 	handler := func(w http.ResponseWriter, r *http.Request) {
-		w = audit(w, r, config.AuditLog.Log())
+		w = audit(w, r, config)
 
 		if r.Method != Method {
 			w.Header().Set("Accept", Method)
@@ -269,12 +385,27 @@ func generateKey(mux *http.ServeMux, config *ServerConfig) API {
 			Error(w, err)
 			return
 		}
+		if len(req.Nonce) > MaxNonceSize {
+			Error(w, kes.NewError(http.StatusBadRequest, "nonce is too large"))
+			return
+		}
+		if req.Bits == 0 {
+			req.Bits = 256
+		}
+		if req.Bits != 128 && req.Bits != 256 {
+			Error(w, kes.NewError(http.StatusBadRequest, "invalid DEK length: must be 128 or 256 bits"))
+			return
+		}
+		if req.Attest && config.AttestationKey == nil {
+			Error(w, kes.NewError(http.StatusNotImplemented, "attestation not supported"))
+			return
+		}
 		key, err := enclave.GetKey(r.Context(), name)
 		if err != nil {
 			Error(w, err)
 			return
 		}
-		dataKey := make([]byte, 32)
+		dataKey := make([]byte, req.Bits/8)
 		if _, err = rand.Read(dataKey); err != nil {
 			Error(w, err)
 			return
@@ -284,10 +415,31 @@ func generateKey(mux *http.ServeMux, config *ServerConfig) API {
 			Error(w, err)
 			return
 		}
+		dek, duplicate := kes.DEK{Plaintext: dataKey, Ciphertext: ciphertext}, false
+		if len(req.Nonce) > 0 {
+			// A retried request with the same nonce gets the
+			// same DEK instead of a new one.
+			dek, duplicate = enclave.DeduplicateDEK(auth.Identify(r), name, req.Nonce, req.Context, dek)
+		}
+
+		var attestation *Attestation
+		if req.Attest {
+			attestation = &Attestation{Time: time.Now().UTC()}
+			attestation.Signature, err = sign(config.AttestationKey, (&kes.Attestation{Time: attestation.Time}).Digest(name, dek.Ciphertext))
+			if err != nil {
+				Error(w, err)
+				return
+			}
+		}
+		if !duplicate {
+			enclave.CountGenerate(name, 1)
+		}
+
 		w.Header().Set("Content-Type", ContentType)
 		json.NewEncoder(w).Encode(Response{
-			Plaintext:  dataKey,
-			Ciphertext: ciphertext,
+			Plaintext:   dek.Plaintext,
+			Ciphertext:  dek.Ciphertext,
+			Attestation: attestation,
 		})
 	}
 	mux.HandleFunc(APIPath, timeout(Timeout, proxy(config.Proxy, config.Metrics.Count(config.Metrics.Latency(handler)))))
@@ -299,6 +451,17 @@ func generateKey(mux *http.ServeMux, config *ServerConfig) API {
 	}
 }
 
+// sign signs the SHA-256 digest with the given signer.
+// Ed25519 keys sign the digest itself since they do not
+// support pre-hashed messages.
+func sign(signer crypto.Signer, digest []byte) ([]byte, error) {
+	var opts crypto.SignerOpts = crypto.SHA256
+	if _, ok := signer.Public().(ed25519.PublicKey); ok {
+		opts = crypto.Hash(0)
+	}
+	return signer.Sign(rand.Reader, digest, opts)
+}
+
 func encryptKey(mux *http.ServeMux, config *ServerConfig) API {
 	const (
 		Method      = http.MethodPost
@@ -315,7 +478,7 @@ func encryptKey(mux *http.ServeMux, config *ServerConfig) API {
 		Ciphertext []byte `json:"ciphertext"`
 	}
 	handler := func(w http.ResponseWriter, r *http.Request) {
-		w = audit(w, r, config.AuditLog.Log())
+		w = audit(w, r, config)
 
 		if r.Method != Method {
 			w.Header().Set("Accept", Method)
@@ -359,6 +522,8 @@ func encryptKey(mux *http.ServeMux, config *ServerConfig) API {
 			Error(w, err)
 			return
 		}
+		enclave.CountEncrypt(name, 1)
+
 		w.Header().Set("Content-Type", ContentType)
 		json.NewEncoder(w).Encode(Response{
 			Ciphertext: ciphertext,
@@ -389,7 +554,7 @@ func decryptKey(mux *http.ServeMux, config *ServerConfig) API {
 		Plaintext []byte `json:"plaintext"`
 	}
 	handler := func(w http.ResponseWriter, r *http.Request) {
-		w = audit(w, r, config.AuditLog.Log())
+		w = audit(w, r, config)
 
 		if r.Method != Method {
 			w.Header().Set("Accept", Method)
@@ -433,6 +598,8 @@ func decryptKey(mux *http.ServeMux, config *ServerConfig) API {
 			Error(w, err)
 			return
 		}
+		enclave.CountDecrypt(name, 1)
+
 		w.Header().Set("Content-Type", ContentType)
 		json.NewEncoder(w).Encode(Response{
 			Plaintext: plaintext,
@@ -464,7 +631,7 @@ func bulkDecryptKey(mux *http.ServeMux, config *ServerConfig) API {
 		Plaintext []byte `json:"plaintext"`
 	}
 	handler := func(w http.ResponseWriter, r *http.Request) {
-		w = audit(w, r, config.AuditLog.Log())
+		w = audit(w, r, config)
 
 		if r.Method != Method {
 			w.Header().Set("Accept", Method)
@@ -521,6 +688,7 @@ func bulkDecryptKey(mux *http.ServeMux, config *ServerConfig) API {
 				Plaintext: plaintext,
 			})
 		}
+		enclave.CountDecrypt(name, uint64(len(responses)))
 
 		w.Header().Set("Content-Type", ContentType)
 		json.NewEncoder(w).Encode(responses)
@@ -534,20 +702,25 @@ func bulkDecryptKey(mux *http.ServeMux, config *ServerConfig) API {
 	}
 }
 
-func listKey(mux *http.ServeMux, config *ServerConfig) API {
+func bulkGenerateKey(mux *http.ServeMux, config *ServerConfig) API {
 	const (
-		Method      = http.MethodGet
-		APIPath     = "/v1/key/list/"
-		MaxBody     = 0
+		Method      = http.MethodPost
+		APIPath     = "/v1/key/bulk/generate/"
+		MaxBody     = 1 << 20
 		Timeout     = 15 * time.Second
-		ContentType = "application/x-ndjson"
+		ContentType = "application/json"
+		MaxRequests = 1000 // For now, we limit the number of data keys generated in a single API call to 1000.
 	)
+	type Request struct {
+		Context []byte `json:"context"` // optional
+		Count   int    `json:"count"`
+	}
 	type Response struct {
-		Name string `json:"name,omitempty"`
-		Err  string `json:"error,omitempty"`
+		Plaintext  []byte `json:"plaintext"`
+		Ciphertext []byte `json:"ciphertext"`
 	}
 	handler := func(w http.ResponseWriter, r *http.Request) {
-		w = audit(w, r, config.AuditLog.Log())
+		w = audit(w, r, config)
 
 		if r.Method != Method {
 			w.Header().Set("Accept", Method)
@@ -570,49 +743,728 @@ func listKey(mux *http.ServeMux, config *ServerConfig) API {
 			return
 		}
 
-		pattern := strings.TrimSpace(strings.TrimPrefix(r.URL.Path, APIPath))
-		if err = validatePattern(pattern); err != nil {
+		name := strings.TrimSpace(strings.TrimPrefix(r.URL.Path, APIPath))
+		if err = validateName(name); err != nil {
 			Error(w, err)
 			return
 		}
-		iterator, err := enclave.ListKeys(r.Context())
+
+		var req Request
+		if err = json.NewDecoder(r.Body).Decode(&req); err != nil {
+			Error(w, err)
+			return
+		}
+		if req.Count <= 0 {
+			Error(w, kes.NewError(http.StatusBadRequest, "invalid number of data keys"))
+			return
+		}
+		if req.Count > MaxRequests {
+			Error(w, kes.NewError(http.StatusBadRequest, "too many data keys"))
+			return
+		}
+		key, err := enclave.GetKey(r.Context(), name)
 		if err != nil {
 			Error(w, err)
 			return
 		}
 
-		var (
-			hasWritten bool
-			encoder    = json.NewEncoder(w)
-		)
-		for iterator.Next() {
-			name := iterator.Name()
-			if ok, _ := path.Match(pattern, name); ok && name != "" {
-				if !hasWritten {
-					w.Header().Set("Content-Type", ContentType)
-				}
-				hasWritten = true
-
-				if err = encoder.Encode(Response{Name: name}); err != nil {
-					return
-				}
-				if err == http.ErrHandlerTimeout {
-					break
-				}
-				if err != nil {
-					encoder.Encode(Response{Err: err.Error()})
-					return
-				}
+		responses := make([]Response, 0, req.Count)
+		for i := 0; i < req.Count; i++ {
+			dataKey := make([]byte, 32)
+			if _, err = rand.Read(dataKey); err != nil {
+				Error(w, err)
+				return
+			}
+			ciphertext, err := key.Wrap(dataKey, req.Context)
+			if err != nil {
+				Error(w, err)
+				return
 			}
+			responses = append(responses, Response{
+				Plaintext:  dataKey,
+				Ciphertext: ciphertext,
+			})
 		}
-		if err = iterator.Err(); err != nil {
-			if !hasWritten {
+		enclave.CountGenerate(name, uint64(len(responses)))
+		w.Header().Set("Content-Type", ContentType)
+		json.NewEncoder(w).Encode(responses)
+	}
+	mux.HandleFunc(APIPath, timeout(Timeout, proxy(config.Proxy, config.Metrics.Count(config.Metrics.Latency(handler)))))
+	return API{
+		Method:  Method,
+		Path:    APIPath,
+		MaxBody: MaxBody,
+		Timeout: Timeout,
+	}
+}
+
+func multiGenerateKey(mux *http.ServeMux, config *ServerConfig) API {
+	const (
+		Method      = http.MethodPost
+		APIPath     = "/v1/key/multi/generate/"
+		MaxBody     = 1 << 20
+		Timeout     = 15 * time.Second
+		ContentType = "application/json"
+		MaxKeys     = 100 // For now, we limit the number of keys used in a single API call to 100.
+
+		GenerateAPIPath = "/v1/key/generate/"
+	)
+	type Request struct {
+		Keys    []string `json:"keys"`    // Names of the keys, besides the one in the URL path
+		Context []byte   `json:"context"` // optional
+	}
+	type Response struct {
+		Plaintext   []byte            `json:"plaintext"`
+		Ciphertexts map[string][]byte `json:"ciphertexts"`
+	}
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		w = audit(w, r, config)
+
+		if r.Method != Method {
+			w.Header().Set("Accept", Method)
+			Error(w, errMethodNotAllowed)
+			return
+		}
+		if err := normalizeURL(r.URL, APIPath); err != nil {
+			Error(w, err)
+			return
+		}
+		r.Body = http.MaxBytesReader(w, r.Body, MaxBody)
+
+		enclave, err := lookupEnclave(config.Vault, r)
+		if err != nil {
+			Error(w, err)
+			return
+		}
+		if err = enclave.VerifyRequest(r); err != nil {
+			Error(w, err)
+			return
+		}
+
+		name := strings.TrimSpace(strings.TrimPrefix(r.URL.Path, APIPath))
+		if err = validateName(name); err != nil {
+			Error(w, err)
+			return
+		}
+
+		var req Request
+		if err = json.NewDecoder(r.Body).Decode(&req); err != nil {
+			Error(w, err)
+			return
+		}
+		names := append([]string{name}, req.Keys...)
+		if len(names) > MaxKeys {
+			Error(w, kes.NewError(http.StatusBadRequest, "too many keys"))
+			return
+		}
+
+		// The identity must be allowed to generate DEKs with
+		// each key. Hence, we verify a generate request for
+		// each key such that this API does not grant access
+		// to keys the identity could not use otherwise.
+		seen := make(map[string]bool, len(names))
+		for _, name := range names {
+			if err = validateName(name); err != nil {
 				Error(w, err)
-			} else {
-				encoder.Encode(Response{Err: err.Error()})
+				return
+			}
+			if seen[name] {
+				Error(w, kes.NewError(http.StatusBadRequest, "duplicate key"))
+				return
+			}
+			seen[name] = true
+
+			generate := r.Clone(r.Context())
+			generate.URL.Path = GenerateAPIPath + name
+			if err = enclave.VerifyRequest(generate); err != nil {
+				Error(w, err)
+				return
 			}
+		}
+
+		dataKey := make([]byte, 32)
+		if _, err = rand.Read(dataKey); err != nil {
+			Error(w, err)
 			return
 		}
+		ciphertexts := make(map[string][]byte, len(names))
+		for _, name := range names {
+			key, err := enclave.GetKey(r.Context(), name)
+			if err != nil {
+				Error(w, err)
+				return
+			}
+			if ciphertexts[name], err = key.Wrap(dataKey, req.Context); err != nil {
+				Error(w, err)
+				return
+			}
+		}
+		for _, name := range names {
+			enclave.CountGenerate(name, 1)
+		}
+
+		w.Header().Set("Content-Type", ContentType)
+		json.NewEncoder(w).Encode(Response{
+			Plaintext:   dataKey,
+			Ciphertexts: ciphertexts,
+		})
+	}
+	mux.HandleFunc(APIPath, timeout(Timeout, proxy(config.Proxy, config.Metrics.Count(config.Metrics.Latency(handler)))))
+	return API{
+		Method:  Method,
+		Path:    APIPath,
+		MaxBody: MaxBody,
+		Timeout: Timeout,
+	}
+}
+
+func rewrapKey(mux *http.ServeMux, config *ServerConfig) API {
+	const (
+		Method      = http.MethodPost
+		APIPath     = "/v1/key/rewrap/"
+		MaxBody     = 1 << 20
+		Timeout     = 15 * time.Second
+		ContentType = "application/json"
+	)
+	type Request struct {
+		Ciphertext []byte `json:"ciphertext"`
+		Context    []byte `json:"context"` // optional
+	}
+	type Response struct {
+		Ciphertext []byte `json:"ciphertext"`
+	}
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		w = audit(w, r, config)
+
+		if r.Method != Method {
+			w.Header().Set("Accept", Method)
+			Error(w, errMethodNotAllowed)
+			return
+		}
+		if err := normalizeURL(r.URL, APIPath); err != nil {
+			Error(w, err)
+			return
+		}
+		r.Body = http.MaxBytesReader(w, r.Body, MaxBody)
+
+		enclave, err := lookupEnclave(config.Vault, r)
+		if err != nil {
+			Error(w, err)
+			return
+		}
+		if err = enclave.VerifyRequest(r); err != nil {
+			Error(w, err)
+			return
+		}
+
+		name := strings.TrimSpace(strings.TrimPrefix(r.URL.Path, APIPath))
+		if err = validateName(name); err != nil {
+			Error(w, err)
+			return
+		}
+
+		var req Request
+		if err = json.NewDecoder(r.Body).Decode(&req); err != nil {
+			Error(w, err)
+			return
+		}
+		key, err := enclave.GetKey(r.Context(), name)
+		if err != nil {
+			Error(w, err)
+			return
+		}
+		plaintext, err := key.Unwrap(req.Ciphertext, req.Context)
+		if err != nil {
+			Error(w, err)
+			return
+		}
+		ciphertext, err := key.Wrap(plaintext, req.Context)
+		for i := range plaintext {
+			plaintext[i] = 0
+		}
+		if err != nil {
+			Error(w, err)
+			return
+		}
+		enclave.CountDecrypt(name, 1)
+		enclave.CountEncrypt(name, 1)
+		w.Header().Set("Content-Type", ContentType)
+		json.NewEncoder(w).Encode(Response{
+			Ciphertext: ciphertext,
+		})
+	}
+	mux.HandleFunc(APIPath, timeout(Timeout, proxy(config.Proxy, config.Metrics.Count(config.Metrics.Latency(handler)))))
+	return API{
+		Method:  Method,
+		Path:    APIPath,
+		MaxBody: MaxBody,
+		Timeout: Timeout,
+	}
+}
+
+func deriveKey(mux *http.ServeMux, config *ServerConfig) API {
+	const (
+		Method      = http.MethodPost
+		APIPath     = "/v1/key/derive/"
+		MaxBody     = 1 << 20
+		Timeout     = 15 * time.Second
+		ContentType = "application/json"
+	)
+	type Request struct {
+		Info   []byte `json:"info"`
+		Length int    `json:"length"`
+	}
+	type Response struct {
+		Key []byte `json:"key"`
+	}
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		w = audit(w, r, config)
+
+		if r.Method != Method {
+			w.Header().Set("Accept", Method)
+			Error(w, errMethodNotAllowed)
+			return
+		}
+		if err := normalizeURL(r.URL, APIPath); err != nil {
+			Error(w, err)
+			return
+		}
+		r.Body = http.MaxBytesReader(w, r.Body, MaxBody)
+
+		enclave, err := lookupEnclave(config.Vault, r)
+		if err != nil {
+			Error(w, err)
+			return
+		}
+		if err = enclave.VerifyRequest(r); err != nil {
+			Error(w, err)
+			return
+		}
+
+		name := strings.TrimSpace(strings.TrimPrefix(r.URL.Path, APIPath))
+		if err = validateName(name); err != nil {
+			Error(w, err)
+			return
+		}
+
+		var req Request
+		if err = json.NewDecoder(r.Body).Decode(&req); err != nil {
+			Error(w, err)
+			return
+		}
+		if req.Length <= 0 || req.Length > key.MaxDeriveSize {
+			Error(w, kes.NewError(http.StatusBadRequest, "invalid key length"))
+			return
+		}
+		masterKey, err := enclave.GetKey(r.Context(), name)
+		if err != nil {
+			Error(w, err)
+			return
+		}
+		derived, err := masterKey.Derive(req.Info, req.Length)
+		if err != nil {
+			Error(w, err)
+			return
+		}
+		enclave.CountGenerate(name, 1)
+		w.Header().Set("Content-Type", ContentType)
+		json.NewEncoder(w).Encode(Response{
+			Key: derived,
+		})
+	}
+	mux.HandleFunc(APIPath, timeout(Timeout, proxy(config.Proxy, config.Metrics.Count(config.Metrics.Latency(handler)))))
+	return API{
+		Method:  Method,
+		Path:    APIPath,
+		MaxBody: MaxBody,
+		Timeout: Timeout,
+	}
+}
+
+func exportKey(mux *http.ServeMux, config *ServerConfig) API {
+	const (
+		Method      = http.MethodGet
+		APIPath     = "/v1/key/export/"
+		MaxBody     = 0
+		Timeout     = 15 * time.Second
+		ContentType = "application/json"
+	)
+	type Response struct {
+		Bytes     []byte `json:"bytes"`
+		Algorithm string `json:"algorithm,omitempty"`
+	}
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		w = audit(w, r, config)
+
+		if r.Method != Method {
+			w.Header().Set("Accept", Method)
+			Error(w, errMethodNotAllowed)
+			return
+		}
+		if err := normalizeURL(r.URL, APIPath); err != nil {
+			Error(w, err)
+			return
+		}
+		r.Body = http.MaxBytesReader(w, r.Body, MaxBody)
+
+		enclave, err := lookupEnclave(config.Vault, r)
+		if err != nil {
+			Error(w, err)
+			return
+		}
+		if err = enclave.VerifyExport(r); err != nil {
+			Error(w, err)
+			return
+		}
+
+		name := strings.TrimSpace(strings.TrimPrefix(r.URL.Path, APIPath))
+		if err = validateName(name); err != nil {
+			Error(w, err)
+			return
+		}
+		key, err := enclave.GetKey(r.Context(), name)
+		if err != nil {
+			Error(w, err)
+			return
+		}
+		w.Header().Set("Content-Type", ContentType)
+		json.NewEncoder(w).Encode(Response{
+			Bytes:     key.Bytes(),
+			Algorithm: key.Algorithm().String(),
+		})
+	}
+	mux.HandleFunc(APIPath, timeout(Timeout, proxy(config.Proxy, config.Metrics.Count(config.Metrics.Latency(handler)))))
+	return API{
+		Method:  Method,
+		Path:    APIPath,
+		MaxBody: MaxBody,
+		Timeout: Timeout,
+	}
+}
+
+func copyKey(mux *http.ServeMux, config *ServerConfig) API {
+	const (
+		Method  = http.MethodPost
+		APIPath = "/v1/key/copy/"
+		MaxBody = 1 << 20
+		Timeout = 15 * time.Second
+	)
+	type Request struct {
+		Name string `json:"name"`
+	}
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		w = audit(w, r, config)
+
+		if r.Method != Method {
+			w.Header().Set("Accept", Method)
+			Error(w, errMethodNotAllowed)
+			return
+		}
+		if err := normalizeURL(r.URL, APIPath); err != nil {
+			Error(w, err)
+			return
+		}
+		r.Body = http.MaxBytesReader(w, r.Body, MaxBody)
+
+		enclave, err := lookupEnclave(config.Vault, r)
+		if err != nil {
+			Error(w, err)
+			return
+		}
+		if err = enclave.VerifyAdmin(r); err != nil { // Only the admin can copy key material
+			Error(w, err)
+			return
+		}
+
+		name := strings.TrimSpace(strings.TrimPrefix(r.URL.Path, APIPath))
+		if err = validateName(name); err != nil {
+			Error(w, err)
+			return
+		}
+
+		var req Request
+		if err = json.NewDecoder(r.Body).Decode(&req); err != nil {
+			Error(w, err)
+			return
+		}
+		if err = validateKeyName(config, req.Name); err != nil {
+			Error(w, err)
+			return
+		}
+
+		src, err := enclave.GetKey(r.Context(), name)
+		if err != nil {
+			Error(w, err)
+			return
+		}
+		dst, err := key.New(src.Algorithm(), src.Bytes(), auth.Identify(r))
+		if err != nil {
+			Error(w, err)
+			return
+		}
+		if err = enclave.CreateKey(r.Context(), req.Name, dst); err != nil {
+			Error(w, err)
+			return
+		}
+		enclave.CopyKeyUsage(name, req.Name)
+		w.WriteHeader(http.StatusOK)
+	}
+	mux.HandleFunc(APIPath, timeout(Timeout, proxy(config.Proxy, config.Metrics.Count(config.Metrics.Latency(handler)))))
+	return API{
+		Method:  Method,
+		Path:    APIPath,
+		MaxBody: MaxBody,
+		Timeout: Timeout,
+	}
+}
+
+func renameKey(mux *http.ServeMux, config *ServerConfig) API {
+	const (
+		Method  = http.MethodPost
+		APIPath = "/v1/key/rename/"
+		MaxBody = 1 << 20
+		Timeout = 15 * time.Second
+	)
+	type Request struct {
+		Name string `json:"name"`
+	}
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		w = audit(w, r, config)
+
+		if r.Method != Method {
+			w.Header().Set("Accept", Method)
+			Error(w, errMethodNotAllowed)
+			return
+		}
+		if err := normalizeURL(r.URL, APIPath); err != nil {
+			Error(w, err)
+			return
+		}
+		r.Body = http.MaxBytesReader(w, r.Body, MaxBody)
+
+		enclave, err := lookupEnclave(config.Vault, r)
+		if err != nil {
+			Error(w, err)
+			return
+		}
+		if err = enclave.VerifyAdmin(r); err != nil { // Only the admin can move keys out of a policy's scope
+			Error(w, err)
+			return
+		}
+
+		name := strings.TrimSpace(strings.TrimPrefix(r.URL.Path, APIPath))
+		if err = validateName(name); err != nil {
+			Error(w, err)
+			return
+		}
+
+		var req Request
+		if err = json.NewDecoder(r.Body).Decode(&req); err != nil {
+			Error(w, err)
+			return
+		}
+		if err = validateKeyName(config, req.Name); err != nil {
+			Error(w, err)
+			return
+		}
+		if err = enclave.RenameKey(r.Context(), name, req.Name); err != nil {
+			Error(w, err)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}
+	mux.HandleFunc(APIPath, timeout(Timeout, proxy(config.Proxy, config.Metrics.Count(config.Metrics.Latency(handler)))))
+	return API{
+		Method:  Method,
+		Path:    APIPath,
+		MaxBody: MaxBody,
+		Timeout: Timeout,
+	}
+}
+
+func listKey(mux *http.ServeMux, config *ServerConfig) API {
+	const (
+		Method      = http.MethodGet
+		APIPath     = "/v1/key/list/"
+		MaxBody     = 0
+		Timeout     = 15 * time.Second
+		ContentType = "application/x-ndjson"
+
+		SortByName      = "name"
+		SortByCreatedAt = "created_at"
+
+		// The server has to buffer all matching keys before
+		// it can sort them. Hence, we limit the number of
+		// keys it sorts.
+		MaxSortedKeys = 10000
+	)
+	type Response struct {
+		Name      string       `json:"name,omitempty"`
+		CreatedAt *time.Time   `json:"created_at,omitempty"`
+		CreatedBy kes.Identity `json:"created_by,omitempty"`
+
+		Err string `json:"error,omitempty"`
+	}
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		w = audit(w, r, config)
+
+		if r.Method != Method {
+			w.Header().Set("Accept", Method)
+			Error(w, errMethodNotAllowed)
+			return
+		}
+		if err := normalizeURL(r.URL, APIPath); err != nil {
+			Error(w, err)
+			return
+		}
+		r.Body = http.MaxBytesReader(w, r.Body, MaxBody)
+
+		enclave, err := lookupEnclave(config.Vault, r)
+		if err != nil {
+			Error(w, err)
+			return
+		}
+		if err = enclave.VerifyRequest(r); err != nil {
+			Error(w, err)
+			return
+		}
+
+		pattern := strings.TrimSpace(strings.TrimPrefix(r.URL.Path, APIPath))
+		if err = validatePattern(pattern); err != nil {
+			Error(w, err)
+			return
+		}
+		// Fetching the creation info of a key requires one
+		// key store lookup per key. Hence, we only do it if
+		// the client asks for it, or needs it for sorting or
+		// filtering the keys.
+		metadata := r.URL.Query().Get("metadata") == "true"
+		sortBy := r.URL.Query().Get("sort")
+		if sortBy != "" && sortBy != SortByName && sortBy != SortByCreatedAt {
+			Error(w, kes.NewError(http.StatusBadRequest, "invalid sort order"))
+			return
+		}
+		var createdAfter, createdBefore time.Time
+		if v := r.URL.Query().Get("created_after"); v != "" {
+			if createdAfter, err = time.Parse(time.RFC3339Nano, v); err != nil {
+				Error(w, kes.NewError(http.StatusBadRequest, "invalid created_after timestamp"))
+				return
+			}
+		}
+		if v := r.URL.Query().Get("created_before"); v != "" {
+			if createdBefore, err = time.Parse(time.RFC3339Nano, v); err != nil {
+				Error(w, kes.NewError(http.StatusBadRequest, "invalid created_before timestamp"))
+				return
+			}
+		}
+		// inRange reports whether a key created at t lies within
+		// the requested time window. Keys without a creation time
+		// are excluded whenever a bound is set.
+		inRange := func(t time.Time) bool {
+			if createdAfter.IsZero() && createdBefore.IsZero() {
+				return true
+			}
+			if t.IsZero() {
+				return false
+			}
+			if !createdAfter.IsZero() && !t.After(createdAfter) {
+				return false
+			}
+			if !createdBefore.IsZero() && !t.Before(createdBefore) {
+				return false
+			}
+			return true
+		}
+		if sortBy == SortByCreatedAt || !createdAfter.IsZero() || !createdBefore.IsZero() {
+			metadata = true
+		}
+		cw := newCompressWriter(w, r)
+		defer cw.Close()
+		w = cw
+
+		iterator, err := enclave.ListKeys(r.Context())
+		if err != nil {
+			Error(w, err)
+			return
+		}
+
+		var (
+			hasWritten bool
+			encoder    = json.NewEncoder(w)
+			sorted     []Response // Only used if the keys should be sorted
+		)
+		for iterator.Next() {
+			name := iterator.Name()
+			if ok, _ := path.Match(pattern, name); ok && name != "" {
+				response := Response{Name: name}
+				if metadata {
+					key, err := enclave.GetKey(r.Context(), name)
+					if errors.Is(err, kes.ErrKeyNotFound) {
+						continue // The key has been deleted in the meantime
+					}
+					if err == http.ErrHandlerTimeout {
+						break
+					}
+					if err != nil {
+						if !hasWritten {
+							Error(w, err)
+						} else {
+							encoder.Encode(Response{Err: err.Error()})
+						}
+						return
+					}
+					if !inRange(key.CreatedAt()) {
+						continue
+					}
+					createdAt := key.CreatedAt()
+					response.CreatedAt, response.CreatedBy = &createdAt, key.CreatedBy()
+				}
+				if sortBy != "" {
+					// Keys can only be sorted once all
+					// of them have been listed.
+					if len(sorted) == MaxSortedKeys {
+						Error(w, kes.NewError(http.StatusBadRequest, "too many keys to sort: pattern must match at most 10000 keys"))
+						return
+					}
+					sorted = append(sorted, response)
+					continue
+				}
+				if !hasWritten {
+					w.Header().Set("Content-Type", ContentType)
+				}
+				hasWritten = true
+
+				if err = encoder.Encode(response); err == http.ErrHandlerTimeout {
+					break
+				}
+				if err != nil {
+					return
+				}
+			}
+		}
+		if err = iterator.Err(); err != nil {
+			if !hasWritten {
+				Error(w, err)
+			} else {
+				encoder.Encode(Response{Err: err.Error()})
+			}
+			return
+		}
+		if len(sorted) > 0 {
+			sort.Slice(sorted, func(i, j int) bool {
+				if sortBy == SortByCreatedAt && !sorted[i].CreatedAt.Equal(*sorted[j].CreatedAt) {
+					return sorted[i].CreatedAt.Before(*sorted[j].CreatedAt)
+				}
+				return sorted[i].Name < sorted[j].Name
+			})
+			w.Header().Set("Content-Type", ContentType)
+			hasWritten = true
+			for _, response := range sorted {
+				if err = encoder.Encode(response); err != nil {
+					return
+				}
+			}
+		}
 		if !hasWritten {
 			w.WriteHeader(http.StatusOK)
 		}
@@ -37,19 +37,23 @@ func logErrorEvents(mux *http.ServeMux, config *ServerConfig) API {
 			Error(w, err)
 			return
 		}
-		if err = enclave.VerifyRequest(r); err != nil {
+		if err = enclave.VerifyAdmin(r); err != nil { // Only the admin can subscribe to the error log
 			Error(w, err)
 			return
 		}
 
 		w.Header().Set("Content-Type", ContentType)
 		w.WriteHeader(http.StatusOK)
+		if flusher, ok := w.(http.Flusher); ok {
+			flusher.Flush() // Send the response headers before the first event
+		}
 
-		out := xlog.NewErrEncoder(NewFlushWriter(w))
-		config.ErrorLog.Add(out)
-		defer config.ErrorLog.Remove(out)
+		out := newKeepAliveWriter(NewFlushWriter(w))
+		errEncoder := xlog.NewErrEncoder(out)
+		config.ErrorLog.Add(errEncoder)
+		defer config.ErrorLog.Remove(errEncoder)
 
-		<-r.Context().Done() // Wait for the client to close the connection
+		out.KeepAlive(r.Context(), config.KeepAlive) // Wait for the client to close the connection
 	}
 	mux.HandleFunc(APIPath, proxy(config.Proxy, config.Metrics.Count(config.Metrics.Latency(handler))))
 	return API{
@@ -86,19 +90,22 @@ func logAuditEvents(mux *http.ServeMux, config *ServerConfig) API {
 			Error(w, err)
 			return
 		}
-		if err = enclave.VerifyRequest(r); err != nil {
+		if err = enclave.VerifyAdmin(r); err != nil { // Only the admin can subscribe to the audit log
 			Error(w, err)
 			return
 		}
 
 		w.Header().Set("Content-Type", ContentType)
 		w.WriteHeader(http.StatusOK)
+		if flusher, ok := w.(http.Flusher); ok {
+			flusher.Flush() // Send the response headers before the first event
+		}
 
-		out := NewFlushWriter(w)
+		out := newKeepAliveWriter(NewFlushWriter(w))
 		config.AuditLog.Add(out)
 		defer config.AuditLog.Remove(out)
 
-		<-r.Context().Done() // Wait for the client to close the connection
+		out.KeepAlive(r.Context(), config.KeepAlive) // Wait for the client to close the connection
 	}
 	mux.HandleFunc(APIPath, proxy(config.Proxy, config.Metrics.Count(config.Metrics.Latency(handler))))
 	return API{
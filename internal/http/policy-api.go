@@ -6,6 +6,7 @@ package http
 
 import (
 	"encoding/json"
+	"math"
 	"net/http"
 	"path"
 	"strings"
@@ -13,6 +14,7 @@ import (
 
 	"github.com/minio/kes"
 	"github.com/minio/kes/internal/auth"
+	"github.com/minio/kes/internal/sys"
 )
 
 func describePolicy(mux *http.ServeMux, config *ServerConfig) API {
@@ -24,11 +26,17 @@ func describePolicy(mux *http.ServeMux, config *ServerConfig) API {
 		ContentType = "application/json"
 	)
 	type Response struct {
-		CreatedAt time.Time    `json:"created_at,omitempty"`
-		CreatedBy kes.Identity `json:"created_by,omitempty"`
+		Allow          []string        `json:"allow,omitempty"`
+		Deny           []string        `json:"deny,omitempty"`
+		Extends        []string        `json:"extends,omitempty"`
+		EffectiveAllow []string        `json:"effective_allow,omitempty"`
+		EffectiveDeny  []string        `json:"effective_deny,omitempty"`
+		AllowWindow    *kes.TimeWindow `json:"effective_allow_window,omitempty"`
+		CreatedAt      time.Time       `json:"created_at,omitempty"`
+		CreatedBy      kes.Identity    `json:"created_by,omitempty"`
 	}
 	handler := func(w http.ResponseWriter, r *http.Request) {
-		w = audit(w, r, config.AuditLog.Log())
+		w = audit(w, r, config)
 
 		if r.Method != Method {
 			w.Header().Set("Accept", Method)
@@ -61,10 +69,21 @@ func describePolicy(mux *http.ServeMux, config *ServerConfig) API {
 			Error(w, err)
 			return
 		}
+		effective, err := enclave.ResolvePolicy(r.Context(), name, policy)
+		if err != nil {
+			Error(w, err)
+			return
+		}
 		w.Header().Set("Content-Type", ContentType)
 		json.NewEncoder(w).Encode(Response{
-			CreatedAt: policy.CreatedAt,
-			CreatedBy: policy.CreatedBy,
+			Allow:          policy.Allow,
+			Deny:           policy.Deny,
+			Extends:        policy.Extends,
+			EffectiveAllow: effective.Allow,
+			EffectiveDeny:  effective.Deny,
+			AllowWindow:    effective.AllowWindow,
+			CreatedAt:      policy.CreatedAt,
+			CreatedBy:      policy.CreatedBy,
 		})
 	}
 	mux.HandleFunc(APIPath, timeout(Timeout, config.Metrics.Count(config.Metrics.Latency(handler))))
@@ -84,10 +103,11 @@ func assignPolicy(mux *http.ServeMux, config *ServerConfig) API {
 		Timeout = 15 * time.Second
 	)
 	type Request struct {
-		Identity kes.Identity `json:"identity"`
+		Identity kes.Identity  `json:"identity"`
+		TTL      time.Duration `json:"ttl,omitempty"`
 	}
 	handler := func(w http.ResponseWriter, r *http.Request) {
-		w = audit(w, r, config.AuditLog.Log())
+		w = audit(w, r, config)
 
 		if r.Method != Method {
 			w.Header().Set("Accept", Method)
@@ -128,7 +148,15 @@ func assignPolicy(mux *http.ServeMux, config *ServerConfig) API {
 			Error(w, kes.NewError(http.StatusForbidden, "identity cannot assign policy to itself"))
 			return
 		}
-		if err = enclave.AssignPolicy(r.Context(), name, req.Identity); err != nil {
+		if req.TTL < 0 {
+			Error(w, kes.NewError(http.StatusBadRequest, "invalid policy assignment: TTL must not be negative"))
+			return
+		}
+		var expiresAt time.Time
+		if req.TTL > 0 {
+			expiresAt = time.Now().UTC().Add(req.TTL)
+		}
+		if err = enclave.AssignPolicy(r.Context(), name, req.Identity, expiresAt); err != nil {
 			Error(w, err)
 			return
 		}
@@ -143,6 +171,90 @@ func assignPolicy(mux *http.ServeMux, config *ServerConfig) API {
 	}
 }
 
+func bulkAssignPolicy(mux *http.ServeMux, config *ServerConfig) API {
+	const (
+		Method        = http.MethodPost
+		APIPath       = "/v1/policy/bulk/assign/"
+		MaxBody       = 1 << 20
+		Timeout       = 15 * time.Second
+		ContentType   = "application/json"
+		MaxIdentities = 1000 // For now, we limit the number of identities assigned in a single API call to 1000.
+	)
+	type Request struct {
+		Identities []string `json:"identities"`
+	}
+	type Response struct {
+		Identity kes.Identity `json:"identity"`
+		Err      string       `json:"error"`
+	}
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		w = audit(w, r, config)
+
+		if r.Method != Method {
+			w.Header().Set("Accept", Method)
+			Error(w, errMethodNotAllowed)
+			return
+		}
+		if err := normalizeURL(r.URL, APIPath); err != nil {
+			Error(w, err)
+			return
+		}
+		r.Body = http.MaxBytesReader(w, r.Body, MaxBody)
+
+		enclave, err := lookupEnclave(config.Vault, r)
+		if err != nil {
+			Error(w, err)
+			return
+		}
+		if err = enclave.VerifyRequest(r); err != nil {
+			Error(w, err)
+			return
+		}
+		name := strings.TrimSpace(strings.TrimPrefix(r.URL.Path, APIPath))
+		if err = validateName(name); err != nil {
+			Error(w, err)
+			return
+		}
+
+		var req Request
+		if err = json.NewDecoder(r.Body).Decode(&req); err != nil {
+			Error(w, err)
+			return
+		}
+		if len(req.Identities) > MaxIdentities {
+			Error(w, kes.NewError(http.StatusBadRequest, "too many identities"))
+			return
+		}
+
+		var (
+			self      = auth.Identify(r)
+			responses = make([]Response, 0, len(req.Identities))
+		)
+		for _, identity := range req.Identities {
+			if err = validateName(identity); err != nil {
+				responses = append(responses, Response{Identity: kes.Identity(identity), Err: err.Error()})
+				continue
+			}
+			if kes.Identity(identity) == self {
+				responses = append(responses, Response{Identity: self, Err: "identity cannot assign policy to itself"})
+				continue
+			}
+			if err = enclave.AssignPolicy(r.Context(), name, kes.Identity(identity), time.Time{}); err != nil {
+				responses = append(responses, Response{Identity: kes.Identity(identity), Err: err.Error()})
+			}
+		}
+		w.Header().Set("Content-Type", ContentType)
+		json.NewEncoder(w).Encode(responses)
+	}
+	mux.HandleFunc(APIPath, timeout(Timeout, proxy(config.Proxy, config.Metrics.Count(config.Metrics.Latency(handler)))))
+	return API{
+		Method:  Method,
+		Path:    APIPath,
+		MaxBody: MaxBody,
+		Timeout: Timeout,
+	}
+}
+
 func readPolicy(mux *http.ServeMux, config *ServerConfig) API {
 	const (
 		Method      = http.MethodGet
@@ -152,13 +264,16 @@ func readPolicy(mux *http.ServeMux, config *ServerConfig) API {
 		ContentType = "application/json"
 	)
 	type Response struct {
-		Allow     []string     `json:"allow,omitempty"`
-		Deny      []string     `json:"deny,omitempty"`
-		CreatedAt time.Time    `json:"created_at,omitempty"`
-		CreatedBy kes.Identity `json:"created_by,omitempty"`
+		Allow       []string           `json:"allow,omitempty"`
+		Deny        []string           `json:"deny,omitempty"`
+		RateLimit   map[string]float64 `json:"rate_limit,omitempty"`
+		Extends     []string           `json:"extends,omitempty"`
+		AllowWindow *kes.TimeWindow    `json:"allow_window,omitempty"`
+		CreatedAt   time.Time          `json:"created_at,omitempty"`
+		CreatedBy   kes.Identity       `json:"created_by,omitempty"`
 	}
 	handler := func(w http.ResponseWriter, r *http.Request) {
-		w = audit(w, r, config.AuditLog.Log())
+		w = audit(w, r, config)
 
 		if r.Method != Method {
 			w.Header().Set("Accept", Method)
@@ -193,10 +308,13 @@ func readPolicy(mux *http.ServeMux, config *ServerConfig) API {
 		}
 		w.Header().Set("Content-Type", ContentType)
 		json.NewEncoder(w).Encode(Response{
-			Allow:     policy.Allow,
-			Deny:      policy.Deny,
-			CreatedAt: policy.CreatedAt,
-			CreatedBy: policy.CreatedBy,
+			Allow:       policy.Allow,
+			Deny:        policy.Deny,
+			RateLimit:   policy.RateLimit,
+			Extends:     policy.Extends,
+			AllowWindow: policy.AllowWindow,
+			CreatedAt:   policy.CreatedAt,
+			CreatedBy:   policy.CreatedBy,
 		})
 	}
 	mux.HandleFunc(APIPath, timeout(Timeout, proxy(config.Proxy, config.Metrics.Count(config.Metrics.Latency(handler)))))
@@ -216,11 +334,14 @@ func writePolicy(mux *http.ServeMux, config *ServerConfig) API {
 		Timeout = 15 * time.Second
 	)
 	type Request struct {
-		Allow []string `json:"allow,omitempty"`
-		Deny  []string `json:"deny,omitempty"`
+		Allow       []string           `json:"allow,omitempty"`
+		Deny        []string           `json:"deny,omitempty"`
+		RateLimit   map[string]float64 `json:"rate_limit,omitempty"`
+		Extends     []string           `json:"extends,omitempty"`
+		AllowWindow *kes.TimeWindow    `json:"allow_window,omitempty"`
 	}
 	handler := func(w http.ResponseWriter, r *http.Request) {
-		w = audit(w, r, config.AuditLog.Log())
+		w = audit(w, r, config)
 
 		if r.Method != Method {
 			w.Header().Set("Accept", Method)
@@ -254,11 +375,23 @@ func writePolicy(mux *http.ServeMux, config *ServerConfig) API {
 			Error(w, err)
 			return
 		}
+		err = validatePolicy(&kes.Policy{
+			RateLimit:   req.RateLimit,
+			Extends:     req.Extends,
+			AllowWindow: req.AllowWindow,
+		})
+		if err != nil {
+			Error(w, err)
+			return
+		}
 		policy := &auth.Policy{
-			Allow:     req.Allow,
-			Deny:      req.Deny,
-			CreatedAt: time.Now().UTC(),
-			CreatedBy: auth.Identify(r),
+			Allow:       req.Allow,
+			Deny:        req.Deny,
+			RateLimit:   req.RateLimit,
+			Extends:     req.Extends,
+			AllowWindow: req.AllowWindow,
+			CreatedAt:   time.Now().UTC(),
+			CreatedBy:   auth.Identify(r),
 		}
 		if err = enclave.SetPolicy(r.Context(), name, policy); err != nil {
 			Error(w, err)
@@ -275,6 +408,115 @@ func writePolicy(mux *http.ServeMux, config *ServerConfig) API {
 	}
 }
 
+func listPolicyIdentities(mux *http.ServeMux, config *ServerConfig) API {
+	const (
+		Method      = http.MethodGet
+		APIPath     = "/v1/policy/identities/"
+		MaxBody     = 0
+		Timeout     = 15 * time.Second
+		ContentType = "application/x-ndjson"
+	)
+	type Response struct {
+		Identity  kes.Identity `json:"identity"`
+		IsAdmin   bool         `json:"admin"`
+		Policy    string       `json:"policy"`
+		CreatedAt time.Time    `json:"created_at,omitempty"`
+		CreatedBy kes.Identity `json:"created_by,omitempty"`
+
+		Err string `json:"error,omitempty"`
+	}
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		w = audit(w, r, config)
+
+		if r.Method != Method {
+			w.Header().Set("Accept", Method)
+			Error(w, errMethodNotAllowed)
+			return
+		}
+		if err := normalizeURL(r.URL, APIPath); err != nil {
+			Error(w, err)
+			return
+		}
+		r.Body = http.MaxBytesReader(w, r.Body, MaxBody)
+
+		enclave, err := lookupEnclave(config.Vault, r)
+		if err != nil {
+			Error(w, err)
+			return
+		}
+		if err = enclave.VerifyRequest(r); err != nil {
+			Error(w, err)
+			return
+		}
+
+		// The policy does not have to exist. Identities may
+		// still be assigned to a policy that has been deleted.
+		name := strings.TrimSpace(strings.TrimPrefix(r.URL.Path, APIPath))
+		if err = validateName(name); err != nil {
+			Error(w, err)
+			return
+		}
+		cw := newCompressWriter(w, r)
+		defer cw.Close()
+		w = cw
+
+		iterator, err := enclave.ListIdentities(r.Context())
+		if err != nil {
+			Error(w, err)
+			return
+		}
+		var (
+			encoder    = json.NewEncoder(w)
+			hasWritten bool
+		)
+		for iterator.Next() {
+			info, err := enclave.GetIdentity(r.Context(), iterator.Identity())
+			if err != nil {
+				if hasWritten {
+					encoder.Encode(Response{Err: err.Error()})
+				} else {
+					Error(w, err)
+				}
+				return
+			}
+			if info.IsAdmin || info.Policy != name {
+				continue
+			}
+			if !hasWritten {
+				w.Header().Set("Content-Type", ContentType)
+			}
+			err = encoder.Encode(Response{
+				Identity:  iterator.Identity(),
+				Policy:    info.Policy,
+				CreatedAt: info.CreatedAt,
+				CreatedBy: info.CreatedBy,
+			})
+			if err != nil {
+				return
+			}
+			hasWritten = true
+		}
+		if err = iterator.Close(); err != nil {
+			if hasWritten {
+				encoder.Encode(Response{Err: err.Error()})
+			} else {
+				Error(w, err)
+			}
+			return
+		}
+		if !hasWritten {
+			w.WriteHeader(http.StatusOK)
+		}
+	}
+	mux.HandleFunc(APIPath, timeout(Timeout, proxy(config.Proxy, config.Metrics.Count(config.Metrics.Latency(handler)))))
+	return API{
+		Method:  Method,
+		Path:    APIPath,
+		MaxBody: MaxBody,
+		Timeout: Timeout,
+	}
+}
+
 func deletePolicy(mux *http.ServeMux, config *ServerConfig) API {
 	const (
 		Method  = http.MethodDelete
@@ -283,7 +525,7 @@ func deletePolicy(mux *http.ServeMux, config *ServerConfig) API {
 		Timeout = 15 * time.Second
 	)
 	handler := func(w http.ResponseWriter, r *http.Request) {
-		w = audit(w, r, config.AuditLog.Log())
+		w = audit(w, r, config)
 
 		if r.Method != Method {
 			w.Header().Set("Accept", Method)
@@ -343,7 +585,7 @@ func listPolicy(mux *http.ServeMux, config *ServerConfig) API {
 		Err string `json:"error,omitempty"`
 	}
 	handler := func(w http.ResponseWriter, r *http.Request) {
-		w = audit(w, r, config.AuditLog.Log())
+		w = audit(w, r, config)
 
 		if r.Method != Method {
 			w.Header().Set("Accept", Method)
@@ -371,6 +613,10 @@ func listPolicy(mux *http.ServeMux, config *ServerConfig) API {
 			Error(w, err)
 			return
 		}
+		cw := newCompressWriter(w, r)
+		defer cw.Close()
+		w = cw
+
 		iterator, err := enclave.ListPolicies(r.Context())
 		if err != nil {
 			Error(w, err)
@@ -416,3 +662,179 @@ func listPolicy(mux *http.ServeMux, config *ServerConfig) API {
 		Timeout: Timeout,
 	}
 }
+
+func applyOps(mux *http.ServeMux, config *ServerConfig) API {
+	const (
+		Method      = http.MethodPost
+		APIPath     = "/v1/apply"
+		MaxBody     = 1 << 20
+		Timeout     = 15 * time.Second
+		ContentType = "application/json"
+		MaxOps      = 1000 // For now, we limit the number of operations applied in a single API call to 1000.
+	)
+	type Policy struct {
+		Allow       []string           `json:"allow,omitempty"`
+		Deny        []string           `json:"deny,omitempty"`
+		RateLimit   map[string]float64 `json:"rate_limit,omitempty"`
+		Extends     []string           `json:"extends,omitempty"`
+		AllowWindow *kes.TimeWindow    `json:"allow_window,omitempty"`
+	}
+	type Op struct {
+		Type     string       `json:"op"`
+		Name     string       `json:"name,omitempty"`
+		Policy   *Policy      `json:"policy,omitempty"`
+		Identity kes.Identity `json:"identity,omitempty"`
+	}
+	type Request struct {
+		Ops []Op `json:"ops"`
+	}
+	type Response struct {
+		Message string `json:"message"`
+		Index   int    `json:"index"`
+	}
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		w = audit(w, r, config)
+
+		if r.Method != Method {
+			w.Header().Set("Accept", Method)
+			Error(w, errMethodNotAllowed)
+			return
+		}
+		if err := normalizeURL(r.URL, APIPath); err != nil {
+			Error(w, err)
+			return
+		}
+		r.Body = http.MaxBytesReader(w, r.Body, MaxBody)
+
+		enclave, err := lookupEnclave(config.Vault, r)
+		if err != nil {
+			Error(w, err)
+			return
+		}
+		if err = enclave.VerifyAdmin(r); err != nil { // Only the admin can apply operations in bulk
+			Error(w, err)
+			return
+		}
+
+		var req Request
+		if err = json.NewDecoder(r.Body).Decode(&req); err != nil {
+			Error(w, err)
+			return
+		}
+		if len(req.Ops) > MaxOps {
+			Error(w, kes.NewError(http.StatusBadRequest, "too many operations"))
+			return
+		}
+
+		// writeError sends the error of the i-th operation
+		// to the client such that it can tell which operation
+		// has failed.
+		writeError := func(i int, err error) {
+			status := http.StatusInternalServerError
+			if e, ok := err.(interface{ Status() int }); ok {
+				status = e.Status()
+			}
+			w.Header().Set("Content-Type", ContentType)
+			w.Header().Set("X-Content-Type-Options", "nosniff")
+			w.WriteHeader(status)
+			json.NewEncoder(w).Encode(Response{Message: err.Error(), Index: i})
+		}
+
+		var (
+			self = auth.Identify(r)
+			now  = time.Now().UTC()
+			ops  = make([]sys.Op, 0, len(req.Ops))
+		)
+		for i, op := range req.Ops {
+			switch op.Type {
+			case sys.OpSetPolicy:
+				if err = validateName(op.Name); err != nil {
+					writeError(i, err)
+					return
+				}
+				if op.Policy == nil {
+					writeError(i, kes.NewError(http.StatusBadRequest, "invalid operation: policy is missing"))
+					return
+				}
+				err = validatePolicy(&kes.Policy{
+					RateLimit:   op.Policy.RateLimit,
+					Extends:     op.Policy.Extends,
+					AllowWindow: op.Policy.AllowWindow,
+				})
+				if err != nil {
+					writeError(i, err)
+					return
+				}
+				ops = append(ops, sys.Op{
+					Type: op.Type,
+					Name: op.Name,
+					Policy: &auth.Policy{
+						Allow:       op.Policy.Allow,
+						Deny:        op.Policy.Deny,
+						RateLimit:   op.Policy.RateLimit,
+						Extends:     op.Policy.Extends,
+						AllowWindow: op.Policy.AllowWindow,
+						CreatedAt:   now,
+						CreatedBy:   self,
+					},
+				})
+			case sys.OpDeletePolicy:
+				if err = validateName(op.Name); err != nil {
+					writeError(i, err)
+					return
+				}
+				ops = append(ops, sys.Op{Type: op.Type, Name: op.Name})
+			case sys.OpAssignPolicy, sys.OpDeleteIdentity:
+				if op.Type == sys.OpAssignPolicy {
+					if err = validateName(op.Name); err != nil {
+						writeError(i, err)
+						return
+					}
+				}
+				if op.Identity.IsUnknown() {
+					writeError(i, kes.NewError(http.StatusBadRequest, "identity is unknown"))
+					return
+				}
+				if err = validateName(op.Identity.String()); err != nil {
+					writeError(i, err)
+					return
+				}
+				ops = append(ops, sys.Op{Type: op.Type, Name: op.Name, Identity: op.Identity})
+			default:
+				writeError(i, kes.NewError(http.StatusBadRequest, "invalid operation type"))
+				return
+			}
+		}
+		if i, err := enclave.Apply(r.Context(), ops); err != nil {
+			writeError(i, err)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}
+	mux.HandleFunc(APIPath, timeout(Timeout, proxy(config.Proxy, config.Metrics.Count(config.Metrics.Latency(handler)))))
+	return API{
+		Method:  Method,
+		Path:    APIPath,
+		MaxBody: MaxBody,
+		Timeout: Timeout,
+	}
+}
+
+// validatePolicy returns an error if the policy contains
+// an invalid rate limit, parent policy name or allow window.
+func validatePolicy(policy *kes.Policy) error {
+	for _, rate := range policy.RateLimit {
+		if rate <= 0 || math.IsNaN(rate) || math.IsInf(rate, 0) {
+			return kes.NewError(http.StatusBadRequest, "invalid rate limit")
+		}
+	}
+	for _, parent := range policy.Extends {
+		if err := validateName(parent); err != nil {
+			return err
+		}
+	}
+	if err := kes.ValidatePolicy(&kes.Policy{AllowWindow: policy.AllowWindow}); err != nil {
+		return kes.NewError(http.StatusBadRequest, "invalid allow window")
+	}
+	return nil
+}
@@ -0,0 +1,135 @@
+// Copyright 2022 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package http
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/minio/kes"
+	"github.com/minio/kes/internal/sys"
+)
+
+// enclaveState describes whether an enclave is sealed
+// and the state of its key store.
+type enclaveState struct {
+	Sealed bool
+	Store  string
+	Err    error // Non-nil if the enclave state cannot be determined
+}
+
+// statusWatcher polls the state of enclaves and notifies
+// all subscribers of an enclave about its state.
+//
+// It polls an enclave once per interval as long as there
+// is at least one subscriber, independent of the number
+// of subscribers. Hence, status stream clients do not
+// increase the load on the key store.
+type statusWatcher struct {
+	vault    sys.Vault
+	interval time.Duration
+
+	lock  sync.Mutex
+	polls map[string]*statusPoll // Maps enclave names to their poll
+}
+
+type statusPoll struct {
+	subscribers map[chan enclaveState]struct{}
+	last        *enclaveState // The most recent state, if any
+	cancel      context.CancelFunc
+}
+
+// Subscribe returns a channel that receives the state of
+// the named enclave whenever the enclave has been polled.
+// A subscriber that falls behind only receives the most
+// recent state.
+//
+// The caller must call the returned function once it is
+// no longer interested in the state of the enclave.
+func (w *statusWatcher) Subscribe(name string) (<-chan enclaveState, func()) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	poll, ok := w.polls[name]
+	if !ok {
+		ctx, cancel := context.WithCancel(context.Background())
+		poll = &statusPoll{
+			subscribers: map[chan enclaveState]struct{}{},
+			cancel:      cancel,
+		}
+		if w.polls == nil {
+			w.polls = map[string]*statusPoll{}
+		}
+		w.polls[name] = poll
+		go w.poll(ctx, name, poll)
+	}
+
+	ch := make(chan enclaveState, 1)
+	if poll.last != nil {
+		ch <- *poll.last
+	}
+	poll.subscribers[ch] = struct{}{}
+
+	unsubscribe := func() {
+		w.lock.Lock()
+		defer w.lock.Unlock()
+
+		delete(poll.subscribers, ch)
+		if len(poll.subscribers) == 0 && w.polls[name] == poll {
+			poll.cancel()
+			delete(w.polls, name)
+		}
+	}
+	return ch, unsubscribe
+}
+
+// poll polls the state of the named enclave until ctx
+// is canceled and sends it to all subscribers.
+func (w *statusWatcher) poll(ctx context.Context, name string, poll *statusPoll) {
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+
+	for {
+		state := w.state(ctx, name)
+		if ctx.Err() != nil {
+			return
+		}
+
+		w.lock.Lock()
+		poll.last = &state
+		for ch := range poll.subscribers {
+			select { // Replace any state the subscriber has not received yet
+			case <-ch:
+			default:
+			}
+			ch <- state
+		}
+		w.lock.Unlock()
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// state returns the current state of the named enclave.
+func (w *statusWatcher) state(ctx context.Context, name string) enclaveState {
+	enclave, err := w.vault.GetEnclave(ctx, name)
+	if errors.Is(err, kes.ErrSealed) {
+		return enclaveState{Sealed: true}
+	}
+	if err != nil {
+		return enclaveState{Err: err}
+	}
+	status, err := enclave.Status(ctx)
+	if err != nil {
+		return enclaveState{Err: err}
+	}
+	return enclaveState{Store: status.State.String()}
+}
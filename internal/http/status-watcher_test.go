@@ -0,0 +1,56 @@
+// Copyright 2022 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package http
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/minio/kes/internal/key"
+	"github.com/minio/kes/internal/mem"
+	"github.com/minio/kes/internal/sys"
+)
+
+func TestStatusWatcher(t *testing.T) {
+	store := &statusCountingStore{Store: &mem.Store{}}
+	watcher := &statusWatcher{
+		vault:    sys.NewStatelessVault("", store, nil, nil),
+		interval: time.Hour,
+	}
+
+	first, unsubscribeFirst := watcher.Subscribe("")
+	if state := <-first; state.Err != nil || state.Sealed || state.Store != key.StoreAvailable.String() {
+		t.Fatalf("Invalid enclave state: %+v", state)
+	}
+	second, unsubscribeSecond := watcher.Subscribe("")
+	if state := <-second; state.Err != nil || state.Store != key.StoreAvailable.String() {
+		t.Fatalf("Invalid enclave state: %+v", state)
+	}
+	if n := atomic.LoadUint32(&store.calls); n != 1 {
+		t.Fatalf("Key store has been polled more than once: got '%d' - want '%d'", n, 1)
+	}
+
+	unsubscribeFirst()
+	unsubscribeSecond()
+	watcher.lock.Lock()
+	defer watcher.lock.Unlock()
+	if n := len(watcher.polls); n != 0 {
+		t.Fatalf("Enclave is still polled without subscribers: got '%d' polls - want '%d'", n, 0)
+	}
+}
+
+// statusCountingStore is a key.Store that counts
+// how often its status has been requested.
+type statusCountingStore struct {
+	*mem.Store
+	calls uint32
+}
+
+func (s *statusCountingStore) Status(ctx context.Context) (key.StoreState, error) {
+	atomic.AddUint32(&s.calls, 1)
+	return s.Store.Status(ctx)
+}
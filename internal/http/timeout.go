@@ -23,11 +23,40 @@ import (
 // Timeout will return 503 ServiceUnavailable to the
 // client.
 //
+// If the client sends a request timeout that is shorter
+// than the given time limit, the request times out after
+// the client's request timeout instead. A client has no
+// use for a response it receives after it has given up.
+//
 // timeout cancels the request context before aborting f.
 func timeout(after time.Duration, f http.HandlerFunc) http.HandlerFunc {
 	const Message = `{"message":"request timeout exceeded"}`
 	handler := http.TimeoutHandler(f, after, Message)
 	return func(w http.ResponseWriter, r *http.Request) {
+		if d, ok := requestTimeout(r); ok && d < after {
+			http.TimeoutHandler(f, d, Message).ServeHTTP(w, r)
+			return
+		}
 		handler.ServeHTTP(w, r)
 	}
 }
+
+// requestTimeoutHeader is the HTTP header containing
+// the time the client is willing to wait for a response
+// as duration string - e.g. "1.5s".
+const requestTimeoutHeader = "X-Request-Timeout"
+
+// requestTimeout returns the request timeout sent by the
+// client, if any. It ignores malformed and non-positive
+// timeouts.
+func requestTimeout(r *http.Request) (time.Duration, bool) {
+	v := r.Header.Get(requestTimeoutHeader)
+	if v == "" {
+		return 0, false
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil || d <= 0 {
+		return 0, false
+	}
+	return d, true
+}
@@ -0,0 +1,60 @@
+// Copyright 2022 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package http
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+var requestTimeoutTests = []struct {
+	Header  string
+	Timeout time.Duration
+	OK      bool
+}{
+	{Header: "", OK: false},                                      // 0
+	{Header: "2s", Timeout: 2 * time.Second, OK: true},           // 1
+	{Header: "1.5s", Timeout: 1500 * time.Millisecond, OK: true}, // 2
+	{Header: "0s", OK: false},                                    // 3
+	{Header: "-1s", OK: false},                                   // 4
+	{Header: "2", OK: false},                                     // 5
+}
+
+func TestRequestTimeout(t *testing.T) {
+	for i, test := range requestTimeoutTests {
+		req := httptest.NewRequest(http.MethodGet, "/v1/status", nil)
+		if test.Header != "" {
+			req.Header.Set(requestTimeoutHeader, test.Header)
+		}
+		timeout, ok := requestTimeout(req)
+		if ok != test.OK {
+			t.Fatalf("Test %d: got '%v' - want '%v'", i, ok, test.OK)
+		}
+		if timeout != test.Timeout {
+			t.Fatalf("Test %d: timeout mismatch: got '%v' - want '%v'", i, timeout, test.Timeout)
+		}
+	}
+}
+
+func TestTimeoutClientHint(t *testing.T) {
+	handler := timeout(10*time.Second, func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/v1/status", nil)
+	req.Header.Set(requestTimeoutHeader, "50ms")
+	resp := httptest.NewRecorder()
+
+	start := time.Now()
+	handler(resp, req)
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Fatalf("Request timeout has been ignored: request took %v", elapsed)
+	}
+	if resp.Code != http.StatusServiceUnavailable {
+		t.Fatalf("Status code mismatch: got '%d' - want '%d'", resp.Code, http.StatusServiceUnavailable)
+	}
+}
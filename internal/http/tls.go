@@ -7,10 +7,12 @@ package http
 import (
 	"bytes"
 	"context"
+	"crypto"
 	"crypto/tls"
 	"crypto/x509"
 	"encoding/pem"
 	"errors"
+	"io"
 	"os"
 	"strings"
 	"sync"
@@ -79,6 +81,32 @@ func (c *Certificate) GetCertificate(clientHello *tls.ClientHelloInfo) (*tls.Cer
 	return &c.certificate, nil
 }
 
+// Public returns the public key of the current X.509
+// TLS certificate.
+func (c *Certificate) Public() crypto.PublicKey {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+
+	if signer, ok := c.certificate.PrivateKey.(crypto.Signer); ok {
+		return signer.Public()
+	}
+	return nil
+}
+
+// Sign signs digest with the private key of the current
+// X.509 TLS certificate. Hence, a Certificate can be used
+// as crypto.Signer - e.g. to sign attestations.
+func (c *Certificate) Sign(rand io.Reader, digest []byte, opts crypto.SignerOpts) ([]byte, error) {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+
+	signer, ok := c.certificate.PrivateKey.(crypto.Signer)
+	if !ok {
+		return nil, errors.New("http: certificate private key cannot be used for signing")
+	}
+	return signer.Sign(rand, digest, opts)
+}
+
 // ReloadAfter reloads the X.509 TLS certificate from its
 // certificate resp. private key file periodically in an
 // infinite loop.
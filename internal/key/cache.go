@@ -23,6 +23,9 @@ var (
 	errGetKey    = kes.NewError(http.StatusBadGateway, "bad gateway: failed to access key")
 	errDeleteKey = kes.NewError(http.StatusBadGateway, "bad gateway: failed to delete key")
 	errListKey   = kes.NewError(http.StatusBadGateway, "bad gateway: failed to list keys")
+	errRenameKey = kes.NewError(http.StatusBadGateway, "bad gateway: failed to rename key")
+
+	errRenameNotSupported = kes.NewError(http.StatusNotImplemented, "key store does not support renaming keys")
 )
 
 // CacheConfig is a structure containing Cache
@@ -98,7 +101,10 @@ type Cache struct {
 	cancel context.CancelFunc
 }
 
-var _ Store = (*Cache)(nil) // compiler check
+var ( // compiler checks
+	_ Store   = (*Cache)(nil)
+	_ Renamer = (*Cache)(nil)
+)
 
 type cacheEntry struct {
 	Key Key
@@ -159,6 +165,31 @@ func (c *Cache) Delete(ctx context.Context, name string) error {
 	return nil
 }
 
+// Rename renames the key from to the key to if the
+// Store supports renaming keys atomically. Otherwise,
+// it returns an error with http.StatusNotImplemented.
+func (c *Cache) Rename(ctx context.Context, from, to string) error {
+	renamer, ok := c.Store.(Renamer)
+	if !ok {
+		return errRenameNotSupported
+	}
+	switch err := renamer.Rename(ctx, from, to); {
+	case err == nil:
+	case errors.Is(err, kes.ErrKeyNotFound):
+		return kes.ErrKeyNotFound
+	case errors.Is(err, kes.ErrKeyExists):
+		return kes.ErrKeyExists
+	default:
+		return errRenameKey
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	delete(c.cache, from)
+	delete(c.offlineCache, from)
+	return nil
+}
+
 // List returns a new Iterator over the Store.
 func (c *Cache) List(ctx context.Context) (Iterator, error) {
 	i, err := c.Store.List(ctx)
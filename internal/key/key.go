@@ -14,6 +14,7 @@ import (
 	"encoding/hex"
 	"encoding/json"
 	"errors"
+	"io"
 	"time"
 
 	"github.com/minio/kes"
@@ -21,6 +22,7 @@ import (
 	"github.com/minio/kes/internal/fips"
 	"golang.org/x/crypto/chacha20"
 	"golang.org/x/crypto/chacha20poly1305"
+	"golang.org/x/crypto/hkdf"
 )
 
 const (
@@ -29,6 +31,10 @@ const (
 
 	// Size is the byte size of a cryptographic key.
 	Size = 256 / 8
+
+	// MaxDeriveSize is the maximum byte size of a
+	// key derived via HKDF-SHA256.
+	MaxDeriveSize = 255 * sha256.Size
 )
 
 // ValidName returns true if and only if name is
@@ -127,6 +133,9 @@ func (k *Key) ID() string {
 	return hex.EncodeToString(h[:Size])
 }
 
+// Bytes returns a copy of the raw key material.
+func (k *Key) Bytes() []byte { return clone(k.bytes...) }
+
 // Clone returns a deep copy of the key.
 func (k *Key) Clone() Key {
 	return Key{
@@ -248,6 +257,29 @@ func (k *Key) Unwrap(ciphertext, associatedData []byte) ([]byte, error) {
 	return plaintext, nil
 }
 
+// deriveSalt is the HKDF salt used by Derive. A fixed,
+// KES-specific salt separates keys derived by KES from
+// keys derived from the same key material by any other
+// HKDF application with the same info.
+var deriveSalt = []byte("kes-key-derive")
+
+// Derive derives a new key of the given length from k
+// using HKDF-SHA256 with the given info and deriveSalt
+// as salt. The same info always yields the same derived
+// key.
+//
+// The length must be between 1 and MaxDeriveSize.
+func (k *Key) Derive(info []byte, length int) ([]byte, error) {
+	if length <= 0 || length > MaxDeriveSize {
+		return nil, errors.New("key: invalid derived key length")
+	}
+	derived := make([]byte, length)
+	if _, err := io.ReadFull(hkdf.New(sha256.New, k.bytes, deriveSalt, info), derived); err != nil {
+		return nil, err
+	}
+	return derived, nil
+}
+
 // newAEAD returns a new AEAD cipher that implements the given
 // algorithm and is initialized with the given key and iv.
 func newAEAD(algorithm Algorithm, Key, IV []byte) (cipher.AEAD, error) {
@@ -115,6 +115,46 @@ func TestKeyWrap(t *testing.T) {
 	}
 }
 
+var keyDeriveTests = []struct {
+	Key        []byte
+	Info       []byte
+	Length     int
+	Derived    []byte
+	ShouldFail bool
+}{
+	{ // 0 - RFC 5869 test case 3 with the salt "kes-key-derive"
+		Key:     mustDecodeHex("0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b"),
+		Info:    nil,
+		Length:  42,
+		Derived: mustDecodeHex("9b3897ba113eaf017703222dbc0b8241e0c7209499d6a08fb60dc96fb53826f2d8f6d62b09516d0b8ca2"),
+	},
+	{ // 1
+		Key:     make([]byte, Size),
+		Info:    []byte("my-app"),
+		Length:  32,
+		Derived: mustDecodeHex("01596e897db9daf74f968090e7b978a71b170c80374d76556bcbe6f91c86e1dc"),
+	},
+	{Key: make([]byte, Size), Length: 0, ShouldFail: true},                 // 2
+	{Key: make([]byte, Size), Length: -1, ShouldFail: true},                // 3
+	{Key: make([]byte, Size), Length: MaxDeriveSize + 1, ShouldFail: true}, // 4
+}
+
+func TestKeyDerive(t *testing.T) {
+	for i, test := range keyDeriveTests {
+		key := Key{bytes: test.Key}
+		derived, err := key.Derive(test.Info, test.Length)
+		if err == nil && test.ShouldFail {
+			t.Fatalf("Test %d: key derivation should have failed", i)
+		}
+		if err != nil && !test.ShouldFail {
+			t.Fatalf("Test %d: failed to derive key: %v", i, err)
+		}
+		if !test.ShouldFail && !bytes.Equal(derived, test.Derived) {
+			t.Fatalf("Test %d: derived key mismatch: got '%x' - want '%x'", i, derived, test.Derived)
+		}
+	}
+}
+
 var keyUnwrapTests = []struct {
 	Algorithm      Algorithm
 	Ciphertext     string
@@ -55,6 +55,19 @@ type Store interface {
 	List(context.Context) (Iterator, error)
 }
 
+// Renamer is implemented by key stores that can rename
+// keys atomically.
+type Renamer interface {
+	// Rename renames the key from to the key to in a single
+	// atomic operation. Once Rename returns, only the new
+	// name exists.
+	//
+	// If there is no entry for from, Rename returns
+	// kes.ErrKeyNotFound. If an entry for to exists,
+	// Rename returns kes.ErrKeyExists.
+	Rename(ctx context.Context, from, to string) error
+}
+
 // Iterator iterates over the names of set of cryptographic keys.
 //   for iterator.Next() {
 //       _ := iterator.Name() // Get the name of the key
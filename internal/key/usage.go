@@ -0,0 +1,115 @@
+// Copyright 2022 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package key
+
+import (
+	"sync"
+	"time"
+)
+
+// Usage describes how often a key has been used
+// for cryptographic operations.
+type Usage struct {
+	EncryptCount  uint64    // Number of encrypt operations
+	DecryptCount  uint64    // Number of decrypt operations
+	GenerateCount uint64    // Number of generate operations
+	LastUsedAt    time.Time // Point in time of the last operation
+}
+
+// UsageCounter counts the cryptographic operations
+// performed with keys. The zero value is ready to use.
+//
+// A UsageCounter keeps its counters in memory. Hence,
+// the counters are reset when the server restarts.
+type UsageCounter struct {
+	lock  sync.Mutex
+	usage map[string]*Usage
+}
+
+// AddEncrypt adds n encrypt operations to the
+// counters of the named key.
+func (c *UsageCounter) AddEncrypt(name string, n uint64) {
+	c.add(name, func(u *Usage) { u.EncryptCount += n }, n)
+}
+
+// AddDecrypt adds n decrypt operations to the
+// counters of the named key.
+func (c *UsageCounter) AddDecrypt(name string, n uint64) {
+	c.add(name, func(u *Usage) { u.DecryptCount += n }, n)
+}
+
+// AddGenerate adds n generate operations to the
+// counters of the named key.
+func (c *UsageCounter) AddGenerate(name string, n uint64) {
+	c.add(name, func(u *Usage) { u.GenerateCount += n }, n)
+}
+
+// Get returns the usage of the named key. It returns
+// a zero Usage if the key has not been used yet.
+func (c *UsageCounter) Get(name string) Usage {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if usage, ok := c.usage[name]; ok {
+		return *usage
+	}
+	return Usage{}
+}
+
+// Delete removes the counters of the named key.
+func (c *UsageCounter) Delete(name string) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	delete(c.usage, name)
+}
+
+// Rename moves the counters of the key from to
+// the key to. It replaces any counters of to.
+func (c *UsageCounter) Rename(from, to string) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if usage, ok := c.usage[from]; ok {
+		c.usage[to] = usage
+		delete(c.usage, from)
+	} else {
+		delete(c.usage, to)
+	}
+}
+
+// Copy copies the counters of the key from to the
+// key to. It replaces any counters of to.
+func (c *UsageCounter) Copy(from, to string) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if usage, ok := c.usage[from]; ok {
+		usage := *usage
+		c.usage[to] = &usage
+	} else {
+		delete(c.usage, to)
+	}
+}
+
+func (c *UsageCounter) add(name string, f func(*Usage), n uint64) {
+	if n == 0 {
+		return
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if c.usage == nil {
+		c.usage = map[string]*Usage{}
+	}
+	usage, ok := c.usage[name]
+	if !ok {
+		usage = new(Usage)
+		c.usage[name] = usage
+	}
+	f(usage)
+	usage.LastUsedAt = time.Now().UTC()
+}
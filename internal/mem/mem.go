@@ -20,7 +20,10 @@ type Store struct {
 	store map[string]key.Key
 }
 
-var _ key.Store = (*Store)(nil)
+var ( // compiler checks
+	_ key.Store   = (*Store)(nil)
+	_ key.Renamer = (*Store)(nil)
+)
 
 // Status returns the state of the in-memory key store which is
 // always healthy.
@@ -57,6 +60,25 @@ func (s *Store) Delete(_ context.Context, name string) error {
 	return nil
 }
 
+// Rename renames the key from to the key to. It returns
+// kes.ErrKeyNotFound if no entry for from exists and
+// kes.ErrKeyExists if an entry for to exists.
+func (s *Store) Rename(_ context.Context, from, to string) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	k, ok := s.store[from]
+	if !ok {
+		return kes.ErrKeyNotFound
+	}
+	if _, ok = s.store[to]; ok {
+		return kes.ErrKeyExists
+	}
+	s.store[to] = k
+	delete(s.store, from)
+	return nil
+}
+
 // Get returns the key associated with the given name. If no
 // entry for this name exists it returns kes.ErrKeyNotFound.
 func (s *Store) Get(_ context.Context, name string) (key.Key, error) {
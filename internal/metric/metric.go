@@ -6,6 +6,7 @@ package metric
 
 import (
 	"io"
+	"net"
 	"net/http"
 	"runtime"
 	"strconv"
@@ -44,6 +45,12 @@ func New() *Metrics {
 			Name:      "request_active",
 			Help:      "Number of active requests that are not finished, yet.",
 		}),
+		connections: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: "kes",
+			Subsystem: "http",
+			Name:      "connections",
+			Help:      "Number of open client connections.",
+		}),
 		requestLatency: prometheus.NewHistogram(prometheus.HistogramOpts{
 			Namespace: "kes",
 			Subsystem: "http",
@@ -64,6 +71,18 @@ func New() *Metrics {
 			Name:      "audit_events",
 			Help:      "Number of audit log events written to the audit log targets.",
 		}),
+		auditLogDelivered: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: "kes",
+			Subsystem: "log",
+			Name:      "audit_events_delivered",
+			Help:      "Number of audit log events received by all audit log targets.",
+		}),
+		auditLogDropped: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: "kes",
+			Subsystem: "log",
+			Name:      "audit_events_dropped",
+			Help:      "Number of audit log events that at least one audit log target failed to receive.",
+		}),
 
 		startTime: time.Now(),
 		upTimeInSeconds: prometheus.NewGauge(prometheus.GaugeOpts{
@@ -116,9 +135,12 @@ func New() *Metrics {
 	metrics.registry.MustRegister(metrics.requestErrored)
 	metrics.registry.MustRegister(metrics.requestFailed)
 	metrics.registry.MustRegister(metrics.requestActive)
+	metrics.registry.MustRegister(metrics.connections)
 	metrics.registry.MustRegister(metrics.requestLatency)
 	metrics.registry.MustRegister(metrics.errorLogEvents)
 	metrics.registry.MustRegister(metrics.auditLogEvents)
+	metrics.registry.MustRegister(metrics.auditLogDelivered)
+	metrics.registry.MustRegister(metrics.auditLogDropped)
 	metrics.registry.MustRegister(metrics.upTimeInSeconds)
 	metrics.registry.MustRegister(metrics.numCPUs)
 	metrics.registry.MustRegister(metrics.numUsableCPUs)
@@ -140,9 +162,12 @@ type Metrics struct {
 	requestErrored   prometheus.Counter
 	requestActive    prometheus.Gauge
 	requestLatency   prometheus.Histogram
+	connections      prometheus.Gauge
 
-	errorLogEvents prometheus.Counter
-	auditLogEvents prometheus.Counter
+	errorLogEvents    prometheus.Counter
+	auditLogEvents    prometheus.Counter
+	auditLogDelivered prometheus.Counter
+	auditLogDropped   prometheus.Counter
 
 	startTime       time.Time // Used to compute the up time as upTime = now - startTime
 	upTimeInSeconds prometheus.Gauge
@@ -206,6 +231,17 @@ func (m *Metrics) Count(h http.HandlerFunc) http.HandlerFunc {
 	}
 }
 
+// ConnState tracks the number of open client connections.
+// It should be used as http.Server.ConnState hook.
+func (m *Metrics) ConnState(_ net.Conn, state http.ConnState) {
+	switch state {
+	case http.StateNew:
+		m.connections.Inc()
+	case http.StateHijacked, http.StateClosed:
+		m.connections.Dec()
+	}
+}
+
 // Latency returns a HandlerFunc that wraps h and measures the
 // internal request-response latency.
 //
@@ -243,6 +279,20 @@ func (m *Metrics) AuditEventCounter() io.Writer {
 	return eventCounter{metric: m.auditLogEvents}
 }
 
+// CountAuditEvent increments the delivered audit event
+// counter if err is nil. Otherwise, it increments the
+// dropped audit event counter.
+//
+// The err should be the error returned when writing an
+// audit event to the audit log targets.
+func (m *Metrics) CountAuditEvent(err error) {
+	if err != nil {
+		m.auditLogDropped.Inc()
+	} else {
+		m.auditLogDelivered.Inc()
+	}
+}
+
 type eventCounter struct {
 	metric prometheus.Counter
 }
@@ -0,0 +1,155 @@
+// Copyright 2022 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package sys
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/minio/kes"
+	"github.com/minio/kes/internal/auth"
+)
+
+// Operations that can be applied as part of a batch.
+const (
+	OpSetPolicy      = "policy/write"    // Create or overwrite a policy
+	OpDeletePolicy   = "policy/delete"   // Delete a policy
+	OpAssignPolicy   = "policy/assign"   // Assign a policy to an identity
+	OpDeleteIdentity = "identity/delete" // Delete an identity
+)
+
+// An Op is a policy or identity operation that can be
+// applied as part of a batch. See Enclave.Apply.
+type Op struct {
+	Type     string       // The operation type, e.g. OpSetPolicy
+	Name     string       // The policy name
+	Policy   *auth.Policy // The policy. Only used by OpSetPolicy
+	Identity kes.Identity // The identity. Only used by OpAssignPolicy and OpDeleteIdentity
+}
+
+// Apply applies the given operations in order. Either all
+// operations succeed or none does. If an operation fails,
+// Apply reverts all operations applied before and returns
+// the index of the failed operation and its error.
+//
+// Policy and identity sets do not support transactions.
+// Hence, Apply reverts operations by restoring the policies
+// and identities they have modified. A restored identity
+// keeps its policy, expiry and alias but gets a new creation
+// timestamp. Concurrent batches are applied one after
+// another. However, other requests may observe the changes
+// of a batch before it completes.
+//
+// If reverting the applied operations fails, Apply returns
+// an error that contains the error of the failed operation
+// and the revert error.
+func (e *Enclave) Apply(ctx context.Context, ops []Op) (int, error) {
+	e.applyLock.Lock()
+	defer e.applyLock.Unlock()
+	defer e.parents.Invalidate()
+
+	undo := make([]func(context.Context) error, 0, len(ops))
+	for i, op := range ops {
+		revert, err := e.apply(ctx, op)
+		if err != nil {
+			if rErr := revertAll(undo); rErr != nil {
+				return i, kes.NewError(http.StatusInternalServerError, fmt.Sprintf("%v: failed to revert applied operations: %v", err, rErr))
+			}
+			return i, err
+		}
+		undo = append(undo, revert)
+	}
+	return -1, nil
+}
+
+// revertTimeout is the max. duration reverting a batch may take.
+const revertTimeout = 15 * time.Second
+
+// revertAll calls the given undo functions in reverse order.
+// It tries to revert all operations, even if one fails, and
+// returns the first error encountered, if any.
+//
+// The request context may be canceled already, e.g. when the
+// request timed out. Hence, revertAll uses its own context such
+// that the operations get reverted anyway.
+func revertAll(undo []func(context.Context) error) error {
+	ctx, cancel := context.WithTimeout(context.Background(), revertTimeout)
+	defer cancel()
+
+	var err error
+	for i := len(undo) - 1; i >= 0; i-- {
+		if rErr := undo[i](ctx); rErr != nil && err == nil {
+			err = rErr
+		}
+	}
+	return err
+}
+
+// apply applies the given operation and returns a function
+// that reverts it.
+func (e *Enclave) apply(ctx context.Context, op Op) (func(context.Context) error, error) {
+	switch op.Type {
+	case OpSetPolicy, OpDeletePolicy:
+		revert, err := e.snapshotPolicy(ctx, op.Name)
+		if err != nil {
+			return nil, err
+		}
+		if op.Type == OpDeletePolicy {
+			return revert, e.deletePolicy(ctx, op.Name)
+		}
+		return revert, e.setPolicy(ctx, op.Name, op.Policy)
+	case OpAssignPolicy, OpDeleteIdentity:
+		revert, err := e.snapshotIdentity(ctx, op.Identity)
+		if err != nil {
+			return nil, err
+		}
+		if op.Type == OpDeleteIdentity {
+			return revert, e.identities.Delete(ctx, op.Identity)
+		}
+		return revert, e.identities.Assign(ctx, op.Name, op.Identity, time.Time{})
+	default:
+		return nil, kes.NewError(http.StatusBadRequest, "invalid operation type")
+	}
+}
+
+// snapshotPolicy returns a function that restores the
+// current state of the policy with the given name.
+func (e *Enclave) snapshotPolicy(ctx context.Context, name string) (func(context.Context) error, error) {
+	policy, err := e.policies.Get(ctx, name)
+	if errors.Is(err, kes.ErrPolicyNotFound) {
+		return func(ctx context.Context) error { return e.policies.Delete(ctx, name) }, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return func(ctx context.Context) error { return e.policies.Set(ctx, name, policy) }, nil
+}
+
+// snapshotIdentity returns a function that restores the
+// current state of the given identity.
+func (e *Enclave) snapshotIdentity(ctx context.Context, identity kes.Identity) (func(context.Context) error, error) {
+	info, err := e.identities.Get(ctx, identity)
+	if errors.Is(err, auth.ErrIdentityNotFound) {
+		return func(ctx context.Context) error { return e.identities.Delete(ctx, identity) }, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if info.IsAdmin {
+		return nil, kes.NewError(http.StatusBadRequest, "identity is admin")
+	}
+	return func(ctx context.Context) error {
+		if err := e.identities.Assign(ctx, info.Policy, identity, info.ExpiresAt); err != nil {
+			return err
+		}
+		if info.Alias != "" {
+			return e.identities.SetAlias(ctx, identity, info.Alias)
+		}
+		return nil
+	}, nil
+}
@@ -11,6 +11,8 @@ import (
 	"encoding/hex"
 	"errors"
 	"net/http"
+	"sync"
+	"time"
 
 	"github.com/minio/kes"
 	"github.com/minio/kes/internal/auth"
@@ -35,8 +37,26 @@ type Enclave struct {
 	policies auth.PolicySet
 
 	identities auth.IdentitySet
+
+	limiter auth.RateLimiter
+	usage   key.UsageCounter
+	tokens  idempotencyTokens
+	nonces  nonceDEKs
+
+	applyLock sync.Mutex // Serializes batches and policy changes. See Apply.
+
+	parents policyCache // Caches parent policies. See verifyRequest.
+
+	countLock  sync.Mutex // Protects keyCount and keyCountAt
+	keyCount   uint64     // Number of keys when they were counted the last time
+	keyCountAt time.Time  // Point in time when the keys were counted the last time
 }
 
+// keyCountTTL is the duration for which the number of keys
+// reported by enclaveStatus is cached. Counting the keys
+// requires iterating over the entire key store.
+const keyCountTTL = 1 * time.Minute
+
 // Status returns the current state of the key store.
 //
 // If Status fails to reach the Store - e.g.
@@ -45,6 +65,54 @@ type Enclave struct {
 // error.
 func (e *Enclave) Status(ctx context.Context) (key.StoreState, error) { return e.keys.Status(ctx) }
 
+// enclaveStatus returns the EnclaveStatus of e. It only
+// counts the keys if the key store is available.
+//
+// If it fails to determine the state of the key store or
+// to count the keys, it returns an EnclaveStatus with the
+// error instead of failing. The key count may be up to
+// keyCountTTL old.
+func (e *Enclave) enclaveStatus(ctx context.Context) EnclaveStatus {
+	state, err := e.keys.Status(ctx)
+	if err != nil {
+		return EnclaveStatus{
+			Store: key.StoreState{State: key.StoreUnreachable},
+			Err:   err,
+		}
+	}
+	status := EnclaveStatus{Store: state}
+	if state.State != key.StoreAvailable {
+		return status
+	}
+	status.KeyCount, status.Err = e.countKeys(ctx)
+	return status
+}
+
+// countKeys returns the number of keys within the key store.
+// It only iterates over the key store if the keys have not
+// been counted within the last keyCountTTL.
+func (e *Enclave) countKeys(ctx context.Context) (uint64, error) {
+	e.countLock.Lock()
+	defer e.countLock.Unlock()
+
+	if !e.keyCountAt.IsZero() && time.Since(e.keyCountAt) < keyCountTTL {
+		return e.keyCount, nil
+	}
+	iterator, err := e.keys.List(ctx)
+	if err != nil {
+		return 0, err
+	}
+	var n uint64
+	for iterator.Next() {
+		n++
+	}
+	if err = iterator.Err(); err != nil {
+		return 0, err
+	}
+	e.keyCount, e.keyCountAt = n, time.Now()
+	return n, nil
+}
+
 // CreateKey stores the given key if and only if no entry with
 // the given name exists.
 //
@@ -53,11 +121,81 @@ func (e *Enclave) CreateKey(ctx context.Context, name string, key key.Key) error
 	return e.keys.Create(ctx, name, key)
 }
 
+// CreateKeyIdempotent stores the given key if and only if no
+// entry with the given name exists - like CreateKey. However,
+// if a key creation request with the same name and idempotency
+// token has been seen recently from the same identity, it does not
+// return kes.ErrKeyExists. Hence, a client can retry a key creation
+// safely.
+//
+// If token is empty, CreateKeyIdempotent behaves like CreateKey.
+func (e *Enclave) CreateKeyIdempotent(ctx context.Context, name string, key key.Key, identity kes.Identity, token string) error {
+	if token == "" {
+		return e.CreateKey(ctx, name, key)
+	}
+	if !e.tokens.Add(identity, name, token) {
+		// A previous request with the same token has
+		// already created the key.
+		err := e.keys.Create(ctx, name, key)
+		if errors.Is(err, kes.ErrKeyExists) {
+			return nil
+		}
+		return err
+	}
+	if err := e.keys.Create(ctx, name, key); err != nil {
+		e.tokens.Remove(identity, name, token)
+		return err
+	}
+	return nil
+}
+
+// DeduplicateDEK returns the DEK generated recently for the
+// identity with the named key for the same nonce and context,
+// if any, and true. Otherwise, it remembers the given DEK such
+// that a retried request of the identity with the same nonce
+// gets the same DEK, and returns it and false.
+func (e *Enclave) DeduplicateDEK(identity kes.Identity, name string, nonce, context []byte, dek kes.DEK) (kes.DEK, bool) {
+	return e.nonces.LoadOrStore(identity, name, nonce, context, dek)
+}
+
 // DeleteKey deletes the key associated with the given name.
 func (e *Enclave) DeleteKey(ctx context.Context, name string) error {
-	return e.keys.Delete(ctx, name)
+	if err := e.keys.Delete(ctx, name); err != nil {
+		return err
+	}
+	e.usage.Delete(name)
+	e.tokens.RemoveAll(name)
+	e.nonces.RemoveAll(name)
+	return nil
 }
 
+// RenameKey renames the key from to the key to. The key
+// keeps its key material, creation time and usage counters.
+//
+// The key store must be able to rename keys atomically.
+// Otherwise, RenameKey returns an error with status code
+// http.StatusNotImplemented.
+//
+// It returns kes.ErrKeyNotFound if from does not exist
+// and kes.ErrKeyExists if to already exists.
+func (e *Enclave) RenameKey(ctx context.Context, from, to string) error {
+	renamer, ok := e.keys.(key.Renamer)
+	if !ok {
+		return errRenameNotSupported
+	}
+	if err := renamer.Rename(ctx, from, to); err != nil {
+		return err
+	}
+	e.usage.Rename(from, to)
+	e.tokens.RemoveAll(from)
+	e.nonces.RemoveAll(from)
+	return nil
+}
+
+// errRenameNotSupported is returned by RenameKey if the
+// key store cannot rename keys atomically.
+var errRenameNotSupported = kes.NewError(http.StatusNotImplemented, "key store does not support renaming keys")
+
 // GetKey returns the key associated with the given name.
 //
 // It returns kes.ErrKeyNotFound if no such entry exists.
@@ -65,6 +203,28 @@ func (e *Enclave) GetKey(ctx context.Context, name string) (key.Key, error) {
 	return e.keys.Get(ctx, name)
 }
 
+// CopyKeyUsage copies the usage counters of the key from
+// to the key to such that a copy of a key keeps the usage
+// history of its key material.
+func (e *Enclave) CopyKeyUsage(from, to string) { e.usage.Copy(from, to) }
+
+// CountEncrypt adds n encrypt operations to the usage
+// counters of the key with the given name.
+func (e *Enclave) CountEncrypt(name string, n uint64) { e.usage.AddEncrypt(name, n) }
+
+// CountDecrypt adds n decrypt operations to the usage
+// counters of the key with the given name.
+func (e *Enclave) CountDecrypt(name string, n uint64) { e.usage.AddDecrypt(name, n) }
+
+// CountGenerate adds n generate operations to the usage
+// counters of the key with the given name.
+func (e *Enclave) CountGenerate(name string, n uint64) { e.usage.AddGenerate(name, n) }
+
+// KeyUsage returns the usage of the key with the given
+// name. The usage counters are kept in memory and get
+// reset when the server restarts.
+func (e *Enclave) KeyUsage(name string) key.Usage { return e.usage.Get(name) }
+
 // ListKeys returns a new iterator over all keys within the
 // Enclave.
 //
@@ -75,13 +235,82 @@ func (e *Enclave) ListKeys(ctx context.Context) (key.Iterator, error) {
 	return e.keys.List(ctx)
 }
 
+// ErrPolicyExtended is returned by DeletePolicy when another
+// policy still extends the policy.
+var ErrPolicyExtended = kes.NewError(http.StatusConflict, "policy is extended by another policy")
+
 // SetPolicy creates or overwrites the policy with the given name.
+//
+// It returns an error if the policy extends a policy that
+// does not exist or if the policy inheritance is invalid.
 func (e *Enclave) SetPolicy(ctx context.Context, name string, policy *auth.Policy) error {
+	e.applyLock.Lock()
+	defer e.applyLock.Unlock()
+	defer e.parents.Invalidate()
+
+	return e.setPolicy(ctx, name, policy)
+}
+
+// setPolicy validates the policy inheritance of the given
+// policy and stores it. The caller must hold the applyLock.
+func (e *Enclave) setPolicy(ctx context.Context, name string, policy *auth.Policy) error {
+	if _, err := e.ResolvePolicy(ctx, name, policy); err != nil {
+		if errors.Is(err, kes.ErrPolicyNotFound) {
+			err = kes.NewError(http.StatusBadRequest, "invalid policy: parent policy does not exist")
+		}
+		return err
+	}
 	return e.policies.Set(ctx, name, policy)
 }
 
+// ResolvePolicy returns the effective policy of the given
+// policy with the given name. It includes the rules of all
+// policies the policy extends. See auth.ResolvePolicy.
+func (e *Enclave) ResolvePolicy(ctx context.Context, name string, policy *auth.Policy) (*auth.Policy, error) {
+	return auth.ResolvePolicy(ctx, e.policies, name, policy)
+}
+
 // DeletePolicy deletes the policy associated with the given name.
+//
+// It returns ErrPolicyExtended if another policy extends the
+// policy.
 func (e *Enclave) DeletePolicy(ctx context.Context, name string) error {
+	e.applyLock.Lock()
+	defer e.applyLock.Unlock()
+	defer e.parents.Invalidate()
+
+	return e.deletePolicy(ctx, name)
+}
+
+// deletePolicy deletes the policy with the given name unless
+// another policy extends it. The caller must hold the applyLock.
+func (e *Enclave) deletePolicy(ctx context.Context, name string) error {
+	iterator, err := e.policies.List(ctx)
+	if err != nil {
+		return err
+	}
+	defer iterator.Close()
+
+	for iterator.Next() {
+		if iterator.Name() == name {
+			continue
+		}
+		policy, err := e.policies.Get(ctx, iterator.Name())
+		if errors.Is(err, kes.ErrPolicyNotFound) {
+			continue // Deleted concurrently
+		}
+		if err != nil {
+			return err
+		}
+		for _, parent := range policy.Extends {
+			if parent == name {
+				return ErrPolicyExtended
+			}
+		}
+	}
+	if err = iterator.Close(); err != nil {
+		return err
+	}
 	return e.policies.Delete(ctx, name)
 }
 
@@ -102,9 +331,16 @@ func (e *Enclave) ListPolicies(ctx context.Context) (auth.PolicyIterator, error)
 	return e.policies.List(ctx)
 }
 
-// AssignPolicy assigns the policy to the identity.
-func (e *Enclave) AssignPolicy(ctx context.Context, policy string, identity kes.Identity) error {
-	return e.identities.Assign(ctx, policy, identity)
+// AssignPolicy assigns the policy to the identity. If expiresAt
+// is not zero, the assignment expires at the given point in time.
+func (e *Enclave) AssignPolicy(ctx context.Context, policy string, identity kes.Identity, expiresAt time.Time) error {
+	return e.identities.Assign(ctx, policy, identity, expiresAt)
+}
+
+// SetIdentityAlias sets the alias of the given identity.
+// An empty alias removes the identity's current alias.
+func (e *Enclave) SetIdentityAlias(ctx context.Context, identity kes.Identity, alias string) error {
+	return e.identities.SetAlias(ctx, identity, alias)
 }
 
 // DeleteIdentity deletes the given identity.
@@ -113,7 +349,25 @@ func (e *Enclave) DeleteIdentity(ctx context.Context, identities kes.Identity) e
 }
 
 // GetIdentity returns metadata about the given identity.
+//
+// It returns auth.ErrIdentityNotFound if the identity's
+// policy assignment has expired.
 func (e *Enclave) GetIdentity(ctx context.Context, identity kes.Identity) (auth.IdentityInfo, error) {
+	info, err := e.identities.Get(ctx, identity)
+	if err != nil {
+		return auth.IdentityInfo{}, err
+	}
+	if info.IsExpired() {
+		return auth.IdentityInfo{}, auth.ErrIdentityNotFound
+	}
+	return info, nil
+}
+
+// GetIdentityAssignment returns metadata about the given
+// identity, like GetIdentity. However, it also returns the
+// metadata of an identity whose policy assignment has
+// expired.
+func (e *Enclave) GetIdentityAssignment(ctx context.Context, identity kes.Identity) (auth.IdentityInfo, error) {
 	return e.identities.Get(ctx, identity)
 }
 
@@ -127,9 +381,41 @@ func (e *Enclave) ListIdentities(ctx context.Context) (auth.IdentityIterator, er
 	return e.identities.List(ctx)
 }
 
+// VerifyAdmin verifies that the given request has been
+// sent by the admin of the Enclave. Otherwise, it returns
+// kes.ErrNotAllowed.
+func (e *Enclave) VerifyAdmin(r *http.Request) error {
+	if r.TLS == nil {
+		return kes.NewError(http.StatusBadRequest, "insecure connection: TLS required")
+	}
+	admin, err := e.identities.Admin(r.Context())
+	if err != nil {
+		return err
+	}
+	if identity := auth.Identify(r); identity.IsUnknown() || identity != admin {
+		return kes.ErrNotAllowed
+	}
+	return nil
+}
+
 // VerifyRequest verifies the given request is allowed
 // based on the policies and identities within the Enclave.
 func (e *Enclave) VerifyRequest(r *http.Request) error {
+	return e.verifyRequest(r, (*auth.Policy).Verify)
+}
+
+// VerifyExport verifies that the given request is allowed
+// to export key material. Apart from the admin, only
+// identities whose policy allows the request explicitly,
+// as defined by auth.Policy.VerifyExplicit, are allowed to
+// export keys.
+func (e *Enclave) VerifyExport(r *http.Request) error {
+	return e.verifyRequest(r, (*auth.Policy).VerifyExplicit)
+}
+
+// verifyRequest verifies the given request using the
+// policy of the request identity and the verify function.
+func (e *Enclave) verifyRequest(r *http.Request, verify func(*auth.Policy, *http.Request) error) error {
 	if r.TLS == nil {
 		return kes.NewError(http.StatusBadRequest, "insecure connection: TLS required")
 	}
@@ -176,5 +462,17 @@ func (e *Enclave) VerifyRequest(r *http.Request) error {
 	if err != nil {
 		return err
 	}
-	return policy.Verify(r)
+	// The parent policies are cached such that a request does
+	// not cause up to MaxPolicyDepth additional lookups.
+	parents := cachedPolicySet{PolicySet: e.policies, cache: &e.parents}
+	if policy, err = auth.ResolvePolicy(r.Context(), parents, info.Policy, policy); err != nil {
+		return err
+	}
+	if err = verify(policy, r); err != nil {
+		return err
+	}
+	if policy.AllowWindow != nil && !policy.AllowWindow.Contains(time.Now()) {
+		return kes.ErrNotAllowed
+	}
+	return e.limiter.Verify(identity, policy, r)
 }
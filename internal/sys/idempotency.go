@@ -0,0 +1,205 @@
+// Copyright 2022 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package sys
+
+import (
+	"crypto/sha256"
+	"sync"
+	"time"
+
+	"github.com/minio/kes"
+)
+
+// idempotencyTTL is the duration an idempotency
+// token is remembered.
+const idempotencyTTL = 5 * time.Minute
+
+// idempotencyTokens remembers recently used idempotency
+// tokens per identity and key name. The zero value is
+// ready to use.
+type idempotencyTokens struct {
+	lock      sync.Mutex
+	tokens    map[idempotencyKey]time.Time // Maps to the point in time when the token expires
+	lastSweep time.Time
+}
+
+type idempotencyKey struct {
+	identity kes.Identity
+	name     string
+	token    string
+}
+
+// Add remembers the token of the identity for the given
+// key name. It returns false if the identity has already
+// added the token and the token has not expired yet.
+//
+// Tokens are scoped to the identity. Hence, two clients
+// that pick the same token do not affect each other.
+func (t *idempotencyTokens) Add(identity kes.Identity, name, token string) bool {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	now := time.Now()
+	if t.tokens == nil {
+		t.tokens = map[idempotencyKey]time.Time{}
+	}
+	if now.Sub(t.lastSweep) >= idempotencyTTL {
+		t.sweep(now)
+	}
+
+	key := idempotencyKey{identity: identity, name: name, token: token}
+	if expiry, ok := t.tokens[key]; ok && !now.After(expiry) {
+		return false
+	}
+	t.tokens[key] = now.Add(idempotencyTTL)
+	return true
+}
+
+// sweep removes all expired tokens. Expired tokens are
+// ignored by Add. Hence, tokens are only removed once
+// per idempotencyTTL instead of on every Add.
+func (t *idempotencyTokens) sweep(now time.Time) {
+	for k, expiry := range t.tokens {
+		if now.After(expiry) {
+			delete(t.tokens, k)
+		}
+	}
+	t.lastSweep = now
+}
+
+// Remove forgets the token of the identity for the
+// given key name.
+func (t *idempotencyTokens) Remove(identity kes.Identity, name, token string) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	delete(t.tokens, idempotencyKey{identity: identity, name: name, token: token})
+}
+
+// RemoveAll forgets all tokens for the given key name.
+func (t *idempotencyTokens) RemoveAll(name string) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	for k := range t.tokens {
+		if k.name == name {
+			delete(t.tokens, k)
+		}
+	}
+}
+
+// nonceTTL is the duration a DEK generated for a
+// request nonce is remembered. It is shorter than
+// idempotencyTTL since the DEK plaintext is kept
+// in memory.
+const nonceTTL = 1 * time.Minute
+
+// maxNonceDEKs is the max. number of DEKs remembered
+// at the same time. It limits the memory used by the
+// nonce cache regardless of how many requests with a
+// nonce clients send.
+const maxNonceDEKs = 10000
+
+// nonceDEKs remembers recently generated DEKs per identity,
+// key name, request nonce, context and DEK length. The zero
+// value is ready to use.
+type nonceDEKs struct {
+	lock      sync.Mutex
+	deks      map[nonceKey]nonceDEK
+	lastSweep time.Time
+}
+
+type nonceKey struct {
+	identity kes.Identity
+	name     string
+	nonce    [sha256.Size]byte
+	context  [sha256.Size]byte
+	size     int // Length of the DEK plaintext in bytes
+}
+
+type nonceDEK struct {
+	dek    kes.DEK
+	expiry time.Time
+}
+
+// LoadOrStore returns the DEK the identity has generated
+// recently with the given key name, nonce and context, if
+// any, and true. Otherwise, it remembers and returns the
+// given DEK and false.
+//
+// A remembered DEK is only returned if its plaintext has
+// the same length as the given DEK. Hence, a request for a
+// 128 bit DEK never gets a 256 bit DEK, and vice versa, even
+// if both requests use the same nonce.
+//
+// DEKs are scoped to the identity. Hence, another identity
+// cannot obtain a DEK by sending the same nonce. If the
+// cache is full, LoadOrStore does not remember the DEK.
+func (d *nonceDEKs) LoadOrStore(identity kes.Identity, name string, nonce, context []byte, dek kes.DEK) (kes.DEK, bool) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	now := time.Now()
+	if d.deks == nil {
+		d.deks = map[nonceKey]nonceDEK{}
+	}
+	if len(d.deks) >= maxNonceDEKs || now.Sub(d.lastSweep) >= nonceTTL {
+		d.sweep(now)
+	}
+
+	key := nonceKey{
+		identity: identity,
+		name:     name,
+		nonce:    sha256.Sum256(nonce),
+		context:  sha256.Sum256(context),
+		size:     len(dek.Plaintext),
+	}
+	if v, ok := d.deks[key]; ok {
+		if !now.After(v.expiry) {
+			return cloneDEK(v.dek), true
+		}
+		kes.Wipe(v.dek.Plaintext)
+		delete(d.deks, key)
+	}
+	if len(d.deks) < maxNonceDEKs {
+		// We keep our own copy of the DEK such that we
+		// can zero its plaintext once it expires without
+		// affecting the caller.
+		d.deks[key] = nonceDEK{dek: cloneDEK(dek), expiry: now.Add(nonceTTL)}
+	}
+	return dek, false
+}
+
+// sweep removes all expired DEKs and zeros their plaintext.
+func (d *nonceDEKs) sweep(now time.Time) {
+	for k, v := range d.deks {
+		if now.After(v.expiry) {
+			kes.Wipe(v.dek.Plaintext)
+			delete(d.deks, k)
+		}
+	}
+	d.lastSweep = now
+}
+
+// RemoveAll forgets all DEKs for the given key name.
+func (d *nonceDEKs) RemoveAll(name string) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	for k, v := range d.deks {
+		if k.name == name {
+			kes.Wipe(v.dek.Plaintext)
+			delete(d.deks, k)
+		}
+	}
+}
+
+// cloneDEK returns a deep copy of the DEK.
+func cloneDEK(dek kes.DEK) kes.DEK {
+	return kes.DEK{
+		Plaintext:  append([]byte(nil), dek.Plaintext...),
+		Ciphertext: append([]byte(nil), dek.Ciphertext...),
+	}
+}
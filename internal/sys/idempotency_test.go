@@ -0,0 +1,54 @@
+// Copyright 2022 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package sys
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/minio/kes"
+)
+
+func TestNonceDEKsLength(t *testing.T) {
+	var (
+		deks     nonceDEKs
+		identity = kes.Identity("ca3de8e6e3a2a8a2d0f4ad4e2e8b3e3e")
+		nonce    = []byte("my-nonce")
+	)
+	dek256 := kes.DEK{Plaintext: bytes.Repeat([]byte{1}, 32), Ciphertext: []byte("256")}
+	if _, ok := deks.LoadOrStore(identity, "my-key", nonce, nil, dek256); ok {
+		t.Fatal("Empty cache returned a DEK")
+	}
+	if dek, ok := deks.LoadOrStore(identity, "my-key", nonce, nil, dek256); !ok || !bytes.Equal(dek.Plaintext, dek256.Plaintext) {
+		t.Fatal("Cache did not return the DEK for the same nonce")
+	}
+
+	dek128 := kes.DEK{Plaintext: bytes.Repeat([]byte{2}, 16), Ciphertext: []byte("128")}
+	dek, ok := deks.LoadOrStore(identity, "my-key", nonce, nil, dek128)
+	if ok || len(dek.Plaintext) != 16 {
+		t.Fatalf("Cache returned a %d bit DEK for a 128 bit request with the same nonce", 8*len(dek.Plaintext))
+	}
+}
+
+func TestIdempotencyTokensExpiry(t *testing.T) {
+	var (
+		tokens   idempotencyTokens
+		identity = kes.Identity("ca3de8e6e3a2a8a2d0f4ad4e2e8b3e3e")
+	)
+	if !tokens.Add(identity, "my-key", "my-token") {
+		t.Fatal("Failed to add token")
+	}
+	if tokens.Add(identity, "my-key", "my-token") {
+		t.Fatal("Added the same token twice")
+	}
+
+	// An expired token that has not been swept yet
+	// must not prevent adding the token again.
+	tokens.tokens[idempotencyKey{identity: identity, name: "my-key", token: "my-token"}] = time.Now().Add(-time.Second)
+	if !tokens.Add(identity, "my-key", "my-token") {
+		t.Fatal("Failed to add expired token")
+	}
+}
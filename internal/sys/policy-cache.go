@@ -0,0 +1,90 @@
+// Copyright 2022 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package sys
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/minio/kes/internal/auth"
+)
+
+// policyCacheTTL is the duration a policy is cached. Policies
+// changed by the Enclave are removed from the cache right away.
+const policyCacheTTL = 10 * time.Second
+
+// policyCache caches policies such that resolving a policy
+// does not fetch all of its parents from the policy set on
+// every request. The zero value is ready to use.
+type policyCache struct {
+	lock     sync.Mutex
+	policies map[string]cachedPolicy
+	gen      uint64 // Incremented by Invalidate
+}
+
+type cachedPolicy struct {
+	policy    *auth.Policy
+	expiresAt time.Time
+}
+
+// Get returns the policy with the given name from the cache,
+// if present and not expired. Otherwise, it fetches the policy
+// from the given policy set and adds it to the cache.
+func (c *policyCache) Get(ctx context.Context, policies auth.PolicySet, name string) (*auth.Policy, error) {
+	now := time.Now()
+
+	c.lock.Lock()
+	entry, ok := c.policies[name]
+	gen := c.gen
+	c.lock.Unlock()
+	if ok && now.Before(entry.expiresAt) {
+		return entry.policy, nil
+	}
+
+	policy, err := policies.Get(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if gen != c.gen { // The policies have changed while fetching the policy
+		return policy, nil
+	}
+	for n, entry := range c.policies {
+		if now.After(entry.expiresAt) {
+			delete(c.policies, n)
+		}
+	}
+	if c.policies == nil {
+		c.policies = map[string]cachedPolicy{}
+	}
+	c.policies[name] = cachedPolicy{
+		policy:    policy,
+		expiresAt: now.Add(policyCacheTTL),
+	}
+	return policy, nil
+}
+
+// Invalidate removes all policies from the cache.
+func (c *policyCache) Invalidate() {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.policies = nil
+	c.gen++
+}
+
+// cachedPolicySet is a PolicySet that fetches policies
+// from the cache first.
+type cachedPolicySet struct {
+	auth.PolicySet
+	cache *policyCache
+}
+
+func (s cachedPolicySet) Get(ctx context.Context, name string) (*auth.Policy, error) {
+	return s.cache.Get(ctx, s.PolicySet, name)
+}
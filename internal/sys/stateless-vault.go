@@ -7,6 +7,7 @@ package sys
 import (
 	"context"
 	"net/http"
+	"sync/atomic"
 
 	"github.com/minio/kes"
 	"github.com/minio/kes/internal/auth"
@@ -31,13 +32,22 @@ func NewStatelessVault(operator kes.Identity, keys key.Store, policies auth.Poli
 type statelessVault struct {
 	enclave  *Enclave
 	operator kes.Identity
+	sealed   uint32 // 1 if sealed, 0 otherwise. Modified atomically.
 }
 
 var _ Vault = (*statelessVault)(nil) // compiler check
 
-func (v *statelessVault) Seal(ctx context.Context) error { return nil }
+func (v *statelessVault) Seal(context.Context) error {
+	if !atomic.CompareAndSwapUint32(&v.sealed, 0, 1) {
+		return kes.ErrSealed
+	}
+	return nil
+}
 
-func (v *statelessVault) Unseal(context.Context) error { return nil }
+func (v *statelessVault) Unseal(context.Context) error {
+	atomic.StoreUint32(&v.sealed, 0)
+	return nil
+}
 
 func (v *statelessVault) Operator(_ context.Context) (kes.Identity, error) {
 	return v.operator, nil
@@ -48,6 +58,9 @@ func (v *statelessVault) CreateEnclave(_ context.Context, _ string) (*Enclave, e
 }
 
 func (v *statelessVault) GetEnclave(_ context.Context, name string) (*Enclave, error) {
+	if atomic.LoadUint32(&v.sealed) == 1 {
+		return nil, kes.ErrSealed
+	}
 	if name == "" {
 		return v.enclave, nil
 	}
@@ -57,3 +70,27 @@ func (v *statelessVault) GetEnclave(_ context.Context, name string) (*Enclave, e
 func (v *statelessVault) DeleteEnclave(_ context.Context, _ string) error {
 	return kes.NewError(http.StatusNotImplemented, "deleting encalves is not supported")
 }
+
+func (v *statelessVault) ListEnclaves(context.Context) ([]string, error) {
+	if atomic.LoadUint32(&v.sealed) == 1 {
+		return nil, kes.ErrSealed
+	}
+	return []string{""}, nil // The Vault only contains the default enclave
+}
+
+func (v *statelessVault) Status(ctx context.Context) (map[string]EnclaveStatus, error) {
+	if atomic.LoadUint32(&v.sealed) == 1 {
+		return map[string]EnclaveStatus{"": {Sealed: true}}, nil
+	}
+	return map[string]EnclaveStatus{"": v.enclave.enclaveStatus(ctx)}, nil
+}
+
+func (v *statelessVault) EnclaveStatus(ctx context.Context, name string) (EnclaveStatus, error) {
+	if name != "" {
+		return EnclaveStatus{}, kes.ErrEnclaveNotFound
+	}
+	if atomic.LoadUint32(&v.sealed) == 1 {
+		return EnclaveStatus{Sealed: true}, nil
+	}
+	return v.enclave.enclaveStatus(ctx), nil
+}
@@ -8,6 +8,7 @@ import (
 	"context"
 
 	"github.com/minio/kes"
+	"github.com/minio/kes/internal/key"
 )
 
 // A Vault manages a set of Enclaves.
@@ -46,4 +47,46 @@ type Vault interface {
 
 	// DeleteEnclave deletes the Enclave with the given name.
 	DeleteEnclave(ctx context.Context, name string) error
+
+	// ListEnclaves returns the names of all enclaves
+	// within the Vault.
+	ListEnclaves(ctx context.Context) ([]string, error)
+
+	// Status returns the status of all enclaves within the Vault
+	// keyed by the enclave name. The default enclave has an
+	// empty name.
+	//
+	// If the status of an enclave cannot be determined, Status
+	// reports the error as part of the enclave's EnclaveStatus
+	// instead of failing.
+	Status(ctx context.Context) (map[string]EnclaveStatus, error)
+
+	// EnclaveStatus returns the status of the Enclave with the
+	// given name. In contrast to Status, it only fetches the
+	// status of this Enclave.
+	//
+	// It returns ErrEnclaveNotFound if no Enclave with the given
+	// name exists. If the status of the Enclave cannot be
+	// determined, EnclaveStatus reports the error as part of
+	// the EnclaveStatus instead of failing.
+	EnclaveStatus(ctx context.Context, name string) (EnclaveStatus, error)
+}
+
+// EnclaveStatus describes the state of an Enclave.
+type EnclaveStatus struct {
+	// Sealed indicates whether the Enclave is sealed.
+	Sealed bool
+
+	// KeyCount is the number of keys within the Enclave.
+	// It is only valid if the Enclave's key store is
+	// available. It may be up to a minute old.
+	KeyCount uint64
+
+	// Store is the state of the Enclave's key store.
+	Store key.StoreState
+
+	// Err is the error, if any, encountered while fetching
+	// the status of the Enclave. If not nil, the KeyCount is
+	// not valid.
+	Err error
 }
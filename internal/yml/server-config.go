@@ -43,14 +43,19 @@ type ServerConfig struct {
 	} `yaml:"cache"`
 
 	Log struct {
-		Error String `yaml:"error"`
-		Audit String `yaml:"audit"`
+		Error     String   `yaml:"error"`
+		Audit     String   `yaml:"audit"`
+		KeepAlive Duration `yaml:"keepalive"`
 	} `yaml:"log"`
 
 	Keys []struct {
 		Name String `yaml:"name"`
 	} `yaml:"keys"`
 
+	KeyName struct {
+		Pattern String `yaml:"pattern"`
+	} `yaml:"keyname"`
+
 	KeyStore struct {
 		Fs struct {
 			Path String `yaml:"path"`
@@ -162,10 +162,11 @@ func (c *serverConfigV0135) migrate() *ServerConfig {
 	config := &ServerConfig{
 		Address:  c.Addr,
 		Cache:    c.Cache,
-		Log:      c.Log,
 		KeyStore: c.Keys,
 	}
 	config.Admin.Identity = c.Root
+	config.Log.Error = c.Log.Error
+	config.Log.Audit = c.Log.Audit
 
 	config.TLS.PrivateKey = c.TLS.PrivateKey
 	config.TLS.Certificate = c.TLS.Certificate
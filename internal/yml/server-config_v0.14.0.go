@@ -166,11 +166,12 @@ func (c *serverConfigV0140) migrate() *ServerConfig {
 	config := &ServerConfig{
 		Address:  c.Addr,
 		Cache:    c.Cache,
-		Log:      c.Log,
 		Keys:     c.Keys,
 		KeyStore: c.KeyStore,
 	}
 	config.Admin.Identity = c.Root
+	config.Log.Error = c.Log.Error
+	config.Log.Audit = c.Log.Audit
 
 	config.TLS.PrivateKey = c.TLS.PrivateKey
 	config.TLS.Certificate = c.TLS.Certificate
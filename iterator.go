@@ -0,0 +1,59 @@
+// Copyright 2022 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package kes
+
+import (
+	"errors"
+	"sync/atomic"
+)
+
+// ErrIteratorClosed is returned by an iterator, like
+// a KeyIterator, when it is used after it has been
+// closed.
+var ErrIteratorClosed = errors.New("kes: iterator is closed")
+
+// ErrIteratorInUse is returned by an iterator's WriteTo
+// method, like KeyIterator.WriteTo, when it has been called
+// while another call of Next or WriteTo was still in
+// progress. An iterator is not safe for concurrent use.
+var ErrIteratorInUse = errors.New("kes: iterator is used concurrently")
+
+// iteratorGuard detects concurrent calls of an iterator's
+// Next, WriteTo or Close method.
+//
+// Iterators are not safe for concurrent use. However,
+// misusing an iterator should fail the colliding call
+// instead of corrupting the iterator state. Further,
+// Close may be called concurrently to cancel a call of
+// Next or WriteTo that is blocked reading the stream.
+type iteratorGuard struct {
+	busy     uint32 // 1 while Next, WriteTo or Close is running. Modified atomically.
+	canceled uint32 // 1 if Close has been called while the iterator was busy. Modified atomically.
+}
+
+// Enter reports whether the caller may use the iterator.
+// It returns false if another goroutine is currently using
+// the iterator.
+//
+// If and only if Enter returns true, the caller must call
+// Leave once it is done.
+func (g *iteratorGuard) Enter() bool {
+	return atomic.CompareAndSwapUint32(&g.busy, 0, 1)
+}
+
+// Leave releases the iterator.
+func (g *iteratorGuard) Leave() { atomic.StoreUint32(&g.busy, 0) }
+
+// Cancel marks the iterator as canceled. It returns true
+// if and only if the iterator has not been canceled before.
+// Then the caller must close the underlying stream.
+func (g *iteratorGuard) Cancel() bool {
+	return atomic.CompareAndSwapUint32(&g.canceled, 0, 1)
+}
+
+// Canceled reports whether the iterator has been canceled.
+func (g *iteratorGuard) Canceled() bool {
+	return atomic.LoadUint32(&g.canceled) == 1
+}
@@ -0,0 +1,167 @@
+// Copyright 2022 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package kes
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestKeyIteratorClose(t *testing.T) {
+	const Stream = `{"name":"my-key-1"}` + "\n" + `{"name":"my-key-2"}` + "\n"
+
+	r := ioutil.NopCloser(strings.NewReader(Stream))
+	iterator := &KeyIterator{
+		decoder: json.NewDecoder(r),
+		closer:  r,
+	}
+	if !iterator.Next() {
+		t.Fatalf("Failed to iterate: %v", iterator.Close())
+	}
+	if name := iterator.Name(); name != "my-key-1" {
+		t.Fatalf("Invalid key name: got '%s' - want '%s'", name, "my-key-1")
+	}
+	if err := iterator.Close(); err != nil {
+		t.Fatalf("Failed to close iterator: %v", err)
+	}
+	if err := iterator.Close(); err != nil {
+		t.Fatalf("Closing an iterator twice returned an error: %v", err)
+	}
+	if iterator.Next() {
+		t.Fatal("Iterator returned a value after it has been closed")
+	}
+	if value := iterator.Value(); value != (KeyInfo{}) {
+		t.Fatalf("Closed iterator returned a non-zero value: %v", value)
+	}
+	if _, err := iterator.WriteTo(ioutil.Discard); err != ErrIteratorClosed {
+		t.Fatalf("WriteTo after Close: got '%v' - want '%v'", err, ErrIteratorClosed)
+	}
+	if err := iterator.Close(); err != ErrIteratorClosed {
+		t.Fatalf("Close after using a closed iterator: got '%v' - want '%v'", err, ErrIteratorClosed)
+	}
+}
+
+func TestKeyIteratorExhausted(t *testing.T) {
+	const Stream = `{"name":"my-key-1"}` + "\n"
+
+	r := ioutil.NopCloser(strings.NewReader(Stream))
+	iterator := &KeyIterator{
+		decoder: json.NewDecoder(r),
+		closer:  r,
+	}
+	for iterator.Next() {
+	}
+	if iterator.Next() {
+		t.Fatal("Exhausted iterator returned a value")
+	}
+	if err := iterator.Close(); err != nil {
+		t.Fatalf("Failed to close exhausted iterator: %v", err)
+	}
+}
+
+func TestKeyIteratorCloseError(t *testing.T) {
+	const Stream = `{"name":"my-key-1"}` + "\n" + `{"error":"internal error"}` + "\n"
+
+	r := ioutil.NopCloser(strings.NewReader(Stream))
+	iterator := &KeyIterator{
+		decoder: json.NewDecoder(r),
+		closer:  r,
+	}
+	for iterator.Next() {
+	}
+	err := iterator.Close()
+	if err == nil || err.Error() != "internal error" {
+		t.Fatalf("Close did not return the iteration error: got '%v' - want '%s'", err, "internal error")
+	}
+	if err2 := iterator.Close(); err2 != err {
+		t.Fatalf("Close is not idempotent: got '%v' - want '%v'", err2, err)
+	}
+}
+
+func TestKeyIteratorConcurrentNext(t *testing.T) {
+	r, w := io.Pipe()
+	iterator := &KeyIterator{
+		decoder: json.NewDecoder(r),
+		closer:  r,
+	}
+
+	done := make(chan bool)
+	go func() { done <- iterator.Next() }()
+
+	// Wait until the first Next call is blocked
+	// reading from the pipe.
+	for i := 0; i < 100 && atomic.LoadUint32(&iterator.guard.busy) == 0; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if iterator.Next() {
+		t.Fatal("Concurrent Next call returned a value")
+	}
+	if _, err := iterator.WriteTo(ioutil.Discard); err != ErrIteratorInUse {
+		t.Fatalf("Concurrent WriteTo call: got '%v' - want '%v'", err, ErrIteratorInUse)
+	}
+
+	if _, err := io.Copy(w, bytes.NewBufferString(`{"name":"my-key"}`+"\n")); err != nil {
+		t.Fatalf("Failed to write to pipe: %v", err)
+	}
+	if !<-done {
+		t.Fatalf("Failed to iterate: %v", iterator.Close())
+	}
+	w.Close()
+
+	// Only the colliding calls fail. The iterator
+	// itself is not affected by the concurrent use.
+	if name := iterator.Name(); name != "my-key" {
+		t.Fatalf("Invalid key name: got '%s' - want '%s'", name, "my-key")
+	}
+	if iterator.Next() {
+		t.Fatal("Exhausted iterator returned a value")
+	}
+	if err := iterator.Close(); err != nil {
+		t.Fatalf("Close after concurrent use: got '%v' - want '%v'", err, nil)
+	}
+}
+
+func TestKeyIteratorConcurrentClose(t *testing.T) {
+	r, _ := io.Pipe()
+	iterator := &KeyIterator{
+		decoder: json.NewDecoder(r),
+		closer:  r,
+	}
+
+	done := make(chan bool)
+	go func() { done <- iterator.Next() }()
+
+	// Wait until the Next call is blocked
+	// reading from the pipe.
+	for i := 0; i < 100 && atomic.LoadUint32(&iterator.guard.busy) == 0; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if err := iterator.Close(); err != nil {
+		t.Fatalf("Failed to close iterator: %v", err)
+	}
+	select {
+	case ok := <-done:
+		if ok {
+			t.Fatal("Canceled Next call returned a value")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Close did not cancel the Next call")
+	}
+	if err := iterator.Close(); err != nil {
+		t.Fatalf("Closing a canceled iterator returned an error: %v", err)
+	}
+	if iterator.Next() {
+		t.Fatal("Iterator returned a value after it has been closed")
+	}
+	if err := iterator.Close(); err != ErrIteratorClosed {
+		t.Fatalf("Close after using a closed iterator: got '%v' - want '%v'", err, ErrIteratorClosed)
+	}
+}
@@ -35,8 +35,11 @@ func (p *PolicySet) Admin() kes.Identity { return p.admin }
 // Any existing policy with the same name is replaced.
 func (p *PolicySet) Add(name string, policy *kes.Policy) {
 	p.policies[name] = &auth.Policy{
-		Allow: policy.Allow,
-		Deny:  policy.Deny,
+		Allow:       policy.Allow,
+		Deny:        policy.Deny,
+		RateLimit:   policy.RateLimit,
+		Extends:     policy.Extends,
+		AllowWindow: policy.AllowWindow,
 	}
 }
 
@@ -175,7 +178,7 @@ type identitySet struct {
 
 func (i *identitySet) Admin(ctx context.Context) (kes.Identity, error) { return i.admin, nil }
 
-func (i *identitySet) Assign(_ context.Context, policy string, identity kes.Identity) error {
+func (i *identitySet) Assign(_ context.Context, policy string, identity kes.Identity, expiresAt time.Time) error {
 	if i.admin == identity {
 		return kes.NewError(http.StatusBadRequest, "identity is root")
 	}
@@ -185,6 +188,8 @@ func (i *identitySet) Assign(_ context.Context, policy string, identity kes.Iden
 	i.roles[identity] = auth.IdentityInfo{
 		Policy:    policy,
 		CreatedAt: time.Now().UTC(),
+		ExpiresAt: expiresAt,
+		Alias:     i.roles[identity].Alias,
 	}
 	return nil
 }
@@ -206,6 +211,29 @@ func (i *identitySet) Get(_ context.Context, identity kes.Identity) (auth.Identi
 	return policy, nil
 }
 
+func (i *identitySet) SetAlias(_ context.Context, identity kes.Identity, alias string) error {
+	if i.admin == identity {
+		return kes.NewError(http.StatusBadRequest, "identity is root")
+	}
+	i.lock.Lock()
+	defer i.lock.Unlock()
+
+	info, ok := i.roles[identity]
+	if !ok {
+		return auth.ErrIdentityNotFound
+	}
+	if alias != "" {
+		for id, role := range i.roles {
+			if id != identity && role.Alias == alias {
+				return auth.ErrAliasExists
+			}
+		}
+	}
+	info.Alias = alias
+	i.roles[identity] = info
+	return nil
+}
+
 func (i *identitySet) Delete(_ context.Context, identity kes.Identity) error {
 	i.lock.Lock()
 	defer i.lock.Unlock()
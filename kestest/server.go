@@ -18,7 +18,9 @@ import (
 	"io"
 	"math/big"
 	"net"
+	"net/http"
 	"net/http/httptest"
+	"sync"
 	"time"
 
 	"github.com/minio/kes"
@@ -52,7 +54,8 @@ type Server struct {
 	caPrivateKey  crypto.PrivateKey
 	caCertificate *x509.Certificate
 
-	server *httptest.Server
+	failures failures
+	server   *httptest.Server
 }
 
 // Client returns a KES client configured for making requests
@@ -69,6 +72,15 @@ func (s *Server) Policy() *PolicySet { return s.policies }
 // requests on this server have completed.
 func (s *Server) Close() { s.server.Close() }
 
+// FailNext makes the server reject the next n requests
+// with the given HTTP status code. It can be used to test
+// how a client handles server failures - e.g. whether it
+// retries requests that fail with 503 Service Unavailable.
+//
+// A subsequent call of FailNext replaces any remaining
+// failures.
+func (s *Server) FailNext(n int, status int) { s.failures.Set(n, status) }
+
 // IssueClientCertificate returns a new TLS certificate for
 // client authentication with the given common name.
 //
@@ -120,13 +132,22 @@ func (s *Server) start() {
 	})
 
 	serverCert := issueCertificate("kestest: server", s.caCertificate, s.caPrivateKey, x509.ExtKeyUsageServerAuth)
-	s.server = httptest.NewUnstartedServer(xhttp.NewServerMux(&xhttp.ServerConfig{
+	mux := xhttp.NewServerMux(&xhttp.ServerConfig{
 		Version:  "v0.0.0-dev",
 		Vault:    sys.NewStatelessVault(Identify(&adminCert), store, s.policies.policySet(), s.policies.identitySet()),
 		Proxy:    nil,
 		AuditLog: auditLog,
 		ErrorLog: errorLog,
 		Metrics:  metrics,
+
+		AttestationKey: serverCert.PrivateKey.(crypto.Signer),
+	})
+	s.server = httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if status, ok := s.failures.Next(); ok {
+			http.Error(w, http.StatusText(status), status)
+			return
+		}
+		mux.ServeHTTP(w, r)
 	}))
 	s.server.TLS = &tls.Config{
 		RootCAs:      rootCAs,
@@ -134,6 +155,7 @@ func (s *Server) start() {
 		Certificates: []tls.Certificate{serverCert},
 		ClientAuth:   tls.RequireAndVerifyClientCert,
 	}
+	s.server.Config.ConnState = metrics.ConnState
 	s.server.StartTLS()
 	s.URL = s.server.URL
 
@@ -221,3 +243,33 @@ func issueCertificate(name string, caCert *x509.Certificate, caKey crypto.Privat
 	}
 	return certificate
 }
+
+// failures is a counter for injected request
+// failures. The zero value is ready to use.
+type failures struct {
+	lock   sync.Mutex
+	n      int
+	status int
+}
+
+// Set sets the number of requests that should fail
+// with the given HTTP status code.
+func (f *failures) Set(n int, status int) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	f.n, f.status = n, status
+}
+
+// Next reports whether the next request should fail
+// and, if so, with which HTTP status code.
+func (f *failures) Next() (int, bool) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	if f.n <= 0 {
+		return 0, false
+	}
+	f.n--
+	return f.status, true
+}
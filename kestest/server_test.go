@@ -8,9 +8,14 @@ package kestest_test
 import (
 	"bytes"
 	"context"
+	"crypto/rand"
+	"crypto/sha256"
 	"crypto/tls"
 	"encoding/base64"
+	"encoding/hex"
+	"errors"
 	"net/http"
+	"runtime"
 	"sort"
 	"strconv"
 	"strings"
@@ -24,35 +29,54 @@ import (
 var serverAPIs = []kes.API{
 	{Method: http.MethodGet, Path: "/version", MaxBody: 0, Timeout: 15 * time.Second},    // 0
 	{Method: http.MethodGet, Path: "/v1/status", MaxBody: 0, Timeout: 15 * time.Second},  // 1
-	{Method: http.MethodGet, Path: "/v1/metrics", MaxBody: 0, Timeout: 15 * time.Second}, // 2
-	{Method: http.MethodGet, Path: "/v1/api", MaxBody: 0, Timeout: 15 * time.Second},     // 3
+	{Method: http.MethodGet, Path: "/v1/status/stream", MaxBody: 0, Timeout: 0},          // 2
+	{Method: http.MethodGet, Path: "/v1/metrics", MaxBody: 0, Timeout: 15 * time.Second}, // 3
+	{Method: http.MethodGet, Path: "/v1/api", MaxBody: 0, Timeout: 15 * time.Second},     // 4
 
-	{Method: http.MethodPost, Path: "/v1/key/create/", MaxBody: 0, Timeout: 15 * time.Second},             // 4
-	{Method: http.MethodPost, Path: "/v1/key/import/", MaxBody: 1 << 20, Timeout: 15 * time.Second},       // 5
-	{Method: http.MethodDelete, Path: "/v1/key/delete/", MaxBody: 0, Timeout: 15 * time.Second},           // 6
-	{Method: http.MethodPost, Path: "/v1/key/generate/", MaxBody: 1 << 20, Timeout: 15 * time.Second},     // 7
-	{Method: http.MethodPost, Path: "/v1/key/encrypt/", MaxBody: 1 << 20, Timeout: 15 * time.Second},      // 8
-	{Method: http.MethodPost, Path: "/v1/key/decrypt/", MaxBody: 1 << 20, Timeout: 15 * time.Second},      // 9
-	{Method: http.MethodPost, Path: "/v1/key/bulk/decrypt/", MaxBody: 1 << 20, Timeout: 15 * time.Second}, // 10
-	{Method: http.MethodGet, Path: "/v1/key/list/", MaxBody: 0, Timeout: 15 * time.Second},                // 11
+	{Method: http.MethodPost, Path: "/v1/key/create/", MaxBody: 1024, Timeout: 15 * time.Second},            // 5
+	{Method: http.MethodPost, Path: "/v1/key/import/", MaxBody: 1 << 20, Timeout: 15 * time.Second},         // 6
+	{Method: http.MethodGet, Path: "/v1/key/describe/", MaxBody: 0, Timeout: 15 * time.Second},              // 7
+	{Method: http.MethodDelete, Path: "/v1/key/delete/", MaxBody: 0, Timeout: 15 * time.Second},             // 8
+	{Method: http.MethodPost, Path: "/v1/key/generate/", MaxBody: 1 << 20, Timeout: 15 * time.Second},       // 9
+	{Method: http.MethodPost, Path: "/v1/key/encrypt/", MaxBody: 1 << 20, Timeout: 15 * time.Second},        // 10
+	{Method: http.MethodPost, Path: "/v1/key/decrypt/", MaxBody: 1 << 20, Timeout: 15 * time.Second},        // 11
+	{Method: http.MethodPost, Path: "/v1/key/bulk/decrypt/", MaxBody: 1 << 20, Timeout: 15 * time.Second},   // 12
+	{Method: http.MethodPost, Path: "/v1/key/bulk/generate/", MaxBody: 1 << 20, Timeout: 15 * time.Second},  // 13
+	{Method: http.MethodPost, Path: "/v1/key/multi/generate/", MaxBody: 1 << 20, Timeout: 15 * time.Second}, // 14
+	{Method: http.MethodPost, Path: "/v1/key/rewrap/", MaxBody: 1 << 20, Timeout: 15 * time.Second},         // 15
+	{Method: http.MethodPost, Path: "/v1/key/derive/", MaxBody: 1 << 20, Timeout: 15 * time.Second},         // 16
+	{Method: http.MethodGet, Path: "/v1/key/export/", MaxBody: 0, Timeout: 15 * time.Second},                // 17
+	{Method: http.MethodPost, Path: "/v1/key/copy/", MaxBody: 1 << 20, Timeout: 15 * time.Second},           // 18
+	{Method: http.MethodPost, Path: "/v1/key/rename/", MaxBody: 1 << 20, Timeout: 15 * time.Second},         // 19
+	{Method: http.MethodGet, Path: "/v1/key/list/", MaxBody: 0, Timeout: 15 * time.Second},                  // 20
 
-	{Method: http.MethodGet, Path: "/v1/policy/describe/", MaxBody: 0, Timeout: 15 * time.Second},     // 12
-	{Method: http.MethodPost, Path: "/v1/policy/assign/", MaxBody: 1024, Timeout: 15 * time.Second},   // 13
-	{Method: http.MethodGet, Path: "/v1/policy/read/", MaxBody: 0, Timeout: 15 * time.Second},         // 14
-	{Method: http.MethodPost, Path: "/v1/policy/write/", MaxBody: 1 << 20, Timeout: 15 * time.Second}, // 15
-	{Method: http.MethodGet, Path: "/v1/policy/list/", MaxBody: 0, Timeout: 15 * time.Second},         // 16
-	{Method: http.MethodDelete, Path: "/v1/policy/delete/", MaxBody: 0, Timeout: 15 * time.Second},    // 17
+	{Method: http.MethodGet, Path: "/v1/policy/describe/", MaxBody: 0, Timeout: 15 * time.Second},           // 21
+	{Method: http.MethodPost, Path: "/v1/policy/assign/", MaxBody: 1024, Timeout: 15 * time.Second},         // 22
+	{Method: http.MethodPost, Path: "/v1/policy/bulk/assign/", MaxBody: 1 << 20, Timeout: 15 * time.Second}, // 23
+	{Method: http.MethodGet, Path: "/v1/policy/read/", MaxBody: 0, Timeout: 15 * time.Second},               // 24
+	{Method: http.MethodPost, Path: "/v1/policy/write/", MaxBody: 1 << 20, Timeout: 15 * time.Second},       // 25
+	{Method: http.MethodGet, Path: "/v1/policy/list/", MaxBody: 0, Timeout: 15 * time.Second},               // 26
+	{Method: http.MethodGet, Path: "/v1/policy/identities/", MaxBody: 0, Timeout: 15 * time.Second},         // 27
+	{Method: http.MethodDelete, Path: "/v1/policy/delete/", MaxBody: 0, Timeout: 15 * time.Second},          // 28
+	{Method: http.MethodPost, Path: "/v1/apply", MaxBody: 1 << 20, Timeout: 15 * time.Second},               // 29
 
-	{Method: http.MethodGet, Path: "/v1/identity/describe/", MaxBody: 0, Timeout: 15 * time.Second},     // 18
-	{Method: http.MethodGet, Path: "/v1/identity/self/describe", MaxBody: 0, Timeout: 15 * time.Second}, // 19
-	{Method: http.MethodGet, Path: "/v1/identity/list/", MaxBody: 0, Timeout: 15 * time.Second},         // 20
-	{Method: http.MethodDelete, Path: "/v1/identity/delete/", MaxBody: 0, Timeout: 15 * time.Second},    // 21
+	{Method: http.MethodGet, Path: "/v1/identity/describe/", MaxBody: 0, Timeout: 15 * time.Second},            // 30
+	{Method: http.MethodGet, Path: "/v1/identity/self/describe", MaxBody: 0, Timeout: 15 * time.Second},        // 31
+	{Method: http.MethodGet, Path: "/v1/identity/list/", MaxBody: 0, Timeout: 15 * time.Second},                // 32
+	{Method: http.MethodPost, Path: "/v1/identity/alias/", MaxBody: 1024, Timeout: 15 * time.Second},           // 33
+	{Method: http.MethodDelete, Path: "/v1/identity/delete/", MaxBody: 0, Timeout: 15 * time.Second},           // 34
+	{Method: http.MethodDelete, Path: "/v1/identity/delete-by-policy/", MaxBody: 0, Timeout: 15 * time.Second}, // 35
 
-	{Method: http.MethodGet, Path: "/v1/log/error", MaxBody: 0, Timeout: 0}, // 22
-	{Method: http.MethodGet, Path: "/v1/log/audit", MaxBody: 0, Timeout: 0}, // 23
+	{Method: http.MethodGet, Path: "/v1/log/error", MaxBody: 0, Timeout: 0}, // 36
+	{Method: http.MethodGet, Path: "/v1/log/audit", MaxBody: 0, Timeout: 0}, // 37
 
-	{Method: http.MethodPost, Path: "/v1/enclave/create/", MaxBody: 0, Timeout: 15 * time.Second},   // 24
-	{Method: http.MethodDelete, Path: "/v1/enclave/delete/", MaxBody: 0, Timeout: 15 * time.Second}, // 25
+	{Method: http.MethodPost, Path: "/v1/enclave/create/", MaxBody: 0, Timeout: 15 * time.Second},   // 38
+	{Method: http.MethodDelete, Path: "/v1/enclave/delete/", MaxBody: 0, Timeout: 15 * time.Second}, // 39
+	{Method: http.MethodGet, Path: "/v1/enclave/list/", MaxBody: 0, Timeout: 15 * time.Second},      // 40
+	{Method: http.MethodGet, Path: "/v1/enclave/describe/", MaxBody: 0, Timeout: 15 * time.Second},  // 41
+	{Method: http.MethodGet, Path: "/v1/vault/status", MaxBody: 0, Timeout: 15 * time.Second},       // 42
+	{Method: http.MethodPost, Path: "/v1/vault/seal", MaxBody: 0, Timeout: 15 * time.Second},        // 43
+	{Method: http.MethodPost, Path: "/v1/vault/unseal", MaxBody: 0, Timeout: 15 * time.Second},      // 44
 }
 
 func TestAPIs(t *testing.T) {
@@ -87,6 +111,25 @@ func TestAPIs(t *testing.T) {
 	}
 }
 
+func TestStatus(t *testing.T) {
+	ctx, cancel := testingContext(t)
+	defer cancel()
+
+	server := kestest.NewServer()
+	defer server.Close()
+
+	state, err := server.Client().Status(ctx)
+	if err != nil {
+		t.Fatalf("Failed to fetch server status: %v", err)
+	}
+	if state.Version == "" {
+		t.Fatal("Server status does not contain a version")
+	}
+	if state.GoVersion != runtime.Version() {
+		t.Fatalf("Go version mismatch: got '%s' - want '%s'", state.GoVersion, runtime.Version())
+	}
+}
+
 var createKeyTests = []struct {
 	Name       string
 	ShouldFail bool
@@ -200,6 +243,175 @@ func TestGenerateKey(t *testing.T) {
 	}
 }
 
+var generateKeyNTests = []struct {
+	Bits       int
+	Context    []byte
+	ShouldFail bool
+}{
+	{Bits: 128}, // 0
+	{Bits: 256}, // 1
+	{Bits: 128, Context: []byte("Hello World")}, // 2
+
+	{Bits: 0, ShouldFail: true},   // 3
+	{Bits: 192, ShouldFail: true}, // 4
+	{Bits: 512, ShouldFail: true}, // 5
+}
+
+func TestGenerateKeyMulti(t *testing.T) {
+	ctx, cancel := testingContext(t)
+	defer cancel()
+
+	server := kestest.NewServer()
+	defer server.Close()
+
+	client := server.Client()
+	keys := []string{"primary", "secondary"}
+	for _, name := range keys {
+		if err := client.CreateKey(ctx, name); err != nil {
+			t.Fatalf("Failed to create key '%s': %v", name, err)
+		}
+	}
+
+	associatedData := []byte("multi-generate")
+	plaintext, ciphertexts, err := client.GenerateKeyMulti(ctx, keys, associatedData)
+	if err != nil {
+		t.Fatalf("Failed to generate DEK: %v", err)
+	}
+	if len(ciphertexts) != len(keys) {
+		t.Fatalf("Invalid number of ciphertexts: got %d - want %d", len(ciphertexts), len(keys))
+	}
+	for _, name := range keys {
+		p, err := client.Decrypt(ctx, name, ciphertexts[name], associatedData)
+		if err != nil {
+			t.Fatalf("Failed to decrypt ciphertext of key '%s': %v", name, err)
+		}
+		if !bytes.Equal(p, plaintext) {
+			t.Fatalf("Ciphertext of key '%s' decrypts to a different plaintext", name)
+		}
+	}
+
+	if _, _, err = client.GenerateKeyMulti(ctx, []string{"primary", "does-not-exist"}, nil); err != kes.ErrKeyNotFound {
+		t.Fatalf("Generating DEK with non-existing key: got '%v' - want '%v'", err, kes.ErrKeyNotFound)
+	}
+	if _, _, err = client.GenerateKeyMulti(ctx, []string{"primary", "primary"}, nil); err == nil {
+		t.Fatal("Generating DEK with duplicate keys should have failed")
+	}
+
+	// An identity has to be allowed to generate DEKs
+	// with each key.
+	cert := server.IssueClientCertificate("multi-generate test")
+	server.Policy().Allow("multi-generate", "/v1/key/multi/generate/*", "/v1/key/generate/primary")
+	server.Policy().Assign("multi-generate", kestest.Identify(&cert))
+	other := kes.NewClientWithConfig(server.URL, &tls.Config{
+		RootCAs:      server.CAs(),
+		Certificates: []tls.Certificate{cert},
+	})
+	if _, _, err = other.GenerateKeyMulti(ctx, []string{"primary"}, nil); err != nil {
+		t.Fatalf("Failed to generate DEK: %v", err)
+	}
+	if _, _, err = other.GenerateKeyMulti(ctx, keys, nil); err != kes.ErrNotAllowed {
+		t.Fatalf("Generating DEK with a prohibited key: got '%v' - want '%v'", err, kes.ErrNotAllowed)
+	}
+}
+
+func TestGenerateKeyN(t *testing.T) {
+	ctx, cancel := testingContext(t)
+	defer cancel()
+
+	server := kestest.NewServer()
+	defer server.Close()
+
+	client := server.Client()
+
+	const KeyName = "my-key"
+	if err := client.CreateKey(ctx, KeyName); err != nil {
+		t.Fatalf("Failed to create %q: %v", KeyName, err)
+	}
+	for i, test := range generateKeyNTests {
+		dek, err := client.GenerateKeyN(ctx, KeyName, test.Bits, test.Context)
+		if err == nil && test.ShouldFail {
+			t.Fatalf("Test %d: should fail but succeeded", i)
+		}
+		if err != nil && !test.ShouldFail {
+			t.Fatalf("Test %d: failed to generate DEK: %v", i, err)
+		}
+		if test.ShouldFail {
+			continue
+		}
+
+		if len(dek.Plaintext) != test.Bits/8 {
+			t.Fatalf("Test %d: DEK length mismatch: got '%d' - want '%d'", i, 8*len(dek.Plaintext), test.Bits)
+		}
+		plaintext, err := client.Decrypt(ctx, KeyName, dek.Ciphertext, test.Context)
+		if err != nil {
+			t.Fatalf("Test %d: failed to decrypt ciphertext: %v", i, err)
+		}
+		if !bytes.Equal(dek.Plaintext, plaintext) {
+			t.Fatalf("Test %d: decryption failed: got %x - want %x", i, plaintext, dek.Plaintext)
+		}
+	}
+}
+
+var generateKeysTests = []struct {
+	N          int
+	Context    []byte
+	ShouldFail bool
+}{
+	{N: 1},                                  // 0
+	{N: 10, Context: []byte("Hello World")}, // 1
+	{N: 1000},                               // 2
+
+	{N: 0, ShouldFail: true},    // 3
+	{N: -1, ShouldFail: true},   // 4
+	{N: 1001, ShouldFail: true}, // 5
+}
+
+func TestGenerateKeys(t *testing.T) {
+	ctx, cancel := testingContext(t)
+	defer cancel()
+
+	server := kestest.NewServer()
+	defer server.Close()
+
+	client := server.Client()
+
+	const KeyName = "my-key"
+	if err := client.CreateKey(ctx, KeyName); err != nil {
+		t.Fatalf("Failed to create %q: %v", KeyName, err)
+	}
+	for i, test := range generateKeysTests {
+		deks, err := client.GenerateKeys(ctx, KeyName, test.N, test.Context)
+		if err == nil && test.ShouldFail {
+			t.Fatalf("Test %d: should fail but succeeded", i)
+		}
+		if err != nil && !test.ShouldFail {
+			t.Fatalf("Test %d: failed to generate DEKs: %v", i, err)
+		}
+		if test.ShouldFail {
+			continue
+		}
+
+		if len(deks) != test.N {
+			t.Fatalf("Test %d: number of DEKs mismatch: got '%d' - want '%d'", i, len(deks), test.N)
+		}
+		plaintexts := make(map[string]bool, len(deks))
+		for j, dek := range deks {
+			if plaintexts[string(dek.Plaintext)] {
+				t.Fatalf("Test %d: %d-nth DEK is not unique", i, j)
+			}
+			plaintexts[string(dek.Plaintext)] = true
+		}
+
+		plaintext, err := client.Decrypt(ctx, KeyName, deks[len(deks)-1].Ciphertext, test.Context)
+		if err != nil {
+			t.Fatalf("Test %d: failed to decrypt ciphertext: %v", i, err)
+		}
+		if !bytes.Equal(deks[len(deks)-1].Plaintext, plaintext) {
+			t.Fatalf("Test %d: decryption failed: got %x - want %x", i, plaintext, deks[len(deks)-1].Plaintext)
+		}
+	}
+}
+
 var encryptKeyTests = []struct {
 	Plaintext  []byte
 	Context    []byte
@@ -389,6 +601,237 @@ func TestDecryptKeyAll(t *testing.T) {
 	}
 }
 
+func TestReWrap(t *testing.T) {
+	ctx, cancel := testingContext(t)
+	defer cancel()
+
+	server := kestest.NewServer()
+	defer server.Close()
+
+	client := server.Client()
+
+	const KeyName = "my-key"
+	const KeyValue = "pQLPe6/f87AMSItvZzEbrxYdRUzmM81ziXF95HOFE4Y="
+	if err := client.ImportKey(ctx, KeyName, mustDecodeB64(KeyValue)); err != nil {
+		t.Fatalf("Failed to create %q: %v", KeyName, err)
+	}
+	for i, test := range decryptKeyTests {
+		ciphertext, err := client.ReWrap(ctx, KeyName, test.Ciphertext, test.Context)
+		if err != nil {
+			t.Fatalf("Test %d: failed to re-wrap ciphertext: %v", i, err)
+		}
+		if bytes.Equal(ciphertext, test.Ciphertext) {
+			t.Fatalf("Test %d: re-wrapped ciphertext is equal to original ciphertext", i)
+		}
+		plaintext, err := client.Decrypt(ctx, KeyName, ciphertext, test.Context)
+		if err != nil {
+			t.Fatalf("Test %d: failed to decrypt re-wrapped ciphertext: %v", i, err)
+		}
+		if !bytes.Equal(plaintext, test.Plaintext) {
+			t.Fatalf("Test %d: failed to decrypt re-wrapped ciphertext: got '%x' - want '%x'", i, plaintext, test.Plaintext)
+		}
+	}
+
+	if _, err := client.ReWrap(ctx, KeyName, decryptKeyTests[0].Ciphertext, []byte("wrong context")); err != kes.ErrDecrypt {
+		t.Fatalf("Re-wrapping with wrong context should fail with '%v' - got '%v'", kes.ErrDecrypt, err)
+	}
+}
+
+func TestListKeys(t *testing.T) {
+	ctx, cancel := testingContext(t)
+	defer cancel()
+
+	server := kestest.NewServer()
+	defer server.Close()
+
+	client := server.Client()
+	for _, name := range []string{"my-key", "my-key2", "other-key"} {
+		if err := client.CreateKey(ctx, name); err != nil {
+			t.Fatalf("Failed to create %q: %v", name, err)
+		}
+	}
+
+	iterator, err := client.ListKeys(ctx, "my-key*")
+	if err != nil {
+		t.Fatalf("Failed to list keys: %v", err)
+	}
+	for iterator.Next() {
+		if !iterator.CreatedAt().IsZero() {
+			t.Fatalf("Key %q has a created_at timestamp although no metadata has been requested", iterator.Name())
+		}
+	}
+	if err = iterator.Close(); err != nil {
+		t.Fatalf("Failed to list keys: %v", err)
+	}
+
+	iterator, err = client.ListKeysWithOptions(ctx, "my-key*", kes.ListKeysOptions{Metadata: true})
+	if err != nil {
+		t.Fatalf("Failed to list keys: %v", err)
+	}
+	var names []string
+	for iterator.Next() {
+		if iterator.CreatedAt().IsZero() {
+			t.Fatalf("Key %q has no created_at timestamp", iterator.Name())
+		}
+		if admin := server.Policy().Admin(); iterator.CreatedBy() != admin {
+			t.Fatalf("Key %q: created_by mismatch: got '%s' - want '%s'", iterator.Name(), iterator.CreatedBy(), admin)
+		}
+		names = append(names, iterator.Name())
+	}
+	if err = iterator.Close(); err != nil {
+		t.Fatalf("Failed to list keys: %v", err)
+	}
+	if want := []string{"my-key", "my-key2"}; !equal(names, want) {
+		t.Fatalf("Key mismatch: got '%v' - want '%v'", names, want)
+	}
+}
+
+func TestListKeysCompressed(t *testing.T) {
+	ctx, cancel := testingContext(t)
+	defer cancel()
+
+	server := kestest.NewServer()
+	defer server.Close()
+
+	// Create enough keys such that the server
+	// compresses the list response.
+	const N = 200
+	client := server.Client()
+	for i := 0; i < N; i++ {
+		if err := client.CreateKey(ctx, "my-key-"+strconv.Itoa(i)); err != nil {
+			t.Fatalf("Failed to create key: %v", err)
+		}
+	}
+
+	iterator, err := client.ListKeys(ctx, "my-key-*")
+	if err != nil {
+		t.Fatalf("Failed to list keys: %v", err)
+	}
+	var n int
+	for iterator.Next() {
+		n++
+	}
+	if err = iterator.Close(); err != nil {
+		t.Fatalf("Failed to list keys: %v", err)
+	}
+	if n != N {
+		t.Fatalf("Key count mismatch: got '%d' - want '%d'", n, N)
+	}
+}
+
+func TestCreateKeyIdempotent(t *testing.T) {
+	ctx, cancel := testingContext(t)
+	defer cancel()
+
+	server := kestest.NewServer()
+	defer server.Close()
+
+	const (
+		KeyName = "my-key"
+		Token   = "a9d6cc4d-c3b4-4ab0-9c2e-6bd1e5b2b0f1"
+	)
+	client := server.Client()
+	for i := 0; i < 3; i++ {
+		if err := client.CreateKeyIdempotent(ctx, KeyName, Token); err != nil {
+			t.Fatalf("Failed to create %q with idempotency token: %v", KeyName, err)
+		}
+	}
+	if err := client.CreateKeyIdempotent(ctx, KeyName, "other-token"); err != kes.ErrKeyExists {
+		t.Fatalf("Creating %q with another token: got '%v' - want '%v'", KeyName, err, kes.ErrKeyExists)
+	}
+	if err := client.CreateKey(ctx, KeyName); err != kes.ErrKeyExists {
+		t.Fatalf("Creating %q without token: got '%v' - want '%v'", KeyName, err, kes.ErrKeyExists)
+	}
+	if err := client.CreateKeyIdempotent(ctx, KeyName, ""); err != kes.ErrKeyExists {
+		t.Fatalf("Creating %q with empty token: got '%v' - want '%v'", KeyName, err, kes.ErrKeyExists)
+	}
+
+	if err := client.CreateKey(ctx, "my-key2"); err != nil {
+		t.Fatalf("Failed to create %q: %v", "my-key2", err)
+	}
+	if err := client.CreateKeyIdempotent(ctx, "my-key2", Token); err != kes.ErrKeyExists {
+		t.Fatalf("Creating existing %q with token: got '%v' - want '%v'", "my-key2", err, kes.ErrKeyExists)
+	}
+
+	// Idempotency tokens are scoped to the identity. Hence,
+	// another identity cannot reuse the admin's token.
+	cert := server.IssueClientCertificate("idempotency test")
+	other := kes.NewClientWithConfig(server.URL, &tls.Config{
+		RootCAs:      server.CAs(),
+		Certificates: []tls.Certificate{cert},
+	})
+	server.Policy().Allow("idempotency", "/v1/key/create/*")
+	server.Policy().Assign("idempotency", kestest.Identify(&cert))
+	if err := other.CreateKeyIdempotent(ctx, KeyName, Token); err != kes.ErrKeyExists {
+		t.Fatalf("Creating %q with another identity's token: got '%v' - want '%v'", KeyName, err, kes.ErrKeyExists)
+	}
+}
+
+func TestDescribeKey(t *testing.T) {
+	ctx, cancel := testingContext(t)
+	defer cancel()
+
+	server := kestest.NewServer()
+	defer server.Close()
+
+	const KeyName = "my-key"
+	client := server.Client()
+	if err := client.CreateKey(ctx, KeyName); err != nil {
+		t.Fatalf("Failed to create %q: %v", KeyName, err)
+	}
+
+	info, err := client.DescribeKey(ctx, KeyName)
+	if err != nil {
+		t.Fatalf("Failed to describe %q: %v", KeyName, err)
+	}
+	if info.Name != KeyName {
+		t.Fatalf("Key name mismatch: got '%s' - want '%s'", info.Name, KeyName)
+	}
+	if admin := server.Policy().Admin(); info.CreatedBy != admin {
+		t.Fatalf("Key %q: created_by mismatch: got '%s' - want '%s'", KeyName, info.CreatedBy, admin)
+	}
+	if info.EncryptCount != 0 || info.DecryptCount != 0 || info.GenerateCount != 0 || !info.LastUsedAt.IsZero() {
+		t.Fatalf("Key %q has been used: %+v", KeyName, info)
+	}
+
+	dek, err := client.GenerateKey(ctx, KeyName, nil)
+	if err != nil {
+		t.Fatalf("Failed to generate DEK: %v", err)
+	}
+	if _, err = client.GenerateKeys(ctx, KeyName, 3, nil); err != nil {
+		t.Fatalf("Failed to generate DEKs: %v", err)
+	}
+	if _, err = client.Encrypt(ctx, KeyName, []byte("Hello World"), nil); err != nil {
+		t.Fatalf("Failed to encrypt plaintext: %v", err)
+	}
+	for i := 0; i < 2; i++ {
+		if _, err = client.Decrypt(ctx, KeyName, dek.Ciphertext, nil); err != nil {
+			t.Fatalf("Failed to decrypt ciphertext: %v", err)
+		}
+	}
+
+	info, err = client.DescribeKey(ctx, KeyName)
+	if err != nil {
+		t.Fatalf("Failed to describe %q: %v", KeyName, err)
+	}
+	if info.GenerateCount != 4 {
+		t.Fatalf("Generate count mismatch: got '%d' - want '%d'", info.GenerateCount, 4)
+	}
+	if info.EncryptCount != 1 {
+		t.Fatalf("Encrypt count mismatch: got '%d' - want '%d'", info.EncryptCount, 1)
+	}
+	if info.DecryptCount != 2 {
+		t.Fatalf("Decrypt count mismatch: got '%d' - want '%d'", info.DecryptCount, 2)
+	}
+	if info.LastUsedAt.IsZero() {
+		t.Fatalf("Key %q has no last_used_at timestamp", KeyName)
+	}
+
+	if _, err = client.DescribeKey(ctx, "non-existing-key"); err != kes.ErrKeyNotFound {
+		t.Fatalf("Describing a non-existing key: got '%v' - want '%v'", err, kes.ErrKeyNotFound)
+	}
+}
+
 var setPolicyTests = []struct {
 	Name       string
 	Policy     *kes.Policy
@@ -440,30 +883,1660 @@ func TestSetPolicy(t *testing.T) {
 	}
 }
 
-var selfDescribeTests = []struct {
-	Policy kes.Policy
-}{
-	{ // 0
-		Policy: kes.Policy{},
-	},
-	{ // 1
-		Policy: kes.Policy{Allow: []string{}, Deny: []string{}},
-	},
-	{ // 2
-		Policy: kes.Policy{
-			Allow: []string{
-				"/v1/key/create/my-key-*",
-				"/v1/key/generate/my-key-*",
-				"/v1/key/decrypt/my-key-*",
-				"/v1/key/delete/my-key-*",
-			},
-			Deny: []string{
-				"/v1/key/delete/my-key-prod-*",
-			},
-		},
-	},
-}
+func TestPolicyExtends(t *testing.T) {
+	ctx, cancel := testingContext(t)
+	defer cancel()
+
+	server := kestest.NewServer()
+	defer server.Close()
+
+	client := server.Client()
+	if err := client.CreateKey(ctx, "my-key"); err != nil {
+		t.Fatalf("Failed to create key: %v", err)
+	}
+	if err := client.CreateKey(ctx, "internal-key"); err != nil {
+		t.Fatalf("Failed to create key: %v", err)
+	}
+	if err := client.SetPolicy(ctx, "base", &kes.Policy{
+		Allow: []string{"/v1/key/generate/*"},
+		Deny:  []string{"/v1/key/generate/internal-*"},
+	}); err != nil {
+		t.Fatalf("Failed to create policy: %v", err)
+	}
+	if err := client.SetPolicy(ctx, "app", &kes.Policy{
+		Allow:   []string{"/v1/key/decrypt/*"},
+		Extends: []string{"base"},
+	}); err != nil {
+		t.Fatalf("Failed to create policy: %v", err)
+	}
+	policy, err := client.GetPolicy(ctx, "app")
+	if err != nil {
+		t.Fatalf("Failed to fetch policy: %v", err)
+	}
+	if !equal(policy.Extends, []string{"base"}) {
+		t.Fatalf("Parent policies mismatch: got '%v' - want '%v'", policy.Extends, []string{"base"})
+	}
+
+	cert := server.IssueClientCertificate("policy-extends test")
+	app := kes.NewClientWithConfig(server.URL, &tls.Config{
+		RootCAs:      server.CAs(),
+		Certificates: []tls.Certificate{cert},
+	})
+	if err = client.AssignPolicy(ctx, "app", kestest.Identify(&cert)); err != nil {
+		t.Fatalf("Failed to assign policy: %v", err)
+	}
+	if _, err = app.GenerateKey(ctx, "my-key", nil); err != nil {
+		t.Fatalf("Inherited allow rule does not apply: %v", err)
+	}
+	if _, err = app.GenerateKey(ctx, "internal-key", nil); err != kes.ErrNotAllowed {
+		t.Fatalf("Inherited deny rule does not apply: got '%v' - want '%v'", err, kes.ErrNotAllowed)
+	}
+
+	if err = client.SetPolicy(ctx, "base", &kes.Policy{Extends: []string{"app"}}); err == nil {
+		t.Fatal("Creating a policy inheritance cycle should have failed")
+	}
+	if err = client.SetPolicy(ctx, "other", &kes.Policy{Extends: []string{"unknown"}}); err == nil {
+		t.Fatal("Extending a non-existing policy should have failed")
+	}
+
+	if err = client.DeletePolicy(ctx, "base"); err == nil {
+		t.Fatal("Deleting a policy that is extended by another policy should have failed")
+	}
+	if _, err = app.GenerateKey(ctx, "my-key", nil); err != nil {
+		t.Fatalf("Failed to generate DEK after rejected policy deletion: %v", err)
+	}
+	if err = client.DeletePolicy(ctx, "app"); err != nil {
+		t.Fatalf("Failed to delete policy: %v", err)
+	}
+	if err = client.DeletePolicy(ctx, "base"); err != nil {
+		t.Fatalf("Failed to delete policy: %v", err)
+	}
+}
+
+func TestDescribePolicy(t *testing.T) {
+	ctx, cancel := testingContext(t)
+	defer cancel()
+
+	server := kestest.NewServer()
+	defer server.Close()
+
+	client := server.Client()
+	if err := client.SetPolicy(ctx, "base", &kes.Policy{
+		Allow: []string{"/v1/key/generate/*"},
+		Deny:  []string{"/v1/key/generate/internal-*"},
+	}); err != nil {
+		t.Fatalf("Failed to create policy: %v", err)
+	}
+	if err := client.SetPolicy(ctx, "app", &kes.Policy{
+		Allow:   []string{"/v1/key/decrypt/*"},
+		Extends: []string{"base"},
+	}); err != nil {
+		t.Fatalf("Failed to create policy: %v", err)
+	}
+
+	info, err := client.DescribePolicy(ctx, "base")
+	if err != nil {
+		t.Fatalf("Failed to describe policy: %v", err)
+	}
+	if info.Name != "base" {
+		t.Fatalf("Policy name mismatch: got '%s' - want '%s'", info.Name, "base")
+	}
+	if info.CreatedAt.IsZero() || info.CreatedBy != server.Policy().Admin() {
+		t.Fatalf("Invalid policy metadata: created_at '%v' - created_by '%s'", info.CreatedAt, info.CreatedBy)
+	}
+	if !equal(info.EffectiveAllow, info.Allow) || !equal(info.EffectiveDeny, info.Deny) {
+		t.Fatal("Effective rules of a policy without parents differ from its rules")
+	}
+
+	info, err = client.DescribePolicy(ctx, "app")
+	if err != nil {
+		t.Fatalf("Failed to describe policy: %v", err)
+	}
+	if !equal(info.Allow, []string{"/v1/key/decrypt/*"}) || len(info.Deny) != 0 {
+		t.Fatalf("Policy rules mismatch: got allow '%v' and deny '%v'", info.Allow, info.Deny)
+	}
+	if !equal(info.Extends, []string{"base"}) {
+		t.Fatalf("Parent policies mismatch: got '%v' - want '%v'", info.Extends, []string{"base"})
+	}
+	if want := []string{"/v1/key/decrypt/*", "/v1/key/generate/*"}; !equal(info.EffectiveAllow, want) {
+		t.Fatalf("Effective allow rules mismatch: got '%v' - want '%v'", info.EffectiveAllow, want)
+	}
+	if want := []string{"/v1/key/generate/internal-*"}; !equal(info.EffectiveDeny, want) {
+		t.Fatalf("Effective deny rules mismatch: got '%v' - want '%v'", info.EffectiveDeny, want)
+	}
+
+	if _, err = client.DescribePolicy(ctx, "unknown"); err != kes.ErrPolicyNotFound {
+		t.Fatalf("Describing non-existing policy: got '%v' - want '%v'", err, kes.ErrPolicyNotFound)
+	}
+}
+
+var listPoliciesTests = []struct {
+	Pattern string
+	Names   []string
+}{
+	{Pattern: "", Names: []string{"my-policy", "my-policy2", "other-policy"}},  // 0
+	{Pattern: "*", Names: []string{"my-policy", "my-policy2", "other-policy"}}, // 1
+	{Pattern: "my-policy*", Names: []string{"my-policy", "my-policy2"}},        // 2
+	{Pattern: "other-policy", Names: []string{"other-policy"}},                 // 3
+	{Pattern: "unknown-policy", Names: []string{}},                             // 4
+}
+
+func TestListPolicies(t *testing.T) {
+	ctx, cancel := testingContext(t)
+	defer cancel()
+
+	server := kestest.NewServer()
+	defer server.Close()
+
+	client := server.Client()
+	for _, name := range []string{"my-policy", "my-policy2", "other-policy"} {
+		if err := client.SetPolicy(ctx, name, &kes.Policy{Allow: []string{"/v1/key/create/*"}}); err != nil {
+			t.Fatalf("Failed to create policy %q: %v", name, err)
+		}
+	}
+	for i, test := range listPoliciesTests {
+		iterator, err := client.ListPolicies(ctx, test.Pattern)
+		if err != nil {
+			t.Fatalf("Test %d: failed to list policies: %v", i, err)
+		}
+		names := []string{}
+		for iterator.Next() {
+			if iterator.CreatedAt().IsZero() {
+				t.Fatalf("Test %d: policy %q has no created_at timestamp", i, iterator.Name())
+			}
+			if iterator.CreatedBy() != server.Policy().Admin() {
+				t.Fatalf("Test %d: created_by mismatch: got '%s' - want '%s'", i, iterator.CreatedBy(), server.Policy().Admin())
+			}
+			names = append(names, iterator.Name())
+		}
+		if err = iterator.Close(); err != nil {
+			t.Fatalf("Test %d: failed to list policies: %v", i, err)
+		}
+		if !equal(names, test.Names) {
+			t.Fatalf("Test %d: policy mismatch: got '%v' - want '%v'", i, names, test.Names)
+		}
+	}
+}
+
+func TestApply(t *testing.T) {
+	ctx, cancel := testingContext(t)
+	defer cancel()
+
+	server := kestest.NewServer()
+	defer server.Close()
+
+	var (
+		identity = kes.Identity("f41ec4d1d1ef1d85b71f0ac7e3fb1a0cd6ec7fa2d6f8b73a7b1e8a4a6e90bb1c")
+		other    = kes.Identity("b0b8a2fe3a2b8cbf4f86a4fdb0ec7e16c1d1d4e4e1cff0d5bd2c1d5b8d9ad2e5")
+	)
+	client := server.Client()
+	err := client.Apply(ctx, []kes.AdminOp{
+		{Type: kes.OpSetPolicy, Name: "my-policy", Policy: &kes.Policy{Allow: []string{"/v1/key/create/*"}}},
+		{Type: kes.OpAssignPolicy, Name: "my-policy", Identity: identity},
+	})
+	if err != nil {
+		t.Fatalf("Failed to apply operations: %v", err)
+	}
+	info, err := client.DescribeIdentity(ctx, identity)
+	if err != nil {
+		t.Fatalf("Failed to describe identity: %v", err)
+	}
+	if info.Policy != "my-policy" {
+		t.Fatalf("Policy mismatch: got '%s' - want '%s'", info.Policy, "my-policy")
+	}
+
+	// All operations are reverted if one operation fails.
+	ops := []kes.AdminOp{
+		{Type: kes.OpSetPolicy, Name: "my-policy", Policy: &kes.Policy{Allow: []string{"/v1/key/delete/*"}}},
+		{Type: kes.OpSetPolicy, Name: "other-policy", Policy: &kes.Policy{Allow: []string{"/v1/key/create/*"}}},
+		{Type: kes.OpAssignPolicy, Name: "other-policy", Identity: other},
+		{Type: kes.OpDeleteIdentity, Identity: identity},
+		{Type: kes.OpSetPolicy, Name: "invalid-policy", Policy: &kes.Policy{Extends: []string{"does-not-exist"}}},
+	}
+	err = client.Apply(ctx, ops)
+	var applyErr *kes.ApplyError
+	if !errors.As(err, &applyErr) {
+		t.Fatalf("Applying invalid operations should fail with an ApplyError: got '%v'", err)
+	}
+	if applyErr.Index != len(ops)-1 || applyErr.Op.Name != "invalid-policy" {
+		t.Fatalf("Failed operation mismatch: got '%d' - want '%d'", applyErr.Index, len(ops)-1)
+	}
+
+	policy, err := client.DescribePolicy(ctx, "my-policy")
+	if err != nil {
+		t.Fatalf("Failed to describe policy: %v", err)
+	}
+	if want := []string{"/v1/key/create/*"}; !equal(policy.Allow, want) {
+		t.Fatalf("Policy has not been restored: got '%v' - want '%v'", policy.Allow, want)
+	}
+	if _, err = client.DescribePolicy(ctx, "other-policy"); err != kes.ErrPolicyNotFound {
+		t.Fatalf("Policy has not been removed: got '%v' - want '%v'", err, kes.ErrPolicyNotFound)
+	}
+	if _, err = client.DescribeIdentity(ctx, other); err == nil {
+		t.Fatal("Identity assignment has not been removed")
+	}
+	if info, err = client.DescribeIdentity(ctx, identity); err != nil || info.Policy != "my-policy" {
+		t.Fatalf("Identity has not been restored: %v", err)
+	}
+
+	cert := server.IssueClientCertificate("apply test")
+	nonAdmin := kes.NewClientWithConfig(server.URL, &tls.Config{
+		RootCAs:      server.CAs(),
+		Certificates: []tls.Certificate{cert},
+	})
+	server.Policy().Allow("apply-policy", "/v1/apply")
+	server.Policy().Assign("apply-policy", kestest.Identify(&cert))
+	if err = nonAdmin.Apply(ctx, ops[:1]); err != kes.ErrNotAllowed {
+		t.Fatalf("Applying operations as non-admin: got '%v' - want '%v'", err, kes.ErrNotAllowed)
+	}
+}
+
+func TestListPolicyIdentities(t *testing.T) {
+	ctx, cancel := testingContext(t)
+	defer cancel()
+
+	server := kestest.NewServer()
+	defer server.Close()
+
+	var (
+		identities = []kes.Identity{
+			"f41ec4d1d1ef1d85b71f0ac7e3fb1a0cd6ec7fa2d6f8b73a7b1e8a4a6e90bb1c",
+			"b0b8a2fe3a2b8cbf4f86a4fdb0ec7e16c1d1d4e4e1cff0d5bd2c1d5b8d9ad2e5",
+		}
+		other = kes.Identity("8dd2b15d8d6c3b6e2f9a1a8b9fa3c8aa7f5c4e9c1b0a4d2c5e6f7a8b9c0d1e2f")
+	)
+	server.Policy().Allow("my-policy", "/v1/key/create/*")
+	server.Policy().Allow("other-policy", "/v1/key/create/*")
+	if err := server.Policy().Assign("my-policy", identities...); err != nil {
+		t.Fatalf("Failed to assign policy: %v", err)
+	}
+	if err := server.Policy().Assign("other-policy", other); err != nil {
+		t.Fatalf("Failed to assign policy: %v", err)
+	}
+
+	client := server.Client()
+	iterator, err := client.ListPolicyIdentities(ctx, "my-policy")
+	if err != nil {
+		t.Fatalf("Failed to list policy identities: %v", err)
+	}
+	var ids []string
+	for iterator.Next() {
+		if iterator.Policy() != "my-policy" {
+			t.Fatalf("Policy mismatch: got '%s' - want '%s'", iterator.Policy(), "my-policy")
+		}
+		ids = append(ids, iterator.Identity().String())
+	}
+	if err = iterator.Close(); err != nil {
+		t.Fatalf("Failed to list policy identities: %v", err)
+	}
+	if want := []string{identities[0].String(), identities[1].String()}; !equal(ids, want) {
+		t.Fatalf("Identity mismatch: got '%v' - want '%v'", ids, want)
+	}
+
+	iterator, err = client.ListPolicyIdentities(ctx, "unknown-policy")
+	if err != nil {
+		t.Fatalf("Failed to list policy identities: %v", err)
+	}
+	if iterator.Next() {
+		t.Fatalf("Unknown policy has assigned identities: %s", iterator.Identity())
+	}
+	if err = iterator.Close(); err != nil {
+		t.Fatalf("Failed to list policy identities: %v", err)
+	}
+}
+
+var selfDescribeTests = []struct {
+	Policy kes.Policy
+}{
+	{ // 0
+		Policy: kes.Policy{},
+	},
+	{ // 1
+		Policy: kes.Policy{Allow: []string{}, Deny: []string{}},
+	},
+	{ // 2
+		Policy: kes.Policy{
+			Allow: []string{
+				"/v1/key/create/my-key-*",
+				"/v1/key/generate/my-key-*",
+				"/v1/key/decrypt/my-key-*",
+				"/v1/key/delete/my-key-*",
+			},
+			Deny: []string{
+				"/v1/key/delete/my-key-prod-*",
+			},
+		},
+	},
+}
+
+func TestVaultStatus(t *testing.T) {
+	ctx, cancel := testingContext(t)
+	defer cancel()
+
+	server := kestest.NewServer()
+	defer server.Close()
+
+	client := server.Client()
+	for _, name := range []string{"my-key", "my-key2"} {
+		if err := client.CreateKey(ctx, name); err != nil {
+			t.Fatalf("Failed to create %q: %v", name, err)
+		}
+	}
+
+	status, err := client.VaultStatus(ctx)
+	if err != nil {
+		t.Fatalf("Failed to fetch vault status: %v", err)
+	}
+	if len(status) != 1 {
+		t.Fatalf("Enclave count mismatch: got '%d' - want '%d'", len(status), 1)
+	}
+	enclave, ok := status[""]
+	if !ok {
+		t.Fatal("Vault status contains no default enclave")
+	}
+	if enclave.Sealed {
+		t.Fatal("Default enclave is sealed")
+	}
+	if enclave.StoreState != "available" {
+		t.Fatalf("Store state mismatch: got '%s' - want '%s'", enclave.StoreState, "available")
+	}
+	if enclave.KeyCount != 2 {
+		t.Fatalf("Key count mismatch: got '%d' - want '%d'", enclave.KeyCount, 2)
+	}
+
+	cert := server.IssueClientCertificate("vault-status test")
+	client = kes.NewClientWithConfig(server.URL, &tls.Config{
+		RootCAs:      server.CAs(),
+		Certificates: []tls.Certificate{cert},
+	})
+	server.Policy().Allow("vault-status", "/v1/vault/status")
+	server.Policy().Assign("vault-status", kestest.Identify(&cert))
+	if _, err = client.VaultStatus(ctx); err != kes.ErrNotAllowed {
+		t.Fatalf("Fetching vault status as non-operator: got '%v' - want '%v'", err, kes.ErrNotAllowed)
+	}
+}
+
+func TestEnclaves(t *testing.T) {
+	ctx, cancel := testingContext(t)
+	defer cancel()
+
+	server := kestest.NewServer()
+	defer server.Close()
+
+	// The kestest server does not support multiple enclaves.
+	client := server.Client()
+	if err := client.CreateEnclave(ctx, "tenant-1"); !isNotImplemented(err) {
+		t.Fatalf("Creating an enclave: got '%v' - want status '%d'", err, http.StatusNotImplemented)
+	}
+	if err := client.DeleteEnclave(ctx, "tenant-1"); !isNotImplemented(err) {
+		t.Fatalf("Deleting an enclave: got '%v' - want status '%d'", err, http.StatusNotImplemented)
+	}
+	enclaves, err := client.ListEnclaves(ctx, "*")
+	if err != nil {
+		t.Fatalf("Failed to list enclaves: %v", err)
+	}
+	if enclaves.Next() {
+		t.Fatalf("Listing enclaves returned an enclave: %q", enclaves.Name())
+	}
+	if err = enclaves.Close(); err != nil {
+		t.Fatalf("Failed to list enclaves: %v", err)
+	}
+
+	cert := server.IssueClientCertificate("enclave test")
+	client = kes.NewClientWithConfig(server.URL, &tls.Config{
+		RootCAs:      server.CAs(),
+		Certificates: []tls.Certificate{cert},
+	})
+	server.Policy().Allow("enclave", "/v1/enclave/create/*", "/v1/enclave/delete/*", "/v1/enclave/list/*")
+	server.Policy().Assign("enclave", kestest.Identify(&cert))
+	if err := client.CreateEnclave(ctx, "tenant-1"); err != kes.ErrNotAllowed {
+		t.Fatalf("Creating an enclave as non-operator: got '%v' - want '%v'", err, kes.ErrNotAllowed)
+	}
+	if _, err := client.ListEnclaves(ctx, "*"); err != kes.ErrNotAllowed {
+		t.Fatalf("Listing enclaves as non-operator: got '%v' - want '%v'", err, kes.ErrNotAllowed)
+	}
+}
+
+func TestClientEnclave(t *testing.T) {
+	ctx, cancel := testingContext(t)
+	defer cancel()
+
+	server := kestest.NewServer()
+	defer server.Close()
+
+	const KeyName = "my-key"
+	enclave := server.Client().Enclave("")
+	if err := enclave.CreateKey(ctx, KeyName); err != nil {
+		t.Fatalf("Failed to create %q within the default enclave: %v", KeyName, err)
+	}
+	if _, err := server.Client().GenerateKey(ctx, KeyName, nil); err != nil {
+		t.Fatalf("Failed to generate DEK: %v", err)
+	}
+
+	enclave = server.Client().Enclave("tenant-1")
+	if err := enclave.CreateKey(ctx, KeyName); err != kes.ErrEnclaveNotFound {
+		t.Fatalf("Creating %q within a non-existing enclave: got '%v' - want '%v'", KeyName, err, kes.ErrEnclaveNotFound)
+	}
+	if _, err := enclave.GenerateKey(ctx, KeyName, nil); err != kes.ErrEnclaveNotFound {
+		t.Fatalf("Generating a DEK within a non-existing enclave: got '%v' - want '%v'", err, kes.ErrEnclaveNotFound)
+	}
+}
+
+func TestDescribeEnclave(t *testing.T) {
+	ctx, cancel := testingContext(t)
+	defer cancel()
+
+	server := kestest.NewServer()
+	defer server.Close()
+
+	client := server.Client()
+	for _, name := range []string{"my-key", "my-key2"} {
+		if err := client.CreateKey(ctx, name); err != nil {
+			t.Fatalf("Failed to create %q: %v", name, err)
+		}
+	}
+
+	info, err := client.DescribeEnclave(ctx, "")
+	if err != nil {
+		t.Fatalf("Failed to describe default enclave: %v", err)
+	}
+	if info.Name != "" {
+		t.Fatalf("Enclave name mismatch: got '%s' - want '%s'", info.Name, "")
+	}
+	if info.Sealed {
+		t.Fatal("Default enclave is sealed")
+	}
+	if info.StoreState != "available" {
+		t.Fatalf("Store state mismatch: got '%s' - want '%s'", info.StoreState, "available")
+	}
+	if info.KeyCount != 2 {
+		t.Fatalf("Key count mismatch: got '%d' - want '%d'", info.KeyCount, 2)
+	}
+	if _, err = client.DescribeEnclave(ctx, "tenant-1"); err != kes.ErrEnclaveNotFound {
+		t.Fatalf("Describing a non-existing enclave: got '%v' - want '%v'", err, kes.ErrEnclaveNotFound)
+	}
+
+	if err = client.Seal(ctx); err != nil {
+		t.Fatalf("Failed to seal vault: %v", err)
+	}
+	if info, err = client.DescribeEnclave(ctx, ""); err != nil {
+		t.Fatalf("Failed to describe default enclave of a sealed vault: %v", err)
+	}
+	if !info.Sealed {
+		t.Fatal("Default enclave of a sealed vault is not sealed")
+	}
+	if err = client.Unseal(ctx); err != nil {
+		t.Fatalf("Failed to unseal vault: %v", err)
+	}
+
+	cert := server.IssueClientCertificate("describe-enclave test")
+	client = kes.NewClientWithConfig(server.URL, &tls.Config{
+		RootCAs:      server.CAs(),
+		Certificates: []tls.Certificate{cert},
+	})
+	server.Policy().Allow("describe-enclave", "/v1/enclave/describe/*")
+	server.Policy().Assign("describe-enclave", kestest.Identify(&cert))
+	if _, err = client.DescribeEnclave(ctx, ""); err != kes.ErrNotAllowed {
+		t.Fatalf("Describing an enclave as non-operator: got '%v' - want '%v'", err, kes.ErrNotAllowed)
+	}
+}
+
+func isNotImplemented(err error) bool {
+	kesErr, ok := err.(kes.Error)
+	return ok && kesErr.Status() == http.StatusNotImplemented
+}
+
+func TestHealthStream(t *testing.T) {
+	ctx, cancel := testingContext(t)
+	defer cancel()
+
+	server := kestest.NewServer()
+	defer server.Close()
+
+	client := server.Client()
+	streamCtx, cancelStream := context.WithCancel(ctx)
+	defer cancelStream()
+
+	events, err := client.HealthStream(streamCtx)
+	if err != nil {
+		t.Fatalf("Failed to subscribe to health stream: %v", err)
+	}
+	if event := <-events; event.Err != nil || event.Sealed || event.StoreState != "available" {
+		t.Fatalf("Invalid initial health event: %+v", event)
+	}
+
+	if err = client.Seal(ctx); err != nil {
+		t.Fatalf("Failed to seal vault: %v", err)
+	}
+	if event := <-events; event.Err != nil || !event.Sealed {
+		t.Fatalf("Invalid health event after sealing: %+v", event)
+	}
+	if err = client.Unseal(ctx); err != nil {
+		t.Fatalf("Failed to unseal vault: %v", err)
+	}
+	if event := <-events; event.Err != nil || event.Sealed || event.StoreState != "available" {
+		t.Fatalf("Invalid health event after unsealing: %+v", event)
+	}
+
+	cancelStream()
+	for event := range events {
+		if event.Err != nil {
+			t.Fatalf("Canceling the health stream should not send an error event: %v", event.Err)
+		}
+	}
+}
+
+func TestSealUnseal(t *testing.T) {
+	ctx, cancel := testingContext(t)
+	defer cancel()
+
+	server := kestest.NewServer()
+	defer server.Close()
+
+	const KeyName = "my-key"
+	client := server.Client()
+	if err := client.CreateKey(ctx, KeyName); err != nil {
+		t.Fatalf("Failed to create %q: %v", KeyName, err)
+	}
+
+	cert := server.IssueClientCertificate("seal test")
+	other := kes.NewClientWithConfig(server.URL, &tls.Config{
+		RootCAs:      server.CAs(),
+		Certificates: []tls.Certificate{cert},
+	})
+	server.Policy().Allow("seal", "/v1/vault/seal", "/v1/vault/unseal")
+	server.Policy().Assign("seal", kestest.Identify(&cert))
+	if err := other.Seal(ctx); err != kes.ErrNotAllowed {
+		t.Fatalf("Sealing as non-operator: got '%v' - want '%v'", err, kes.ErrNotAllowed)
+	}
+
+	if err := client.Seal(ctx); err != nil {
+		t.Fatalf("Failed to seal vault: %v", err)
+	}
+	if err := client.Seal(ctx); err != kes.ErrSealed {
+		t.Fatalf("Sealing a sealed vault: got '%v' - want '%v'", err, kes.ErrSealed)
+	}
+	if _, err := client.GenerateKey(ctx, KeyName, nil); err != kes.ErrSealed {
+		t.Fatalf("Generating a DEK with a sealed vault: got '%v' - want '%v'", err, kes.ErrSealed)
+	}
+	if _, err := client.ListEnclaves(ctx, "*"); err != kes.ErrSealed {
+		t.Fatalf("Listing enclaves with a sealed vault: got '%v' - want '%v'", err, kes.ErrSealed)
+	}
+	status, err := client.VaultStatus(ctx)
+	if err != nil {
+		t.Fatalf("Failed to fetch vault status: %v", err)
+	}
+	if !status[""].Sealed {
+		t.Fatal("Vault status reports an unsealed enclave")
+	}
+	if err = other.Unseal(ctx); err != kes.ErrNotAllowed {
+		t.Fatalf("Unsealing as non-operator: got '%v' - want '%v'", err, kes.ErrNotAllowed)
+	}
+
+	if err = client.Unseal(ctx); err != nil {
+		t.Fatalf("Failed to unseal vault: %v", err)
+	}
+	if _, err = client.GenerateKey(ctx, KeyName, nil); err != nil {
+		t.Fatalf("Failed to generate DEK: %v", err)
+	}
+}
+
+func TestAuditLog(t *testing.T) {
+	ctx, cancel := testingContext(t)
+	defer cancel()
+
+	server := kestest.NewServer()
+	defer server.Close()
+
+	stream, err := server.Client().AuditLog(ctx)
+	if err != nil {
+		t.Fatalf("Failed to subscribe to the audit log: %v", err)
+	}
+	if err = stream.Close(); err != nil {
+		t.Fatalf("Failed to close audit log stream: %v", err)
+	}
+
+	cert := server.IssueClientCertificate("audit-log test")
+	client := kes.NewClientWithConfig(server.URL, &tls.Config{
+		RootCAs:      server.CAs(),
+		Certificates: []tls.Certificate{cert},
+	})
+	server.Policy().Allow("audit-log", "/v1/log/audit")
+	server.Policy().Assign("audit-log", kestest.Identify(&cert))
+	if _, err = client.AuditLog(ctx); err != kes.ErrNotAllowed {
+		t.Fatalf("Subscribing to the audit log as non-admin: got '%v' - want '%v'", err, kes.ErrNotAllowed)
+	}
+}
+
+func TestErrorLog(t *testing.T) {
+	ctx, cancel := testingContext(t)
+	defer cancel()
+
+	server := kestest.NewServer()
+	defer server.Close()
+
+	streamCtx, streamCancel := context.WithCancel(ctx)
+	stream, err := server.Client().ErrorLog(streamCtx)
+	if err != nil {
+		t.Fatalf("Failed to subscribe to the error log: %v", err)
+	}
+	streamCancel()
+	if stream.Next() {
+		t.Fatalf("Error log stream returned an event after the context has been canceled")
+	}
+	stream.Close()
+
+	cert := server.IssueClientCertificate("error-log test")
+	client := kes.NewClientWithConfig(server.URL, &tls.Config{
+		RootCAs:      server.CAs(),
+		Certificates: []tls.Certificate{cert},
+	})
+	server.Policy().Allow("error-log", "/v1/log/error")
+	server.Policy().Assign("error-log", kestest.Identify(&cert))
+	if _, err = client.ErrorLog(ctx); err != kes.ErrNotAllowed {
+		t.Fatalf("Subscribing to the error log as non-admin: got '%v' - want '%v'", err, kes.ErrNotAllowed)
+	}
+}
+
+func TestSetCertificate(t *testing.T) {
+	ctx, cancel := testingContext(t)
+	defer cancel()
+
+	server := kestest.NewServer()
+	defer server.Close()
+
+	oldCert := server.IssueClientCertificate("set-certificate test old")
+	newCert := server.IssueClientCertificate("set-certificate test new")
+	server.Policy().Allow("set-certificate", "/v1/key/create/*")
+	server.Policy().Assign("set-certificate", kestest.Identify(&newCert))
+
+	client := kes.NewClientWithConfig(server.URL, &tls.Config{
+		RootCAs:      server.CAs(),
+		Certificates: []tls.Certificate{oldCert},
+	})
+	if err := client.CreateKey(ctx, "my-key"); err != kes.ErrNotAllowed {
+		t.Fatalf("Creating key with old certificate: got '%v' - want '%v'", err, kes.ErrNotAllowed)
+	}
+
+	client.SetCertificate(newCert)
+	client.HTTPClient.CloseIdleConnections() // Force a new TLS handshake
+	if err := client.CreateKey(ctx, "my-key"); err != nil {
+		t.Fatalf("Failed to create key with new certificate: %v", err)
+	}
+}
+
+func TestWithIdentity(t *testing.T) {
+	ctx, cancel := testingContext(t)
+	defer cancel()
+
+	server := kestest.NewServer()
+	defer server.Close()
+
+	allowed := server.IssueClientCertificate("with-identity test allowed")
+	denied := server.IssueClientCertificate("with-identity test denied")
+	server.Policy().Allow("with-identity", "/v1/key/create/*")
+	server.Policy().Assign("with-identity", kestest.Identify(&allowed))
+
+	client := kes.NewClientWithConfig(server.URL, &tls.Config{
+		RootCAs:      server.CAs(),
+		Certificates: []tls.Certificate{allowed},
+	})
+	defer client.Close()
+
+	if err := client.CreateKey(ctx, "my-key-1"); err != nil {
+		t.Fatalf("Failed to create key: %v", err)
+	}
+	other, err := client.WithIdentity(denied)
+	if err != nil {
+		t.Fatalf("Failed to create client with other identity: %v", err)
+	}
+	defer other.Close()
+	if _, err = other.ServerCertificateFingerprint(); err == nil {
+		t.Fatal("New client shares the server certificate fingerprint with the base client")
+	}
+
+	if err = other.CreateKey(ctx, "my-key-2"); err != kes.ErrNotAllowed {
+		t.Fatalf("Creating key with other identity: got '%v' - want '%v'", err, kes.ErrNotAllowed)
+	}
+	if n := other.OpenConnections(); n != 1 {
+		t.Fatalf("Invalid number of open connections: got %d - want %d", n, 1)
+	}
+	if _, err = other.ServerCertificateFingerprint(); err != nil {
+		t.Fatalf("Failed to get server certificate fingerprint: %v", err)
+	}
+	if n := client.OpenConnections(); n != 1 {
+		t.Fatalf("Invalid number of open connections of base client: got %d - want %d", n, 1)
+	}
+	if err = client.CreateKey(ctx, "my-key-2"); err != nil {
+		t.Fatalf("Failed to create key with base client: %v", err)
+	}
+
+	if _, err = (&kes.Client{Endpoints: []string{server.URL}}).WithIdentity(denied); err == nil {
+		t.Fatal("Changing the identity of a client not created via NewClient should have failed")
+	}
+}
+
+func TestAssignPolicyWithTTL(t *testing.T) {
+	ctx, cancel := testingContext(t)
+	defer cancel()
+
+	server := kestest.NewServer()
+	defer server.Close()
+
+	const TTL = 500 * time.Millisecond
+	cert := server.IssueClientCertificate("assign-ttl test")
+	client := kes.NewClientWithConfig(server.URL, &tls.Config{
+		RootCAs:      server.CAs(),
+		Certificates: []tls.Certificate{cert},
+	})
+	if err := server.Client().SetPolicy(ctx, "assign-ttl", &kes.Policy{Allow: []string{"/v1/key/create/*"}}); err != nil {
+		t.Fatalf("Failed to create policy: %v", err)
+	}
+	if err := server.Client().AssignPolicyWithTTL(ctx, "assign-ttl", kestest.Identify(&cert), -TTL); err == nil {
+		t.Fatal("Assigning policy with a negative TTL should have failed")
+	}
+	if err := server.Client().AssignPolicyWithTTL(ctx, "assign-ttl", kestest.Identify(&cert), TTL); err != nil {
+		t.Fatalf("Failed to assign policy: %v", err)
+	}
+
+	info, err := server.Client().DescribeIdentity(ctx, kestest.Identify(&cert))
+	if err != nil {
+		t.Fatalf("Failed to describe identity: %v", err)
+	}
+	if info.ExpiresAt.IsZero() {
+		t.Fatal("Identity has no expiry")
+	}
+	if err = client.CreateKey(ctx, "my-key-1"); err != nil {
+		t.Fatalf("Failed to create key: %v", err)
+	}
+
+	time.Sleep(TTL)
+	if err = client.CreateKey(ctx, "my-key-2"); err != kes.ErrNotAllowed {
+		t.Fatalf("Creating key after the assignment expired: got '%v' - want '%v'", err, kes.ErrNotAllowed)
+	}
+	if _, err = server.Client().DescribeIdentity(ctx, kestest.Identify(&cert)); err == nil {
+		t.Fatal("Describing an expired identity should have failed")
+	}
+}
+
+func TestAssignPolicies(t *testing.T) {
+	ctx, cancel := testingContext(t)
+	defer cancel()
+
+	server := kestest.NewServer()
+	defer server.Close()
+
+	client := server.Client()
+	if err := client.SetPolicy(ctx, "bulk-assign", &kes.Policy{Allow: []string{"/v1/key/create/*"}}); err != nil {
+		t.Fatalf("Failed to create policy: %v", err)
+	}
+
+	cert1 := server.IssueClientCertificate("bulk-assign test 1")
+	cert2 := server.IssueClientCertificate("bulk-assign test 2")
+	identities := []kes.Identity{kestest.Identify(&cert1), kestest.Identify(&cert2)}
+	if err := client.AssignPolicies(ctx, "bulk-assign", identities); err != nil {
+		t.Fatalf("Failed to assign policy: %v", err)
+	}
+	for _, identity := range identities {
+		info, err := client.DescribeIdentity(ctx, identity)
+		if err != nil {
+			t.Fatalf("Failed to describe identity %q: %v", identity, err)
+		}
+		if info.Policy != "bulk-assign" {
+			t.Fatalf("Policy mismatch: got '%s' - want '%s'", info.Policy, "bulk-assign")
+		}
+	}
+
+	const InvalidIdentity = "invalid/identity"
+	err := client.AssignPolicies(ctx, "bulk-assign", []kes.Identity{InvalidIdentity, identities[0]})
+	assignErr, ok := err.(*kes.AssignPolicyError)
+	if !ok {
+		t.Fatalf("Invalid error type: got '%T' - want '%T'", err, assignErr)
+	}
+	if len(assignErr.Errors) != 1 {
+		t.Fatalf("Invalid number of errors: got '%d' - want '%d'", len(assignErr.Errors), 1)
+	}
+	if _, ok = assignErr.Errors[InvalidIdentity]; !ok {
+		t.Fatalf("No error for invalid identity %q", InvalidIdentity)
+	}
+}
+
+func TestDeriveKey(t *testing.T) {
+	ctx, cancel := testingContext(t)
+	defer cancel()
+
+	server := kestest.NewServer()
+	defer server.Close()
+
+	client := server.Client()
+	if err := client.CreateKey(ctx, "my-key"); err != nil {
+		t.Fatalf("Failed to create key: %v", err)
+	}
+	bucket1, err := client.DeriveKey(ctx, "my-key", []byte("bucket-1"), 32)
+	if err != nil {
+		t.Fatalf("Failed to derive key: %v", err)
+	}
+	if len(bucket1) != 32 {
+		t.Fatalf("Derived key length mismatch: got '%d' - want '%d'", len(bucket1), 32)
+	}
+	bucket2, err := client.DeriveKey(ctx, "my-key", []byte("bucket-2"), 32)
+	if err != nil {
+		t.Fatalf("Failed to derive key: %v", err)
+	}
+	if bytes.Equal(bucket1, bucket2) {
+		t.Fatal("Keys derived with different info are equal")
+	}
+	derived, err := client.DeriveKey(ctx, "my-key", []byte("bucket-1"), 32)
+	if err != nil {
+		t.Fatalf("Failed to derive key: %v", err)
+	}
+	if !bytes.Equal(bucket1, derived) {
+		t.Fatal("Keys derived with the same info are not equal")
+	}
+
+	if _, err = client.DeriveKey(ctx, "my-key", nil, 0); err == nil {
+		t.Fatal("Deriving a key with zero length should have failed")
+	}
+	if _, err = client.DeriveKey(ctx, "non-existing-key", nil, 32); err != kes.ErrKeyNotFound {
+		t.Fatalf("Deriving from non-existing key: got '%v' - want '%v'", err, kes.ErrKeyNotFound)
+	}
+}
+
+func TestKeyExists(t *testing.T) {
+	ctx, cancel := testingContext(t)
+	defer cancel()
+
+	server := kestest.NewServer()
+	defer server.Close()
+
+	client := server.Client()
+	if exists, err := client.KeyExists(ctx, "my-key"); err != nil || exists {
+		t.Fatalf("Checking non-existing key: got '%v' and '%v' - want 'false' and 'nil'", exists, err)
+	}
+	if err := client.CreateKey(ctx, "my-key"); err != nil {
+		t.Fatalf("Failed to create key: %v", err)
+	}
+	if exists, err := client.KeyExists(ctx, "my-key"); err != nil || !exists {
+		t.Fatalf("Checking existing key: got '%v' and '%v' - want 'true' and 'nil'", exists, err)
+	}
+
+	cert := server.IssueClientCertificate("key-exists test")
+	other := kes.NewClientWithConfig(server.URL, &tls.Config{
+		RootCAs:      server.CAs(),
+		Certificates: []tls.Certificate{cert},
+	})
+	if _, err := other.KeyExists(ctx, "my-key"); err != kes.ErrNotAllowed {
+		t.Fatalf("Checking key without permission: got '%v' - want '%v'", err, kes.ErrNotAllowed)
+	}
+}
+
+func TestCreateKeyWithAlgorithm(t *testing.T) {
+	ctx, cancel := testingContext(t)
+	defer cancel()
+
+	server := kestest.NewServer()
+	defer server.Close()
+
+	client := server.Client()
+	for _, algorithm := range []kes.KeyAlgorithm{kes.AES256, kes.ChaCha20Poly1305} {
+		name := "my-key-" + algorithm.String()
+		if err := client.CreateKeyWithAlgorithm(ctx, name, algorithm); err != nil {
+			t.Fatalf("Failed to create %s key: %v", algorithm, err)
+		}
+		info, err := client.DescribeKey(ctx, name)
+		if err != nil {
+			t.Fatalf("Failed to describe key: %v", err)
+		}
+		if info.Algorithm != algorithm {
+			t.Fatalf("Algorithm mismatch: got '%s' - want '%s'", info.Algorithm, algorithm)
+		}
+
+		plaintext := []byte("Hello World")
+		ciphertext, err := client.Encrypt(ctx, name, plaintext, nil)
+		if err != nil {
+			t.Fatalf("Failed to encrypt plaintext: %v", err)
+		}
+		decrypted, err := client.Decrypt(ctx, name, ciphertext, nil)
+		if err != nil {
+			t.Fatalf("Failed to decrypt ciphertext: %v", err)
+		}
+		if !bytes.Equal(plaintext, decrypted) {
+			t.Fatal("Decrypted plaintext does not match the original plaintext")
+		}
+	}
+	if err := client.CreateKeyWithAlgorithm(ctx, "my-key", "invalid-algorithm"); err == nil {
+		t.Fatal("Creating a key with an invalid algorithm should have failed")
+	}
+}
+
+func TestMetrics(t *testing.T) {
+	ctx, cancel := testingContext(t)
+	defer cancel()
+
+	server := kestest.NewServer()
+	defer server.Close()
+
+	if _, err := server.Client().Version(ctx); err != nil {
+		t.Fatalf("Failed to fetch server version: %v", err)
+	}
+	metric, err := server.Client().Metrics(ctx)
+	if err != nil {
+		t.Fatalf("Failed to fetch metrics: %v", err)
+	}
+	if metric.AuditEventsDelivered == 0 {
+		t.Fatal("Invalid metrics: got no delivered audit events")
+	}
+	if metric.AuditEventsDropped != 0 {
+		t.Fatalf("Invalid metrics: got %d dropped audit events - want 0", metric.AuditEventsDropped)
+	}
+	if metric.Connections == 0 {
+		t.Fatalf("Invalid metrics: got %d open connections - want at least one", metric.Connections)
+	}
+	if metric.Threads == 0 {
+		t.Fatal("Invalid metrics: got no threads")
+	}
+	if metric.HeapAlloc == 0 {
+		t.Fatal("Invalid metrics: got no heap memory allocations")
+	}
+}
+
+func TestExportKey(t *testing.T) {
+	ctx, cancel := testingContext(t)
+	defer cancel()
+
+	server := kestest.NewServer()
+	defer server.Close()
+
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	client := server.Client()
+	if err := client.ImportKey(ctx, "my-key", key); err != nil {
+		t.Fatalf("Failed to import key: %v", err)
+	}
+	exported, err := client.ExportKey(ctx, "my-key")
+	if err != nil {
+		t.Fatalf("Failed to export key: %v", err)
+	}
+	if !bytes.Equal(exported, key) {
+		t.Fatal("Exported key is not equal to imported key")
+	}
+	if err = client.ImportKey(ctx, "my-key-backup", exported); err != nil {
+		t.Fatalf("Failed to re-import exported key: %v", err)
+	}
+
+	cert := server.IssueClientCertificate("export-key test")
+	other := kes.NewClientWithConfig(server.URL, &tls.Config{
+		RootCAs:      server.CAs(),
+		Certificates: []tls.Certificate{cert},
+	})
+	server.Policy().Allow("wildcard-policy", "/v1/key/*/*")
+	server.Policy().Assign("wildcard-policy", kestest.Identify(&cert))
+	if _, err = other.ExportKey(ctx, "my-key"); err != kes.ErrNotAllowed {
+		t.Fatalf("Exporting key with wildcard policy: got '%v' - want '%v'", err, kes.ErrNotAllowed)
+	}
+
+	server.Policy().Allow("export-policy", "/v1/key/export/my-*")
+	server.Policy().Assign("export-policy", kestest.Identify(&cert))
+	if _, err = other.ExportKey(ctx, "my-key"); err != nil {
+		t.Fatalf("Failed to export key with explicit policy: %v", err)
+	}
+}
+
+func TestCopyKey(t *testing.T) {
+	ctx, cancel := testingContext(t)
+	defer cancel()
+
+	server := kestest.NewServer()
+	defer server.Close()
+
+	client := server.Client()
+	if err := client.CreateKey(ctx, "tenant-a"); err != nil {
+		t.Fatalf("Failed to create key: %v", err)
+	}
+	if _, err := client.GenerateKey(ctx, "tenant-a", nil); err != nil {
+		t.Fatalf("Failed to generate data key: %v", err)
+	}
+	if err := client.CopyKey(ctx, "tenant-a", "tenant-b"); err != nil {
+		t.Fatalf("Failed to copy key: %v", err)
+	}
+	info, err := client.DescribeKey(ctx, "tenant-b")
+	if err != nil {
+		t.Fatalf("Failed to describe copied key: %v", err)
+	}
+	if info.GenerateCount != 1 {
+		t.Fatalf("Generate count of copied key mismatch: got '%d' - want '%d'", info.GenerateCount, 1)
+	}
+	dek, err := client.GenerateKey(ctx, "tenant-a", nil)
+	if err != nil {
+		t.Fatalf("Failed to generate data key: %v", err)
+	}
+	plaintext, err := client.Decrypt(ctx, "tenant-b", dek.Ciphertext, nil)
+	if err != nil {
+		t.Fatalf("Failed to decrypt data key with copied key: %v", err)
+	}
+	if !bytes.Equal(plaintext, dek.Plaintext) {
+		t.Fatal("Decrypted plaintext does not match the original plaintext")
+	}
+
+	if err = client.CopyKey(ctx, "tenant-a", "tenant-b"); err != kes.ErrKeyExists {
+		t.Fatalf("Copying key to existing key: got '%v' - want '%v'", err, kes.ErrKeyExists)
+	}
+	if err = client.CopyKey(ctx, "tenant-c", "tenant-d"); err != kes.ErrKeyNotFound {
+		t.Fatalf("Copying non-existing key: got '%v' - want '%v'", err, kes.ErrKeyNotFound)
+	}
+
+	cert := server.IssueClientCertificate("copy-key test")
+	other := kes.NewClientWithConfig(server.URL, &tls.Config{
+		RootCAs:      server.CAs(),
+		Certificates: []tls.Certificate{cert},
+	})
+	server.Policy().Allow("copy-policy", "/v1/key/copy/*")
+	server.Policy().Assign("copy-policy", kestest.Identify(&cert))
+	if err = other.CopyKey(ctx, "tenant-a", "tenant-c"); err != kes.ErrNotAllowed {
+		t.Fatalf("Copying key as non-admin: got '%v' - want '%v'", err, kes.ErrNotAllowed)
+	}
+}
+
+func TestRenameKey(t *testing.T) {
+	ctx, cancel := testingContext(t)
+	defer cancel()
+
+	server := kestest.NewServer()
+	defer server.Close()
+
+	client := server.Client()
+	if err := client.CreateKey(ctx, "my-key"); err != nil {
+		t.Fatalf("Failed to create key: %v", err)
+	}
+	dek, err := client.GenerateKey(ctx, "my-key", nil)
+	if err != nil {
+		t.Fatalf("Failed to generate data key: %v", err)
+	}
+	before, err := client.DescribeKey(ctx, "my-key")
+	if err != nil {
+		t.Fatalf("Failed to describe key: %v", err)
+	}
+
+	if err = client.RenameKey(ctx, "my-key", "my-renamed-key"); err != nil {
+		t.Fatalf("Failed to rename key: %v", err)
+	}
+	if _, err = client.DescribeKey(ctx, "my-key"); err != kes.ErrKeyNotFound {
+		t.Fatalf("Describing renamed key: got '%v' - want '%v'", err, kes.ErrKeyNotFound)
+	}
+	after, err := client.DescribeKey(ctx, "my-renamed-key")
+	if err != nil {
+		t.Fatalf("Failed to describe key: %v", err)
+	}
+	if !after.CreatedAt.Equal(before.CreatedAt) || after.CreatedBy != before.CreatedBy {
+		t.Fatalf("Key metadata has not been preserved: got '%v' - want '%v'", after, before)
+	}
+	if after.GenerateCount != before.GenerateCount {
+		t.Fatalf("Key usage has not been preserved: got '%d' - want '%d'", after.GenerateCount, before.GenerateCount)
+	}
+	plaintext, err := client.Decrypt(ctx, "my-renamed-key", dek.Ciphertext, nil)
+	if err != nil {
+		t.Fatalf("Failed to decrypt data key with renamed key: %v", err)
+	}
+	if !bytes.Equal(plaintext, dek.Plaintext) {
+		t.Fatal("Decrypted plaintext does not match the original plaintext")
+	}
+
+	if err = client.CreateKey(ctx, "other-key"); err != nil {
+		t.Fatalf("Failed to create key: %v", err)
+	}
+	if err = client.RenameKey(ctx, "my-renamed-key", "other-key"); err != kes.ErrKeyExists {
+		t.Fatalf("Renaming key to existing key: got '%v' - want '%v'", err, kes.ErrKeyExists)
+	}
+	if err = client.RenameKey(ctx, "my-key", "new-key"); err != kes.ErrKeyNotFound {
+		t.Fatalf("Renaming non-existing key: got '%v' - want '%v'", err, kes.ErrKeyNotFound)
+	}
+
+	cert := server.IssueClientCertificate("rename-key test")
+	other := kes.NewClientWithConfig(server.URL, &tls.Config{
+		RootCAs:      server.CAs(),
+		Certificates: []tls.Certificate{cert},
+	})
+	server.Policy().Allow("rename-policy", "/v1/key/rename/*")
+	server.Policy().Assign("rename-policy", kestest.Identify(&cert))
+	if err = other.RenameKey(ctx, "other-key", "new-key"); err != kes.ErrNotAllowed {
+		t.Fatalf("Renaming key as non-admin: got '%v' - want '%v'", err, kes.ErrNotAllowed)
+	}
+}
+
+func TestGenerateAttestedKey(t *testing.T) {
+	ctx, cancel := testingContext(t)
+	defer cancel()
+
+	server := kestest.NewServer()
+	defer server.Close()
+
+	client := server.Client()
+	if err := client.CreateKey(ctx, "my-key"); err != nil {
+		t.Fatalf("Failed to create key: %v", err)
+	}
+	dek, attestation, err := client.GenerateAttestedKey(ctx, "my-key", nil)
+	if err != nil {
+		t.Fatalf("Failed to generate attested key: %v", err)
+	}
+	if attestation.Time.IsZero() {
+		t.Fatal("Attestation time is zero")
+	}
+
+	cert := server.IssueClientCertificate("attestation test")
+	conn, err := tls.Dial("tcp", strings.TrimPrefix(server.URL, "https://"), &tls.Config{
+		RootCAs:      server.CAs(),
+		Certificates: []tls.Certificate{cert},
+	})
+	if err != nil {
+		t.Fatalf("Failed to connect to server: %v", err)
+	}
+	publicKey := conn.ConnectionState().PeerCertificates[0].PublicKey
+	conn.Close()
+
+	if err = kes.VerifyAttestation(publicKey, "my-key", dek.Ciphertext, attestation); err != nil {
+		t.Fatalf("Failed to verify attestation: %v", err)
+	}
+	if err = kes.VerifyAttestation(publicKey, "other-key", dek.Ciphertext, attestation); err != kes.ErrInvalidAttestation {
+		t.Fatalf("Verifying attestation for wrong key: got '%v' - want '%v'", err, kes.ErrInvalidAttestation)
+	}
+	attestation.Time = attestation.Time.Add(time.Second)
+	if err = kes.VerifyAttestation(publicKey, "my-key", dek.Ciphertext, attestation); err != kes.ErrInvalidAttestation {
+		t.Fatalf("Verifying attestation with modified time: got '%v' - want '%v'", err, kes.ErrInvalidAttestation)
+	}
+}
+
+func TestGenerateKeyWithNonce(t *testing.T) {
+	ctx, cancel := testingContext(t)
+	defer cancel()
+
+	server := kestest.NewServer()
+	defer server.Close()
+
+	client := server.Client()
+	if err := client.CreateKey(ctx, "my-key"); err != nil {
+		t.Fatalf("Failed to create key: %v", err)
+	}
+	nonce := []byte("my-nonce")
+	dek, err := client.GenerateKeyWithNonce(ctx, "my-key", nil, nonce)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	retry, err := client.GenerateKeyWithNonce(ctx, "my-key", nil, nonce)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	if !bytes.Equal(dek.Plaintext, retry.Plaintext) || !bytes.Equal(dek.Ciphertext, retry.Ciphertext) {
+		t.Fatal("Retried request with the same nonce returned a different DEK")
+	}
+	if info, err := client.DescribeKey(ctx, "my-key"); err != nil || info.GenerateCount != 1 {
+		t.Fatalf("Invalid generate count: got '%d' and '%v' - want '1' and 'nil'", info.GenerateCount, err)
+	}
+
+	other, err := client.GenerateKeyWithNonce(ctx, "my-key", []byte("context"), nonce)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	if bytes.Equal(dek.Plaintext, other.Plaintext) {
+		t.Fatal("Request with the same nonce but a different context returned the same DEK")
+	}
+	other, err = client.GenerateKeyWithNonce(ctx, "my-key", nil, []byte("other-nonce"))
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	if bytes.Equal(dek.Plaintext, other.Plaintext) {
+		t.Fatal("Request with a different nonce returned the same DEK")
+	}
+	if _, err = client.GenerateKeyWithNonce(ctx, "my-key", nil, make([]byte, 257)); err == nil {
+		t.Fatal("Generated key with a nonce larger than 256 bytes")
+	}
+
+	// Another identity must not obtain the DEK by sending
+	// the same nonce.
+	cert := server.IssueClientCertificate("nonce test")
+	client = kes.NewClientWithConfig(server.URL, &tls.Config{
+		RootCAs:      server.CAs(),
+		Certificates: []tls.Certificate{cert},
+	})
+	server.Policy().Allow("nonce", "/v1/key/generate/my-key")
+	server.Policy().Assign("nonce", kestest.Identify(&cert))
+	other, err = client.GenerateKeyWithNonce(ctx, "my-key", nil, nonce)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	if bytes.Equal(dek.Plaintext, other.Plaintext) {
+		t.Fatal("Request of another identity with the same nonce returned the same DEK")
+	}
+}
+
+func TestFailNext(t *testing.T) {
+	ctx, cancel := testingContext(t)
+	defer cancel()
+
+	server := kestest.NewServer()
+	defer server.Close()
+
+	client := server.Client()
+	server.FailNext(1, http.StatusServiceUnavailable)
+	if _, err := client.Version(ctx); err != nil {
+		t.Fatalf("Client did not retry request: %v", err)
+	}
+
+	server.FailNext(1, http.StatusInternalServerError)
+	_, err := client.Version(ctx)
+	if kesErr, ok := err.(kes.Error); !ok || kesErr.Status() != http.StatusInternalServerError {
+		t.Fatalf("Invalid error: got '%v' - want status '%d'", err, http.StatusInternalServerError)
+	}
+	if _, err = client.Version(ctx); err != nil {
+		t.Fatalf("Failed to fetch server version: %v", err)
+	}
+}
+
+func TestClientHooks(t *testing.T) {
+	ctx, cancel := testingContext(t)
+	defer cancel()
+
+	server := kestest.NewServer()
+	defer server.Close()
+
+	var (
+		requests  []string
+		responses []int
+	)
+	client := server.Client()
+	client.OnRequest(func(req *http.Request) {
+		req.Header.Set("X-Tenant", "my-tenant")
+		requests = append(requests, req.URL.Path)
+	})
+	client.OnResponse(func(resp *http.Response) {
+		if resp.Request.Header.Get("X-Tenant") != "my-tenant" {
+			t.Errorf("Response hook: request header has not been set by request hook")
+		}
+		responses = append(responses, resp.StatusCode)
+	})
+
+	if _, err := client.Version(ctx); err != nil {
+		t.Fatalf("Failed to fetch server version: %v", err)
+	}
+	if _, err := client.DescribeKey(ctx, "my-key"); err != kes.ErrKeyNotFound {
+		t.Fatalf("Describing non-existing key: got '%v' - want '%v'", err, kes.ErrKeyNotFound)
+	}
+	if len(requests) != 2 || requests[0] != "/version" || requests[1] != "/v1/key/describe/my-key" {
+		t.Fatalf("Request hook: got requests %v", requests)
+	}
+	if len(responses) != 2 || responses[0] != http.StatusOK || responses[1] != http.StatusNotFound {
+		t.Fatalf("Response hook: got responses %v", responses)
+	}
+}
+
+func TestPing(t *testing.T) {
+	ctx, cancel := testingContext(t)
+	defer cancel()
+
+	server := kestest.NewServer()
+	defer server.Close()
+
+	rtt, err := server.Client().Ping(ctx)
+	if err != nil {
+		t.Fatalf("Failed to ping server: %v", err)
+	}
+	if rtt <= 0 {
+		t.Fatalf("Invalid round-trip time: got '%v' - want a positive duration", rtt)
+	}
+
+	server.FailNext(1, http.StatusInternalServerError)
+	if _, err = server.Client().Ping(ctx); err == nil {
+		t.Fatal("Ping should fail when the server responds with an error")
+	}
+}
+
+func TestWaitReady(t *testing.T) {
+	ctx, cancel := testingContext(t)
+	defer cancel()
+
+	server := kestest.NewServer()
+	defer server.Close()
+
+	server.FailNext(3, http.StatusInternalServerError)
+	if err := server.Client().WaitReady(ctx, 10*time.Millisecond); err != nil {
+		t.Fatalf("Failed to wait for server: %v", err)
+	}
+
+	server.FailNext(1000, http.StatusInternalServerError)
+	timeoutCtx, cancelTimeout := context.WithTimeout(ctx, 100*time.Millisecond)
+	defer cancelTimeout()
+	if err := server.Client().WaitReady(timeoutCtx, 10*time.Millisecond); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Invalid error: got '%v' - want '%v'", err, context.DeadlineExceeded)
+	}
+}
+
+func TestServerCertificateFingerprint(t *testing.T) {
+	ctx, cancel := testingContext(t)
+	defer cancel()
+
+	server := kestest.NewServer()
+	defer server.Close()
+
+	client := server.Client()
+	if _, err := client.ServerCertificateFingerprint(); err == nil {
+		t.Fatal("Fingerprint should not be available before connecting to the server")
+	}
+	if _, err := client.Ping(ctx); err != nil {
+		t.Fatalf("Failed to ping server: %v", err)
+	}
+	fingerprint, err := client.ServerCertificateFingerprint()
+	if err != nil {
+		t.Fatalf("Failed to get server certificate fingerprint: %v", err)
+	}
+
+	conn, err := tls.Dial("tcp", strings.TrimPrefix(server.URL, "https://"), &tls.Config{RootCAs: server.CAs()})
+	if err != nil {
+		t.Fatalf("Failed to connect to server: %v", err)
+	}
+	sum := sha256.Sum256(conn.ConnectionState().PeerCertificates[0].Raw)
+	conn.Close()
+
+	if want := hex.EncodeToString(sum[:]); fingerprint != want {
+		t.Fatalf("Fingerprint mismatch: got '%s' - want '%s'", fingerprint, want)
+	}
+}
+
+func TestListKeysSorted(t *testing.T) {
+	ctx, cancel := testingContext(t)
+	defer cancel()
+
+	server := kestest.NewServer()
+	defer server.Close()
+
+	client := server.Client()
+	names := []string{"key-c", "key-a", "key-b"}
+	for _, name := range names {
+		if err := client.CreateKey(ctx, name); err != nil {
+			t.Fatalf("Failed to create key '%s': %v", name, err)
+		}
+		time.Sleep(10 * time.Millisecond) // Ensure distinct creation times
+	}
+
+	for _, test := range []struct {
+		Order kes.KeyOrder
+		Names []string
+	}{
+		{Order: kes.SortByName, Names: []string{"key-a", "key-b", "key-c"}},
+		{Order: kes.SortByCreatedAt, Names: names},
+	} {
+		iterator, err := client.ListKeysSorted(ctx, "*", test.Order)
+		if err != nil {
+			t.Fatalf("Failed to list keys ordered by '%s': %v", test.Order, err)
+		}
+		var listed []string
+		for iterator.Next() {
+			listed = append(listed, iterator.Name())
+		}
+		if err = iterator.Close(); err != nil {
+			t.Fatalf("Failed to list keys ordered by '%s': %v", test.Order, err)
+		}
+		if strings.Join(listed, ",") != strings.Join(test.Names, ",") {
+			t.Fatalf("Invalid key order '%s': got %v - want %v", test.Order, listed, test.Names)
+		}
+	}
+
+	if _, err := client.ListKeysSorted(ctx, "*", "size"); err == nil {
+		t.Fatal("Listing keys with an invalid order should fail")
+	}
+}
+
+func TestListKeysCreatedRange(t *testing.T) {
+	ctx, cancel := testingContext(t)
+	defer cancel()
+
+	server := kestest.NewServer()
+	defer server.Close()
+
+	client := server.Client()
+	names := []string{"key-a", "key-b", "key-c"}
+	createdAt := make([]time.Time, 0, len(names))
+	for _, name := range names {
+		if err := client.CreateKey(ctx, name); err != nil {
+			t.Fatalf("Failed to create key '%s': %v", name, err)
+		}
+		info, err := client.DescribeKey(ctx, name)
+		if err != nil {
+			t.Fatalf("Failed to describe key '%s': %v", name, err)
+		}
+		createdAt = append(createdAt, info.CreatedAt)
+		time.Sleep(10 * time.Millisecond) // Ensure distinct creation times
+	}
+
+	for i, test := range []struct {
+		Options kes.ListKeysOptions
+		Names   []string
+	}{
+		{ // 0
+			Options: kes.ListKeysOptions{Order: kes.SortByName},
+			Names:   names,
+		},
+		{ // 1
+			Options: kes.ListKeysOptions{Order: kes.SortByName, CreatedAfter: createdAt[0]},
+			Names:   []string{"key-b", "key-c"},
+		},
+		{ // 2
+			Options: kes.ListKeysOptions{Order: kes.SortByName, CreatedBefore: createdAt[2]},
+			Names:   []string{"key-a", "key-b"},
+		},
+		{ // 3
+			Options: kes.ListKeysOptions{CreatedAfter: createdAt[0], CreatedBefore: createdAt[2]},
+			Names:   []string{"key-b"},
+		},
+		{ // 4
+			Options: kes.ListKeysOptions{CreatedAfter: createdAt[2]},
+			Names:   nil,
+		},
+	} {
+		iterator, err := client.ListKeysWithOptions(ctx, "*", test.Options)
+		if err != nil {
+			t.Fatalf("Test %d: failed to list keys: %v", i, err)
+		}
+		var listed []string
+		for iterator.Next() {
+			listed = append(listed, iterator.Name())
+		}
+		if err = iterator.Close(); err != nil {
+			t.Fatalf("Test %d: failed to list keys: %v", i, err)
+		}
+		if strings.Join(listed, ",") != strings.Join(test.Names, ",") {
+			t.Fatalf("Test %d: invalid keys: got %v - want %v", i, listed, test.Names)
+		}
+	}
+}
+
+func TestSetIdentityAlias(t *testing.T) {
+	ctx, cancel := testingContext(t)
+	defer cancel()
+
+	server := kestest.NewServer()
+	defer server.Close()
+
+	client := server.Client()
+	if err := client.SetPolicy(ctx, "alias", &kes.Policy{Allow: []string{"/v1/key/create/*"}}); err != nil {
+		t.Fatalf("Failed to create policy: %v", err)
+	}
+	cert1 := server.IssueClientCertificate("alias test 1")
+	cert2 := server.IssueClientCertificate("alias test 2")
+	identity1, identity2 := kestest.Identify(&cert1), kestest.Identify(&cert2)
+	if err := client.AssignPolicies(ctx, "alias", []kes.Identity{identity1, identity2}); err != nil {
+		t.Fatalf("Failed to assign policy: %v", err)
+	}
+
+	if err := client.SetIdentityAlias(ctx, identity1, "my-app"); err != nil {
+		t.Fatalf("Failed to set alias: %v", err)
+	}
+	info, err := client.DescribeIdentity(ctx, identity1)
+	if err != nil {
+		t.Fatalf("Failed to describe identity: %v", err)
+	}
+	if info.Alias != "my-app" {
+		t.Fatalf("Alias mismatch: got '%s' - want '%s'", info.Alias, "my-app")
+	}
+
+	identities, err := client.ListIdentities(ctx, "*")
+	if err != nil {
+		t.Fatalf("Failed to list identities: %v", err)
+	}
+	var found bool
+	for identities.Next() {
+		if identities.Identity() == identity1 {
+			found = identities.Alias() == "my-app"
+		}
+	}
+	if err = identities.Close(); err != nil {
+		t.Fatalf("Failed to list identities: %v", err)
+	}
+	if !found {
+		t.Fatalf("Identity %q has not been listed with its alias", identity1)
+	}
+
+	if err = client.SetIdentityAlias(ctx, identity2, "my-app"); !errors.Is(err, kes.ErrAliasExists) {
+		t.Fatalf("Setting a duplicate alias should have failed: got '%v' - want '%v'", err, kes.ErrAliasExists)
+	}
+	if err = client.SetIdentityAlias(ctx, identity2, "my app"); err == nil {
+		t.Fatal("Setting an invalid alias should have failed")
+	}
+	if err = client.SetIdentityAlias(ctx, "unknown", "unknown-app"); err == nil {
+		t.Fatal("Setting an alias of an unknown identity should have failed")
+	}
+
+	// Once the alias has been removed, it can be used by another identity.
+	if err = client.SetIdentityAlias(ctx, identity1, ""); err != nil {
+		t.Fatalf("Failed to remove alias: %v", err)
+	}
+	if err = client.SetIdentityAlias(ctx, identity2, "my-app"); err != nil {
+		t.Fatalf("Failed to set alias: %v", err)
+	}
+	if info, err = client.DescribeIdentity(ctx, identity1); err != nil {
+		t.Fatalf("Failed to describe identity: %v", err)
+	}
+	if info.Alias != "" {
+		t.Fatalf("Alias has not been removed: got '%s'", info.Alias)
+	}
+}
+
+func TestDeleteIdentitiesByPolicy(t *testing.T) {
+	ctx, cancel := testingContext(t)
+	defer cancel()
+
+	server := kestest.NewServer()
+	defer server.Close()
+
+	client := server.Client()
+	for _, policy := range []string{"tenant-1", "tenant-2"} {
+		if err := client.SetPolicy(ctx, policy, &kes.Policy{Allow: []string{"/v1/identity/delete-by-policy/*"}}); err != nil {
+			t.Fatalf("Failed to create policy: %v", err)
+		}
+	}
+	cert1 := server.IssueClientCertificate("delete-by-policy test 1")
+	cert2 := server.IssueClientCertificate("delete-by-policy test 2")
+	cert3 := server.IssueClientCertificate("delete-by-policy test 3")
+	tenant1 := []kes.Identity{kestest.Identify(&cert1), kestest.Identify(&cert2)}
+	tenant2 := kestest.Identify(&cert3)
+	if err := client.AssignPolicies(ctx, "tenant-1", tenant1); err != nil {
+		t.Fatalf("Failed to assign policy: %v", err)
+	}
+	if err := client.AssignPolicy(ctx, "tenant-2", tenant2); err != nil {
+		t.Fatalf("Failed to assign policy: %v", err)
+	}
+
+	// Only the admin can delete identities by policy.
+	tenantClient := kes.NewClientWithConfig(server.URL, &tls.Config{
+		RootCAs:      server.CAs(),
+		Certificates: []tls.Certificate{cert3},
+	})
+	if _, err := tenantClient.DeleteIdentitiesByPolicy(ctx, "tenant-1"); !errors.Is(err, kes.ErrNotAllowed) {
+		t.Fatalf("Deleting identities should have failed: got '%v' - want '%v'", err, kes.ErrNotAllowed)
+	}
+
+	n, err := client.DeleteIdentitiesByPolicy(ctx, "tenant-1")
+	if err != nil {
+		t.Fatalf("Failed to delete identities: %v", err)
+	}
+	if n != len(tenant1) {
+		t.Fatalf("Invalid number of deleted identities: got '%d' - want '%d'", n, len(tenant1))
+	}
+	for _, identity := range tenant1 {
+		if _, err = client.DescribeIdentity(ctx, identity); err == nil {
+			t.Fatalf("Identity %q has not been deleted", identity)
+		}
+	}
+	if _, err = client.DescribeIdentity(ctx, tenant2); err != nil {
+		t.Fatalf("Identity %q should not have been deleted: %v", tenant2, err)
+	}
+	if _, _, err = client.DescribeSelf(ctx); err != nil {
+		t.Fatalf("Admin identity should not have been deleted: %v", err)
+	}
+
+	if n, err = client.DeleteIdentitiesByPolicy(ctx, "tenant-1"); err != nil {
+		t.Fatalf("Failed to delete identities: %v", err)
+	}
+	if n != 0 {
+		t.Fatalf("Invalid number of deleted identities: got '%d' - want '%d'", n, 0)
+	}
+
+	// Identities of a policy named "delete" must be listed
+	// instead of being mistaken for a bulk deletion.
+	if err = client.SetPolicy(ctx, "delete", &kes.Policy{}); err != nil {
+		t.Fatalf("Failed to create policy: %v", err)
+	}
+	if err = client.AssignPolicy(ctx, "delete", tenant2); err != nil {
+		t.Fatalf("Failed to assign policy: %v", err)
+	}
+	iterator, err := client.ListPolicyIdentities(ctx, "delete")
+	if err != nil {
+		t.Fatalf("Failed to list identities of policy 'delete': %v", err)
+	}
+	var identities []kes.Identity
+	for iterator.Next() {
+		identities = append(identities, iterator.Identity())
+	}
+	if err = iterator.Close(); err != nil {
+		t.Fatalf("Failed to list identities of policy 'delete': %v", err)
+	}
+	if len(identities) != 1 || identities[0] != tenant2 {
+		t.Fatalf("Identity mismatch: got '%v' - want '%v'", identities, []kes.Identity{tenant2})
+	}
+}
+
+
+func TestDeleteIdentitiesByPolicyExpired(t *testing.T) {
+	ctx, cancel := testingContext(t)
+	defer cancel()
+
+	server := kestest.NewServer()
+	defer server.Close()
+
+	const TTL = 200 * time.Millisecond
+	client := server.Client()
+	if err := client.SetPolicy(ctx, "tenant-1", &kes.Policy{Allow: []string{"/v1/key/create/*"}}); err != nil {
+		t.Fatalf("Failed to create policy: %v", err)
+	}
+	cert := server.IssueClientCertificate("delete-by-policy expired test")
+	if err := client.AssignPolicyWithTTL(ctx, "tenant-1", kestest.Identify(&cert), TTL); err != nil {
+		t.Fatalf("Failed to assign policy: %v", err)
+	}
+	time.Sleep(2 * TTL)
 
+	// An identity whose assignment has expired is still
+	// assigned to the policy and must be deleted.
+	n, err := client.DeleteIdentitiesByPolicy(ctx, "tenant-1")
+	if err != nil {
+		t.Fatalf("Failed to delete identities: %v", err)
+	}
+	if n != 1 {
+		t.Fatalf("Invalid number of deleted identities: got '%d' - want '%d'", n, 1)
+	}
+	if n, err = client.DeleteIdentitiesByPolicy(ctx, "tenant-1"); err != nil || n != 0 {
+		t.Fatalf("Expired identity has not been deleted: got '%d' and '%v' - want '%d' and 'nil'", n, err, 0)
+	}
+}
 func TestSelfDescribe(t *testing.T) {
 	ctx, cancel := testingContext(t)
 	defer cancel()
@@ -518,6 +2591,177 @@ func TestSelfDescribe(t *testing.T) {
 	}
 }
 
+func TestSelfDescribeIdentity(t *testing.T) {
+	ctx, cancel := testingContext(t)
+	defer cancel()
+
+	server := kestest.NewServer()
+	defer server.Close()
+
+	info, _, err := server.Client().DescribeSelf(ctx)
+	if err != nil {
+		t.Fatalf("Failed to self-describe client: %v", err)
+	}
+	if !info.IsAdmin || info.Policy != "" {
+		t.Fatalf("Admin identity is bound to a policy: got '%s'", info.Policy)
+	}
+	if len(info.Allow) != 0 || len(info.Deny) != 0 {
+		t.Fatalf("Admin identity has policy rules: allow '%v' - deny '%v'", info.Allow, info.Deny)
+	}
+
+	for i, test := range selfDescribeTests {
+		cert := server.IssueClientCertificate("self-describe test")
+		client := kes.NewClientWithConfig(server.URL, &tls.Config{
+			RootCAs:      server.CAs(),
+			Certificates: []tls.Certificate{cert},
+		})
+		policyName := "Test-" + strconv.Itoa(i)
+		server.Policy().Add(policyName, &test.Policy)
+		server.Policy().Assign(policyName, kestest.Identify(&cert))
+
+		info, _, err = client.DescribeSelf(ctx)
+		if err != nil {
+			t.Fatalf("Test %d: failed to self-describe client: %v", i, err)
+		}
+		if info.IsAdmin {
+			t.Fatalf("Test %d: identity has admin privileges", i)
+		}
+		if info.Policy != policyName {
+			t.Fatalf("Test %d: policy name mismatch: got '%s' - want '%s'", i, info.Policy, policyName)
+		}
+		if !equal(info.Allow, test.Policy.Allow) {
+			t.Fatalf("Test %d: allow policy mismatch: got '%v' - want '%v'", i, info.Allow, test.Policy.Allow)
+		}
+		if !equal(info.Deny, test.Policy.Deny) {
+			t.Fatalf("Test %d: deny policy mismatch: got '%v' - want '%v'", i, info.Deny, test.Policy.Deny)
+		}
+	}
+}
+
+func TestRateLimit(t *testing.T) {
+	ctx, cancel := testingContext(t)
+	defer cancel()
+
+	server := kestest.NewServer()
+	defer server.Close()
+
+	const KeyName = "my-key"
+	if err := server.Client().CreateKey(ctx, KeyName); err != nil {
+		t.Fatalf("Failed to create key '%s': %v", KeyName, err)
+	}
+
+	cert := server.IssueClientCertificate("rate-limit test")
+	client := kes.NewClientWithConfig(server.URL, &tls.Config{
+		RootCAs:      server.CAs(),
+		Certificates: []tls.Certificate{cert},
+	})
+	server.Policy().Add("rate-limit", &kes.Policy{
+		Allow:     []string{"/v1/key/generate/*"},
+		RateLimit: map[string]float64{"/v1/key/generate/*": 1},
+	})
+	server.Policy().Assign("rate-limit", kestest.Identify(&cert))
+
+	if _, err := client.GenerateKey(ctx, KeyName, nil); err != nil {
+		t.Fatalf("Failed to generate DEK: %v", err)
+	}
+	_, err := client.GenerateKey(ctx, KeyName, nil)
+	if !errors.Is(err, kes.ErrRateLimited) {
+		t.Fatalf("Request should have been rate limited: got '%v'", err)
+	}
+	var rateLimitErr *kes.RateLimitError
+	if !errors.As(err, &rateLimitErr) || rateLimitErr.Status() != http.StatusTooManyRequests {
+		t.Fatalf("Request should have failed with a RateLimitError: got '%v'", err)
+	}
+	if rateLimitErr.RetryAfter() <= 0 {
+		t.Fatalf("Rate limit error should specify a retry-after duration: got '%v'", rateLimitErr.RetryAfter())
+	}
+
+	// The admin is not subject to any rate limit.
+	for i := 0; i < 3; i++ {
+		if _, err = server.Client().GenerateKey(ctx, KeyName, nil); err != nil {
+			t.Fatalf("Failed to generate DEK as admin: %v", err)
+		}
+	}
+}
+
+func TestAllowWindow(t *testing.T) {
+	ctx, cancel := testingContext(t)
+	defer cancel()
+
+	server := kestest.NewServer()
+	defer server.Close()
+
+	const KeyName = "my-key"
+	if err := server.Client().CreateKey(ctx, KeyName); err != nil {
+		t.Fatalf("Failed to create key '%s': %v", KeyName, err)
+	}
+
+	cert := server.IssueClientCertificate("allow-window test")
+	client := kes.NewClientWithConfig(server.URL, &tls.Config{
+		RootCAs:      server.CAs(),
+		Certificates: []tls.Certificate{cert},
+	})
+	server.Policy().Add("allow-window", &kes.Policy{
+		Allow:       []string{"/v1/key/generate/*"},
+		AllowWindow: &kes.TimeWindow{Start: "00:00", End: "24:00"},
+	})
+	server.Policy().Assign("allow-window", kestest.Identify(&cert))
+	if _, err := client.GenerateKey(ctx, KeyName, nil); err != nil {
+		t.Fatalf("Failed to generate DEK within allow window: %v", err)
+	}
+
+	// Exclude today and tomorrow such that the test does
+	// not fail when running around midnight.
+	var (
+		days  []string
+		today = time.Now().UTC().Weekday()
+	)
+	for day := time.Sunday; day <= time.Saturday; day++ {
+		if day != today && day != (today+1)%7 {
+			days = append(days, day.String())
+		}
+	}
+	server.Policy().Add("allow-window", &kes.Policy{
+		Allow:       []string{"/v1/key/generate/*"},
+		AllowWindow: &kes.TimeWindow{Days: days},
+	})
+	if _, err := client.GenerateKey(ctx, KeyName, nil); err != kes.ErrNotAllowed {
+		t.Fatalf("Generating DEK outside allow window: got '%v' - want '%v'", err, kes.ErrNotAllowed)
+	}
+
+	// The admin is not subject to any allow window.
+	if _, err := server.Client().GenerateKey(ctx, KeyName, nil); err != nil {
+		t.Fatalf("Failed to generate DEK as admin: %v", err)
+	}
+
+	window := &kes.TimeWindow{Days: []string{"Mon", "Fri"}, Start: "08:00", End: "18:00", Location: "Europe/Berlin"}
+	if err := server.Client().SetPolicy(ctx, "business-hours", &kes.Policy{AllowWindow: window}); err != nil {
+		t.Fatalf("Failed to create policy: %v", err)
+	}
+	policy, err := server.Client().GetPolicy(ctx, "business-hours")
+	if err != nil {
+		t.Fatalf("Failed to fetch policy: %v", err)
+	}
+	if policy.AllowWindow == nil || policy.AllowWindow.Start != window.Start || policy.AllowWindow.Location != window.Location {
+		t.Fatalf("Invalid allow window: got '%v' - want '%v'", policy.AllowWindow, window)
+	}
+
+	// A policy without an allow window inherits the one of its parent.
+	if err = server.Client().SetPolicy(ctx, "business-child", &kes.Policy{Extends: []string{"business-hours"}}); err != nil {
+		t.Fatalf("Failed to create policy: %v", err)
+	}
+	info, err := server.Client().DescribePolicy(ctx, "business-child")
+	if err != nil {
+		t.Fatalf("Failed to describe policy: %v", err)
+	}
+	if info.AllowWindow == nil || info.AllowWindow.Start != window.Start || info.AllowWindow.Location != window.Location {
+		t.Fatalf("Invalid effective allow window: got '%v' - want '%v'", info.AllowWindow, window)
+	}
+	if err = server.Client().SetPolicy(ctx, "invalid", &kes.Policy{AllowWindow: &kes.TimeWindow{Start: "8am"}}); err == nil {
+		t.Fatal("Creating policy with an invalid allow window should have failed")
+	}
+}
+
 func testingContext(t *testing.T) (context.Context, context.CancelFunc) {
 	deadline, ok := t.Deadline()
 	if ok {
@@ -5,6 +5,7 @@
 package kes
 
 import (
+	"encoding/base64"
 	"encoding/json"
 	"errors"
 	"io"
@@ -22,11 +23,34 @@ import (
 // safe to store the DEK's ciphertext representation next
 // to the encrypted data. The ciphertext representation
 // does not need to stay secret.
+//
+// The String representation of a DEK does not contain
+// the plaintext. Hence, a DEK can be logged or printed
+// without leaking the plaintext by accident.
 type DEK struct {
 	Plaintext  []byte
 	Ciphertext []byte
 }
 
+// Zero overwrites the plaintext with zeros and removes
+// it from the DEK. It should be called once the plaintext
+// is no longer needed.
+func (d *DEK) Zero() {
+	Wipe(d.Plaintext)
+	d.Plaintext = nil
+}
+
+// String returns a string representation of the DEK
+// that contains the ciphertext but not the plaintext.
+func (d DEK) String() string {
+	return "DEK{Plaintext: <redacted>, Ciphertext: " + base64.StdEncoding.EncodeToString(d.Ciphertext) + "}"
+}
+
+// GoString returns the same redacted representation as
+// String such that the %#v format verb does not print
+// the plaintext.
+func (d DEK) GoString() string { return "kes." + d.String() }
+
 // CCP is a structure wrapping a ciphertext / decryption context
 // pair.
 //
@@ -47,30 +71,115 @@ type PCP struct {
 	Context   []byte
 }
 
+// KeyAlgorithm is the cryptographic algorithm a KES server
+// uses when encrypting or decrypting data with a key.
+type KeyAlgorithm string
+
+// Supported key algorithms.
+const (
+	// AES256 uses HMAC-SHA256 for key derivation and
+	// AES-256-GCM for encryption and decryption.
+	AES256 KeyAlgorithm = "AES256-GCM_SHA256"
+
+	// ChaCha20Poly1305 uses HChaCha20 for key derivation
+	// and ChaCha20-Poly1305 for encryption and decryption.
+	// It is usually faster than AES256 on platforms
+	// without AES hardware support. It is not available
+	// when the KES server runs in FIPS mode.
+	ChaCha20Poly1305 KeyAlgorithm = "XCHACHA20-POLY1305"
+)
+
+// String returns the KeyAlgorithm's string representation.
+func (a KeyAlgorithm) String() string { return string(a) }
+
+// KeyOrder is the order in which a KES server lists keys.
+type KeyOrder string
+
+// Supported key orders.
+const (
+	// SortByName lists keys ordered by their names.
+	SortByName KeyOrder = "name"
+
+	// SortByCreatedAt lists keys ordered by the point in
+	// time when they have been created, oldest first. Keys
+	// created at the same time are ordered by their names.
+	SortByCreatedAt KeyOrder = "created_at"
+)
+
+// ListKeysOptions are optional parameters of ListKeysWithOptions.
+// The zero value lists all matching keys in no particular order.
+type ListKeysOptions struct {
+	// Order is the order in which the KES server lists
+	// keys. If empty, the keys are not sorted.
+	//
+	// The KES server buffers all matching keys before it
+	// sorts them. Hence, it only sorts up to 10000 keys
+	// and returns an error if more keys match.
+	Order KeyOrder
+
+	// Metadata, if true, makes the KES server report when
+	// and by whom each key has been created. Otherwise, the
+	// server only lists the key names since fetching the
+	// metadata requires a key store lookup per key.
+	//
+	// The server always fetches the metadata when sorting
+	// by or filtering on the creation time.
+	Metadata bool
+
+	// CreatedAfter, if not zero, restricts the listing to
+	// keys created after the given point in time.
+	CreatedAfter time.Time
+
+	// CreatedBefore, if not zero, restricts the listing to
+	// keys created before the given point in time.
+	CreatedBefore time.Time
+}
+
 // KeyInfo describes a cryptographic key at a KES server.
+//
+// The usage counters and LastUsedAt are only populated by
+// DescribeKey. A KES server keeps them in memory and does
+// not persist them in the key store. Hence, they are reset
+// when the server restarts, and each server of a cluster
+// only counts the operations it has performed itself.
 type KeyInfo struct {
 	Name      string    // Name of the cryptographic key
 	CreatedAt time.Time // Point in time when the key was created
 	CreatedBy Identity  // Identity that created the key
+
+	Algorithm KeyAlgorithm // The key's algorithm. Only populated by DescribeKey
+
+	EncryptCount  uint64    // Number of encrypt operations performed with the key
+	DecryptCount  uint64    // Number of decrypt operations performed with the key
+	GenerateCount uint64    // Number of generate operations performed with the key
+	LastUsedAt    time.Time // Point in time when the key was used the last time
 }
 
 // KeyIterator iterates over a stream of KeyInfo objects.
 // Close the KeyIterator to release associated resources.
+//
+// A KeyIterator is not safe for concurrent use. Calling
+// Next while another call of Next is in progress returns
+// false. However, Close may be called concurrently to
+// cancel an in-progress call of Next.
 type KeyIterator struct {
 	decoder *json.Decoder
 	closer  io.Closer
 
 	current KeyInfo
 	err     error
-	closed  bool
+	closed  bool // Close has been called
+	done    bool // The stream has been read completely
+	guard   iteratorGuard
 }
 
 // Value returns the current KeyInfo. It returns
 // the same KeyInfo until Next is called again.
 //
-// If KeyIterator has been closed or if Next has not been
-// called once resp. once Next returns false then the
-// behavior of Value is undefined.
+// If Next has not been called once resp. once Next
+// returns false then the behavior of Value is undefined.
+// Once the KeyIterator has been closed, Value returns
+// the zero KeyInfo.
 func (i *KeyIterator) Value() KeyInfo { return i.current }
 
 // Name returns the name of the current key. It is a
@@ -97,13 +206,29 @@ func (i *KeyIterator) Next() bool {
 
 		Err string `json:"error"`
 	}
-	if i.closed || i.err != nil {
+	if !i.enter() {
+		return false
+	}
+	defer i.guard.Leave()
+
+	if i.closed {
+		if i.err == nil {
+			i.err = ErrIteratorClosed
+		}
+		return false
+	}
+	if i.done || i.err != nil {
 		return false
 	}
 	var resp Response
 	if err := i.decoder.Decode(&resp); err != nil {
+		if i.guard.Canceled() { // Close has been called concurrently
+			i.current = KeyInfo{}
+			i.closed = true
+			return false
+		}
 		if errors.Is(err, io.EOF) {
-			i.err = i.Close()
+			i.err = i.finish()
 		} else {
 			i.err = err
 		}
@@ -133,11 +258,16 @@ func (i *KeyIterator) WriteTo(w io.Writer) (int64, error) {
 
 		Err string `json:"error,omitempty"`
 	}
-	if i.err != nil {
-		return 0, i.err
+	if !i.enter() {
+		return 0, ErrIteratorInUse
 	}
-	if i.closed {
-		return 0, errors.New("kes: WriteTo called after Close")
+	defer i.guard.Leave()
+
+	if i.closed && i.err == nil {
+		i.err = ErrIteratorClosed
+	}
+	if i.done || i.err != nil {
+		return 0, i.err
 	}
 
 	cw := countWriter{W: w}
@@ -145,8 +275,13 @@ func (i *KeyIterator) WriteTo(w io.Writer) (int64, error) {
 	for {
 		var resp Response
 		if err := i.decoder.Decode(&resp); err != nil {
+			if i.guard.Canceled() { // Close has been called concurrently
+				i.current = KeyInfo{}
+				i.closed = true
+				return cw.N, ErrIteratorClosed
+			}
 			if errors.Is(err, io.EOF) {
-				i.err = i.Close()
+				i.err = i.finish()
 			} else {
 				i.err = err
 			}
@@ -163,16 +298,60 @@ func (i *KeyIterator) WriteTo(w io.Writer) (int64, error) {
 	}
 }
 
-// Close closes the IdentityIterator and releases
-// any associated resources.
+// Close closes the KeyIterator and releases any
+// associated resources. It returns the first error
+// encountered while iterating, if any.
+//
+// Close is idempotent. Calling Close more than once
+// returns the same error. Once closed, Next returns
+// false and Value returns the zero KeyInfo. Calling Next
+// after Close records ErrIteratorClosed, which any
+// subsequent Close call returns.
+//
+// Calling Close while Next is in progress closes the
+// underlying stream such that Next returns false. Then
+// Close returns nil and the KeyIterator is closed once
+// Next returns.
 func (i *KeyIterator) Close() error {
+	if !i.enter() {
+		if i.guard.Cancel() {
+			i.closer.Close()
+		}
+		return nil
+	}
+	defer i.guard.Leave()
+
 	if !i.closed {
-		err := i.closer.Close()
-		if i.err == nil {
-			i.err = err
+		if !i.done {
+			if err := i.closer.Close(); i.err == nil {
+				i.err = err
+			}
 		}
+		i.current = KeyInfo{}
 		i.closed = true
-		return err
 	}
 	return i.err
 }
+
+// finish releases any associated resources once the
+// entire stream has been read. The caller must hold
+// the iterator guard.
+func (i *KeyIterator) finish() error {
+	i.current = KeyInfo{}
+	i.done = true
+	return i.closer.Close()
+}
+
+// enter acquires the iterator guard. It returns false
+// if the KeyIterator is in use. Once Close has canceled
+// the KeyIterator, enter marks it as closed.
+func (i *KeyIterator) enter() bool {
+	if !i.guard.Enter() {
+		return false
+	}
+	if i.guard.Canceled() && !i.closed {
+		i.current = KeyInfo{}
+		i.closed = true
+	}
+	return true
+}
@@ -0,0 +1,50 @@
+// Copyright 2022 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package kes
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestDEKZero(t *testing.T) {
+	plaintext := []byte("my-secret-plaintext")
+	dek := DEK{
+		Plaintext:  plaintext,
+		Ciphertext: []byte("ciphertext"),
+	}
+	dek.Zero()
+
+	if dek.Plaintext != nil {
+		t.Fatal("DEK plaintext has not been removed")
+	}
+	if !bytes.Equal(plaintext, make([]byte, len(plaintext))) {
+		t.Fatal("DEK plaintext has not been overwritten with zeros")
+	}
+	if !bytes.Equal(dek.Ciphertext, []byte("ciphertext")) {
+		t.Fatal("DEK ciphertext has been modified")
+	}
+}
+
+var dekStringTests = []DEK{
+	{Plaintext: nil, Ciphertext: nil},                                            // 0
+	{Plaintext: []byte("my-secret-plaintext"), Ciphertext: nil},                  // 1
+	{Plaintext: []byte("my-secret-plaintext"), Ciphertext: []byte("ciphertext")}, // 2
+}
+
+func TestDEKString(t *testing.T) {
+	for i, dek := range dekStringTests {
+		for _, format := range []string{"%v", "%+v", "%#v", "%s"} {
+			if s := fmt.Sprintf(format, dek); len(dek.Plaintext) > 0 && strings.Contains(s, "my-secret-plaintext") {
+				t.Fatalf("Test %d: %s format contains the plaintext: %s", i, format, s)
+			}
+			if s := fmt.Sprintf(format, &dek); len(dek.Plaintext) > 0 && strings.Contains(s, "my-secret-plaintext") {
+				t.Fatalf("Test %d: %s format of pointer contains the plaintext: %s", i, format, s)
+			}
+		}
+	}
+}
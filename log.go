@@ -125,6 +125,7 @@ func (s *ErrorStream) Close() error {
 type AuditEvent struct {
 	Timestamp time.Time // The point in time when the KES server received the request
 	APIPath   string    // The API called by the client. May contain API arguments
+	RequestID string    // The ID of the request. Empty if not sent by the KES server
 
 	ClientIP       net.IP   // The client's IP address
 	ClientIdentity Identity // The client's KES identity
@@ -167,6 +168,7 @@ func (s *AuditStream) Next() bool {
 	type Response struct {
 		Timestamp time.Time `json:"time"`
 		Request   struct {
+			ID       string   `json:"id,omitempty"`
 			IP       net.IP   `json:"ip"`
 			APIPath  string   `json:"path"`
 			Identity Identity `json:"identity"`
@@ -191,6 +193,7 @@ func (s *AuditStream) Next() bool {
 	s.event = AuditEvent{
 		Timestamp:      resp.Timestamp,
 		APIPath:        resp.Request.APIPath,
+		RequestID:      resp.Request.ID,
 		ClientIP:       resp.Request.IP,
 		ClientIdentity: resp.Request.Identity,
 		StatusCode:     resp.Response.StatusCode,
@@ -206,6 +209,7 @@ func (s *AuditStream) WriteTo(w io.Writer) (int64, error) {
 	type Response struct {
 		Timestamp time.Time `json:"time"`
 		Request   struct {
+			ID       string   `json:"id,omitempty"`
 			IP       net.IP   `json:"ip"`
 			APIPath  string   `json:"path"`
 			Identity Identity `json:"identity"`
@@ -12,10 +12,20 @@ type Metric struct {
 	RequestErr    uint64 `json:"kes_http_request_error"`   // Requests that failed with a well-defined error
 	RequestFail   uint64 `json:"kes_http_request_failure"` // Requests that failed unexpectedly due to an internal error
 	RequestActive uint64 `json:"kes_http_request_active"`  // Requests that are currently active and haven't completed yet
+	Connections   uint64 `json:"kes_http_connections"`     // Client connections that are currently open
 
 	AuditEvents uint64 `json:"kes_log_audit_events"` // Number of generated audit events
 	ErrorEvents uint64 `json:"kes_log_error_events"` // Number of generated error events
 
+	// Number of audit events received by all audit log targets.
+	AuditEventsDelivered uint64 `json:"kes_log_audit_events_delivered"`
+
+	// Number of audit events that at least one audit log target
+	// failed to receive - e.g. because the connection to a client
+	// tracing the audit log broke. Audit events are only lost
+	// if AuditEventsDropped is not zero.
+	AuditEventsDropped uint64 `json:"kes_log_audit_events_dropped"`
+
 	// Histogram of the KES server response latency.
 	// It shows how fast the server can handle requests.
 	//
@@ -7,7 +7,11 @@ package kes
 import (
 	"encoding/json"
 	"errors"
+	"fmt"
 	"io"
+	"math"
+	"path"
+	"strings"
 	"time"
 )
 
@@ -34,11 +38,164 @@ import (
 // rules and no deny rule matches the request. Also, a deny
 // rule takes precedence over an allow rule.
 //
+// A policy may extend one or more parent policies. Then, the
+// rules of the policy and all its parents apply. Hence, a deny
+// rule of a parent policy takes precedence over an allow rule
+// of the policy itself. A policy must not extend itself, neither
+// directly nor via its parents.
+//
+// Further, a policy may limit the number of requests per
+// second an identity can send to API paths that match a
+// rate limit pattern. Requests that exceed a rate limit
+// are rejected with 429 (Too Many Requests), i.e. with
+// ErrRateLimited. Policies without rate limits don't
+// limit the request rate.
+//
 // [1]: https://en.wikipedia.org/wiki/Glob_(programming)
 // [2]: https://golang.org/pkg/path/#Match
 type Policy struct {
 	Allow []string // Set of allow patterns
 	Deny  []string // Set of deny patterns
+
+	// Optional rate limits in requests per second
+	RateLimit map[string]float64 `json:"rate_limit,omitempty"`
+
+	// Optional list of parent policies. A policy inherits
+	// all allow, deny and rate limit rules of its parents.
+	Extends []string `json:"extends,omitempty"`
+
+	// Optional time window. If set, the policy only allows
+	// requests within the window. A policy without a time
+	// window inherits the one of its parents, if any.
+	AllowWindow *TimeWindow `json:"allow_window,omitempty"`
+}
+
+// ValidatePolicy returns an error if any allow or deny rule
+// of the policy is not a well-formed glob pattern or does not
+// match any KES server API. Such rules never apply to any
+// request and are most likely a typo - e.g. "/v1/key/genrate/*".
+// The same applies to rate limit patterns. Further, any rate limit
+// must be a positive number and the time window, if any, must be
+// well-formed.
+func ValidatePolicy(p *Policy) error {
+	for _, pattern := range p.Allow {
+		if err := validatePattern(pattern); err != nil {
+			return fmt.Errorf("kes: invalid allow rule %q: %v", pattern, err)
+		}
+	}
+	for _, pattern := range p.Deny {
+		if err := validatePattern(pattern); err != nil {
+			return fmt.Errorf("kes: invalid deny rule %q: %v", pattern, err)
+		}
+	}
+	for pattern, rate := range p.RateLimit {
+		if err := validatePattern(pattern); err != nil {
+			return fmt.Errorf("kes: invalid rate limit rule %q: %v", pattern, err)
+		}
+		if rate <= 0 || math.IsNaN(rate) || math.IsInf(rate, 0) {
+			return fmt.Errorf("kes: invalid rate limit rule %q: rate must be a positive number", pattern)
+		}
+	}
+	if p.AllowWindow != nil {
+		if _, err := p.AllowWindow.parse(); err != nil {
+			return fmt.Errorf("kes: invalid allow window: %v", err)
+		}
+	}
+	return nil
+}
+
+// apiPaths contains the URL paths of the KES server APIs
+// that can be allowed or denied by a policy. A path ending
+// with a '/' expects an argument - e.g. a key name.
+var apiPaths = []string{
+	"/version",
+	"/v1/status",
+	"/v1/status/stream",
+	"/v1/metrics",
+	"/v1/api",
+
+	"/v1/key/create/",
+	"/v1/key/import/",
+	"/v1/key/describe/",
+	"/v1/key/delete/",
+	"/v1/key/generate/",
+	"/v1/key/encrypt/",
+	"/v1/key/decrypt/",
+	"/v1/key/bulk/decrypt/",
+	"/v1/key/bulk/generate/",
+	"/v1/key/multi/generate/",
+	"/v1/key/rewrap/",
+	"/v1/key/derive/",
+	"/v1/key/export/",
+	"/v1/key/copy/",
+	"/v1/key/rename/",
+	"/v1/key/list/",
+
+	"/v1/policy/describe/",
+	"/v1/policy/assign/",
+	"/v1/policy/bulk/assign/",
+	"/v1/policy/read/",
+	"/v1/policy/write/",
+	"/v1/policy/list/",
+	"/v1/policy/identities/",
+	"/v1/policy/delete/",
+	"/v1/apply",
+
+	"/v1/identity/describe/",
+	"/v1/identity/self/describe",
+	"/v1/identity/list/",
+	"/v1/identity/alias/",
+	"/v1/identity/delete/",
+	"/v1/identity/delete-by-policy/",
+
+	"/v1/log/error",
+	"/v1/log/audit",
+
+	"/v1/enclave/create/",
+	"/v1/enclave/delete/",
+	"/v1/enclave/list/",
+	"/v1/enclave/describe/",
+	"/v1/vault/status",
+	"/v1/vault/seal",
+	"/v1/vault/unseal",
+}
+
+// validatePattern returns an error if the pattern is
+// malformed or does not match any KES server API path.
+//
+// A '*' does not match the path separator '/'. Therefore,
+// a pattern has to consist of the same number of path
+// segments as an API path to match it.
+func validatePattern(pattern string) error {
+	if pattern == "" {
+		return errors.New("empty pattern")
+	}
+	if _, err := path.Match(pattern, ""); err != nil {
+		return err
+	}
+
+	segments := strings.Split(pattern, "/")
+	for _, api := range apiPaths {
+		apiSegments := strings.Split(api, "/")
+		if len(segments) != len(apiSegments) {
+			continue
+		}
+
+		matches := true
+		for i := range segments {
+			if i == len(apiSegments)-1 && apiSegments[i] == "" {
+				break // The last segment is an argument and any pattern may match it.
+			}
+			if ok, _ := path.Match(segments[i], apiSegments[i]); !ok {
+				matches = false
+				break
+			}
+		}
+		if matches {
+			return nil
+		}
+	}
+	return errors.New("pattern does not match any API")
 }
 
 // PolicyInfo describes a KES policy.
@@ -46,17 +203,45 @@ type PolicyInfo struct {
 	Name      string    `json:"name"`                 // Name of the policy
 	CreatedAt time.Time `json:"created_at,omitempty"` // Point in time when the policy was created
 	CreatedBy Identity  `json:"created_by,omitempty"` // Identity that created the policy
+
+	// The rules of the policy itself and its parent policies.
+	// Only populated by DescribePolicy.
+	Allow   []string `json:"allow,omitempty"`
+	Deny    []string `json:"deny,omitempty"`
+	Extends []string `json:"extends,omitempty"`
+
+	// The effective rules that apply to requests. They
+	// contain the rules of the policy and of all policies
+	// it extends, directly or indirectly. For policies that
+	// don't extend other policies, the effective rules are
+	// equal to Allow resp. Deny. Only populated by
+	// DescribePolicy.
+	EffectiveAllow []string `json:"effective_allow,omitempty"`
+	EffectiveDeny  []string `json:"effective_deny,omitempty"`
+
+	// AllowWindow is the effective allow window of the
+	// policy, if any. A policy without an allow window
+	// inherits the one of its first parent that has one.
+	// Only populated by DescribePolicy.
+	AllowWindow *TimeWindow `json:"effective_allow_window,omitempty"`
 }
 
 // PolicyIterator iterates over a stream of PolicyInfo objects.
 // Close the PolicyIterator to release associated resources.
+//
+// A PolicyIterator is not safe for concurrent use. Calling
+// Next while another call of Next is in progress returns
+// false. However, Close may be called concurrently to
+// cancel an in-progress call of Next.
 type PolicyIterator struct {
 	decoder *json.Decoder
 	closer  io.Closer
 
 	current PolicyInfo
 	err     error
-	closed  bool
+	closed  bool // Close has been called
+	done    bool // The stream has been read completely
+	guard   iteratorGuard
 }
 
 // Value returns the current PolicyInfo. It remains valid
@@ -87,14 +272,30 @@ func (i *PolicyIterator) Next() bool {
 
 		Err string `json:"error"`
 	}
-	if i.closed || i.err != nil {
+	if !i.enter() {
+		return false
+	}
+	defer i.guard.Leave()
+
+	if i.closed {
+		if i.err == nil {
+			i.err = ErrIteratorClosed
+		}
+		return false
+	}
+	if i.done || i.err != nil {
 		return false
 	}
 
 	var resp Response
 	if err := i.decoder.Decode(&resp); err != nil {
+		if i.guard.Canceled() { // Close has been called concurrently
+			i.current = PolicyInfo{}
+			i.closed = true
+			return false
+		}
 		if errors.Is(err, io.EOF) {
-			i.err = i.Close()
+			i.err = i.finish()
 		} else {
 			i.err = err
 		}
@@ -125,11 +326,16 @@ func (i *PolicyIterator) WriteTo(w io.Writer) (int64, error) {
 
 		Err string `json:"error,omitempty"`
 	}
-	if i.err != nil {
-		return 0, i.err
+	if !i.enter() {
+		return 0, ErrIteratorInUse
 	}
-	if i.closed {
-		return 0, errors.New("kes: WriteTo called after Close")
+	defer i.guard.Leave()
+
+	if i.closed && i.err == nil {
+		i.err = ErrIteratorClosed
+	}
+	if i.done || i.err != nil {
+		return 0, i.err
 	}
 
 	cw := countWriter{W: w}
@@ -137,8 +343,13 @@ func (i *PolicyIterator) WriteTo(w io.Writer) (int64, error) {
 	for {
 		var resp Response
 		if err := i.decoder.Decode(&resp); err != nil {
+			if i.guard.Canceled() { // Close has been called concurrently
+				i.current = PolicyInfo{}
+				i.closed = true
+				return cw.N, ErrIteratorClosed
+			}
 			if errors.Is(err, io.EOF) {
-				i.err = i.Close()
+				i.err = i.finish()
 			} else {
 				i.err = err
 			}
@@ -155,16 +366,60 @@ func (i *PolicyIterator) WriteTo(w io.Writer) (int64, error) {
 	}
 }
 
-// Close closes the PolicyIterator and releases
-// any associated resources.
+// Close closes the PolicyIterator and releases any
+// associated resources. It returns the first error
+// encountered while iterating, if any.
+//
+// Close is idempotent. Calling Close more than once
+// returns the same error. Once closed, Next returns
+// false and Value returns the zero PolicyInfo. Calling Next
+// after Close records ErrIteratorClosed, which any
+// subsequent Close call returns.
+//
+// Calling Close while Next is in progress closes the
+// underlying stream such that Next returns false. Then
+// Close returns nil and the PolicyIterator is closed once
+// Next returns.
 func (i *PolicyIterator) Close() error {
+	if !i.enter() {
+		if i.guard.Cancel() {
+			i.closer.Close()
+		}
+		return nil
+	}
+	defer i.guard.Leave()
+
 	if !i.closed {
-		err := i.closer.Close()
-		if i.err == nil {
-			i.err = err
+		if !i.done {
+			if err := i.closer.Close(); i.err == nil {
+				i.err = err
+			}
 		}
+		i.current = PolicyInfo{}
 		i.closed = true
-		return err
 	}
 	return i.err
 }
+
+// finish releases any associated resources once the
+// entire stream has been read. The caller must hold
+// the iterator guard.
+func (i *PolicyIterator) finish() error {
+	i.current = PolicyInfo{}
+	i.done = true
+	return i.closer.Close()
+}
+
+// enter acquires the iterator guard. It returns false
+// if the PolicyIterator is in use. Once Close has canceled
+// the PolicyIterator, enter marks it as closed.
+func (i *PolicyIterator) enter() bool {
+	if !i.guard.Enter() {
+		return false
+	}
+	if i.guard.Canceled() && !i.closed {
+		i.current = PolicyInfo{}
+		i.closed = true
+	}
+	return true
+}
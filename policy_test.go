@@ -0,0 +1,44 @@
+// Copyright 2022 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package kes
+
+import "testing"
+
+var validatePolicyTests = []struct {
+	Policy     Policy
+	ShouldFail bool
+}{
+	{Policy: Policy{}}, // 0
+	{Policy: Policy{Allow: []string{"/v1/key/create/*"}}},                                                     // 1
+	{Policy: Policy{Allow: []string{"/v1/key/*/*"}}},                                                          // 2
+	{Policy: Policy{Allow: []string{"/v1/key/generate/my-key"}}},                                              // 3
+	{Policy: Policy{Allow: []string{"/v1/log/*", "/version"}}},                                                // 4
+	{Policy: Policy{Allow: []string{"/v1/identity/self/describe"}, Deny: []string{"/v1/key/delete/*"}}},       // 5
+	{Policy: Policy{Allow: []string{"/v1/*/list/*"}}},                                                         // 6
+	{Policy: Policy{Allow: []string{"/v1/key/*/*"}, RateLimit: map[string]float64{"/v1/key/generate/*": 10}}}, // 7
+
+	{Policy: Policy{Allow: []string{""}}, ShouldFail: true},                                    // 8
+	{Policy: Policy{Allow: []string{"/v1/key/genrate/*"}}, ShouldFail: true},                   // 9
+	{Policy: Policy{Allow: []string{"/v1/key/create/["}}, ShouldFail: true},                    // 10
+	{Policy: Policy{Allow: []string{"/v1/*/"}}, ShouldFail: true},                              // 11
+	{Policy: Policy{Deny: []string{"/v1/key/create/*/*"}}, ShouldFail: true},                   // 12
+	{Policy: Policy{Deny: []string{"v1/key/create/*"}}, ShouldFail: true},                      // 13
+	{Policy: Policy{Allow: []string{"/v1/status/"}}, ShouldFail: true},                         // 14
+	{Policy: Policy{Allow: []string{"/v1/key/create/*", "/v2/*"}}, ShouldFail: true},           // 15
+	{Policy: Policy{RateLimit: map[string]float64{"/v1/key/genrate/*": 10}}, ShouldFail: true}, // 16
+	{Policy: Policy{RateLimit: map[string]float64{"/v1/key/generate/*": 0}}, ShouldFail: true}, // 17
+}
+
+func TestValidatePolicy(t *testing.T) {
+	for i, test := range validatePolicyTests {
+		err := ValidatePolicy(&test.Policy)
+		if err != nil && !test.ShouldFail {
+			t.Fatalf("Test %d: failed to validate policy: %v", i, err)
+		}
+		if err == nil && test.ShouldFail {
+			t.Fatalf("Test %d: validation should have failed", i)
+		}
+	}
+}
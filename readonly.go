@@ -0,0 +1,61 @@
+// Copyright 2022 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package kes
+
+import (
+	"errors"
+	"net/http"
+	"path"
+)
+
+// ErrReadOnly is returned by a read-only Client when an
+// API call would modify the state of the KES server - e.g.
+// create or delete a key. The request is never sent to the
+// server.
+//
+// A read-only Client can be obtained via Client.ReadOnly.
+var ErrReadOnly = errors.New("kes: client is read-only")
+
+// mutatingAPIs contains the KES server APIs that modify the
+// server state. It maps the API path to the API method.
+var mutatingAPIs = map[string]string{
+	"/v1/key/create": http.MethodPost,
+	"/v1/key/import": http.MethodPost,
+	"/v1/key/copy":   http.MethodPost,
+	"/v1/key/rename": http.MethodPost,
+	"/v1/key/delete": http.MethodDelete,
+
+	"/v1/policy/assign":      http.MethodPost,
+	"/v1/policy/bulk/assign": http.MethodPost,
+	"/v1/policy/write":       http.MethodPost,
+	"/v1/policy/delete":      http.MethodDelete,
+	"/v1/apply":              http.MethodPost,
+
+	"/v1/identity/alias":            http.MethodPost,
+	"/v1/identity/delete":           http.MethodDelete,
+	"/v1/identity/delete-by-policy": http.MethodDelete,
+
+	"/v1/enclave/create": http.MethodPost,
+	"/v1/enclave/delete": http.MethodDelete,
+	"/v1/vault/seal":     http.MethodPost,
+	"/v1/vault/unseal":   http.MethodPost,
+}
+
+// readOnlyTransport is an http.RoundTripper that rejects
+// requests to mutating APIs with ErrReadOnly.
+type readOnlyTransport struct {
+	transport http.RoundTripper
+}
+
+func (t readOnlyTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	// API arguments, like key names, are appended as path
+	// segments. Hence, we have to check all path prefixes.
+	for api := req.URL.Path; api != "/" && api != "."; api = path.Dir(api) {
+		if method, ok := mutatingAPIs[api]; ok && method == req.Method {
+			return nil, ErrReadOnly
+		}
+	}
+	return t.transport.RoundTrip(req)
+}
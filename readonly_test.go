@@ -0,0 +1,75 @@
+// Copyright 2022 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package kes
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"net/http"
+	"testing"
+)
+
+var readOnlyTransportTests = []struct {
+	Method string
+	Path   string
+	Err    error
+}{
+	{Method: http.MethodGet, Path: "/v1/status", Err: nil},                                         // 0
+	{Method: http.MethodGet, Path: "/v1/key/describe/my-key", Err: nil},                            // 1
+	{Method: http.MethodGet, Path: "/v1/key/list/*", Err: nil},                                     // 2
+	{Method: http.MethodPost, Path: "/v1/key/decrypt/my-key", Err: nil},                            // 3
+	{Method: http.MethodGet, Path: "/v1/policy/identities/delete", Err: nil},                       // 4
+	{Method: http.MethodPost, Path: "/v1/key/create/my-key", Err: ErrReadOnly},                     // 5
+	{Method: http.MethodPost, Path: "/v1/key/import/my-key", Err: ErrReadOnly},                     // 6
+	{Method: http.MethodDelete, Path: "/v1/key/delete/my-key", Err: ErrReadOnly},                   // 7
+	{Method: http.MethodDelete, Path: "/v1/identity/delete-by-policy/my-policy", Err: ErrReadOnly}, // 8
+	{Method: http.MethodPost, Path: "/v1/vault/seal", Err: ErrReadOnly},                            // 9
+}
+
+func TestReadOnlyTransport(t *testing.T) {
+	for i, test := range readOnlyTransportTests {
+		var sent bool
+		transport := readOnlyTransport{
+			transport: roundTripperFunc(func(*http.Request) (*http.Response, error) {
+				sent = true
+				return &http.Response{StatusCode: http.StatusOK, Body: http.NoBody}, nil
+			}),
+		}
+		req, err := http.NewRequest(test.Method, "https://127.0.0.1:7373"+test.Path, nil)
+		if err != nil {
+			t.Fatalf("Test %d: failed to create request: %v", i, err)
+		}
+		resp, err := transport.RoundTrip(req)
+		if err != test.Err {
+			t.Fatalf("Test %d: invalid error: got '%v' - want '%v'", i, err, test.Err)
+		}
+		if err == nil {
+			resp.Body.Close()
+		}
+		if sent != (test.Err == nil) {
+			t.Fatalf("Test %d: request sent: got '%v' - want '%v'", i, sent, test.Err == nil)
+		}
+	}
+}
+
+func TestClientReadOnly(t *testing.T) {
+	client := NewClient("https://127.0.0.1:7373", tls.Certificate{})
+	client.HTTPClient.Transport = roundTripperFunc(func(*http.Request) (*http.Response, error) {
+		t.Fatal("Read-only client sent a mutating request")
+		return nil, nil
+	})
+
+	readOnly := client.ReadOnly()
+	if err := readOnly.CreateKey(context.Background(), "my-key"); !errors.Is(err, ErrReadOnly) {
+		t.Fatalf("Invalid error: got '%v' - want '%v'", err, ErrReadOnly)
+	}
+	if err := readOnly.DeleteKey(context.Background(), "my-key"); !errors.Is(err, ErrReadOnly) {
+		t.Fatalf("Invalid error: got '%v' - want '%v'", err, ErrReadOnly)
+	}
+	if err := readOnly.ImportKey(context.Background(), "my-key", make([]byte, 32)); !errors.Is(err, ErrReadOnly) {
+		t.Fatalf("Invalid error: got '%v' - want '%v'", err, ErrReadOnly)
+	}
+}
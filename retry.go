@@ -14,6 +14,8 @@ import (
 	"net"
 	"net/http"
 	"net/url"
+	"path"
+	"strings"
 	"time"
 )
 
@@ -66,7 +68,13 @@ func withHeader(key, value string) requestOption {
 // but requires that the request body implements io.Seeker.
 // Otherwise, it cannot guarantee that the entire request
 // body gets sent when retrying a request.
-type retry http.Client
+type retry struct {
+	http.Client
+
+	// timeouts contains optional per-API timeouts.
+	// See Client.Timeouts.
+	timeouts map[string]time.Duration
+}
 
 // Send creates a new HTTP request with the given method, context
 // request body and request options, if any. It randomly iterates
@@ -75,10 +83,51 @@ type retry http.Client
 // If sending a request to one endpoint fails due to e.g. a network
 // or DNS error, Send tries the next endpoint. It aborts once the
 // context is canceled or its deadline exceeded.
+//
+// If there is a timeout for the API, Send limits the entire call,
+// including all retries and reading the response body, to it.
 func (r *retry) Send(ctx context.Context, method string, endpoints []string, path string, body io.ReadSeeker, options ...requestOption) (*http.Response, error) {
 	if len(endpoints) == 0 {
 		return nil, errors.New("kes: no server endpoint")
 	}
+	if timeout := r.timeout(path); timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+
+		resp, err := r.send(ctx, method, endpoints, path, body, options...)
+		if err != nil {
+			cancel()
+			return nil, err
+		}
+		resp.Body = cancelBody{ReadCloser: resp.Body, cancel: cancel}
+		return resp, nil
+	}
+	return r.send(ctx, method, endpoints, path, body, options...)
+}
+
+// timeout returns the timeout of the API the given request
+// path belongs to, if any.
+func (r *retry) timeout(urlPath string) time.Duration {
+	if len(r.timeouts) == 0 {
+		return 0
+	}
+	if i := strings.IndexByte(urlPath, '?'); i >= 0 {
+		urlPath = urlPath[:i]
+	}
+
+	// The API path is a prefix of the request path since
+	// API arguments, like key names, are appended as path
+	// segments. Hence, we look for the longest API path that
+	// has a timeout.
+	for api := urlPath; api != "/" && api != "."; api = path.Dir(api) {
+		if d, ok := r.timeouts[api]; ok {
+			return d
+		}
+	}
+	return 0
+}
+
+func (r *retry) send(ctx context.Context, method string, endpoints []string, path string, body io.ReadSeeker, options ...requestOption) (*http.Response, error) {
 	var (
 		request  *http.Request
 		response *http.Response
@@ -105,6 +154,9 @@ func (r *retry) Send(ctx context.Context, method string, endpoints []string, pat
 		if errors.Is(err, context.DeadlineExceeded) {
 			return nil, err
 		}
+		if ctx.Err() != nil { // There is no point in trying another endpoint
+			return nil, err
+		}
 	}
 	return response, err
 }
@@ -182,10 +234,14 @@ func (r *retry) Do(req *http.Request) (*http.Response, error) {
 	)
 	var (
 		retry  = 2 // For now, we retry 2 times before we give up
-		client = (*http.Client)(r)
+		client = &r.Client
 	)
 	resp, err := client.Do(req)
-	for retry > 0 && (isTemporary(err) || (resp != nil && resp.StatusCode == http.StatusServiceUnavailable)) {
+
+	// There is no point in retrying a request once its context
+	// is done. The retry would fail immediately - but only after
+	// the retry delay.
+	for retry > 0 && req.Context().Err() == nil && (isTemporary(err) || (resp != nil && resp.StatusCode == http.StatusServiceUnavailable)) {
 		randomRetryDelay := time.Duration(rand.Intn(MaxRandRetryDelay)) * time.Millisecond
 		time.Sleep(MinRetryDelay + randomRetryDelay)
 		retry--
@@ -201,7 +257,7 @@ func (r *retry) Do(req *http.Request) (*http.Response, error) {
 
 		resp, err = client.Do(req) // Now, retry.
 	}
-	if isTemporary(err) {
+	if isTemporary(err) && req.Context().Err() == nil {
 		// If the request still fails with a temporary error
 		// we wrap the error to provide more information to the
 		// caller.
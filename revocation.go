@@ -0,0 +1,80 @@
+// Copyright 2022 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package kes
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"math/big"
+	"time"
+
+	"golang.org/x/crypto/ocsp"
+)
+
+// RevocationError is returned when the KES server presents
+// a certificate that has been revoked by its issuer.
+//
+// A client only checks whether the server certificate has
+// been revoked when OCSP verification is enabled. See
+// TransportConfig.VerifyOCSP.
+type RevocationError struct {
+	SerialNumber *big.Int  // Serial number of the revoked certificate
+	RevokedAt    time.Time // Point in time when the certificate got revoked
+	Reason       int       // The RFC 5280 revocation reason code
+}
+
+func (e *RevocationError) Error() string {
+	return fmt.Sprintf("kes: server certificate '%s' has been revoked at %v", e.SerialNumber, e.RevokedAt.Format(time.RFC3339))
+}
+
+// verifyOCSP returns a tls.Config.VerifyConnection callback
+// that verifies the OCSP response stapled by the server, if
+// any, once the given verify callback succeeds.
+//
+// It returns a *RevocationError if the OCSP response reports
+// the server certificate as revoked.
+func verifyOCSP(verify func(tls.ConnectionState) error) func(tls.ConnectionState) error {
+	return func(state tls.ConnectionState) error {
+		if verify != nil {
+			if err := verify(state); err != nil {
+				return err
+			}
+		}
+		if len(state.OCSPResponse) == 0 || len(state.PeerCertificates) == 0 {
+			return nil // The server has not stapled an OCSP response
+		}
+
+		var (
+			leaf   = state.PeerCertificates[0]
+			issuer *x509.Certificate
+		)
+		switch {
+		case len(state.VerifiedChains) > 0 && len(state.VerifiedChains[0]) > 1:
+			issuer = state.VerifiedChains[0][1]
+		case len(state.PeerCertificates) > 1:
+			issuer = state.PeerCertificates[1]
+		default:
+			return errors.New("kes: invalid OCSP response: issuer of server certificate not found")
+		}
+
+		resp, err := ocsp.ParseResponseForCert(state.OCSPResponse, leaf, issuer)
+		if err != nil {
+			return fmt.Errorf("kes: invalid OCSP response: %v", err)
+		}
+		if !resp.NextUpdate.IsZero() && time.Now().After(resp.NextUpdate) {
+			return errors.New("kes: invalid OCSP response: response has expired")
+		}
+		if resp.Status == ocsp.Revoked {
+			return &RevocationError{
+				SerialNumber: leaf.SerialNumber,
+				RevokedAt:    resp.RevokedAt,
+				Reason:       resp.RevocationReason,
+			}
+		}
+		return nil
+	}
+}
@@ -0,0 +1,110 @@
+// Copyright 2022 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package kes
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"math/big"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/ocsp"
+)
+
+var verifyOCSPTests = []struct {
+	Status     int
+	NextUpdate time.Duration
+	Revoked    bool
+	ShouldFail bool
+}{
+	{Status: ocsp.Good, NextUpdate: time.Hour},                                     // 0
+	{Status: ocsp.Unknown, NextUpdate: time.Hour},                                  // 1
+	{Status: ocsp.Revoked, NextUpdate: time.Hour, Revoked: true, ShouldFail: true}, // 2
+	{Status: ocsp.Good, NextUpdate: -time.Hour, ShouldFail: true},                  // 3
+}
+
+func TestVerifyOCSP(t *testing.T) {
+	caKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate private key: %v", err)
+	}
+	caTemplate := x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "kes-ca"},
+		NotBefore:             time.Now(),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	caDER, err := x509.CreateCertificate(rand.Reader, &caTemplate, &caTemplate, &caKey.PublicKey, caKey)
+	if err != nil {
+		t.Fatalf("Failed to create CA certificate: %v", err)
+	}
+	ca, err := x509.ParseCertificate(caDER)
+	if err != nil {
+		t.Fatalf("Failed to parse CA certificate: %v", err)
+	}
+
+	priv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate private key: %v", err)
+	}
+	template := x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: "kes-server"},
+		NotBefore:    time.Now(),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	certDER, err := x509.CreateCertificate(rand.Reader, &template, ca, &priv.PublicKey, caKey)
+	if err != nil {
+		t.Fatalf("Failed to create server certificate: %v", err)
+	}
+	cert, err := x509.ParseCertificate(certDER)
+	if err != nil {
+		t.Fatalf("Failed to parse server certificate: %v", err)
+	}
+
+	verify := verifyOCSP(nil)
+	if err = verify(tls.ConnectionState{PeerCertificates: []*x509.Certificate{cert, ca}}); err != nil {
+		t.Fatalf("Failed to verify connection without OCSP response: %v", err)
+	}
+	for i, test := range verifyOCSPTests {
+		resp, err := ocsp.CreateResponse(ca, ca, ocsp.Response{
+			Status:       test.Status,
+			SerialNumber: cert.SerialNumber,
+			ThisUpdate:   time.Now().Add(-2 * time.Hour),
+			NextUpdate:   time.Now().Add(test.NextUpdate),
+			RevokedAt:    time.Now().Add(-time.Hour),
+		}, caKey)
+		if err != nil {
+			t.Fatalf("Test %d: failed to create OCSP response: %v", i, err)
+		}
+
+		err = verify(tls.ConnectionState{
+			PeerCertificates: []*x509.Certificate{cert, ca},
+			OCSPResponse:     resp,
+		})
+		if err == nil && test.ShouldFail {
+			t.Fatalf("Test %d: verification should have failed", i)
+		}
+		if err != nil && !test.ShouldFail {
+			t.Fatalf("Test %d: failed to verify OCSP response: %v", i, err)
+		}
+
+		var rErr *RevocationError
+		if revoked := errors.As(err, &rErr); revoked != test.Revoked {
+			t.Fatalf("Test %d: revocation mismatch: got '%v' - want '%v'", i, revoked, test.Revoked)
+		}
+	}
+}
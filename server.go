@@ -8,11 +8,51 @@ import "time"
 
 // State is a KES server status snapshot.
 type State struct {
-	Version string // The KES server version
+	Version   string    // The KES server version
+	Commit    string    // The git commit the KES server has been built from, if known
+	BuildTime time.Time // Point in time when the KES server has been built, if known
+	GoVersion string    // The Go version the KES server has been built with
 
 	UpTime time.Duration // The time the KES server has been up and running
 }
 
+// HealthEvent describes a state change of a KES server. It
+// is sent by the KES server whenever the server gets sealed
+// or unsealed or when the state of its key store changes.
+type HealthEvent struct {
+	Time   time.Time // Point in time when the KES server observed the state
+	Sealed bool      // Indicates whether the KES server is sealed
+
+	// State of the key store backend - either "available",
+	// "reachable" or "unreachable". Empty if the KES server
+	// is sealed.
+	StoreState string
+
+	// Err is not nil if the connection to the KES server has
+	// been lost. Then the event does not describe the server
+	// state and is the last event of the stream.
+	Err error
+}
+
+// EnclaveStatus describes the state of an enclave
+// within the KES server vault.
+type EnclaveStatus struct {
+	Sealed   bool   // Indicates whether the enclave is sealed
+	KeyCount uint64 // Number of keys within the enclave. May be up to a minute old
+
+	// State of the enclave's key store backend -
+	// either "available", "reachable" or "unreachable".
+	StoreState string
+
+	// Time elapsed to reach the key store backend.
+	StoreLatency time.Duration
+
+	// Err is not nil if the KES server failed to fetch the
+	// status of the enclave - e.g. because its key store
+	// is not reachable. Then KeyCount is not valid.
+	Err error
+}
+
 // API describes a KES server API.
 type API struct {
 	Method  string        // The HTTP method
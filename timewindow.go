@@ -0,0 +1,156 @@
+// Copyright 2022 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package kes
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// A TimeWindow is a recurring period of time, e.g. Monday
+// to Friday from 08:00 to 18:00. A policy with a time
+// window only allows requests within the window.
+//
+// If End is before Start, the window spans midnight and
+// closes on the following day. For example, the window
+// {Days: ["Fri"], Start: "22:00", End: "06:00"} opens on
+// Friday at 22:00 and closes on Saturday at 06:00.
+type TimeWindow struct {
+	// Days is the list of weekdays, e.g. "Mon" or "Monday",
+	// on which the window opens. If empty, the window opens
+	// every day.
+	Days []string `json:"days,omitempty"`
+
+	// Start is the time of day, in the format "HH:MM", when
+	// the window opens. If empty, the window opens at 00:00.
+	Start string `json:"start,omitempty"`
+
+	// End is the time of day, in the format "HH:MM", when
+	// the window closes. If empty, the window closes at
+	// 24:00.
+	End string `json:"end,omitempty"`
+
+	// Location is the IANA time zone, e.g. "Europe/Berlin",
+	// of Start and End. If empty, it defaults to UTC.
+	Location string `json:"location,omitempty"`
+}
+
+// Contains reports whether t lies within the time window.
+// It returns false if the time window is not valid.
+func (w *TimeWindow) Contains(t time.Time) bool {
+	window, err := w.parse()
+	if err != nil {
+		return false
+	}
+
+	t = t.In(window.Location)
+	minute := t.Hour()*60 + t.Minute()
+	if window.Start < window.End {
+		return window.Days[t.Weekday()] && minute >= window.Start && minute < window.End
+	}
+	if minute >= window.Start { // The window opened today
+		return window.Days[t.Weekday()]
+	}
+	return window.Days[(t.Weekday()+6)%7] && minute < window.End // The window opened yesterday
+}
+
+// timeWindow is a parsed TimeWindow.
+type timeWindow struct {
+	Days       [7]bool // Indexed by time.Weekday
+	Start, End int     // Minutes since midnight
+	Location   *time.Location
+}
+
+// parse parses the time window. It returns an error
+// if any of its fields is invalid.
+func (w *TimeWindow) parse() (timeWindow, error) {
+	var (
+		window timeWindow
+		err    error
+	)
+	if len(w.Days) == 0 {
+		for i := range window.Days {
+			window.Days[i] = true
+		}
+	}
+	for _, day := range w.Days {
+		weekday, ok := parseWeekday(day)
+		if !ok {
+			return timeWindow{}, fmt.Errorf("invalid weekday %q", day)
+		}
+		window.Days[weekday] = true
+	}
+
+	window.Start, window.End = 0, 24*60
+	if w.Start != "" {
+		if window.Start, err = parseTimeOfDay(w.Start); err != nil {
+			return timeWindow{}, err
+		}
+	}
+	if w.End != "" {
+		if window.End, err = parseTimeOfDay(w.End); err != nil {
+			return timeWindow{}, err
+		}
+	}
+	if window.Start == window.End {
+		return timeWindow{}, errors.New("start and end of time window are equal")
+	}
+
+	window.Location = time.UTC
+	if w.Location != "" {
+		if window.Location, err = loadLocation(w.Location); err != nil {
+			return timeWindow{}, fmt.Errorf("invalid location %q", w.Location)
+		}
+	}
+	return window, nil
+}
+
+// parseWeekday parses s as English weekday name, like
+// "Monday", or its three letter abbreviation, like "Mon".
+// The case of s is ignored.
+func parseWeekday(s string) (time.Weekday, bool) {
+	for day := time.Sunday; day <= time.Saturday; day++ {
+		if name := day.String(); strings.EqualFold(s, name) || strings.EqualFold(s, name[:3]) {
+			return day, true
+		}
+	}
+	return 0, false
+}
+
+// parseTimeOfDay parses s as time of day in the format
+// "HH:MM" and returns the minutes since midnight. Since
+// a time window may close at midnight, it also accepts
+// "24:00".
+func parseTimeOfDay(s string) (int, error) {
+	if s == "24:00" {
+		return 24 * 60, nil
+	}
+	t, err := time.Parse("15:04", s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid time of day %q", s)
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+// locations caches the time zones loaded by loadLocation.
+var locations sync.Map // map[string]*time.Location
+
+// loadLocation returns the time zone with the given name.
+// In contrast to time.LoadLocation, it only reads the time
+// zone database once per time zone.
+func loadLocation(name string) (*time.Location, error) {
+	if loc, ok := locations.Load(name); ok {
+		return loc.(*time.Location), nil
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, err
+	}
+	locations.Store(name, loc)
+	return loc, nil
+}
@@ -0,0 +1,72 @@
+// Copyright 2022 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package kes
+
+import (
+	"testing"
+	"time"
+)
+
+var (
+	friday   = time.Date(2022, time.June, 3, 0, 0, 0, 0, time.UTC)
+	saturday = time.Date(2022, time.June, 4, 0, 0, 0, 0, time.UTC)
+)
+
+var timeWindowContainsTests = []struct {
+	Window   TimeWindow
+	Time     time.Time
+	Contains bool
+}{
+	{Window: TimeWindow{}, Time: friday, Contains: true},                                                                           // 0
+	{Window: TimeWindow{Days: []string{"Mon", "Tue", "Wed", "Thu", "Fri"}}, Time: friday, Contains: true},                          // 1
+	{Window: TimeWindow{Days: []string{"Mon", "Tue", "Wed", "Thu", "Fri"}}, Time: saturday},                                        // 2
+	{Window: TimeWindow{Days: []string{"friday"}}, Time: friday.Add(23 * time.Hour), Contains: true},                               // 3
+	{Window: TimeWindow{Start: "08:00", End: "18:00"}, Time: friday.Add(8 * time.Hour), Contains: true},                            // 4
+	{Window: TimeWindow{Start: "08:00", End: "18:00"}, Time: friday.Add(18 * time.Hour)},                                           // 5
+	{Window: TimeWindow{Start: "08:00", End: "18:00"}, Time: friday.Add(7 * time.Hour)},                                            // 6
+	{Window: TimeWindow{Days: []string{"Fri"}, Start: "22:00", End: "06:00"}, Time: saturday.Add(5 * time.Hour), Contains: true},   // 7
+	{Window: TimeWindow{Days: []string{"Fri"}, Start: "22:00", End: "06:00"}, Time: friday.Add(5 * time.Hour)},                     // 8
+	{Window: TimeWindow{Days: []string{"Fri"}, Start: "22:00", End: "06:00"}, Time: saturday.Add(22 * time.Hour)},                  // 9
+	{Window: TimeWindow{Start: "08:00", End: "18:00", Location: "Europe/Berlin"}, Time: friday.Add(17 * time.Hour)},                // 10
+	{Window: TimeWindow{Start: "08:00", End: "18:00", Location: "Europe/Berlin"}, Time: friday.Add(6 * time.Hour), Contains: true}, // 11
+	{Window: TimeWindow{Start: "08:00", End: "08:00"}, Time: friday.Add(8 * time.Hour)},                                            // 12
+	{Window: TimeWindow{Days: []string{"Caturday"}}, Time: saturday},                                                               // 13
+}
+
+func TestTimeWindowContains(t *testing.T) {
+	for i, test := range timeWindowContainsTests {
+		if contains := test.Window.Contains(test.Time); contains != test.Contains {
+			t.Fatalf("Test %d: got %v - want %v", i, contains, test.Contains)
+		}
+	}
+}
+
+var validateTimeWindowTests = []struct {
+	Window     TimeWindow
+	ShouldFail bool
+}{
+	{Window: TimeWindow{}},                                                     // 0
+	{Window: TimeWindow{Days: []string{"Sat", "Sunday"}}},                      // 1
+	{Window: TimeWindow{Start: "08:00", End: "24:00"}},                         // 2
+	{Window: TimeWindow{Start: "22:00", End: "06:00", Location: "Asia/Tokyo"}}, // 3
+
+	{Window: TimeWindow{Days: []string{"Sa"}}, ShouldFail: true},          // 4
+	{Window: TimeWindow{Start: "8am"}, ShouldFail: true},                  // 5
+	{Window: TimeWindow{End: "25:00"}, ShouldFail: true},                  // 6
+	{Window: TimeWindow{Start: "24:00"}, ShouldFail: true},                // 7
+	{Window: TimeWindow{Location: "Mars/Olympus_Mons"}, ShouldFail: true}, // 8
+}
+
+func TestValidateTimeWindow(t *testing.T) {
+	for i, test := range validateTimeWindowTests {
+		err := ValidatePolicy(&Policy{AllowWindow: &test.Window})
+		if err != nil && !test.ShouldFail {
+			t.Fatalf("Test %d: failed to validate time window: %v", i, err)
+		}
+		if err == nil && test.ShouldFail {
+			t.Fatalf("Test %d: validation should have failed", i)
+		}
+	}
+}
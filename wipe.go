@@ -0,0 +1,59 @@
+// Copyright 2022 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package kes
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"runtime"
+)
+
+// Wipe overwrites b with zeros. It should be used to remove
+// plaintext key material, e.g. the plaintext of a DEK or a
+// decrypted key, from memory once it is no longer needed.
+//
+// Wipe only clears the given slice. The Go runtime may have
+// copied the key material before - e.g. when growing a slice
+// or when the garbage collector moves values. Such copies
+// cannot be wiped. Hence, Wipe reduces the time plaintext
+// key material stays in memory but cannot guarantee that no
+// copies remain.
+//
+// The client wipes its internal buffers containing plaintext
+// key material - e.g. the raw server response of GenerateKey
+// or Decrypt. Wiping the returned plaintexts is up to the
+// application.
+func Wipe(b []byte) {
+	for i := range b {
+		b[i] = 0
+	}
+	runtime.KeepAlive(b)
+}
+
+// decodeSecret decodes the JSON response body into v. It
+// reads at most maxSize bytes and wipes its internal buffer
+// once v has been decoded. It should be used to decode
+// responses that contain plaintext key material.
+func decodeSecret(resp *http.Response, maxSize int64, v interface{}) error {
+	var (
+		buf []byte
+		err error
+	)
+	if size := resp.ContentLength; size >= 0 && size <= maxSize {
+		// Allocate the buffer up front such that no
+		// copies are left behind when growing it.
+		buf = make([]byte, size)
+		_, err = io.ReadFull(resp.Body, buf)
+	} else {
+		buf, err = io.ReadAll(io.LimitReader(resp.Body, maxSize))
+	}
+	defer Wipe(buf)
+
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(buf, v)
+}
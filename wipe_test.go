@@ -0,0 +1,60 @@
+// Copyright 2022 - MinIO, Inc. All rights reserved.
+// Use of this source code is governed by the AGPLv3
+// license that can be found in the LICENSE file.
+
+package kes
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestWipe(t *testing.T) {
+	b := []byte("my-secret-plaintext")
+	Wipe(b)
+	if !bytes.Equal(b, make([]byte, len(b))) {
+		t.Fatal("Slice has not been overwritten with zeros")
+	}
+	Wipe(nil) // Must not panic
+}
+
+var decodeSecretTests = []struct {
+	Body          string
+	ContentLength int64
+	Plaintext     []byte
+	ShouldFail    bool
+}{
+	{Body: `{"plaintext":"AAECAw=="}`, ContentLength: 24, Plaintext: []byte{0, 1, 2, 3}},                                    // 0
+	{Body: `{"plaintext":"AAECAw=="}` + "\n", ContentLength: -1, Plaintext: []byte{0, 1, 2, 3}},                             // 1
+	{Body: `{"plaintext":"AAECAw=="}`, ContentLength: 1 << 20, Plaintext: []byte{0, 1, 2, 3}},                               // 2
+	{Body: `{"plaintext":"AAECAw=="}`, ContentLength: 30, ShouldFail: true},                                                 // 3
+	{Body: `{"plaintext":"AAECAw=="}`, ContentLength: 10, ShouldFail: true},                                                 // 4
+	{Body: `{"plaintext":"AAECAw==", "padding":"` + strings.Repeat("a", 1<<10) + `"}`, ContentLength: -1, ShouldFail: true}, // 5
+}
+
+func TestDecodeSecret(t *testing.T) {
+	const MaxSize = 1 << 10
+	type Response struct {
+		Plaintext []byte `json:"plaintext"`
+	}
+	for i, test := range decodeSecretTests {
+		resp := &http.Response{
+			Body:          io.NopCloser(strings.NewReader(test.Body)),
+			ContentLength: test.ContentLength,
+		}
+		var response Response
+		err := decodeSecret(resp, MaxSize, &response)
+		if err == nil && test.ShouldFail {
+			t.Fatalf("Test %d: should fail but succeeded", i)
+		}
+		if err != nil && !test.ShouldFail {
+			t.Fatalf("Test %d: failed to decode response: %v", i, err)
+		}
+		if err == nil && !bytes.Equal(response.Plaintext, test.Plaintext) {
+			t.Fatalf("Test %d: got plaintext '%x' - want '%x'", i, response.Plaintext, test.Plaintext)
+		}
+	}
+}